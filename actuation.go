@@ -0,0 +1,129 @@
+package xlpp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUndeclaredActuator is returned for actuation commands that do not match the declared actuators,
+// see ParseDownlink and WithActuators.
+var ErrUndeclaredActuator = errors.New("xlpp: undeclared actuator")
+
+var errActuationValue = errors.New("xlpp: actuation commands require a value that is not an Object, Array or marker")
+
+// An Actuation is a command of a downlink for the actuator on a channel.
+type Actuation struct {
+	Channel int
+	// Value is the command, e.g. a *Switch.
+	Value Value
+	// Delay is the delay of a ScheduledCommand, or 0 for commands that are executed on reception.
+	Delay time.Duration
+}
+
+// actuators is a set of declared actuators.
+type actuators struct {
+	declared bool
+	types    map[Type]bool
+	channels map[Actuator]bool
+}
+
+// newActuators returns the set of the actuators declared by the Actuators and ActuatorsWithChannel markers.
+// Other values are ignored.
+func newActuators(declared []Value) actuators {
+	a := actuators{types: make(map[Type]bool), channels: make(map[Actuator]bool)}
+	for _, v := range declared {
+		switch v := v.(type) {
+		case *Actuators:
+			a.declared = true
+			for _, t := range *v {
+				a.types[actuatorType(t)] = true
+			}
+		case *ActuatorsWithChannel:
+			a.declared = true
+			for _, act := range *v {
+				a.channels[Actuator{Channel: act.Channel, Type: actuatorType(act.Type)}] = true
+			}
+		}
+	}
+	return a
+}
+
+// actuatorType returns TypeBool for all Bool types, so that an actuator of either type accepts true and false.
+func actuatorType(t Type) Type {
+	switch t {
+	case TypeBoolTrue, TypeBoolFalse:
+		return TypeBool
+	}
+	return t
+}
+
+// check checks the command v for the actuator on the channel.
+// Actuators declare actuators on any channel, ActuatorsWithChannel on the given channel only.
+// Commands of ScheduledCommands are checked instead of the ScheduledCommand.
+func (a actuators) check(channel int, v Value) error {
+	if c, ok := v.(*ScheduledCommand); ok {
+		v = c.Value
+	}
+	if v == nil {
+		return errActuationValue
+	}
+	if _, ok := v.(Marker); ok || !commandType(v.XLPPType()) {
+		return fmt.Errorf("%w: %s on channel %d", errActuationValue, NameOf(v), channel)
+	}
+	t := v.XLPPType()
+	if !a.declared {
+		return nil
+	}
+	t = actuatorType(t)
+	if a.types[t] || a.channels[Actuator{Channel: channel, Type: t}] {
+		return nil
+	}
+	return fmt.Errorf("%w: %s on channel %d", ErrUndeclaredActuator, t.Name(), channel)
+}
+
+// WithActuators makes AddActuation check the commands against the actuators declared by the Actuators and
+// ActuatorsWithChannel markers, usually from an uplink of the device. Other values are ignored.
+func WithActuators(declared ...Value) WriterOption {
+	return func(w *Writer) {
+		w.actuators = newActuators(declared)
+	}
+}
+
+// AddActuation writes a command for the actuator on the channel, e.g. a *Switch or a *ScheduledCommand.
+// Objects, Arrays and markers are not commands. With WithActuators, the command must match the declared actuators.
+func (w *Writer) AddActuation(channel int, v Value) (n int, err error) {
+	if err = w.actuators.check(channel, v); err != nil {
+		return
+	}
+	return w.Add(channel, v)
+}
+
+// ParseDownlink decodes the commands of a downlink payload, and checks them against the actuators declared by
+// the Actuators and ActuatorsWithChannel markers in declared, usually from an uplink of the device.
+// Without declared actuators, all commands are accepted.
+// ScheduledCommands are returned as Actuation with their Delay. ActuatorAck and Priority markers are skipped,
+// all other markers, Objects and Arrays are not commands.
+func ParseDownlink(data []byte, declared ...Value) ([]Actuation, error) {
+	a := newActuators(declared)
+	m, err := NewBytesReader(data).ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var commands []Actuation
+	for _, e := range m {
+		switch e.Value.(type) {
+		case *ActuatorAck, *Priority:
+			continue
+		}
+		if err := a.check(e.Channel, e.Value); err != nil {
+			return nil, err
+		}
+		act := Actuation{Channel: e.Channel, Value: e.Value}
+		if c, ok := e.Value.(*ScheduledCommand); ok {
+			act.Value, act.Delay = c.Value, c.Delay
+		}
+		commands = append(commands, act)
+	}
+	return commands, nil
+}
@@ -0,0 +1,145 @@
+// Package airtime computes the LoRaWAN time on air of payloads, and estimates the daily airtime of a device,
+// to check that an XLPP encoding fits the regulatory limits of a region before deployment.
+//
+// The time on air follows the formula of the Semtech LoRa modem designer's guide (AN1200.13),
+// for uplinks with explicit header, CRC and coding rate 4/5.
+package airtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Overhead is the LoRaWAN overhead of an uplink without FOpts: MHDR, FHDR, FPort and MIC.
+const Overhead = 13
+
+// FairUse is the daily airtime per device of the fair use policy of The Things Network.
+const FairUse = 30 * time.Second
+
+// ErrDutyCycle is returned by Estimate.Check if the airtime exceeds the duty cycle of the region.
+var ErrDutyCycle = errors.New("airtime: duty cycle exceeded")
+
+// ErrDwellTime is returned by Estimate.Check if an uplink exceeds the max. dwell time of the region.
+var ErrDwellTime = errors.New("airtime: dwell time exceeded")
+
+// A Region holds the parameters of a LoRaWAN region that limit the airtime of uplinks.
+type Region struct {
+	Name string
+	// Bandwidth is the bandwidth of the uplink channels in Hz.
+	Bandwidth int
+	// DutyCycle is the max. fraction of time on air, e.g. 0.01, or zero if the region has no duty cycle.
+	DutyCycle float64
+	// DwellTime is the max. time on air of a single uplink, or zero if the region has no dwell time limit.
+	DwellTime time.Duration
+	// MinSF and MaxSF are the spreading factors of the uplink data rates.
+	MinSF, MaxSF int
+}
+
+// Regions are the parameters of some LoRaWAN regions (see the LoRaWAN Regional Parameters), with default settings.
+var Regions = map[string]Region{
+	"EU868": {Name: "EU868", Bandwidth: 125000, DutyCycle: 0.01, MinSF: 7, MaxSF: 12},
+	"IN865": {Name: "IN865", Bandwidth: 125000, MinSF: 7, MaxSF: 12},
+	"KR920": {Name: "KR920", Bandwidth: 125000, MinSF: 7, MaxSF: 12},
+	"AS923": {Name: "AS923", Bandwidth: 125000, DwellTime: 400 * time.Millisecond, MinSF: 7, MaxSF: 12},
+	"US915": {Name: "US915", Bandwidth: 125000, DwellTime: 400 * time.Millisecond, MinSF: 7, MaxSF: 10},
+	"AU915": {Name: "AU915", Bandwidth: 125000, MinSF: 7, MaxSF: 12},
+}
+
+// TimeOnAir returns the time on air of an uplink with an application payload of size bytes,
+// with the spreading factor sf and the bandwidth in Hz.
+func TimeOnAir(size, sf, bandwidth int) time.Duration {
+	symbol := math.Pow(2, float64(sf)) / float64(bandwidth)
+	preamble := (8 + 4.25) * symbol
+	de := 0.0
+	if symbol > 0.016 {
+		// low data rate optimization, e.g. SF11 and SF12 at 125 kHz
+		de = 1
+	}
+	pl := float64(size + Overhead)
+	n := math.Ceil((8*pl-4*float64(sf)+28+16)/(4*(float64(sf)-2*de))) * 5
+	symbols := 8 + math.Max(n, 0)
+	return time.Duration(math.Round((preamble + symbols*symbol) * 1e9))
+}
+
+// TimeOnAir returns the time on air of an uplink in the region with an application payload of size bytes.
+func (r Region) TimeOnAir(size, sf int) time.Duration {
+	return TimeOnAir(size, sf, r.Bandwidth)
+}
+
+// An Uplink is an uplink of a device, with the time it has been sent and the size of the application payload.
+type Uplink struct {
+	Time time.Time
+	Size int
+}
+
+// An Estimate is the airtime of a series of uplinks, extrapolated to one day.
+type Estimate struct {
+	Region Region
+	SF     int
+	// Uplinks is the number of uplinks, and Airtime their time on air.
+	Uplinks int
+	Airtime time.Duration
+	// Max is the time on air of the longest uplink.
+	Max time.Duration
+	// Period is the time the uplinks have been sent in. The last uplink is counted as one average interval.
+	Period time.Duration
+	// Daily is the extrapolated time on air per day.
+	Daily time.Duration
+}
+
+// Estimate estimates the daily airtime of the uplinks sent with the spreading factor sf.
+// The uplinks are assumed to be representative: the airtime is extrapolated from the period they have been sent in.
+// A single uplink is assumed to be sent once per day.
+func (r Region) Estimate(sf int, uplinks []Uplink) Estimate {
+	e := Estimate{Region: r, SF: sf, Uplinks: len(uplinks), Period: 24 * time.Hour}
+	if len(uplinks) == 0 {
+		return e
+	}
+	times := make([]time.Time, len(uplinks))
+	for i, u := range uplinks {
+		t := r.TimeOnAir(u.Size, sf)
+		e.Airtime += t
+		if t > e.Max {
+			e.Max = t
+		}
+		times[i] = u.Time
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	if span := times[len(times)-1].Sub(times[0]); len(times) > 1 && span > 0 {
+		e.Period = span + span/time.Duration(len(times)-1)
+	}
+	e.Daily = time.Duration(float64(e.Airtime) * float64(24*time.Hour) / float64(e.Period))
+	return e
+}
+
+// DutyCycle returns the fraction of time on air.
+func (e Estimate) DutyCycle() float64 {
+	return float64(e.Daily) / float64(24*time.Hour)
+}
+
+// Check checks the estimate against the duty cycle and dwell time of the region.
+func (e Estimate) Check() error {
+	if e.Region.DwellTime != 0 && e.Max > e.Region.DwellTime {
+		return fmt.Errorf("%w: %v per uplink at SF%d, %s allows %v", ErrDwellTime, e.Max, e.SF, e.Region.Name, e.Region.DwellTime)
+	}
+	if e.Region.DutyCycle != 0 && e.DutyCycle() > e.Region.DutyCycle {
+		return fmt.Errorf("%w: %.2f%% at SF%d, %s allows %.2f%%", ErrDutyCycle, 100*e.DutyCycle(), e.SF, e.Region.Name, 100*e.Region.DutyCycle)
+	}
+	return nil
+}
+
+// Budget returns the number of uplinks per day with an application payload of size bytes,
+// that fit into the duty cycle of the region, or into the daily airtime if it is not zero (e.g. FairUse).
+// It returns -1 if the region has no duty cycle and daily is zero.
+func (r Region) Budget(size, sf int, daily time.Duration) int {
+	if daily == 0 {
+		if r.DutyCycle == 0 {
+			return -1
+		}
+		daily = time.Duration(r.DutyCycle * float64(24*time.Hour))
+	}
+	return int(daily / r.TimeOnAir(size, sf))
+}
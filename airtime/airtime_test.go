@@ -0,0 +1,55 @@
+package airtime_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp/airtime"
+)
+
+func TestTimeOnAir(t *testing.T) {
+	tests := []struct {
+		size, sf int
+		want     time.Duration
+	}{
+		{0, 7, 46336 * time.Microsecond},
+		{4, 7, 51456 * time.Microsecond},
+		{51, 7, 118016 * time.Microsecond},
+		{0, 12, 1155072 * time.Microsecond},
+	}
+	for _, test := range tests {
+		if got := airtime.TimeOnAir(test.size, test.sf, 125000); got != test.want {
+			t.Errorf("%d bytes at SF%d: %v, want %v", test.size, test.sf, got, test.want)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	eu := airtime.Regions["EU868"]
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	var uplinks []airtime.Uplink
+	for i := 0; i < 4; i++ {
+		uplinks = append(uplinks, airtime.Uplink{Time: start.Add(time.Duration(i) * 15 * time.Minute), Size: 4})
+	}
+	e := eu.Estimate(7, uplinks)
+	if e.Period != time.Hour || e.Daily != 96*51456*time.Microsecond {
+		t.Fatalf("period %v, daily %v", e.Period, e.Daily)
+	}
+	if err := e.Check(); err != nil {
+		t.Fatal(err)
+	}
+	// 4 bytes every minute at SF12 need more than 1%
+	for i := range uplinks {
+		uplinks[i].Time = start.Add(time.Duration(i) * time.Minute)
+	}
+	if err := eu.Estimate(12, uplinks).Check(); !errors.Is(err, airtime.ErrDutyCycle) {
+		t.Fatalf("SF12: %v, want ErrDutyCycle", err)
+	}
+	if err := airtime.Regions["US915"].Estimate(10, []airtime.Uplink{{Size: 200}}).Check(); !errors.Is(err, airtime.ErrDwellTime) {
+		t.Fatalf("US915: %v, want ErrDwellTime", err)
+	}
+	if n := eu.Budget(4, 7, airtime.FairUse); n != 583 {
+		t.Fatalf("fair use budget: %d, want 583", n)
+	}
+}
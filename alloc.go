@@ -0,0 +1,113 @@
+package xlpp
+
+import (
+	"io"
+	"unsafe"
+)
+
+// An Allocator provides the memory for decoded Objects, Arrays, Strings and Binary values.
+// Use WithAllocator to make a Reader use an Allocator.
+type Allocator interface {
+	// Bytes returns a byte slice of length n.
+	Bytes(n int) []byte
+	// String returns a string with the content of b.
+	// b is only valid during the call.
+	String(b []byte) string
+	// Values returns an empty Value slice with capacity n.
+	Values(n int) []Value
+	// Object returns an empty Object.
+	Object() Object
+}
+
+// WithAllocator makes the Reader take the memory for Objects, Arrays, Strings and Binary values from the allocator.
+func WithAllocator(a Allocator) ReaderOption {
+	return func(r *Reader) {
+		r.alloc = a
+	}
+}
+
+// allocator returns the Allocator of the Reader if r is the decoder of a Reader.
+func allocator(r io.Reader) Allocator {
+	if opts := options(r); opts != nil {
+		return opts.alloc
+	}
+	return nil
+}
+
+const arenaChunkSize = 4096
+
+// An Arena is an Allocator that hands out memory from large chunks.
+// All memory of an Arena is freed at once with Reset, so that decoding many messages does not put pressure on the garbage collector.
+// Strings returned by the Arena share the Arena memory: all values decoded with the Arena must not be used after calling Reset.
+// The zero value is an empty Arena ready to use. An Arena must not be used concurrently.
+type Arena struct {
+	bytes   []byte
+	values  []Value
+	objects []Object
+	used    int // used objects
+}
+
+// Bytes returns a byte slice of length n from the Arena.
+func (a *Arena) Bytes(n int) []byte {
+	l := len(a.bytes)
+	if n > cap(a.bytes)-l {
+		size := arenaChunkSize
+		if n > size {
+			size = n
+		}
+		a.bytes = make([]byte, 0, size)
+		l = 0
+	}
+	a.bytes = a.bytes[:l+n]
+	return a.bytes[l : l+n : l+n]
+}
+
+// String returns a string with the content of b, backed by the Arena memory.
+func (a *Arena) String(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	s := a.Bytes(len(b))
+	copy(s, b)
+	return *(*string)(unsafe.Pointer(&s))
+}
+
+// Values returns an empty Value slice with capacity n from the Arena.
+func (a *Arena) Values(n int) []Value {
+	l := len(a.values)
+	if n > cap(a.values)-l {
+		size := arenaChunkSize / 16
+		if n > size {
+			size = n
+		}
+		a.values = make([]Value, 0, size)
+		l = 0
+	}
+	a.values = a.values[:l+n]
+	return a.values[l : l : l+n]
+}
+
+// Object returns an empty Object from the Arena.
+func (a *Arena) Object() Object {
+	if a.used == len(a.objects) {
+		a.objects = append(a.objects, make(Object))
+	}
+	o := a.objects[a.used]
+	a.used++
+	return o
+}
+
+// Reset frees all memory handed out by the Arena, so that it can be reused.
+func (a *Arena) Reset() {
+	a.bytes = a.bytes[:0]
+	for i := range a.values {
+		a.values[i] = nil
+	}
+	a.values = a.values[:0]
+	for _, o := range a.objects[:a.used] {
+		for key := range o {
+			delete(o, key)
+		}
+	}
+	a.used = 0
+}
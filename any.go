@@ -0,0 +1,71 @@
+package xlpp
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// DecodeToAny decodes the payload into a tree of built-in Go types, for callers that want to json.Marshal
+// or template the values without using the XLPP types.
+// The keys are the type name and channel of the entries, e.g. "temperature5", as in the JSON format of the xlpp command.
+// If the payload has multiple entries with the same type and channel, the last entry wins.
+//
+// Numbers are float64, Strings string, Bools bool, UnixTimes time.Time, Nulls nil, Objects map[string]interface{}
+// and Arrays []interface{}. All other values (e.g. GPS or Binary) are converted like their JSON representation.
+func DecodeToAny(data []byte) (map[string]interface{}, error) {
+	m, err := NewBytesReader(data).ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]interface{}, len(m))
+	for _, e := range m {
+		v, err := toAny(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		tree[NameOf(e.Value)+strconv.Itoa(e.Channel)] = v
+	}
+	return tree, nil
+}
+
+// toAny converts the value to built-in Go types.
+func toAny(v Value) (interface{}, error) {
+	switch v := v.(type) {
+	case *Object:
+		m := make(map[string]interface{}, len(*v))
+		for key, item := range *v {
+			i, err := toAny(item)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = i
+		}
+		return m, nil
+	case *Array:
+		a := make([]interface{}, len(*v))
+		for j, item := range *v {
+			i, err := toAny(item)
+			if err != nil {
+				return nil, err
+			}
+			a[j] = i
+		}
+		return a, nil
+	case *UnixTime:
+		return time.Time(*v), nil
+	case *Null:
+		return nil, nil
+	case *Bool:
+		return bool(*v), nil
+	case *String:
+		return string(*v), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var i interface{}
+	err = json.Unmarshal(data, &i)
+	return i, err
+}
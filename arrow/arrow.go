@@ -0,0 +1,192 @@
+// Package arrow converts batches of decoded XLPP messages to Apache Arrow record batches,
+// for loading sensor history into analytics engines.
+//
+// A Batch has one row per device and measurement time, and one column per channel and type,
+// named like the keys of the JSON format, e.g. "temperature5". Delay markers are resolved into timestamps
+// (see recorder.Resolve). Batch.WriteTo writes the batch in the Arrow IPC stream format,
+// which can be read by all Arrow implementations (e.g. pyarrow.ipc.open_stream) and converted to Parquet files there.
+package arrow
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/recorder"
+)
+
+// A Record is a message with the time it has been received, and the device it has been received from.
+type Record struct {
+	Device   string
+	Received time.Time
+	Message  xlpp.Message
+}
+
+// A DataType is the Arrow data type of a Column.
+type DataType int
+
+const (
+	// Timestamp columns hold times with nanosecond precision in UTC.
+	Timestamp DataType = iota
+	// Float64 columns hold all numeric values, except Integers.
+	Float64
+	// Int64 columns hold Integers.
+	Int64
+	// Bool columns hold Bools.
+	Bool
+	// Utf8 columns hold Strings, and the JSON representation of all other values (e.g. GPS or Objects).
+	Utf8
+	// Binary columns hold Binary values.
+	Binary
+)
+
+var dataTypeNames = [...]string{"timestamp", "float64", "int64", "bool", "utf8", "binary"}
+
+func (t DataType) String() string {
+	if int(t) < len(dataTypeNames) {
+		return dataTypeNames[t]
+	}
+	return "unknown"
+}
+
+// A Column is a named column of a Batch.
+// Values holds one value per row: time.Time, float64, int64, bool, string or []byte, or nil if the value is missing.
+type Column struct {
+	Name   string
+	Type   DataType
+	Values []interface{}
+}
+
+// A Batch is a table of values. The first columns are "time", the measurement time, and "device".
+type Batch struct {
+	Columns []*Column
+}
+
+// Len returns the number of rows of the batch.
+func (b *Batch) Len() int {
+	if len(b.Columns) == 0 {
+		return 0
+	}
+	return len(b.Columns[0].Values)
+}
+
+// Column returns the column with the given name, or nil.
+func (b *Batch) Column(name string) *Column {
+	for _, c := range b.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// columnKey identifies a column by channel and type name.
+type columnKey struct {
+	channel int
+	name    string
+}
+
+// rowKey identifies a row by device and measurement time.
+type rowKey struct {
+	device string
+	time   int64
+}
+
+// NewBatch converts the records to a batch. Rows are sorted by time and device, and value columns by channel and name.
+// If a device has multiple values with the same channel and type at the same time, the last value wins.
+// Nulls and markers are left out.
+func NewBatch(records []Record) *Batch {
+	rows := make(map[rowKey]map[columnKey]interface{})
+	columns := make(map[columnKey]DataType)
+	for _, rec := range records {
+		for _, e := range recorder.Resolve(rec.Received, rec.Message) {
+			v, t, ok := cell(e.Value)
+			if !ok {
+				continue
+			}
+			key := columnKey{e.Channel, xlpp.NameOf(e.Value)}
+			columns[key] = t
+			row := rowKey{rec.Device, e.Time.UnixNano()}
+			if rows[row] == nil {
+				rows[row] = make(map[columnKey]interface{})
+			}
+			rows[row][key] = v
+		}
+	}
+
+	rowKeys := make([]rowKey, 0, len(rows))
+	for row := range rows {
+		rowKeys = append(rowKeys, row)
+	}
+	sort.Slice(rowKeys, func(i, j int) bool {
+		if rowKeys[i].time != rowKeys[j].time {
+			return rowKeys[i].time < rowKeys[j].time
+		}
+		return rowKeys[i].device < rowKeys[j].device
+	})
+	columnKeys := make([]columnKey, 0, len(columns))
+	for key := range columns {
+		columnKeys = append(columnKeys, key)
+	}
+	sort.Slice(columnKeys, func(i, j int) bool {
+		if columnKeys[i].channel != columnKeys[j].channel {
+			return columnKeys[i].channel < columnKeys[j].channel
+		}
+		return columnKeys[i].name < columnKeys[j].name
+	})
+
+	b := &Batch{Columns: make([]*Column, 0, 2+len(columnKeys))}
+	timeColumn := &Column{Name: "time", Type: Timestamp, Values: make([]interface{}, len(rowKeys))}
+	deviceColumn := &Column{Name: "device", Type: Utf8, Values: make([]interface{}, len(rowKeys))}
+	for i, row := range rowKeys {
+		timeColumn.Values[i] = time.Unix(0, row.time).UTC()
+		deviceColumn.Values[i] = row.device
+	}
+	b.Columns = append(b.Columns, timeColumn, deviceColumn)
+	for _, key := range columnKeys {
+		c := &Column{Name: key.name + strconv.Itoa(key.channel), Type: columns[key], Values: make([]interface{}, len(rowKeys))}
+		for i, row := range rowKeys {
+			c.Values[i] = rows[row][key]
+		}
+		b.Columns = append(b.Columns, c)
+	}
+	return b
+}
+
+// cell converts the value to the value of a column cell.
+func cell(v xlpp.Value) (interface{}, DataType, bool) {
+	switch v := v.(type) {
+	case *xlpp.Null, xlpp.Marker:
+		return nil, 0, false
+	case *xlpp.Integer:
+		return int64(*v), Int64, true
+	case *xlpp.UnixTime:
+		return time.Time(*v).UTC(), Timestamp, true
+	case *xlpp.String:
+		return string(*v), Utf8, true
+	case *xlpp.Binary:
+		return []byte(*v), Binary, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), Float64, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), Float64, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), Float64, true
+	case reflect.Bool:
+		return rv.Bool(), Bool, true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, 0, false
+	}
+	return string(data), Utf8, true
+}
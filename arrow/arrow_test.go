@@ -0,0 +1,96 @@
+package arrow_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/arrow"
+)
+
+func TestNewBatch(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	temp := xlpp.Temperature(21.5)
+	count := xlpp.Integer(-7)
+	open := xlpp.Bool(true)
+	delay := xlpp.Delay(time.Minute)
+	null := xlpp.Null{}
+	batch := arrow.NewBatch([]arrow.Record{
+		{Device: "b", Received: received, Message: xlpp.Message{{Channel: 1, Value: &temp}}},
+		{Device: "a", Received: received, Message: xlpp.Message{
+			{Channel: 1, Value: &temp},
+			{Channel: 2, Value: &count},
+			{Channel: xlpp.ChanDelay, Value: &delay},
+			{Channel: 3, Value: &open},
+			{Channel: 4, Value: &null},
+		}},
+	})
+	if batch.Len() != 3 {
+		t.Fatalf("rows: %d, want 3", batch.Len())
+	}
+	var names []string
+	for _, c := range batch.Columns {
+		names = append(names, c.Name)
+	}
+	if want := []string{"time", "device", "temperature1", "integer2", "bool3"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("columns: %v, want %v", names, want)
+	}
+	tests := []struct {
+		name   string
+		typ    arrow.DataType
+		values []interface{}
+	}{
+		{"time", arrow.Timestamp, []interface{}{received.Add(-time.Minute), received, received}},
+		{"device", arrow.Utf8, []interface{}{"a", "a", "b"}},
+		{"temperature1", arrow.Float64, []interface{}{nil, 21.5, 21.5}},
+		{"integer2", arrow.Int64, []interface{}{nil, int64(-7), nil}},
+		{"bool3", arrow.Bool, []interface{}{true, nil, nil}},
+	}
+	for _, test := range tests {
+		c := batch.Column(test.name)
+		if c.Type != test.typ || !reflect.DeepEqual(c.Values, test.values) {
+			t.Errorf("column %s: %v %v, want %v %v", test.name, c.Type, c.Values, test.typ, test.values)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	temp := xlpp.Temperature(21.5)
+	str := xlpp.String("hello")
+	batch := arrow.NewBatch([]arrow.Record{
+		{Device: "a", Received: time.Unix(1714564800, 0), Message: xlpp.Message{{Channel: 1, Value: &temp}, {Channel: 2, Value: &str}}},
+	})
+	var buf bytes.Buffer
+	n, err := batch.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(n) != buf.Len() {
+		t.Fatalf("n: %d, written %d", n, buf.Len())
+	}
+	data := buf.Bytes()
+	// schema and record batch messages, and the end of the stream
+	messages := 0
+	for len(data) > 8 {
+		if binary.LittleEndian.Uint32(data) != 0xffffffff {
+			t.Fatalf("message %d: missing continuation marker", messages)
+		}
+		size := binary.LittleEndian.Uint32(data[4:])
+		if size%8 != 0 {
+			t.Fatalf("message %d: metadata size %d is not a multiple of 8", messages, size)
+		}
+		// bodyLength is the last field of the Message table, see Message.fbs
+		metadata := data[8 : 8+size]
+		root := binary.LittleEndian.Uint32(metadata)
+		vtable := int(root) - int(int32(binary.LittleEndian.Uint32(metadata[root:])))
+		bodyLength := binary.LittleEndian.Uint64(metadata[int(root)+int(binary.LittleEndian.Uint16(metadata[vtable+10:])):])
+		data = data[8+int(size)+int(bodyLength):]
+		messages++
+	}
+	if messages != 2 || !bytes.Equal(data, []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}) {
+		t.Fatalf("%d messages, end of stream %x", messages, data)
+	}
+}
@@ -0,0 +1,324 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// The Arrow IPC stream format is a sequence of encapsulated messages: a continuation marker, the size of the
+// metadata, the metadata as a Flatbuffer (see Schema.fbs and Message.fbs of the Arrow format), and the body.
+
+const continuation = 0xffffffff
+
+// Message header types.
+const (
+	headerSchema      = 1
+	headerRecordBatch = 3
+)
+
+// Field type ids.
+const (
+	typeInt           = 2
+	typeFloatingPoint = 3
+	typeBinary        = 4
+	typeUtf8          = 5
+	typeBool          = 6
+	typeTimestamp     = 10
+)
+
+const (
+	metadataV5     = 4
+	precisionFloat = 2
+	unitNanosecond = 3
+)
+
+// WriteTo writes the batch in the Arrow IPC stream format: the schema, one record batch and the end of the stream.
+func (b *Batch) WriteTo(w io.Writer) (n int64, err error) {
+	var buf []byte
+	buf = appendMessage(buf, headerSchema, b.schema(), nil)
+	body, nodes, buffers := b.body()
+	buf = appendMessage(buf, headerRecordBatch, recordBatch(int64(b.Len()), nodes, buffers), body)
+	buf = appendUint32(buf, continuation)
+	buf = appendUint32(buf, 0)
+	m, err := w.Write(buf)
+	return int64(m), err
+}
+
+// appendMessage appends an encapsulated message.
+func appendMessage(buf []byte, headerType int, header *fbTable, body []byte) []byte {
+	var fb fbBuilder
+	fb.root(&fbTable{
+		{size: 2, value: metadataV5},
+		{size: 1, value: uint64(headerType)},
+		{ref: header.write},
+		{size: 8, value: uint64(len(body))},
+	})
+	// the body must start at a multiple of 8
+	fb.pad(8)
+	buf = appendUint32(buf, continuation)
+	buf = appendUint32(buf, uint32(len(fb.buf)))
+	buf = append(buf, fb.buf...)
+	return append(buf, body...)
+}
+
+func (b *Batch) schema() *fbTable {
+	fields := make([]*fbTable, len(b.Columns))
+	for i, c := range b.Columns {
+		fields[i] = c.field()
+	}
+	return &fbTable{
+		{size: 2, value: 0}, // little endian
+		{ref: fbTables(fields)},
+	}
+}
+
+// field returns the Field of the column.
+func (c *Column) field() *fbTable {
+	var typeID int
+	var typ *fbTable
+	switch c.Type {
+	case Timestamp:
+		typeID, typ = typeTimestamp, &fbTable{{size: 2, value: unitNanosecond}, {ref: fbString("UTC")}}
+	case Float64:
+		typeID, typ = typeFloatingPoint, &fbTable{{size: 2, value: precisionFloat}}
+	case Int64:
+		typeID, typ = typeInt, &fbTable{{size: 4, value: 64}, {size: 1, value: 1}}
+	case Bool:
+		typeID, typ = typeBool, &fbTable{}
+	case Utf8:
+		typeID, typ = typeUtf8, &fbTable{}
+	case Binary:
+		typeID, typ = typeBinary, &fbTable{}
+	}
+	return &fbTable{
+		{ref: fbString(c.Name)},
+		{size: 1, value: 1}, // nullable
+		{size: 1, value: uint64(typeID)},
+		{ref: typ.write},
+		{},                   // dictionary
+		{ref: fbTables(nil)}, // children
+	}
+}
+
+func recordBatch(length int64, nodes, buffers [][2]int64) *fbTable {
+	return &fbTable{
+		{size: 8, value: uint64(length)},
+		{ref: fbStructs(nodes)},
+		{ref: fbStructs(buffers)},
+	}
+}
+
+// body returns the body of the record batch, with the field nodes (length and null count of each column)
+// and the buffers (offset and length in the body).
+func (b *Batch) body() (body []byte, nodes, buffers [][2]int64) {
+	addBuffer := func(data []byte) {
+		buffers = append(buffers, [2]int64{int64(len(body)), int64(len(data))})
+		body = append(body, data...)
+		for len(body)%8 != 0 {
+			body = append(body, 0)
+		}
+	}
+	for _, c := range b.Columns {
+		n := len(c.Values)
+		validity := make([]byte, (n+7)/8)
+		nulls := 0
+		for i, v := range c.Values {
+			if v == nil {
+				nulls++
+			} else {
+				validity[i/8] |= 1 << (i % 8)
+			}
+		}
+		nodes = append(nodes, [2]int64{int64(n), int64(nulls)})
+		if nulls == 0 {
+			validity = nil
+		}
+		addBuffer(validity)
+
+		switch c.Type {
+		case Timestamp, Float64, Int64:
+			values := make([]byte, 8*n)
+			for i, v := range c.Values {
+				var u uint64
+				switch v := v.(type) {
+				case time.Time:
+					u = uint64(v.UnixNano())
+				case float64:
+					u = math.Float64bits(v)
+				case int64:
+					u = uint64(v)
+				}
+				binary.LittleEndian.PutUint64(values[8*i:], u)
+			}
+			addBuffer(values)
+		case Bool:
+			values := make([]byte, (n+7)/8)
+			for i, v := range c.Values {
+				if v, _ := v.(bool); v {
+					values[i/8] |= 1 << (i % 8)
+				}
+			}
+			addBuffer(values)
+		case Utf8, Binary:
+			offsets := make([]byte, 4*(n+1))
+			var data []byte
+			for i, v := range c.Values {
+				switch v := v.(type) {
+				case string:
+					data = append(data, v...)
+				case []byte:
+					data = append(data, v...)
+				}
+				binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+			}
+			addBuffer(offsets)
+			addBuffer(data)
+		}
+	}
+	return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// fbBuilder writes Flatbuffers front to back: each table is preceded by its vtable,
+// and objects referenced by a table are written after it, so that all offsets point forward.
+type fbBuilder struct {
+	buf []byte
+}
+
+// An fbTable is a Flatbuffer table, with one field per vtable slot.
+type fbTable []fbField
+
+// An fbField is a scalar of size bytes, or a reference to an object written by ref. Absent fields are zero.
+type fbField struct {
+	size  int
+	value uint64
+	ref   func(fb *fbBuilder) int
+}
+
+func (fb *fbBuilder) pad(align int) {
+	for len(fb.buf)%align != 0 {
+		fb.buf = append(fb.buf, 0)
+	}
+}
+
+func (fb *fbBuilder) root(t *fbTable) {
+	fb.buf = append(fb.buf, 0, 0, 0, 0)
+	fb.patch(0, t.write(fb))
+}
+
+// patch sets the offset at pos to the object at target.
+func (fb *fbBuilder) patch(pos, target int) {
+	binary.LittleEndian.PutUint32(fb.buf[pos:], uint32(target-pos))
+}
+
+// write writes the table with its vtable and the referenced objects, and returns the position of the table.
+func (t *fbTable) write(fb *fbBuilder) int {
+	fields := *t
+	// lay out the fields after the vtable offset, ordered by size for alignment
+	offsets := make([]int, len(fields))
+	size := 4
+	for _, s := range []int{8, 4, 2, 1} {
+		for i, f := range fields {
+			fs := f.size
+			if f.ref != nil {
+				fs = 4
+			}
+			if fs != s {
+				continue
+			}
+			for size%s != 0 {
+				size++
+			}
+			offsets[i] = size
+			size += s
+		}
+	}
+
+	fb.pad(2)
+	vtable := len(fb.buf)
+	fb.buf = appendUint16(fb.buf, uint16(4+2*len(fields)))
+	fb.buf = appendUint16(fb.buf, uint16(size))
+	for _, offset := range offsets {
+		fb.buf = appendUint16(fb.buf, uint16(offset))
+	}
+	fb.pad(8)
+	pos := len(fb.buf)
+	fb.buf = append(fb.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(fb.buf[pos:], uint32(pos-vtable))
+	for i, f := range fields {
+		switch f.size {
+		case 1:
+			fb.buf[pos+offsets[i]] = byte(f.value)
+		case 2:
+			binary.LittleEndian.PutUint16(fb.buf[pos+offsets[i]:], uint16(f.value))
+		case 4:
+			binary.LittleEndian.PutUint32(fb.buf[pos+offsets[i]:], uint32(f.value))
+		case 8:
+			binary.LittleEndian.PutUint64(fb.buf[pos+offsets[i]:], f.value)
+		}
+	}
+	for i, f := range fields {
+		if f.ref != nil {
+			fb.patch(pos+offsets[i], f.ref(fb))
+		}
+	}
+	return pos
+}
+
+// fbString returns a reference to a string.
+func fbString(s string) func(fb *fbBuilder) int {
+	return func(fb *fbBuilder) int {
+		fb.pad(4)
+		pos := len(fb.buf)
+		fb.buf = appendUint32(fb.buf, uint32(len(s)))
+		fb.buf = append(fb.buf, s...)
+		fb.buf = append(fb.buf, 0)
+		return pos
+	}
+}
+
+// fbTables returns a reference to a vector of tables.
+func fbTables(tables []*fbTable) func(fb *fbBuilder) int {
+	return func(fb *fbBuilder) int {
+		fb.pad(4)
+		pos := len(fb.buf)
+		fb.buf = appendUint32(fb.buf, uint32(len(tables)))
+		fb.buf = append(fb.buf, make([]byte, 4*len(tables))...)
+		for i, t := range tables {
+			fb.patch(pos+4+4*i, t.write(fb))
+		}
+		return pos
+	}
+}
+
+// fbStructs returns a reference to a vector of structs of two longs, e.g. FieldNode or Buffer.
+func fbStructs(structs [][2]int64) func(fb *fbBuilder) int {
+	return func(fb *fbBuilder) int {
+		// the structs must be aligned to 8 bytes
+		for len(fb.buf)%8 != 4 {
+			fb.buf = append(fb.buf, 0)
+		}
+		pos := len(fb.buf)
+		fb.buf = appendUint32(fb.buf, uint32(len(structs)))
+		for _, s := range structs {
+			fb.buf = appendUint64(fb.buf, uint64(s[0]))
+			fb.buf = appendUint64(fb.buf, uint64(s[1]))
+		}
+		return pos
+	}
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v)), uint32(v>>32))
+}
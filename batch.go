@@ -0,0 +1,38 @@
+package xlpp
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DecodeBatch decodes many independent payloads concurrently, using at most workers goroutines.
+// If workers is <= 0, runtime.GOMAXPROCS(0) workers are used.
+// The messages and errors are returned in the order of the payloads. The error is nil for payloads that have been decoded successfully.
+func DecodeBatch(payloads [][]byte, workers int) ([]Message, []error) {
+	messages := make([]Message, len(payloads))
+	errs := make([]error, len(payloads))
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(payloads) {
+		workers = len(payloads)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				messages[i], errs[i] = NewBytesReader(payloads[i]).ReadMessage()
+			}
+		}()
+	}
+	for i := range payloads {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return messages, errs
+}
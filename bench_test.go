@@ -0,0 +1,98 @@
+package xlpp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/waziup/xlpp"
+)
+
+func BenchmarkEncode(b *testing.B) {
+	for _, v := range values {
+		v := v
+		b.Run(xlpp.NameOf(v), func(b *testing.B) {
+			benchmarkEncode(b, []xlpp.Value{v})
+		})
+	}
+	b.Run("mixed", func(b *testing.B) {
+		benchmarkEncode(b, values)
+	})
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, v := range values {
+		v := v
+		b.Run(xlpp.NameOf(v), func(b *testing.B) {
+			benchmarkDecode(b, []xlpp.Value{v})
+		})
+	}
+	b.Run("mixed", func(b *testing.B) {
+		benchmarkDecode(b, values)
+	})
+}
+
+func benchmarkEncode(b *testing.B, values []xlpp.Value) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for i, v := range values {
+		w.Add(i, v)
+	}
+	b.SetBytes(int64(buf.Len()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		for i, v := range values {
+			w.Add(i, v)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, values []xlpp.Value) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for i, v := range values {
+		w.Add(i, v)
+	}
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := xlpp.NewReader(bytes.NewReader(data))
+		for {
+			_, v, err := r.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if v == nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeBytes(b *testing.B) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for i, v := range values {
+		w.Add(i, v)
+	}
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	r := xlpp.NewBytesReader(nil)
+	for i := 0; i < b.N; i++ {
+		r.ResetBytes(data)
+		for {
+			_, v, err := r.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if v == nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Package cbor converts XLPP messages to CBOR (RFC 8949) and back, for gateways that use CBOR internally.
+//
+// A message is a CBOR array of entries, and each entry an array [channel, type, value] with the XLPP type
+// of the value (255 for markers, that are identified by their channel):
+//
+//	[[5, 103, 23.5], [3, 136, {"Latitude": 52.5, "Longitude": 13.4, "Meters": 34}]]
+//
+// Values are mapped to their natural CBOR representation: Integers and other integral values to integers,
+// floating point values to floats, Bools and Switches to booleans, Strings to text strings, Binaries to byte strings,
+// Nulls to null and UnixTimes to epoch-based date/times (tag 1). Objects are maps and Arrays arrays of [type, value]
+// items, so nested values keep their types. All other values are mapped like their JSON representation,
+// e.g. GPS to a map and Colour to "#rrggbb".
+//
+// Marshal uses the core deterministic encoding of RFC 8949, section 4.2.1, so equal messages have byte-identical
+// encodings. Unmarshal also accepts indefinite lengths and non-preferred serializations.
+package cbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/waziup/xlpp"
+)
+
+// Marshal encodes the message as CBOR.
+func Marshal(m xlpp.Message) ([]byte, error) {
+	entries := make([]interface{}, len(m))
+	for i, e := range m {
+		v, err := toItem(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cbor: channel %d: %v", e.Channel, err)
+		}
+		entries[i] = []interface{}{uint64(e.Channel), uint64(e.Value.XLPPType()), v}
+	}
+	var enc encoder
+	if err := enc.encode(entries); err != nil {
+		return nil, err
+	}
+	return enc.buf.Bytes(), nil
+}
+
+// toItem converts the value to a CBOR data item.
+func toItem(v xlpp.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case *xlpp.Object:
+		m := make(map[string]interface{}, len(*v))
+		for key, item := range *v {
+			i, err := toItem(item)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = []interface{}{uint64(item.XLPPType()), i}
+		}
+		return m, nil
+	case *xlpp.Array:
+		a := make([]interface{}, len(*v))
+		for j, item := range *v {
+			i, err := toItem(item)
+			if err != nil {
+				return nil, err
+			}
+			a[j] = []interface{}{uint64(item.XLPPType()), i}
+		}
+		return a, nil
+	case *xlpp.Binary:
+		return []byte(*v), nil
+	case *xlpp.UnixTime:
+		return tagged{tagEpoch, int64(time.Time(*v).Unix())}, nil
+	case *xlpp.Null:
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var tree interface{}
+	if err := d.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return fromJSON(tree), nil
+}
+
+// fromJSON converts the JSON numbers of a decoded JSON tree to integers and floats.
+func fromJSON(tree interface{}) interface{} {
+	switch tree := tree.(type) {
+	case json.Number:
+		if i, err := tree.Int64(); err == nil {
+			return i
+		}
+		f, _ := tree.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range tree {
+			tree[key] = fromJSON(item)
+		}
+	case []interface{}:
+		for i, item := range tree {
+			tree[i] = fromJSON(item)
+		}
+	}
+	return tree
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Unmarshal decodes a message from CBOR. Types are looked up in the xlpp.Registry.
+func Unmarshal(data []byte) (xlpp.Message, error) {
+	d := decoder{data: data}
+	tree, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.off != len(data) {
+		return nil, fmt.Errorf("cbor: %d bytes of trailing data", len(data)-d.off)
+	}
+	entries, ok := tree.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cbor: message is not an array")
+	}
+	m := make(xlpp.Message, 0, len(entries))
+	for i, entry := range entries {
+		e, ok := entry.([]interface{})
+		if !ok || len(e) != 3 {
+			return nil, fmt.Errorf("cbor: entry %d is not a [channel, type, value] array", i)
+		}
+		channel, ok := e[0].(uint64)
+		if !ok || channel > 255 {
+			return nil, fmt.Errorf("cbor: entry %d: bad channel %v", i, e[0])
+		}
+		var v xlpp.Value
+		if t, ok := e[1].(uint64); ok && t == 255 {
+			if v = newMarker(int(channel)); v == nil {
+				return nil, fmt.Errorf("cbor: entry %d: channel %d is not a marker channel", i, channel)
+			}
+			err = fromItem(v, e[2])
+		} else {
+			v, err = fromTypedItem(e[1:])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cbor: entry %d: %v", i, err)
+		}
+		m = append(m, xlpp.Entry{Channel: int(channel), Value: v})
+	}
+	return m, nil
+}
+
+// newMarker returns a new Marker for the marker channel.
+func newMarker(channel int) xlpp.Value {
+	switch channel {
+	case xlpp.ChanDelay:
+		return new(xlpp.Delay)
+	case xlpp.ChanMilliDelay:
+		return new(xlpp.MilliDelay)
+	case xlpp.ChanPriority:
+		return new(xlpp.Priority)
+	case xlpp.ChanActuators:
+		return new(xlpp.Actuators)
+	case xlpp.ChanActuatorsWithChannel:
+		return new(xlpp.ActuatorsWithChannel)
+	case xlpp.ChanActuatorAck:
+		return new(xlpp.ActuatorAck)
+	}
+	return nil
+}
+
+// fromTypedItem converts a [type, value] pair to a new value of the type.
+func fromTypedItem(pair []interface{}) (xlpp.Value, error) {
+	t, ok := pair[0].(uint64)
+	if !ok || t > 255 {
+		return nil, fmt.Errorf("bad type %v", pair[0])
+	}
+	f := xlpp.LookupType(xlpp.Type(t))
+	if f == nil || t == uint64(xlpp.TypeEndOfArray) {
+		return nil, fmt.Errorf("unknown type %d", t)
+	}
+	v := f()
+	return v, fromItem(v, pair[1])
+}
+
+// fromItem sets the value from a CBOR data item.
+func fromItem(v xlpp.Value, item interface{}) error {
+	switch v := v.(type) {
+	case *xlpp.Object:
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("object is not a map")
+		}
+		*v = make(xlpp.Object, len(m))
+		for key, i := range m {
+			pair, ok := i.([]interface{})
+			if !ok || len(pair) != 2 {
+				return fmt.Errorf("object key %q is not a [type, value] array", key)
+			}
+			value, err := fromTypedItem(pair)
+			if err != nil {
+				return fmt.Errorf("object key %q: %v", key, err)
+			}
+			(*v)[key] = value
+		}
+		return nil
+	case *xlpp.Array:
+		a, ok := item.([]interface{})
+		if !ok {
+			return fmt.Errorf("array is not an array")
+		}
+		*v = make(xlpp.Array, len(a))
+		for j, i := range a {
+			pair, ok := i.([]interface{})
+			if !ok || len(pair) != 2 {
+				return fmt.Errorf("array item %d is not a [type, value] array", j)
+			}
+			value, err := fromTypedItem(pair)
+			if err != nil {
+				return fmt.Errorf("array item %d: %v", j, err)
+			}
+			(*v)[j] = value
+		}
+		return nil
+	case *xlpp.Binary:
+		b, ok := item.([]byte)
+		if !ok {
+			return fmt.Errorf("binary is not a byte string")
+		}
+		*v = b
+		return nil
+	case *xlpp.UnixTime:
+		t, ok := item.(tagged)
+		if !ok || t.tag != tagEpoch {
+			return fmt.Errorf("unixtime is not an epoch-based date/time")
+		}
+		switch sec := t.value.(type) {
+		case uint64:
+			*v = xlpp.UnixTime(time.Unix(int64(sec), 0))
+		case int64:
+			*v = xlpp.UnixTime(time.Unix(sec, 0))
+		case float64:
+			*v = xlpp.UnixTime(time.Unix(0, int64(sec*1e9)))
+		default:
+			return fmt.Errorf("unixtime is not a number")
+		}
+		return nil
+	case *xlpp.Null:
+		if item != nil {
+			return fmt.Errorf("null is not null")
+		}
+		return nil
+	}
+	data, err := json.Marshal(toJSON(item))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// toJSON converts the CBOR data items that encoding/json can not marshal.
+func toJSON(item interface{}) interface{} {
+	switch item := item.(type) {
+	case tagged:
+		return toJSON(item.value)
+	case map[string]interface{}:
+		for key, i := range item {
+			item[key] = toJSON(i)
+		}
+	case []interface{}:
+		for j, i := range item {
+			item[j] = toJSON(i)
+		}
+	}
+	return item
+}
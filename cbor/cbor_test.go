@@ -0,0 +1,105 @@
+package cbor_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/cbor"
+)
+
+func TestMarshal(t *testing.T) {
+	temp := xlpp.Temperature(23.5)
+	data, err := cbor.Marshal(xlpp.Message{{Channel: 5, Value: &temp}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// [[5, 103, 23.5]] with 23.5 as half-precision float
+	if hex.EncodeToString(data) != "8183051867f94de0" {
+		t.Fatalf("Marshal: %x", data)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	temp := xlpp.Temperature(-12.3)
+	gps := xlpp.GPS{Latitude: 52.5219, Longitude: 13.4132, Meters: 34.5}
+	colour := xlpp.Colour{R: 255, G: 128}
+	unix := xlpp.UnixTime(time.Unix(1700000000, 0))
+	bin := xlpp.Binary{0, 1, 0xff}
+	null := xlpp.Null{}
+	on := xlpp.Bool(true)
+	str := xlpp.String("hello")
+	integer := xlpp.Integer(-70000)
+	flags := xlpp.Flags(9)
+	delay := xlpp.Delay(90 * time.Second)
+	actuators := xlpp.Actuators{xlpp.TypeSwitch, xlpp.TypeColour}
+	object := xlpp.Object{"a": &temp, "b": &xlpp.Array{&on, &str, &null}}
+	m := xlpp.Message{
+		{Channel: 1, Value: &temp},
+		{Channel: 2, Value: &gps},
+		{Channel: 3, Value: &colour},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 4, Value: &unix},
+		{Channel: 5, Value: &bin},
+		{Channel: 6, Value: &object},
+		{Channel: 7, Value: &integer},
+		{Channel: 8, Value: &flags},
+		{Channel: xlpp.ChanActuators, Value: &actuators},
+	}
+	data, err := cbor.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := cbor.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := m.MarshalBinary()
+	got, err := decoded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("round trip:\n%v\n%v", decoded, m)
+	}
+	if !reflect.DeepEqual(decoded[6].Value, &object) {
+		t.Fatalf("object: %v", decoded[6].Value)
+	}
+
+	again, _ := cbor.Marshal(decoded)
+	if !bytes.Equal(again, data) {
+		t.Fatalf("not deterministic:\n%x\n%x", again, data)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	// indefinite length array and a non-preferred float64 for 23.5
+	data, _ := hex.DecodeString("9f830518" + "67fb4037800000000000" + "ff")
+	m, err := cbor.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m.Get(5); !ok || *v.(*xlpp.Temperature) != 23.5 {
+		t.Fatalf("Unmarshal: %v", m)
+	}
+
+	for _, bad := range []string{
+		"",
+		"80ff",                         // trailing data
+		"a0",                           // not an array
+		"8182051867",                   // entry without value
+		"81831901000000",               // channel 256
+		"818305" + "18c8" + "00",       // unknown type 200
+		"818300" + "18ff" + "00",       // marker on channel 0
+		"81830518" + "67" + "63616263", // temperature as text
+		"9b00000000ffffffff",           // array longer than the data
+	} {
+		data, _ := hex.DecodeString(bad)
+		if _, err := cbor.Unmarshal(data); err == nil {
+			t.Fatalf("Unmarshal(%s): no error", bad)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CBOR major types.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// tagEpoch is the tag of epoch-based date/time values.
+const tagEpoch = 1
+
+// maxDepth is the max. nesting depth of decoded arrays, maps and tags.
+const maxDepth = 64
+
+var errTruncated = errors.New("cbor: unexpected end of data")
+var errDepth = errors.New("cbor: nesting too deep")
+
+// A tagged is a tagged data item.
+type tagged struct {
+	tag   uint64
+	value interface{}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// encoder writes data items in the core deterministic encoding of RFC 8949, section 4.2.1:
+// shortest arguments and floats, definite lengths, and map keys in bytewise lexicographic order.
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) head(major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		e.buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		e.buf.Write([]byte{major<<5 | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		var b [3]byte
+		b[0] = major<<5 | 25
+		binary.BigEndian.PutUint16(b[1:], uint16(arg))
+		e.buf.Write(b[:])
+	case arg <= math.MaxUint32:
+		var b [5]byte
+		b[0] = major<<5 | 26
+		binary.BigEndian.PutUint32(b[1:], uint32(arg))
+		e.buf.Write(b[:])
+	default:
+		var b [9]byte
+		b[0] = major<<5 | 27
+		binary.BigEndian.PutUint64(b[1:], arg)
+		e.buf.Write(b[:])
+	}
+}
+
+func (e *encoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteByte(majorSimple<<5 | 22)
+	case bool:
+		if v {
+			e.buf.WriteByte(majorSimple<<5 | 21)
+		} else {
+			e.buf.WriteByte(majorSimple<<5 | 20)
+		}
+	case uint64:
+		e.head(majorUint, v)
+	case int64:
+		if v < 0 {
+			e.head(majorNegInt, uint64(-1-v))
+		} else {
+			e.head(majorUint, uint64(v))
+		}
+	case float64:
+		e.float(v)
+	case string:
+		e.head(majorText, uint64(len(v)))
+		e.buf.WriteString(v)
+	case []byte:
+		e.head(majorBytes, uint64(len(v)))
+		e.buf.Write(v)
+	case []interface{}:
+		e.head(majorArray, uint64(len(v)))
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([][]byte, 0, len(v))
+		for key := range v {
+			var k encoder
+			k.encode(key)
+			keys = append(keys, k.buf.Bytes())
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		e.head(majorMap, uint64(len(v)))
+		for _, key := range keys {
+			e.buf.Write(key)
+			// the encoded key is the head followed by the text
+			_, n := headLength(key[0])
+			if err := e.encode(v[string(key[1+n:])]); err != nil {
+				return err
+			}
+		}
+	case tagged:
+		e.head(majorTag, v.tag)
+		return e.encode(v.value)
+	default:
+		return fmt.Errorf("cbor: can not encode %T", v)
+	}
+	return nil
+}
+
+// float writes the float in the shortest form that preserves its value.
+func (e *encoder) float(f float64) {
+	if h, ok := float16(f); ok {
+		e.buf.Write([]byte{majorSimple<<5 | 25, byte(h >> 8), byte(h)})
+		return
+	}
+	if float64(float32(f)) == f {
+		var b [5]byte
+		b[0] = majorSimple<<5 | 26
+		binary.BigEndian.PutUint32(b[1:], math.Float32bits(float32(f)))
+		e.buf.Write(b[:])
+		return
+	}
+	var b [9]byte
+	b[0] = majorSimple<<5 | 27
+	binary.BigEndian.PutUint64(b[1:], math.Float64bits(f))
+	e.buf.Write(b[:])
+}
+
+// float16 returns the half-precision bits of f, if f can be represented without loss.
+func float16(f float64) (uint16, bool) {
+	if math.IsNaN(f) {
+		return 0x7e00, true
+	}
+	if float64(float32(f)) != f {
+		return 0, false
+	}
+	bits := math.Float32bits(float32(f))
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23&0xff) - 127
+	mant := bits & 0x7fffff
+	switch {
+	case math.IsInf(f, 0):
+		return sign | 0x7c00, true
+	case f == 0:
+		return sign, true
+	case exp >= -14 && exp <= 15:
+		// normal
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(exp+15)<<10 | uint16(mant>>13), true
+	case exp >= -24 && exp < -14:
+		// subnormal
+		shift := uint(-14 - exp + 13)
+		m := mant | 0x800000
+		if m&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(m>>shift), true
+	}
+	return 0, false
+}
+
+// headLength returns the major type and the number of argument bytes of the initial byte of a data item.
+func headLength(b byte) (major byte, n int) {
+	major = b >> 5
+	switch b & 0x1f {
+	case 24:
+		n = 1
+	case 25:
+		n = 2
+	case 26:
+		n = 4
+	case 27:
+		n = 8
+	}
+	return
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// decoder reads data items. Unsigned integers are decoded as uint64, negative integers as int64,
+// floats as float64, maps as map[string]interface{} (with text keys only) and tags as tagged.
+type decoder struct {
+	data []byte
+	off  int
+}
+
+// head reads the head of a data item. For indefinite lengths, it returns indefinite.
+func (d *decoder) head() (major byte, arg uint64, indefinite bool, err error) {
+	if d.off >= len(d.data) {
+		return 0, 0, false, errTruncated
+	}
+	b := d.data[d.off]
+	d.off++
+	major = b >> 5
+	info := b & 0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info == 31:
+		return major, 0, true, nil
+	case info > 27:
+		return 0, 0, false, fmt.Errorf("cbor: bad additional information %d", info)
+	}
+	_, n := headLength(b)
+	if len(d.data)-d.off < n {
+		return 0, 0, false, errTruncated
+	}
+	for _, c := range d.data[d.off : d.off+n] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.off += n
+	return major, arg, false, nil
+}
+
+// length checks the number of items or bytes against the remaining data.
+func (d *decoder) length(arg uint64) (int, error) {
+	if arg > uint64(len(d.data)-d.off) {
+		return 0, errTruncated
+	}
+	return int(arg), nil
+}
+
+// atBreak reports whether the next byte is the break of an indefinite length item, and consumes it.
+func (d *decoder) atBreak() (bool, error) {
+	if d.off >= len(d.data) {
+		return false, errTruncated
+	}
+	if d.data[d.off] == 0xff {
+		d.off++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errDepth
+	}
+	start := d.off
+	major, arg, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if indefinite && (major < majorBytes || major == majorTag) {
+		return nil, fmt.Errorf("cbor: bad indefinite length at offset %d", start)
+	}
+	switch major {
+	case majorUint:
+		return arg, nil
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("cbor: integer overflow at offset %d", start)
+		}
+		return -1 - int64(arg), nil
+	case majorBytes, majorText:
+		var b []byte
+		if indefinite {
+			// concatenation of definite length chunks of the same major type
+			for {
+				if brk, err := d.atBreak(); err != nil {
+					return nil, err
+				} else if brk {
+					break
+				}
+				chunkMajor, l, indef, err := d.head()
+				if err != nil {
+					return nil, err
+				}
+				if chunkMajor != major || indef {
+					return nil, fmt.Errorf("cbor: bad chunk at offset %d", start)
+				}
+				n, err := d.length(l)
+				if err != nil {
+					return nil, err
+				}
+				b = append(b, d.data[d.off:d.off+n]...)
+				d.off += n
+			}
+		} else {
+			n, err := d.length(arg)
+			if err != nil {
+				return nil, err
+			}
+			b = append([]byte{}, d.data[d.off:d.off+n]...)
+			d.off += n
+		}
+		if major == majorText {
+			return string(b), nil
+		}
+		return b, nil
+	case majorArray:
+		var a []interface{}
+		for i := 0; indefinite || uint64(i) < arg; i++ {
+			if indefinite {
+				if brk, err := d.atBreak(); err != nil {
+					return nil, err
+				} else if brk {
+					break
+				}
+			} else if i == 0 {
+				if _, err := d.length(arg); err != nil {
+					return nil, err
+				}
+				a = make([]interface{}, 0, arg)
+			}
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, item)
+		}
+		if a == nil {
+			a = []interface{}{}
+		}
+		return a, nil
+	case majorMap:
+		m := make(map[string]interface{})
+		for i := 0; indefinite || uint64(i) < arg; i++ {
+			if indefinite {
+				if brk, err := d.atBreak(); err != nil {
+					return nil, err
+				} else if brk {
+					break
+				}
+			} else if i == 0 {
+				if _, err := d.length(arg); err != nil {
+					return nil, err
+				}
+			}
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: map key %v is not a text string", key)
+			}
+			if m[k], err = d.decode(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case majorTag:
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return tagged{arg, v}, nil
+	}
+	// major type 7
+	switch d.data[start] & 0x1f {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfToFloat(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d at offset %d", arg, start)
+}
+
+// halfToFloat converts half-precision bits to a float.
+func halfToFloat(h uint16) float64 {
+	exp := int(h >> 10 & 0x1f)
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/waziup/xlpp/airtime"
+)
+
+// airtimeCheck prints the time on air of payloads, and checks the daily airtime if they are sent every interval.
+func airtimeCheck(args []string) {
+	fs := flag.NewFlagSet("airtime", flag.ExitOnError)
+	region := fs.String("region", "EU868", "LoRaWAN region")
+	sf := fs.Int("sf", 7, "spreading factor")
+	interval := fs.Duration("interval", 15*time.Minute, "interval of the uplinks")
+	format := fs.String("f", "", "format, base64, hex or bin")
+	fs.Parse(args)
+
+	r, ok := airtime.Regions[*region]
+	if !ok {
+		log.Fatalf("unknown region %q", *region)
+	}
+	if *interval <= 0 {
+		log.Fatal("the interval must be positive")
+	}
+
+	var uplinks []airtime.Uplink
+	for i, arg := range fs.Args() {
+		data := readPayload(arg, *format)
+		uplinks = append(uplinks, airtime.Uplink{Time: time.Unix(0, 0).Add(time.Duration(i) * *interval), Size: len(data)})
+		fmt.Printf("%3d bytes  %v\n", len(data), r.TimeOnAir(len(data), *sf))
+	}
+	if len(uplinks) == 0 {
+		log.Fatal("no payloads")
+	}
+	e := r.Estimate(*sf, uplinks)
+	if len(uplinks) == 1 {
+		// one payload, sent every interval
+		e.Daily = time.Duration(float64(e.Airtime) * float64(24*time.Hour) / float64(*interval))
+	}
+	fmt.Printf("%v per day at SF%d (%.3f%% duty cycle), fair use allows %d uplinks per day\n",
+		e.Daily.Round(time.Millisecond), *sf, 100*e.DutyCycle(), int(airtime.FairUse*time.Duration(e.Uplinks)/e.Airtime))
+	if err := e.Check(); err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+}
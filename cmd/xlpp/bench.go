@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+)
+
+type benchEntry struct {
+	channel int
+	value   xlpp.Value
+}
+
+var (
+	benchTemperature = []xlpp.Temperature{23.5, 23.1, 22.8}
+	benchHumidity    = []xlpp.RelativeHumidity{51.5, 52, 53.5}
+	benchPressure    = xlpp.BarometricPressure(1013.2)
+	benchVoltage     = xlpp.Voltage(3.3)
+	benchGPS         = xlpp.GPS{Latitude: 51.0493, Longitude: 13.7381, Meters: 122}
+	benchDelay       = xlpp.Delay(15 * time.Minute)
+	benchID          = xlpp.String("node-42")
+	benchCount       = xlpp.Integer(5182)
+	benchOK          = xlpp.Bool(true)
+	benchItems       = []xlpp.Integer{1, 2, 3}
+	benchBinary      = xlpp.Binary{1, 2, 3, 4, 5, 6, 7, 8}
+)
+
+// benchPayloads are representative payloads for the bench subcommand.
+var benchPayloads = []struct {
+	name    string
+	entries []benchEntry
+}{
+	{
+		name: "sensor",
+		entries: []benchEntry{
+			{1, &benchTemperature[0]},
+			{2, &benchHumidity[0]},
+			{3, &benchPressure},
+			{4, &benchVoltage},
+			{5, &benchGPS},
+		},
+	},
+	{
+		name: "history",
+		entries: []benchEntry{
+			{1, &benchTemperature[0]},
+			{2, &benchHumidity[0]},
+			{xlpp.ChanDelay, &benchDelay},
+			{1, &benchTemperature[1]},
+			{2, &benchHumidity[1]},
+			{xlpp.ChanDelay, &benchDelay},
+			{1, &benchTemperature[2]},
+			{2, &benchHumidity[2]},
+		},
+	},
+	{
+		name: "structured",
+		entries: []benchEntry{
+			{1, &xlpp.Object{
+				"id":    &benchID,
+				"count": &benchCount,
+				"ok":    &benchOK,
+			}},
+			{2, &xlpp.Array{
+				&benchItems[0],
+				&benchItems[1],
+				&benchItems[2],
+			}},
+			{3, &benchBinary},
+		},
+	},
+}
+
+// bench measures the encode and decode throughput of the representative payloads.
+func bench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	name := fs.String("p", "", "only run the payload with this name")
+	fs.Parse(args)
+
+	fmt.Printf("%-12s %-7s %5s %12s %10s %10s %10s\n", "payload", "op", "size", "ns/op", "MB/s", "B/op", "allocs/op")
+	for _, p := range benchPayloads {
+		if *name != "" && *name != p.name {
+			continue
+		}
+		var buf bytes.Buffer
+		w := xlpp.NewWriter(&buf)
+		for _, e := range p.entries {
+			if _, err := w.Add(e.channel, e.value); err != nil {
+				log.Fatalf("can not write %s: %v", p.name, err)
+			}
+		}
+		data := buf.Bytes()
+
+		encode := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			var buf bytes.Buffer
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				w := xlpp.NewWriter(&buf)
+				for _, e := range p.entries {
+					w.Add(e.channel, e.value)
+				}
+			}
+		})
+		printBench(p.name, "encode", len(data), encode)
+
+		decode := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				r := xlpp.NewReader(bytes.NewReader(data))
+				for {
+					_, value, err := r.Next()
+					if err != nil {
+						b.Fatal(err)
+					}
+					if value == nil {
+						break
+					}
+				}
+			}
+		})
+		printBench(p.name, "decode", len(data), decode)
+	}
+}
+
+func printBench(name string, op string, size int, r testing.BenchmarkResult) {
+	mbs := 0.0
+	if r.T > 0 {
+		mbs = float64(r.Bytes) * float64(r.N) / 1e6 / r.T.Seconds()
+	}
+	fmt.Printf("%-12s %-7s %5d %12d %10.2f %10d %10d\n", name, op, size, r.NsPerOp(), mbs, r.AllocedBytesPerOp(), r.AllocsPerOp())
+}
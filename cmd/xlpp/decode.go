@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// headers is a repeatable -H flag with HTTP headers.
+type headers http.Header
+
+func (h headers) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headers) Set(s string) error {
+	i := strings.IndexByte(s, ':')
+	if i == -1 {
+		return fmt.Errorf("bad header %q, expected 'Name: value'", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]))
+	return nil
+}
+
+// decodeStream decodes base64 payloads, one per line, from the arguments or stdin, into one JSON document per line.
+// With -post, the documents are POSTed to a URL instead, e.g. to bridge a gateway to an HTTP API.
+// Batches that can not be POSTed, even after retries, are appended to the -spool file as payloads,
+// so they can be POSTed later with 'xlpp decode -post URL < spool'. If POSTs failed, decode exits with status 1.
+func decodeStream(args []string) {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	post := fs.String("post", "", "POST the JSON documents to this URL")
+	hdr := make(headers)
+	fs.Var(hdr, "H", "HTTP header 'Name: value' for -post, can be repeated")
+	batch := fs.Int("batch", 1, "POST up to n documents at once, as JSON array")
+	retries := fs.Int("retries", 3, "retries of failed POSTs, with exponential backoff")
+	spool := fs.String("spool", "", "append the payloads of failed POSTs to this file")
+	units := fs.Bool("units", false, "decode values with units")
+	fs.Var(quietFlag{}, "q", "do not log payloads that can not be decoded and failed POSTs")
+	fs.Parse(args)
+
+	f := &forwarder{url: *post, header: http.Header(hdr), retries: *retries, client: &http.Client{Timeout: 30 * time.Second}}
+
+	var docs [][]byte
+	var lines []string // the payloads of docs
+	var failed int
+	flush := func() {
+		if len(docs) == 0 {
+			return
+		}
+		body := docs[0]
+		if *batch > 1 {
+			body = append(append([]byte{'['}, bytes.Join(docs, []byte{','})...), ']')
+		}
+		if err := f.send(body); err != nil {
+			logger.Printf("%v", err)
+			failed += len(docs)
+			if *spool != "" {
+				if err := appendLines(*spool, lines); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+		docs, lines = docs[:0], lines[:0]
+	}
+	handle := func(line string) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return
+		}
+		var doc []byte
+		data, err := decodePayload([]byte(line), "base64")
+		if err == nil {
+			doc, err = decodeJSON(data, *units)
+		}
+		if err != nil {
+			logger.Printf("%s: %v", line, err)
+			return
+		}
+		if *post == "" {
+			os.Stdout.Write(append(doc, '\n'))
+			return
+		}
+		docs = append(docs, doc)
+		lines = append(lines, line)
+		if len(docs) >= *batch {
+			flush()
+		}
+	}
+
+	if fs.NArg() != 0 {
+		for _, arg := range fs.Args() {
+			handle(arg)
+		}
+	} else {
+		s := bufio.NewScanner(os.Stdin)
+		for s.Scan() {
+			handle(s.Text())
+		}
+		if err := s.Err(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	flush()
+	if failed != 0 {
+		if *spool != "" {
+			log.Fatalf("%d documents not POSTed, spooled to %s", failed, *spool)
+		}
+		log.Fatalf("%d documents not POSTed", failed)
+	}
+}
+
+// appendLines appends the lines to the file.
+func appendLines(name string, lines []string) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// forwarder POSTs JSON documents to a URL.
+type forwarder struct {
+	url     string
+	header  http.Header
+	retries int
+	client  *http.Client
+}
+
+// send POSTs the body, retrying on network errors and 5xx responses.
+func (f *forwarder) send(body []byte) (err error) {
+	backoff := 500 * time.Millisecond
+	for i := 0; ; i++ {
+		var retry bool
+		if retry, err = f.post(body); !retry || i >= f.retries {
+			return
+		}
+		logger.Printf("%v, retrying in %s", err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post POSTs the body once, and reports whether a failed request should be retried.
+func (f *forwarder) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, f.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, values := range f.header {
+		req.Header[name] = values
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, fmt.Errorf("POST %s: %s", f.url, resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/waziup/xlpp"
+)
+
+// dump prints the values of a payload as human readable text, one value per line.
+func dump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	format := fs.String("f", "", "format, base64, hex or bin")
+	precision := fs.Int("precision", 0, "number of decimals, 0 uses the precision of the type, -1 for no decimals")
+	noUnits := fs.Bool("no-units", false, "omit the physical units")
+	decimal := fs.String("decimal", "", "decimal separator, e.g. ','")
+	fs.Parse(args)
+
+	data := readPayload(fs.Arg(0), *format)
+	f := xlpp.Formatter{
+		Precision:        *precision,
+		NoUnits:          *noUnits,
+		DecimalSeparator: *decimal,
+	}
+	if err := f.Dump(os.Stdout, xlpp.NewBytesReader(data)); err != nil {
+		log.Fatal("can not read xlpp: ", err)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/waziup/xlpp"
+)
+
+// fuzz generates a corpus of valid, mutated and truncated payloads.
+func fuzz(args []string) {
+	fs := flag.NewFlagSet("fuzz", flag.ExitOnError)
+	out := fs.String("out", "corpus", "output directory")
+	num := fs.Int("n", 100, "number of payloads per kind (valid, mutated, truncated)")
+	seed := fs.Int64("seed", 1, "random seed")
+	format := fs.String("format", "raw", "file format, raw or go (go test fuzz corpus)")
+	fs.Parse(args)
+
+	if *format != "raw" && *format != "go" {
+		log.Fatal("unknown format")
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(*seed))
+	for i := 0; i < *num; i++ {
+		valid := randomPayload(rnd)
+		writeCorpus(*out, *format, fmt.Sprintf("valid-%04d", i), valid)
+		writeCorpus(*out, *format, fmt.Sprintf("mutated-%04d", i), mutate(rnd, randomPayload(rnd)))
+		truncated := randomPayload(rnd)
+		truncated = truncated[:rnd.Intn(len(truncated))]
+		writeCorpus(*out, *format, fmt.Sprintf("truncated-%04d", i), truncated)
+	}
+}
+
+func writeCorpus(dir string, format string, name string, data []byte) {
+	if format == "go" {
+		data = []byte(fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", data))
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// randomPayload returns a valid payload with 1 to 8 random entries.
+func randomPayload(rnd *rand.Rand) []byte {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	n := 1 + rnd.Intn(8)
+	for i := 0; i < n; i++ {
+		var err error
+		switch rnd.Intn(10) {
+		case 0:
+			d := xlpp.Delay(time.Duration(rnd.Intn(24*60*60)) * time.Second)
+			_, err = w.Add(xlpp.ChanDelay, &d)
+		case 1:
+			a := xlpp.Actuators{randomType(rnd), randomType(rnd)}
+			_, err = w.Add(xlpp.ChanActuators, &a)
+		case 2:
+			a := xlpp.ActuatorsWithChannel{{Channel: rnd.Intn(64), Type: randomType(rnd)}}
+			_, err = w.Add(xlpp.ChanActuatorsWithChannel, &a)
+		default:
+			_, err = w.Add(rnd.Intn(64), randomValue(rnd, randomType(rnd), 0))
+		}
+		if err != nil {
+			log.Fatal("can not write xlpp: ", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// randomType returns a random registered type (except the end of array).
+func randomType(rnd *rand.Rand) xlpp.Type {
+	for {
+		t := xlpp.Type(rnd.Intn(256))
+		if xlpp.LookupType(t) != nil && t != xlpp.TypeEndOfArray {
+			return t
+		}
+	}
+}
+
+// randomValue returns a value of type t with random content.
+func randomValue(rnd *rand.Rand, t xlpp.Type, depth int) xlpp.Value {
+	switch t {
+	case xlpp.TypeDigitalInput:
+		v := xlpp.DigitalInput(rnd.Intn(256))
+		return &v
+	case xlpp.TypeDigitalOutput:
+		v := xlpp.DigitalOutput(rnd.Intn(256))
+		return &v
+	case xlpp.TypeAnalogInput:
+		v := xlpp.AnalogInput(float64(rnd.Intn(65536)-32768) / 100)
+		return &v
+	case xlpp.TypeAnalogOutput:
+		v := xlpp.AnalogOutput(float64(rnd.Intn(65536)-32768) / 100)
+		return &v
+	case xlpp.TypeLuminosity:
+		v := xlpp.Luminosity(rnd.Intn(65536))
+		return &v
+	case xlpp.TypePresence:
+		v := xlpp.Presence(rnd.Intn(2))
+		return &v
+	case xlpp.TypeTemperature:
+		v := xlpp.Temperature(float64(rnd.Intn(1000)-400) / 10)
+		return &v
+	case xlpp.TypeRelativeHumidity:
+		v := xlpp.RelativeHumidity(float64(rnd.Intn(201)) / 2)
+		return &v
+	case xlpp.TypeAccelerometer:
+		return &xlpp.Accelerometer{
+			X: float64(rnd.Intn(4000)-2000) / 1000,
+			Y: float64(rnd.Intn(4000)-2000) / 1000,
+			Z: float64(rnd.Intn(4000)-2000) / 1000,
+		}
+	case xlpp.TypeBarometricPressure:
+		v := xlpp.BarometricPressure(float64(9000+rnd.Intn(2000)) / 10)
+		return &v
+	case xlpp.TypeGyrometer:
+		return &xlpp.Gyrometer{
+			X: float32(rnd.Intn(20000)-10000) / 100,
+			Y: float32(rnd.Intn(20000)-10000) / 100,
+			Z: float32(rnd.Intn(20000)-10000) / 100,
+		}
+	case xlpp.TypeGPS:
+		return &xlpp.GPS{
+			Latitude:  float64(rnd.Intn(1800000)-900000) / 10000,
+			Longitude: float64(rnd.Intn(3600000)-1800000) / 10000,
+			Meters:    float64(rnd.Intn(1000000)) / 100,
+		}
+	case xlpp.TypeVoltage:
+		v := xlpp.Voltage(float64(rnd.Intn(32768)) / 100)
+		return &v
+	case xlpp.TypeCurrent:
+		v := xlpp.Current(float64(rnd.Intn(32768)) / 1000)
+		return &v
+	case xlpp.TypeFrequency:
+		v := xlpp.Frequency(rnd.Uint32())
+		return &v
+	case xlpp.TypePercentage:
+		v := xlpp.Percentage(rnd.Intn(101))
+		return &v
+	case xlpp.TypeExtendedPercentage:
+		v := xlpp.ExtendedPercentage(rnd.Intn(256))
+		return &v
+	case xlpp.TypeBarometricPressure24:
+		v := xlpp.BarometricPressure24(float64(rnd.Intn(1<<24)) / 100)
+		return &v
+	case xlpp.TypeDistanceLong:
+		v := xlpp.DistanceLong(float64(rnd.Int63()>>10) / 1000)
+		return &v
+	case xlpp.TypePowerPrecise:
+		v := xlpp.PowerPrecise(float64(rnd.Uint32()) / 10)
+		return &v
+	case xlpp.TypeCurrentHiRange:
+		v := xlpp.CurrentHiRange(float64(rnd.Uint32()) / 100)
+		return &v
+	case xlpp.TypeVoltageSigned:
+		v := xlpp.VoltageSigned(float64(int32(rnd.Uint32())) / 100)
+		return &v
+	case xlpp.TypeAnalogUnit:
+		return &xlpp.AnalogUnit{Unit: xlpp.UnitCode(rnd.Intn(15)), Value: float64(int32(rnd.Uint32())) / 1000}
+	case xlpp.TypeGasConcentration:
+		return &xlpp.GasConcentration{Gas: xlpp.GasID(rnd.Intn(12)), PPM: float64(rnd.Uint32()) / 1000}
+	case xlpp.TypeGPS2D:
+		return &xlpp.GPS2D{
+			Latitude:  float64(rnd.Intn(1800000)-900000) / 10000,
+			Longitude: float64(rnd.Intn(3600000)-1800000) / 10000,
+		}
+	case xlpp.TypeAccelerometerHiG:
+		return &xlpp.AccelerometerHiG{
+			X: float64(rnd.Intn(40000)-20000) / 100,
+			Y: float64(rnd.Intn(40000)-20000) / 100,
+			Z: float64(rnd.Intn(40000)-20000) / 100,
+		}
+	case xlpp.TypeGyrometerHiRate:
+		return &xlpp.GyrometerHiRate{
+			X: float32(rnd.Intn(4000) - 2000),
+			Y: float32(rnd.Intn(4000) - 2000),
+			Z: float32(rnd.Intn(4000) - 2000),
+		}
+	case xlpp.TypeSamples:
+		v := xlpp.Samples{Type: xlpp.TypeTemperature, Interval: time.Duration(1+rnd.Intn(3600)) * time.Second}
+		for i := rnd.Intn(60); i > 0; i-- {
+			v.Values = append(v.Values, float64(rnd.Intn(1000)-300)/10)
+		}
+		return &v
+	case xlpp.TypeSpectrum:
+		v := xlpp.Spectrum{BinWidth: float64(rnd.Intn(100000)) / 100, Bins: make([]uint8, rnd.Intn(64))}
+		rnd.Read(v.Bins)
+		return &v
+	case xlpp.TypeImageChunk:
+		v := xlpp.ImageChunk{ImageID: rnd.Uint32(), Total: uint32(1 + rnd.Intn(16)), Data: make([]byte, rnd.Intn(32))}
+		v.Index = uint32(rnd.Intn(int(v.Total)))
+		rnd.Read(v.Data)
+		return &v
+	case xlpp.TypeTrack:
+		v := make(xlpp.Track, rnd.Intn(8))
+		for i := range v {
+			v[i] = xlpp.TrackPoint{
+				Latitude:  float64(rnd.Intn(1800001)-900000) / 10000,
+				Longitude: float64(rnd.Intn(3600001)-1800000) / 10000,
+				Meters:    float64(rnd.Intn(1000000)-100000) / 100,
+				Offset:    time.Duration(len(v)-i) * time.Minute,
+			}
+		}
+		return &v
+	case xlpp.TypeScheduledCommand:
+		return &xlpp.ScheduledCommand{Delay: time.Duration(rnd.Intn(86400)) * time.Second, Value: randomValue(rnd, xlpp.TypeDigitalOutput, depth+1)}
+	case xlpp.TypeAltitude:
+		v := xlpp.Altitude(rnd.Intn(65536) - 32768)
+		return &v
+	case xlpp.TypeConcentration:
+		v := xlpp.Concentration(rnd.Intn(65536))
+		return &v
+	case xlpp.TypePower:
+		v := xlpp.Power(rnd.Intn(65536))
+		return &v
+	case xlpp.TypeDistance:
+		v := xlpp.Distance(float64(rnd.Int31()) / 1000)
+		return &v
+	case xlpp.TypeEnergy:
+		v := xlpp.Energy(float64(rnd.Int31()) / 1000)
+		return &v
+	case xlpp.TypeDirection:
+		v := xlpp.Direction(rnd.Intn(360))
+		return &v
+	case xlpp.TypeUnixTime:
+		v := xlpp.UnixTime(time.Unix(int64(rnd.Uint32()), 0))
+		return &v
+	case xlpp.TypeColour:
+		return &xlpp.Colour{R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256))}
+	case xlpp.TypeSwitch:
+		v := xlpp.Switch(rnd.Intn(2) == 1)
+		return &v
+	case xlpp.TypeInteger:
+		v := xlpp.Integer(rnd.Int31() - rnd.Int31())
+		return &v
+	case xlpp.TypeString:
+		v := xlpp.String(randomString(rnd))
+		return &v
+	case xlpp.TypeBoolTrue, xlpp.TypeBoolFalse, xlpp.TypeBool:
+		v := xlpp.Bool(rnd.Intn(2) == 1)
+		return &v
+	case xlpp.TypeFlags:
+		v := xlpp.Flags(rnd.Uint64() >> uint(rnd.Intn(64)))
+		return &v
+	case xlpp.TypeBinary:
+		v := make(xlpp.Binary, rnd.Intn(16))
+		rnd.Read(v)
+		return &v
+	case xlpp.TypeObject:
+		v := make(xlpp.Object)
+		if depth < 3 {
+			for i := rnd.Intn(4); i > 0; i-- {
+				v["k"+randomString(rnd)] = randomValue(rnd, randomType(rnd), depth+1)
+			}
+		}
+		return &v
+	case xlpp.TypeArray, xlpp.TypeArrayOf:
+		var v xlpp.Array
+		if depth < 3 {
+			for i := rnd.Intn(4); i > 0; i-- {
+				v = append(v, randomValue(rnd, randomType(rnd), depth+1))
+			}
+		}
+		return &v
+	}
+	return xlpp.LookupType(t)()
+}
+
+func randomString(rnd *rand.Rand) string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789 :)"
+	b := make([]byte, rnd.Intn(12))
+	for i := range b {
+		b[i] = chars[rnd.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// mutate applies 1 to 4 random byte flips, insertions or deletions.
+func mutate(rnd *rand.Rand, data []byte) []byte {
+	for i := 1 + rnd.Intn(4); i > 0; i-- {
+		p := rnd.Intn(len(data) + 1)
+		switch rnd.Intn(3) {
+		case 0:
+			if p < len(data) {
+				data[p] ^= byte(1 << uint(rnd.Intn(8)))
+			}
+		case 1:
+			data = append(data[:p], append([]byte{byte(rnd.Intn(256))}, data[p:]...)...)
+		case 2:
+			if p < len(data) {
+				data = append(data[:p], data[p+1:]...)
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/ttn"
+)
+
+// gen generates code: `xlpp gen js-formatter` a JavaScript payload formatter for The Things Stack,
+// `xlpp gen go` the same as `xlpp gen-go`.
+func gen(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: xlpp gen js-formatter|go [flags]")
+	}
+	switch args[0] {
+	case "js-formatter":
+		genJSFormatter(args[1:])
+	case "go":
+		genGo(args[1:])
+	default:
+		log.Fatalf("unknown generator %q, want js-formatter or go", args[0])
+	}
+}
+
+// genJSFormatter generates the decodeUplink, decodeDownlink and encodeDownlink functions from the xlpp.Registry.
+func genJSFormatter(args []string) {
+	fs := flag.NewFlagSet("gen js-formatter", flag.ExitOnError)
+	fPort := fs.Int("fport", 1, "FPort of encoded downlinks")
+	canonical := fs.Bool("canonical", false, "decode with lowercase JSON field names, e.g. {\"x\":1,\"y\":2,\"z\":3}")
+	out := fs.String("o", "", "output file, defaults to stdout")
+	fs.Parse(args)
+
+	naming := xlpp.LegacyJSONNaming
+	if *canonical {
+		naming = xlpp.CanonicalJSONNaming
+	}
+	formatter, err := ttn.JavaScriptFormatter(*fPort, naming)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(formatter)
+		return
+	}
+	if err := ioutil.WriteFile(*out, formatter, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
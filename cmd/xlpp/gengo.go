@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/profiles"
+	"github.com/waziup/xlpp/schema"
+)
+
+// genGo generates a typed Go struct with Encode and Decode methods from a device profile or schema.
+func genGo(args []string) {
+	fs := flag.NewFlagSet("gen-go", flag.ExitOnError)
+	file := fs.String("profiles", "", "profiles JSON file")
+	schemaFile := fs.String("schema", "", "schema file, used instead of -profiles and -model")
+	model := fs.String("model", "", "device model of the profile")
+	pkg := fs.String("package", "main", "package name of the generated code")
+	out := fs.String("o", "", "output file, defaults to stdout")
+	fs.Parse(args)
+
+	var profile *profiles.Profile
+	if *schemaFile != "" {
+		s, err := schema.ParseFile(*schemaFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profile = s.Profile()
+	} else {
+		registry := profiles.NewRegistry()
+		if err := registry.LoadFile(*file); err != nil {
+			log.Fatal(err)
+		}
+		var ok bool
+		profile, ok = registry.Get(*model)
+		if !ok {
+			log.Fatalf("unknown model %q, available models: %s", *model, strings.Join(registry.Models(), ", "))
+		}
+	}
+
+	code, err := generateGo(profile, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := ioutil.WriteFile(*out, code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type genStruct struct {
+	Name   string
+	Doc    string
+	Fields []genField
+}
+
+type genField struct {
+	Name    string
+	Const   string
+	Channel int
+	Type    string
+	Unit    string
+}
+
+// generateGo returns the formatted Go code for the profile.
+func generateGo(p *profiles.Profile, pkg string) ([]byte, error) {
+	name := goName(p.Model)
+	var structs []genStruct
+	// the channel constants of the uplink and the command share one const block
+	consts := make(map[string]bool)
+	uplink := genStruct{Name: name, Doc: "is the payload of a " + p.Model + " device."}
+	uplink.Fields = genFields(p.Channels, consts)
+	structs = append(structs, uplink)
+	if len(p.Actuators) != 0 {
+		command := genStruct{Name: name + "Command", Doc: "is a command for the actuators of a " + p.Model + " device."}
+		command.Fields = genFields(p.Actuators, consts)
+		structs = append(structs, command)
+	}
+
+	var buf bytes.Buffer
+	err := goTemplate.Execute(&buf, map[string]interface{}{
+		"Package": pkg,
+		"Model":   p.Model,
+		"Structs": structs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// genFields returns the struct fields of the channels.
+// Field names that are used twice (e.g. by channels of the same type without name) or that are method names
+// get the channel number as suffix, as do constant names that are already in consts.
+func genFields(channels []profiles.Channel, consts map[string]bool) []genField {
+	fields := make([]genField, len(channels))
+	// the methods of the generated structs
+	names := map[string]bool{"Encode": true, "Decode": true}
+	for i, c := range channels {
+		v := xlpp.LookupName(c.Type)()
+		typ := reflect.TypeOf(v).Elem().Name()
+		name := c.Name
+		if name == "" {
+			name = typ
+		}
+		unit := c.Unit
+		if unit == "" {
+			unit = v.XLPPType().Unit()
+		}
+		fields[i] = genField{
+			Name:    uniqueName(goName(name), c.Channel, names),
+			Const:   uniqueName("Chan"+goName(name), c.Channel, consts),
+			Channel: c.Channel,
+			Type:    "xlpp." + typ,
+			Unit:    unit,
+		}
+	}
+	return fields
+}
+
+// uniqueName returns name, or name with the channel as suffix if name is already used, and marks it as used.
+func uniqueName(name string, channel int, used map[string]bool) string {
+	unique := name
+	for i := 0; used[unique]; i++ {
+		unique = name + strconv.Itoa(channel) + strings.Repeat("X", i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// goName converts a name like "outdoor temperature" or "weather-station" to an exported Go name.
+func goName(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteRune('X')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var goTemplate = template.Must(template.New("go").Parse(`// Code generated by xlpp gen-go. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/waziup/xlpp"
+)
+
+// Channels of the {{.Model}} device.
+const (
+{{- range .Structs}}{{range .Fields}}
+	{{.Const}} = {{.Channel}}
+{{- end}}{{end}}
+)
+{{range .Structs}}{{$struct := .}}
+// {{.Name}} {{.Doc}}
+type {{.Name}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}}{{if .Unit}} // [{{.Unit}}]{{end}}
+{{- end}}
+}
+
+// Encode encodes the {{.Name}} to a XLPP payload.
+func (p *{{.Name}}) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+{{- range .Fields}}
+	if _, err := w.Add({{.Const}}, &p.{{.Name}}); err != nil {
+		return nil, err
+	}
+{{- end}}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes the {{.Name}} from a XLPP payload.
+// Values on other channels are ignored.
+func (p *{{.Name}}) Decode(data []byte) error {
+	r := xlpp.NewBytesReader(data)
+	for {
+		channel, value, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return nil
+		}
+		switch channel {
+{{- range .Fields}}
+		case {{.Const}}:
+			v, ok := value.(*{{.Type}})
+			if !ok {
+				return fmt.Errorf("{{$struct.Name}}: channel %d: unexpected type %s", channel, xlpp.NameOf(value))
+			}
+			p.{{.Name}} = *v
+{{- end}}
+		}
+	}
+}
+{{end}}`))
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/waziup/xlpp"
+)
+
+// jsonSchema prints the JSON Schema of the JSON format, see xlpp.JSONSchema.
+func jsonSchema(args []string) {
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	if err := e.Encode(xlpp.JSONSchema()); err != nil {
+		log.Fatal(err)
+	}
+}
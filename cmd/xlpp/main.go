@@ -1,60 +1,154 @@
 package main
 
 import (
-	"bytes"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
-	"reflect"
-	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/lorawan"
+	"github.com/waziup/xlpp/xlppcsv"
 )
 
-var registry = make(map[string]func() xlpp.Value, len(xlpp.Registry))
+// commands are the xlpp subcommands, e.g. `xlpp split`.
+var commands = map[string]func(args []string){
+	"split":   split,
+	"cat":     cat,
+	"decode":  decodeStream,
+	"bench":   bench,
+	"fuzz":    fuzz,
+	"dump":    dump,
+	"worker":  worker,
+	"airtime": airtimeCheck,
+
+	"jsonschema": jsonSchema,
+	"mqtt":       mqttBridge,
+	"serve":      serve,
+
+	"optimize": optimize,
+	"pipe":     pipe,
+	"simulate": simulateDevice,
+	"gen-go":   genGo,
+	"gen":      gen,
+}
+
+// logger receives the diagnostics of the modes that process many payloads, e.g. payloads that can not be decoded
+// or failed POSTs. The -q flag discards them.
+var logger xlpp.Logger = log.New(os.Stderr, "", 0)
+
+// quietFlag is the -q flag, that replaces the logger with xlpp.NopLogger.
+type quietFlag struct{}
+
+func (quietFlag) String() string   { return "false" }
+func (quietFlag) IsBoolFlag() bool { return true }
+
+func (quietFlag) Set(s string) error {
+	quiet, err := strconv.ParseBool(s)
+	if quiet {
+		logger = xlpp.NopLogger
+	}
+	return err
+}
 
 func main() {
 	var err error
 	log.SetFlags(0)
 
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	decode := flag.Bool("d", false, "decode")
 	encode := flag.Bool("e", false, "encode")
-	format := flag.String("f", "", "format, json or bin")
+	format := flag.String("f", "", "format, base64, hex or bin, csv to decode base64 payloads (one per line) to CSV, or table to decode a base64 payload to a table")
+	units := flag.Bool("units", false, "decode values with units, e.g. {\"value\":23.5,\"unit\":\"°C\"}")
+	canonical := flag.Bool("canonical", false, "decode with lowercase JSON field names, e.g. {\"x\":1,\"y\":2,\"z\":3}")
+	phy := flag.Bool("lorawan", false, "decode a LoRaWAN PHYPayload, see -appskey")
+	appSKey := flag.String("appskey", "", "hex AppSKey to decrypt the FRMPayload of a LoRaWAN PHYPayload")
+	stream := flag.Bool("stream", false, "decode one payload per line of stdin into one JSON document per line, without exiting on errors")
 	help := flag.Bool("h", false, "help")
+	flag.Var(quietFlag{}, "q", "with -stream, do not log payloads that can not be decoded")
 
 	flag.Parse()
 
+	var jsonOpts []xlpp.JSONOption
+	if *canonical {
+		jsonOpts = append(jsonOpts, xlpp.WithJSONNaming(xlpp.CanonicalJSONNaming))
+	}
+
 	if *help {
 		log.Print("Usage:")
 		log.Print(`  xlpp -e '{"temperature5":23.5}'`)
 		log.Print(`  xlpp -d 'AGcA6w=='`)
+		log.Print(`  xlpp -d -f hex '006700eb'`)
+		log.Print(`  mosquitto_sub -t 'uplinks/#' | xlpp -d -stream | jq .`)
+		log.Print(`  xlpp dump -decimal , 'AGcA6w=='`)
+		log.Print(`  xlpp -d -f csv < payloads.txt > trial.csv`)
+		log.Print(`  xlpp -d -f table 'AGcA6w=='`)
+		log.Print(`  xlpp -d -lorawan -appskey ec925802ae430ca77fd3dd73cb2cc588 'QPF9vkkACgAC8wEAQwAAAAA='`)
+		log.Print(`  xlpp mqtt -broker tcp://localhost:1883 -topic 'devices/+/up' -field data -republish '{topic}/json'`)
+		log.Print(`  xlpp serve -addr :8080`)
+		log.Print(`  xlpp decode -post https://example.com/ingest -batch 10 < payloads.txt`)
+		log.Print(`  xlpp optimize -fail 'AzPIAw=='`)
+		log.Print(`  xlpp airtime -region EU868 -sf 12 -interval 10m 'AGcA6w=='`)
+		log.Print(`  xlpp split -max 51 'AGcA6wFnAOs='`)
+		log.Print(`  xlpp pipe -p 'drop 3; rename 5=1' -o json 'AGcA6w=='`)
+		log.Print(`  xlpp cat -f bin pl1.xlpp pl2.xlpp`)
+		log.Print(`  xlpp jsonschema > xlpp.schema.json`)
+		log.Print(`  xlpp worker`)
+		log.Print(`  xlpp bench`)
+		log.Print(`  xlpp fuzz -out corpus/`)
+		log.Print(`  xlpp gen-go -profiles profiles.json -model weather-station -package weather`)
+		log.Print(`  xlpp gen-go -schema weather.xlpps -package weather`)
+		log.Print(`  xlpp gen js-formatter -fport 2 > formatter.js`)
+		log.Print(`  xlpp simulate -schema weather.xlpps -interval 1m`)
 		log.Print(``)
 		log.Print(`JSON Format: { type channel : value, ...}`)
 		log.Print("XLPP types and example zero value:")
-		for _, f := range xlpp.Registry {
-			if v := f(); v != nil {
-				data, err := json.Marshal(v)
-				if err == nil {
-					log.Printf("%19s: %s", typeName(v), data)
-				}
+		listed := make(map[string]bool)
+		for _, info := range xlpp.ListTypes() {
+			if listed[info.Name] {
+				continue
+			}
+			listed[info.Name] = true
+			data, err := json.Marshal(xlpp.LookupName(info.Name)())
+			if err != nil {
+				continue
+			}
+			if info.Unit != "" {
+				log.Printf("%20s: %s (%s)", info.Name, data, info.Unit)
+			} else {
+				log.Printf("%20s: %s", info.Name, data)
 			}
 		}
 		return
 	}
 
-	for _, f := range xlpp.Registry {
-		if v := f(); v != nil {
-			registry[typeName(v)] = f
+	var data []byte
+
+	if *decode && *stream {
+		switch *format {
+		case "b64", "base64", "", "hex":
+		default:
+			log.Fatal("-stream decodes base64 or hex payloads")
+		}
+		if !streamJSON(os.Stdin, os.Stdout, *format, *units, *phy, *appSKey, jsonOpts...) {
+			os.Exit(1)
 		}
+		return
 	}
 
-	var data []byte
-
 	if *decode {
 		if flag.Arg(0) != "" {
 			data = []byte(flag.Arg(0))
@@ -68,10 +162,26 @@ func main() {
 		case "b64", "base64", "":
 			data = base642xlpp(data)
 		case "bin":
+		case "hex":
+			data = hex2xlpp(data)
+		case "csv":
+			xlpp2csv(data, *phy, *appSKey)
+			return
+		case "table":
+			data = base642xlpp(data)
 		default:
 			log.Fatal("unknown format")
 		}
-		data = xlpp2json(data)
+		if *phy {
+			data = frmPayload(data, *appSKey)
+		}
+		if *format == "table" {
+			if err := (xlpp.Formatter{}).Table(os.Stdout, xlpp.NewBytesReader(data)); err != nil {
+				log.Fatal("can not read xlpp: ", err)
+			}
+			return
+		}
+		data = xlpp2json(data, *units, jsonOpts...)
 		os.Stdout.Write(data)
 		return
 
@@ -89,6 +199,8 @@ func main() {
 		case "bin":
 		case "b64", "base64", "":
 			data = xlpp2base64(data)
+		case "hex":
+			data = xlpp2hex(data)
 		default:
 			log.Fatal("unknown format")
 		}
@@ -97,81 +209,118 @@ func main() {
 	}
 }
 
-var jsonKeyRegexp = regexp.MustCompile(`^([a-zA-Z]+)([0-9]+)$`)
+// frmPayload returns the (decrypted) FRMPayload of a LoRaWAN PHYPayload.
+func frmPayload(phy []byte, appSKey string) []byte {
+	data, err := decryptFRMPayload(phy, appSKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
+// decryptFRMPayload is frmPayload without exiting on errors.
+func decryptFRMPayload(phy []byte, appSKey string) ([]byte, error) {
+	f, err := lorawan.Parse(phy)
+	if err != nil {
+		return nil, err
+	}
+	if appSKey != "" {
+		key, err := hex.DecodeString(appSKey)
+		if err != nil {
+			return nil, fmt.Errorf("bad AppSKey: %v", err)
+		}
+		if err := f.Decrypt(key, 0); err != nil {
+			return nil, err
+		}
+	}
+	return f.FRMPayload, nil
+}
 
 func xlpp2base64(data []byte) []byte {
 	str := base64.StdEncoding.EncodeToString(data)
 	return []byte(str)
 }
 
-func base642xlpp(data []byte) []byte {
-	data, err := base64.StdEncoding.DecodeString(string(data))
+func hex2xlpp(data []byte) []byte {
+	data, err := xlpp.DecodeHex(string(data))
 	if err != nil {
 		log.Fatal(err)
 	}
 	return data
 }
 
-func json2xlpp(data []byte) []byte {
-	var buf bytes.Buffer
-	w := xlpp.NewWriter(&buf)
+func xlpp2hex(data []byte) []byte {
+	return []byte(hex.EncodeToString(data))
+}
 
-	values := make(map[string]json.RawMessage)
-	if err := json.Unmarshal(data, &values); err != nil {
+func base642xlpp(data []byte) []byte {
+	data, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
 		log.Fatal(err)
 	}
+	return data
+}
 
-	for key, m := range values {
-		match := jsonKeyRegexp.FindStringSubmatch(key)
-		if match == nil {
-			log.Fatal("bad json entry: ", key)
+// xlpp2csv writes the base64 payloads, one per line, as CSV to stdout (see the xlppcsv package).
+func xlpp2csv(data []byte, phy bool, appSKey string) {
+	w := xlppcsv.NewWriter(os.Stdout)
+	now := time.Now()
+	for _, line := range strings.Fields(string(data)) {
+		payload := base642xlpp([]byte(line))
+		if phy {
+			payload = frmPayload(payload, appSKey)
 		}
-		name := match[1]
-		channel, _ := strconv.Atoi(match[2])
-		f, ok := registry[name]
-		if !ok {
-			log.Fatal("unknown type: ", name)
-		}
-		v := f()
-		if err := json.Unmarshal(m, v); err != nil {
-			log.Fatalf("can not unmarshal %q: %v", name, err)
+		m, err := xlpp.NewBytesReader(payload).ReadMessage()
+		if err != nil {
+			log.Fatal("can not read xlpp: ", err)
 		}
-		if _, err := w.Add(channel, v); err != nil {
-			log.Fatalf("can not write %q: %v", name, err)
+		if err := w.Write(now, m); err != nil {
+			log.Fatal(err)
 		}
 	}
-
-	return buf.Bytes()
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
 }
 
-func xlpp2json(data []byte) []byte {
-	buf := bytes.NewBuffer(data)
-	r := xlpp.NewReader(buf)
-	values := make(map[string]interface{})
+func json2xlpp(data []byte) []byte {
+	data, err := encodeJSON(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
 
-	for {
-		channel, value, err := r.Next()
-		if err != nil {
-			log.Fatal("can not read xlpp: ", err)
-		}
-		if value == nil {
-			break
-		}
-		name := typeName(value) + strconv.Itoa(channel)
-		values[name] = value
+// encodeJSON encodes the JSON format, e.g. {"temperature0":23.5}, into a payload.
+func encodeJSON(data []byte) ([]byte, error) {
+	m, err := xlpp.UnmarshalJSON(data)
+	if err != nil {
+		return nil, err
 	}
-	data, err := json.Marshal(values)
+	return m.MarshalBinary()
+}
+
+func xlpp2json(data []byte, units bool, opts ...xlpp.JSONOption) []byte {
+	data, err := decodeJSON(data, units, opts...)
 	if err != nil {
-		log.Fatal("can not marshal json: ", err)
+		log.Fatal(err)
 	}
 	return data
 }
 
-func typeName(v interface{}) (name string) {
-	if t := reflect.TypeOf(v); t.Kind() == reflect.Ptr {
-		name = t.Elem().Name()
+// decodeJSON decodes the payload into the JSON format, e.g. {"temperature0":23.5}.
+func decodeJSON(data []byte, units bool, opts ...xlpp.JSONOption) ([]byte, error) {
+	m, err := xlpp.NewBytesReader(data).ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("can not read xlpp: %v", err)
+	}
+	if units {
+		data, err = xlpp.MarshalJSONUnits(m, opts...)
 	} else {
-		name = t.Name()
+		data, err = xlpp.MarshalJSON(m, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal json: %v", err)
 	}
-	return strings.ToLower(name)
+	return data, nil
 }
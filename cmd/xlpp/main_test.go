@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/profiles"
+)
+
+func TestGenerateGo(t *testing.T) {
+	p := &profiles.Profile{
+		Model: "valve",
+		Channels: []profiles.Channel{
+			{Channel: 1, Type: "temperature"},
+			{Channel: 2, Type: "temperature"},
+			{Channel: 3, Type: "switch", Name: "valve"},
+			{Channel: 4, Type: "digitalinput", Name: "encode"},
+		},
+		Actuators: []profiles.Channel{
+			{Channel: 10, Type: "switch", Name: "valve"},
+			{Channel: 11, Type: "temperature"},
+		},
+	}
+	code, err := generateGo(p, "valve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "valve.go", code, 0)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, code)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("valve", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("%v\n%s", err, code)
+	}
+}
+
+type testLogger []string
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestStreamLogger(t *testing.T) {
+	defer func(l xlpp.Logger) { logger = l }(logger)
+	var l testLogger
+	logger = &l
+
+	var out bytes.Buffer
+	if streamJSON(strings.NewReader("AGcA6w==\n!bad\n"), &out, "base64", false, false, "") {
+		t.Fatal("expected failure for bad line")
+	}
+	if out.String() != "{\"temperature0\":23.5}\n" || len(l) != 1 || !strings.HasPrefix(l[0], "!bad: ") {
+		t.Fatalf("wrote %q, logged %q", out.String(), l)
+	}
+
+	if err := (quietFlag{}).Set("true"); err != nil || logger != xlpp.NopLogger {
+		t.Fatalf("-q: %v, %v", logger, err)
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mqttBridge subscribes to MQTT topics, decodes the payloads of the messages and prints the JSON documents,
+// one per line, or republishes them to MQTT.
+func mqttBridge(args []string) {
+	fs := flag.NewFlagSet("mqtt", flag.ExitOnError)
+	broker := fs.String("broker", "tcp://localhost:1883", "broker URL, tcp:// or ssl://, with optional user:password@")
+	var topics multiFlag
+	fs.Var(&topics, "topic", "topic filter, e.g. devices/+/up, can be repeated")
+	clientID := fs.String("client-id", "", "client identifier (default: xlpp-<pid>)")
+	payload := fs.String("payload", "raw", "payload encoding, raw, base64 or hex")
+	field := fs.String("field", "", "JSON field that holds the base64 (or -payload hex) payload, e.g. uplink_message.frm_payload")
+	republish := fs.String("republish", "", "publish the JSON documents to this topic instead of printing them, {topic} is the topic of the message, e.g. {topic}/json")
+	units := fs.Bool("units", false, "decode values with units")
+	keepAlive := fs.Duration("keepalive", 60*time.Second, "keep alive interval")
+	fs.Var(quietFlag{}, "q", "do not log messages that can not be decoded and reconnects")
+	fs.Parse(args)
+
+	if len(topics) == 0 {
+		log.Fatal("no -topic")
+	}
+	if *keepAlive < time.Second || *keepAlive > 65535*time.Second {
+		log.Fatal("-keepalive must be between 1s and 65535s")
+	}
+	u, err := url.Parse(*broker)
+	if err != nil {
+		log.Fatal("bad broker URL: ", err)
+	}
+	if *clientID == "" {
+		*clientID = fmt.Sprintf("xlpp-%d", os.Getpid())
+	}
+	if *field != "" && *payload == "raw" {
+		*payload = "base64"
+	}
+
+	handle := func(c *mqttConn, topic string, data []byte) {
+		doc, err := decodeMessage(data, *payload, *field, *units)
+		if err != nil {
+			logger.Printf("%s: %v", topic, err)
+			return
+		}
+		if *republish == "" {
+			os.Stdout.Write(append(doc, '\n'))
+			return
+		}
+		if err := c.publish(strings.Replace(*republish, "{topic}", topic, -1), doc); err != nil {
+			logger.Printf("%s: %v", topic, err)
+		}
+	}
+
+	backoff := time.Second
+	for {
+		c, err := dialMQTT(u, *clientID, *keepAlive)
+		if err == nil {
+			backoff = time.Second
+			if err = c.subscribe(topics); err == nil {
+				err = c.run(handle)
+			}
+			c.Close()
+		}
+		logger.Printf("mqtt: %v, reconnecting in %s", err, backoff)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// multiFlag is a repeatable string flag.
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// decodeMessage extracts the payload of an MQTT message and decodes it into a JSON document.
+func decodeMessage(data []byte, payload, field string, units bool) ([]byte, error) {
+	if field != "" {
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		for _, key := range strings.Split(field, ".") {
+			m, ok := doc.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("no field %q", field)
+			}
+			doc = m[key]
+		}
+		s, ok := doc.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %q is not a string", field)
+		}
+		data = []byte(s)
+	}
+	if payload == "raw" {
+		payload = "bin"
+	}
+	data, err := decodePayload(data, payload)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(data, units)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MQTT 3.1.1 control packet types.
+const (
+	mqttConnect   = 1
+	mqttConnack   = 2
+	mqttPublish   = 3
+	mqttPuback    = 4
+	mqttSubscribe = 8
+	mqttSuback    = 9
+	mqttPingreq   = 12
+	mqttPingresp  = 13
+)
+
+var errMQTTPacket = errors.New("malformed packet")
+
+// mqttConn is a minimal MQTT 3.1.1 client connection: it subscribes with QoS 1, and publishes with QoS 0.
+type mqttConn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	mu        sync.Mutex // serializes writes
+	keepAlive time.Duration
+	done      chan struct{}
+}
+
+// dialMQTT connects to the broker.
+func dialMQTT(u *url.URL, clientID string, keepAlive time.Duration) (*mqttConn, error) {
+	var conn net.Conn
+	var err error
+	host := u.Host
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if u.Port() == "" {
+			host += ":1883"
+		}
+		conn, err = net.DialTimeout("tcp", host, 30*time.Second)
+	case "ssl", "tls", "mqtts":
+		if u.Port() == "" {
+			host += ":8883"
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return connectMQTT(conn, u.User, clientID, keepAlive)
+}
+
+// connectMQTT sends the CONNECT packet over an established connection to the broker, and waits for its CONNACK.
+// It closes conn if the broker does not accept the connection.
+func connectMQTT(conn net.Conn, user *url.Userinfo, clientID string, keepAlive time.Duration) (*mqttConn, error) {
+	c := &mqttConn{conn: conn, r: bufio.NewReader(conn), keepAlive: keepAlive, done: make(chan struct{})}
+
+	var p []byte
+	p = appendString(p, "MQTT")
+	flags := byte(0x02) // clean session
+	if user != nil {
+		flags |= 0x80
+		if _, ok := user.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	p = append(p, 4, flags, byte(keepAlive/time.Second>>8), byte(keepAlive/time.Second))
+	p = appendString(p, clientID)
+	if user != nil {
+		p = appendString(p, user.Username())
+		if password, ok := user.Password(); ok {
+			p = appendString(p, password)
+		}
+	}
+	if err := c.write(mqttConnect<<4, p); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	t, body, err := c.read()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if t>>4 != mqttConnack || len(body) != 2 {
+		conn.Close()
+		return nil, errMQTTPacket
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused, return code %d", body[1])
+	}
+	return c, nil
+}
+
+// subscribe subscribes to the topic filters with QoS 1.
+func (c *mqttConn) subscribe(topics []string) error {
+	p := []byte{0, 1} // packet identifier
+	for _, topic := range topics {
+		p = append(appendString(p, topic), 1)
+	}
+	return c.write(mqttSubscribe<<4|0x02, p)
+}
+
+// publish publishes the payload to the topic with QoS 0.
+func (c *mqttConn) publish(topic string, payload []byte) error {
+	return c.write(mqttPublish<<4, append(appendString(nil, topic), payload...))
+}
+
+// run reads packets until the connection fails, and calls handle for every published message.
+// It pings the broker to keep the connection alive.
+func (c *mqttConn) run(handle func(c *mqttConn, topic string, payload []byte)) error {
+	go func() {
+		t := time.NewTicker(c.keepAlive / 2)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if c.write(mqttPingreq<<4, nil) != nil {
+					return
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 3 / 2))
+		t, body, err := c.read()
+		if err != nil {
+			return err
+		}
+		switch t >> 4 {
+		case mqttPublish:
+			qos := t >> 1 & 3
+			if len(body) < 2 {
+				return errMQTTPacket
+			}
+			l := int(binary.BigEndian.Uint16(body))
+			if len(body) < 2+l {
+				return errMQTTPacket
+			}
+			topic := string(body[2 : 2+l])
+			body = body[2+l:]
+			var id []byte
+			if qos > 0 {
+				if len(body) < 2 {
+					return errMQTTPacket
+				}
+				id, body = body[:2], body[2:]
+			}
+			handle(c, topic, body)
+			if id != nil {
+				// acknowledge after handling, for at least once delivery
+				if err := c.write(mqttPuback<<4, id); err != nil {
+					return err
+				}
+			}
+		case mqttSuback:
+			if len(body) < 2 {
+				return errMQTTPacket
+			}
+			for _, code := range body[2:] {
+				if code == 0x80 {
+					return errors.New("subscription refused")
+				}
+			}
+		case mqttPingresp, mqttPuback:
+		default:
+			return fmt.Errorf("unexpected packet type %d", t>>4)
+		}
+	}
+}
+
+// Close closes the connection.
+func (c *mqttConn) Close() error {
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+	return c.conn.Close()
+}
+
+// write writes a packet with the fixed header byte t and the body.
+func (c *mqttConn) write(t byte, body []byte) error {
+	p := []byte{t}
+	l := len(body)
+	for {
+		b := byte(l % 128)
+		l /= 128
+		if l > 0 {
+			b |= 0x80
+		}
+		p = append(p, b)
+		if l == 0 {
+			break
+		}
+	}
+	p = append(p, body...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := c.conn.Write(p)
+	return err
+}
+
+// read reads a packet, and returns the fixed header byte and the body.
+func (c *mqttConn) read() (t byte, body []byte, err error) {
+	if t, err = c.r.ReadByte(); err != nil {
+		return
+	}
+	l, shift := 0, uint(0)
+	for i := 0; ; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		if i == 4 {
+			return 0, nil, errMQTTPacket
+		}
+		l |= int(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body = make([]byte, l)
+	_, err = io.ReadFull(c.r, body)
+	return
+}
+
+// appendString appends a length-prefixed UTF-8 string.
+func appendString(p []byte, s string) []byte {
+	return append(append(p, byte(len(s)>>8), byte(len(s))), s...)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBroker returns the client and the broker side of a connection, with the broker side as mqttConn
+// to read and write raw packets.
+func fakeBroker() (client net.Conn, broker *mqttConn) {
+	client, server := net.Pipe()
+	return client, &mqttConn{conn: server, r: bufio.NewReader(server), done: make(chan struct{})}
+}
+
+// accept reads the CONNECT packet and accepts the connection.
+func accept(t *testing.T, broker *mqttConn) {
+	typ, body, err := broker.read()
+	if err != nil || typ>>4 != mqttConnect || !bytes.HasPrefix(body, []byte("\x00\x04MQTT\x04")) {
+		t.Errorf("CONNECT: %x %x %v", typ, body, err)
+	}
+	broker.write(mqttConnack<<4, []byte{0, 0})
+}
+
+func TestMQTTRemainingLength(t *testing.T) {
+	client, broker := fakeBroker()
+	defer broker.Close()
+	c := &mqttConn{conn: client, r: bufio.NewReader(client), done: make(chan struct{})}
+	defer c.Close()
+
+	// 0, 127, 128 and 16384 bytes need 1, 1, 2 and 3 bytes of remaining length
+	for _, n := range []int{0, 127, 128, 16384} {
+		body := bytes.Repeat([]byte{'x'}, n)
+		go broker.write(mqttPublish<<4, body)
+		typ, got, err := c.read()
+		if err != nil || typ != mqttPublish<<4 || !bytes.Equal(got, body) {
+			t.Fatalf("%d bytes: %x, %d bytes, %v", n, typ, len(got), err)
+		}
+	}
+
+	// 128 bytes as two-byte remaining length 0x80 0x01
+	go broker.conn.Write(append([]byte{mqttPublish << 4, 0x80, 0x01}, make([]byte, 128)...))
+	if _, got, err := c.read(); err != nil || len(got) != 128 {
+		t.Fatalf("0x80 0x01: %d bytes, %v", len(got), err)
+	}
+
+	// the remaining length has at most 4 bytes
+	go broker.conn.Write([]byte{mqttPublish << 4, 0xff, 0xff, 0xff, 0xff, 0x7f})
+	if _, _, err := c.read(); err != errMQTTPacket {
+		t.Fatalf("5 byte remaining length: %v", err)
+	}
+}
+
+func TestMQTTPuback(t *testing.T) {
+	client, broker := fakeBroker()
+	defer broker.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		accept(t, broker)
+		// SUBSCRIBE, SUBACK granting QoS 1
+		if typ, body, err := broker.read(); err != nil || typ != mqttSubscribe<<4|0x02 || !bytes.HasSuffix(body, []byte("up\x01")) {
+			t.Errorf("SUBSCRIBE: %x %q %v", typ, body, err)
+		}
+		broker.write(mqttSuback<<4, []byte{0, 1, 1})
+		// PUBLISH with QoS 1 and packet identifier 0x1234
+		broker.write(mqttPublish<<4|0x02, append(appendString(nil, "up"), 0x12, 0x34, 'h', 'i'))
+		typ, body, err := broker.read()
+		if err == nil && (typ != mqttPuback<<4 || !bytes.Equal(body, []byte{0x12, 0x34})) {
+			t.Errorf("PUBACK: %x %x", typ, body)
+		}
+		broker.Close()
+		errc <- err
+	}()
+
+	c, err := connectMQTT(client, url.UserPassword("user", "secret"), "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.subscribe([]string{"up"}); err != nil {
+		t.Fatal(err)
+	}
+	var handled []string
+	c.run(func(c *mqttConn, topic string, payload []byte) {
+		handled = append(handled, topic+" "+string(payload))
+	})
+	if err := <-errc; err != nil {
+		t.Fatalf("no PUBACK: %v", err)
+	}
+	if len(handled) != 1 || handled[0] != "up hi" {
+		t.Fatalf("handled %q", handled)
+	}
+}
+
+func TestMQTTRefusedSubscription(t *testing.T) {
+	client, broker := fakeBroker()
+	defer broker.Close()
+
+	go func() {
+		accept(t, broker)
+		broker.read()
+		broker.write(mqttSuback<<4, []byte{0, 1, 0x80})
+	}()
+
+	c, err := connectMQTT(client, nil, "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.subscribe([]string{"forbidden"}); err != nil {
+		t.Fatal(err)
+	}
+	err = c.run(func(c *mqttConn, topic string, payload []byte) {
+		t.Errorf("unexpected message on %s", topic)
+	})
+	if err == nil || !strings.Contains(err.Error(), "subscription refused") {
+		t.Fatalf("expected refused subscription, got %v", err)
+	}
+}
+
+func TestMQTTRefusedConnection(t *testing.T) {
+	client, broker := fakeBroker()
+	defer broker.Close()
+
+	go func() {
+		broker.read()
+		broker.write(mqttConnack<<4, []byte{0, 5}) // not authorized
+	}()
+	if _, err := connectMQTT(client, nil, "test", time.Minute); err == nil || !strings.Contains(err.Error(), "return code 5") {
+		t.Fatalf("expected refused connection, got %v", err)
+	}
+}
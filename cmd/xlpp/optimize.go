@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/waziup/xlpp"
+)
+
+// optimize prints suggestions for a smaller encoding of a payload.
+// With -fail it exits with status 1 if there are any, e.g. to check firmware payloads in CI.
+func optimize(args []string) {
+	fs := flag.NewFlagSet("optimize", flag.ExitOnError)
+	format := fs.String("f", "", "format, base64, hex or bin")
+	fail := fs.Bool("fail", false, "exit with status 1 if the payload can be optimized")
+	fs.Parse(args)
+
+	data := readPayload(fs.Arg(0), *format)
+	m, err := xlpp.NewBytesReader(data).ReadMessage()
+	if err != nil {
+		log.Fatal("can not read xlpp: ", err)
+	}
+	suggestions := xlpp.Optimize(m)
+	savings := 0
+	for _, s := range suggestions {
+		fmt.Println(s)
+		savings += s.Savings
+	}
+	fmt.Printf("%d suggestions, saving about %d of %d bytes\n", len(suggestions), savings, len(data))
+	if *fail && len(suggestions) != 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/waziup/xlpp/pipeline"
+)
+
+// pipe passes a payload through a pipeline of transformations, see the pipeline package.
+func pipe(args []string) {
+	fs := flag.NewFlagSet("pipe", flag.ExitOnError)
+	stages := fs.String("p", "", "pipeline stages, e.g. 'drop 3; rename 5=1'")
+	file := fs.String("file", "", "read the pipeline stages from a file")
+	format := fs.String("f", "", "format, base64, hex or bin")
+	out := fs.String("o", "", "output format, base64, hex, bin or json (default: the input format)")
+	fs.Parse(args)
+
+	desc := *stages
+	if *file != "" {
+		data, err := ioutil.ReadFile(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		desc = string(data)
+	}
+	p, err := pipeline.Parse(desc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := p.Process(readPayload(fs.Arg(0), *format))
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch *out {
+	case "json":
+		os.Stdout.Write(xlpp2json(data, false))
+	case "":
+		os.Stdout.Write(formatPayload(data, *format))
+	default:
+		os.Stdout.Write(formatPayload(data, *out))
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/waziup/xlpp/xlpphttp"
+)
+
+// serve runs the REST codec service of xlpphttp.NewServeMux, so services in other languages can decode and encode
+// payloads over HTTP:
+//
+//	POST /decode  binary (application/xlpp, application/octet-stream) or base64 (application/xlpp+base64, text/plain) body -> JSON
+//	POST /encode  JSON (application/json) body -> binary, or the type of the Accept header
+//	GET  /openapi.json
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	fs.Parse(args)
+
+	s := &http.Server{
+		Addr:              *addr,
+		Handler:           xlpphttp.NewServeMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	logger.Printf("listening on %s", *addr)
+	log.Fatal(s.ListenAndServe())
+}
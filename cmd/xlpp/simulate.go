@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/waziup/xlpp/schema"
+	"github.com/waziup/xlpp/simulate"
+)
+
+// simulateDevice writes simulated payloads for the channels of a schema to stdout, one payload per line.
+func simulateDevice(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	file := fs.String("schema", "", "schema file of the simulated device")
+	interval := fs.Duration("interval", 10*time.Second, "time between payloads")
+	n := fs.Int("n", 0, "number of payloads, 0 runs forever")
+	fast := fs.Bool("fast", false, "do not wait, simulate the time between payloads")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "random seed")
+	format := fs.String("f", "", "format, base64, hex or bin")
+	fs.Parse(args)
+
+	s, err := schema.ParseFile(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sim := simulate.New(s, *seed)
+
+	count := 0
+	write := func(t time.Time, payload []byte) error {
+		os.Stdout.Write(formatPayload(payload, *format))
+		if *format != "bin" {
+			os.Stdout.Write([]byte{'\n'})
+		}
+		count++
+		if count == *n {
+			return errDone
+		}
+		return nil
+	}
+
+	if *fast {
+		for t := time.Now(); write(t, mustPayload(sim, t)) == nil; t = t.Add(*interval) {
+		}
+		return
+	}
+	if err := sim.Run(context.Background(), *interval, write); err != errDone {
+		log.Fatal(err)
+	}
+}
+
+// errDone stops the simulation after -n payloads.
+var errDone = errors.New("done")
+
+func mustPayload(sim *simulate.Simulator, t time.Time) []byte {
+	payload, err := sim.Payload(t)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return payload
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/fragment"
+)
+
+// split splits a payload into fragments of at most -max bytes with fragment.SplitEntries.
+// Each fragment is a valid XLPP payload on its own. Entries are never cut,
+// and the accumulated Delay is repeated at the start of every fragment so
+// that all values keep their historical context.
+func split(args []string) {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	max := fs.Int("max", 51, "max fragment size in bytes")
+	format := fs.String("f", "", "format, base64, hex or bin")
+	out := fs.String("o", "", "write fragments to files <o>0.xlpp, <o>1.xlpp, ... instead of stdout")
+	fs.Parse(args)
+
+	data := readPayload(fs.Arg(0), *format)
+	fragments, err := fragment.SplitEntries(data, *max)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i, f := range fragments {
+		if *out != "" {
+			name := fmt.Sprintf("%s%d.xlpp", *out, i)
+			if err := ioutil.WriteFile(name, formatPayload(f, *format), 0644); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+		os.Stdout.Write(formatPayload(f, *format))
+		if *format != "bin" {
+			os.Stdout.Write([]byte{'\n'})
+		}
+	}
+}
+
+// cat concatenates multiple payload files into a single payload.
+func cat(args []string) {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	format := fs.String("f", "", "format, base64, hex or bin")
+	fs.Parse(args)
+
+	var buf bytes.Buffer
+	for _, name := range fs.Args() {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data = parsePayload(data, *format)
+		r := xlpp.NewBytesReader(data)
+		for {
+			_, value, err := r.Next()
+			if err != nil {
+				log.Fatalf("can not read xlpp from %s: %v", name, err)
+			}
+			if value == nil {
+				break
+			}
+		}
+		buf.Write(data)
+	}
+	os.Stdout.Write(formatPayload(buf.Bytes(), *format))
+}
+
+// readPayload reads a payload from the argument, or from stdin if arg is empty.
+func readPayload(arg string, format string) []byte {
+	var data []byte
+	if arg != "" {
+		data = []byte(arg)
+	} else {
+		var err error
+		data, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	return parsePayload(data, format)
+}
+
+func parsePayload(data []byte, format string) []byte {
+	data, err := decodePayload(data, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
+// decodePayload decodes a payload in the format, base64, hex or bin.
+func decodePayload(data []byte, format string) ([]byte, error) {
+	switch format {
+	case "b64", "base64", "":
+		return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	case "bin":
+		return data, nil
+	case "hex":
+		return xlpp.DecodeHex(string(data))
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func formatPayload(data []byte, format string) []byte {
+	switch format {
+	case "b64", "base64", "":
+		return xlpp2base64(data)
+	case "bin":
+		return data
+	case "hex":
+		return xlpp2hex(data)
+	default:
+		log.Fatal("unknown format")
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/waziup/xlpp"
+)
+
+// streamJSON decodes one payload per line of r into one JSON document per line of w, e.g. for
+// `mosquitto_sub | xlpp -d -stream | jq`. Empty lines are skipped. Lines that can not be decoded are logged
+// and skipped, so a bad payload does not stop the stream. It reports whether all lines have been decoded.
+func streamJSON(r io.Reader, w io.Writer, format string, units, phy bool, appSKey string, opts ...xlpp.JSONOption) bool {
+	ok := true
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		doc, err := decodeLine(line, format, units, phy, appSKey, opts...)
+		if err != nil {
+			logger.Printf("%s: %v", line, err)
+			ok = false
+			continue
+		}
+		if _, err := w.Write(append(doc, '\n')); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		logger.Printf("%v", err)
+		return false
+	}
+	return ok
+}
+
+// decodeLine decodes a base64 or hex payload into a JSON document.
+func decodeLine(line, format string, units, phy bool, appSKey string, opts ...xlpp.JSONOption) ([]byte, error) {
+	data, err := decodePayload([]byte(line), format)
+	if err != nil {
+		return nil, err
+	}
+	if phy {
+		if data, err = decryptFRMPayload(data, appSKey); err != nil {
+			return nil, err
+		}
+	}
+	return decodeJSON(data, units, opts...)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// job is a request of the worker, one JSON document per line.
+type job struct {
+	ID interface{} `json:"id,omitempty"`
+	// Op is "decode" (base64 payload to JSON) or "encode" (JSON to base64 payload).
+	Op    string          `json:"op"`
+	Data  json.RawMessage `json:"data"`
+	Units bool            `json:"units,omitempty"`
+}
+
+// result is the response to a job.
+type result struct {
+	ID     interface{}     `json:"id,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// worker reads JSON jobs from stdin, one per line, and writes one result per line to stdout, in the same order:
+//
+//	{"id":1,"op":"decode","data":"AGcA6w=="}  ->  {"id":1,"result":{"temperature0":23.5}}
+//	{"id":2,"op":"encode","data":{"temperature0":23.5}}  ->  {"id":2,"result":"AGcA6w=="}
+//
+// It allows other processes to use xlpp as a persistent codec, without starting a process per payload.
+func worker(args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	fs.Parse(args)
+
+	s := bufio.NewScanner(os.Stdin)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	w := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(w)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var j job
+		var res result
+		if err := json.Unmarshal(s.Bytes(), &j); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.ID = j.ID
+			res.Result, err = j.run()
+			if err != nil {
+				res.Error = err.Error()
+			}
+		}
+		if err := enc.Encode(res); err != nil {
+			log.Fatal(err)
+		}
+		// flush after every result, the caller waits for it
+		if err := w.Flush(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (j *job) run() (json.RawMessage, error) {
+	switch j.Op {
+	case "decode":
+		var str string
+		if err := json.Unmarshal(j.Data, &str); err != nil {
+			return nil, fmt.Errorf("data: expected a base64 string")
+		}
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, err
+		}
+		return decodeJSON(data, j.Units)
+	case "encode":
+		data, err := encodeJSON(j.Data)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(base64.StdEncoding.EncodeToString(data))
+	}
+	return nil, fmt.Errorf("unknown op %q", j.Op)
+}
@@ -0,0 +1,85 @@
+package xlpp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// A Codec encodes and decodes XLPP payloads.
+// Alternative implementations of XLPP (e.g. bindings to the Arduino XLPP library) implement a Codec to verify their conformance with xlpptest.Conformance.
+type Codec interface {
+	// Encode encodes the message to a XLPP payload.
+	Encode(m Message) ([]byte, error)
+	// Decode decodes a XLPP payload to a message.
+	Decode(data []byte) (Message, error)
+}
+
+// ReferenceCodec is the Codec of this package, using a Writer to encode and a Reader to decode payloads.
+var ReferenceCodec Codec = referenceCodec{}
+
+type referenceCodec struct{}
+
+func (referenceCodec) Encode(m Message) ([]byte, error) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range m {
+		if _, err := w.Add(e.Channel, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (referenceCodec) Decode(data []byte) (Message, error) {
+	return NewBytesReader(data).ReadMessage()
+}
+
+// jsonEntry is the JSON representation of an Entry in MessageJSON.
+type jsonEntry struct {
+	Channel int    `json:"channel"`
+	Type    string `json:"type"`
+	Value   Value  `json:"value"`
+}
+
+// MessageJSON returns the JSON representation of the message that is used by the GoldenVectors:
+// a list of entries, each with the channel, the type name (see NameOf) and the value.
+func MessageJSON(m Message) ([]byte, error) {
+	entries := make([]jsonEntry, len(m))
+	for i, e := range m {
+		entries[i] = jsonEntry{Channel: e.Channel, Type: NameOf(e.Value), Value: e.Value}
+	}
+	return json.Marshal(entries)
+}
+
+// ParseMessageJSON parses the JSON representation of a message returned by MessageJSON.
+func ParseMessageJSON(data []byte) (m Message, err error) {
+	var entries []struct {
+		Channel int             `json:"channel"`
+		Type    string          `json:"type"`
+		Value   json.RawMessage `json:"value"`
+	}
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	m = make(Message, 0, len(entries))
+	for _, e := range entries {
+		v := newMarker(e.Channel)
+		switch {
+		case v != nil:
+			err = json.Unmarshal(e.Value, v)
+		default:
+			f := LookupName(e.Type)
+			if f == nil {
+				return nil, fmt.Errorf("xlpp: unknown type %q", e.Type)
+			}
+			v = f()
+			err = json.Unmarshal(e.Value, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xlpp: bad %s value %s: %v", e.Type, e.Value, err)
+		}
+		m = append(m, Entry{Channel: e.Channel, Value: v})
+	}
+	return m, nil
+}
@@ -0,0 +1,208 @@
+package xlpp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseColour parses a colour in the formats "#rrggbb", "#rgb", or a CSS colour name like "red" or "teal".
+// Names are not case sensitive.
+func ParseColour(s string) (c Colour, err error) {
+	if strings.HasPrefix(s, "#") {
+		switch len(s) {
+		case 7:
+			_, err = fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+			return
+		case 4:
+			_, err = fmt.Sscanf(s, "#%01x%01x%01x", &c.R, &c.G, &c.B)
+			c.R, c.G, c.B = c.R*0x11, c.G*0x11, c.B*0x11
+			return
+		}
+		return c, fmt.Errorf("xlpp: invalid colour %q, want #rrggbb or #rgb", s)
+	}
+	name := strings.ToLower(s)
+	for _, n := range colourNames {
+		if n.name == name {
+			return n.colour, nil
+		}
+	}
+	return c, fmt.Errorf("xlpp: unknown colour %q", s)
+}
+
+// Name returns the CSS colour name of the Colour, or an empty string if it has none.
+func (v Colour) Name() string {
+	for _, n := range colourNames {
+		if n.colour == v {
+			return n.name
+		}
+	}
+	return ""
+}
+
+// NearestName returns the CSS colour name that is nearest to the Colour.
+func (v Colour) NearestName() string {
+	var nearest string
+	min := -1
+	for _, n := range colourNames {
+		dr, dg, db := int(v.R)-int(n.colour.R), int(v.G)-int(n.colour.G), int(v.B)-int(n.colour.B)
+		if d := dr*dr + dg*dg + db*db; min == -1 || d < min {
+			nearest, min = n.name, d
+		}
+	}
+	return nearest
+}
+
+// colourNames are the CSS colour names, in alphabetical order.
+var colourNames = []struct {
+	name   string
+	colour Colour
+}{
+	{"aliceblue", Colour{240, 248, 255}},
+	{"antiquewhite", Colour{250, 235, 215}},
+	{"aqua", Colour{0, 255, 255}},
+	{"aquamarine", Colour{127, 255, 212}},
+	{"azure", Colour{240, 255, 255}},
+	{"beige", Colour{245, 245, 220}},
+	{"bisque", Colour{255, 228, 196}},
+	{"black", Colour{0, 0, 0}},
+	{"blanchedalmond", Colour{255, 235, 205}},
+	{"blue", Colour{0, 0, 255}},
+	{"blueviolet", Colour{138, 43, 226}},
+	{"brown", Colour{165, 42, 42}},
+	{"burlywood", Colour{222, 184, 135}},
+	{"cadetblue", Colour{95, 158, 160}},
+	{"chartreuse", Colour{127, 255, 0}},
+	{"chocolate", Colour{210, 105, 30}},
+	{"coral", Colour{255, 127, 80}},
+	{"cornflowerblue", Colour{100, 149, 237}},
+	{"cornsilk", Colour{255, 248, 220}},
+	{"crimson", Colour{220, 20, 60}},
+	{"cyan", Colour{0, 255, 255}},
+	{"darkblue", Colour{0, 0, 139}},
+	{"darkcyan", Colour{0, 139, 139}},
+	{"darkgoldenrod", Colour{184, 134, 11}},
+	{"darkgray", Colour{169, 169, 169}},
+	{"darkgreen", Colour{0, 100, 0}},
+	{"darkgrey", Colour{169, 169, 169}},
+	{"darkkhaki", Colour{189, 183, 107}},
+	{"darkmagenta", Colour{139, 0, 139}},
+	{"darkolivegreen", Colour{85, 107, 47}},
+	{"darkorange", Colour{255, 140, 0}},
+	{"darkorchid", Colour{153, 50, 204}},
+	{"darkred", Colour{139, 0, 0}},
+	{"darksalmon", Colour{233, 150, 122}},
+	{"darkseagreen", Colour{143, 188, 143}},
+	{"darkslateblue", Colour{72, 61, 139}},
+	{"darkslategray", Colour{47, 79, 79}},
+	{"darkslategrey", Colour{47, 79, 79}},
+	{"darkturquoise", Colour{0, 206, 209}},
+	{"darkviolet", Colour{148, 0, 211}},
+	{"deeppink", Colour{255, 20, 147}},
+	{"deepskyblue", Colour{0, 191, 255}},
+	{"dimgray", Colour{105, 105, 105}},
+	{"dimgrey", Colour{105, 105, 105}},
+	{"dodgerblue", Colour{30, 144, 255}},
+	{"firebrick", Colour{178, 34, 34}},
+	{"floralwhite", Colour{255, 250, 240}},
+	{"forestgreen", Colour{34, 139, 34}},
+	{"fuchsia", Colour{255, 0, 255}},
+	{"gainsboro", Colour{220, 220, 220}},
+	{"ghostwhite", Colour{248, 248, 255}},
+	{"gold", Colour{255, 215, 0}},
+	{"goldenrod", Colour{218, 165, 32}},
+	{"gray", Colour{128, 128, 128}},
+	{"green", Colour{0, 128, 0}},
+	{"greenyellow", Colour{173, 255, 47}},
+	{"grey", Colour{128, 128, 128}},
+	{"honeydew", Colour{240, 255, 240}},
+	{"hotpink", Colour{255, 105, 180}},
+	{"indianred", Colour{205, 92, 92}},
+	{"indigo", Colour{75, 0, 130}},
+	{"ivory", Colour{255, 255, 240}},
+	{"khaki", Colour{240, 230, 140}},
+	{"lavender", Colour{230, 230, 250}},
+	{"lavenderblush", Colour{255, 240, 245}},
+	{"lawngreen", Colour{124, 252, 0}},
+	{"lemonchiffon", Colour{255, 250, 205}},
+	{"lightblue", Colour{173, 216, 230}},
+	{"lightcoral", Colour{240, 128, 128}},
+	{"lightcyan", Colour{224, 255, 255}},
+	{"lightgoldenrodyellow", Colour{250, 250, 210}},
+	{"lightgray", Colour{211, 211, 211}},
+	{"lightgreen", Colour{144, 238, 144}},
+	{"lightgrey", Colour{211, 211, 211}},
+	{"lightpink", Colour{255, 182, 193}},
+	{"lightsalmon", Colour{255, 160, 122}},
+	{"lightseagreen", Colour{32, 178, 170}},
+	{"lightskyblue", Colour{135, 206, 250}},
+	{"lightslategray", Colour{119, 136, 153}},
+	{"lightslategrey", Colour{119, 136, 153}},
+	{"lightsteelblue", Colour{176, 196, 222}},
+	{"lightyellow", Colour{255, 255, 224}},
+	{"lime", Colour{0, 255, 0}},
+	{"limegreen", Colour{50, 205, 50}},
+	{"linen", Colour{250, 240, 230}},
+	{"magenta", Colour{255, 0, 255}},
+	{"maroon", Colour{128, 0, 0}},
+	{"mediumaquamarine", Colour{102, 205, 170}},
+	{"mediumblue", Colour{0, 0, 205}},
+	{"mediumorchid", Colour{186, 85, 211}},
+	{"mediumpurple", Colour{147, 112, 219}},
+	{"mediumseagreen", Colour{60, 179, 113}},
+	{"mediumslateblue", Colour{123, 104, 238}},
+	{"mediumspringgreen", Colour{0, 250, 154}},
+	{"mediumturquoise", Colour{72, 209, 204}},
+	{"mediumvioletred", Colour{199, 21, 133}},
+	{"midnightblue", Colour{25, 25, 112}},
+	{"mintcream", Colour{245, 255, 250}},
+	{"mistyrose", Colour{255, 228, 225}},
+	{"moccasin", Colour{255, 228, 181}},
+	{"navajowhite", Colour{255, 222, 173}},
+	{"navy", Colour{0, 0, 128}},
+	{"oldlace", Colour{253, 245, 230}},
+	{"olive", Colour{128, 128, 0}},
+	{"olivedrab", Colour{107, 142, 35}},
+	{"orange", Colour{255, 165, 0}},
+	{"orangered", Colour{255, 69, 0}},
+	{"orchid", Colour{218, 112, 214}},
+	{"palegoldenrod", Colour{238, 232, 170}},
+	{"palegreen", Colour{152, 251, 152}},
+	{"paleturquoise", Colour{175, 238, 238}},
+	{"palevioletred", Colour{219, 112, 147}},
+	{"papayawhip", Colour{255, 239, 213}},
+	{"peachpuff", Colour{255, 218, 185}},
+	{"peru", Colour{205, 133, 63}},
+	{"pink", Colour{255, 192, 203}},
+	{"plum", Colour{221, 160, 221}},
+	{"powderblue", Colour{176, 224, 230}},
+	{"purple", Colour{128, 0, 128}},
+	{"rebeccapurple", Colour{102, 51, 153}},
+	{"red", Colour{255, 0, 0}},
+	{"rosybrown", Colour{188, 143, 143}},
+	{"royalblue", Colour{65, 105, 225}},
+	{"saddlebrown", Colour{139, 69, 19}},
+	{"salmon", Colour{250, 128, 114}},
+	{"sandybrown", Colour{244, 164, 96}},
+	{"seagreen", Colour{46, 139, 87}},
+	{"seashell", Colour{255, 245, 238}},
+	{"sienna", Colour{160, 82, 45}},
+	{"silver", Colour{192, 192, 192}},
+	{"skyblue", Colour{135, 206, 235}},
+	{"slateblue", Colour{106, 90, 205}},
+	{"slategray", Colour{112, 128, 144}},
+	{"slategrey", Colour{112, 128, 144}},
+	{"snow", Colour{255, 250, 250}},
+	{"springgreen", Colour{0, 255, 127}},
+	{"steelblue", Colour{70, 130, 180}},
+	{"tan", Colour{210, 180, 140}},
+	{"teal", Colour{0, 128, 128}},
+	{"thistle", Colour{216, 191, 216}},
+	{"tomato", Colour{255, 99, 71}},
+	{"turquoise", Colour{64, 224, 208}},
+	{"violet", Colour{238, 130, 238}},
+	{"wheat", Colour{245, 222, 179}},
+	{"white", Colour{255, 255, 255}},
+	{"whitesmoke", Colour{245, 245, 245}},
+	{"yellow", Colour{255, 255, 0}},
+	{"yellowgreen", Colour{154, 205, 50}},
+}
@@ -0,0 +1,129 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// TypeScheduledCommand is the type of ScheduledCommand.
+const TypeScheduledCommand Type = 75 // uvarint delay [s], followed by the type and value of the command
+
+var errCommandNested = errors.New("xlpp: ScheduledCommand requires a value that is not an Object, Array, marker or ScheduledCommand")
+
+// A ScheduledCommand is a downlink command that the device executes after a delay, e.g. to close an irrigation valve in 20 minutes.
+// The channel of the entry is the channel of the actuator, and the Value is the command, e.g. a *DigitalOutput or *Switch.
+type ScheduledCommand struct {
+	// Delay is the time after reception of the downlink when the command is executed, with 1s resolution.
+	Delay time.Duration
+	Value Value
+}
+
+// XLPPType for ScheduledCommand returns TypeScheduledCommand.
+func (v ScheduledCommand) XLPPType() Type {
+	return TypeScheduledCommand
+}
+
+func (v ScheduledCommand) String() string {
+	if v.Value == nil {
+		return fmt.Sprintf("<nil> in %s", v.Delay)
+	}
+	return fmt.Sprintf("%s %v in %s", NameOf(v.Value), v.Value, v.Delay)
+}
+
+// At returns the execution time of the command, for a downlink received at the given time.
+func (v ScheduledCommand) At(received time.Time) time.Time {
+	return received.Add(v.Delay)
+}
+
+// ReadFrom reads the ScheduledCommand from the reader.
+func (v *ScheduledCommand) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	delay, err := binary.ReadUvarint(&brc)
+	n = int64(brc.Count)
+	if err != nil {
+		return
+	}
+	v.Delay = time.Duration(delay) * time.Second
+	var t [1]byte
+	m, err := readFrom(r, t[:])
+	n += m
+	if err != nil {
+		return n, toErr(err)
+	}
+	if !commandType(Type(t[0])) {
+		return n, errCommandNested
+	}
+	v.Value, m, err = readValue(r, Type(t[0]))
+	n += m
+	return
+}
+
+// WriteTo writes the ScheduledCommand to the writer.
+func (v ScheduledCommand) WriteTo(w io.Writer) (n int64, err error) {
+	if _, ok := v.Value.(Marker); ok || v.Value == nil || !commandType(v.Value.XLPPType()) {
+		return 0, errCommandNested
+	}
+	var buf [binary.MaxVarintLen64]byte
+	m, err := writeTo(w, buf[:binary.PutUvarint(buf[:], uint64(v.Delay/time.Second))])
+	n = int64(m)
+	if err == nil {
+		m, err = write(w, v.Value)
+		n += int64(m)
+	}
+	return
+}
+
+// commandType reports whether values of type t can be commands of a ScheduledCommand.
+func commandType(t Type) bool {
+	switch t {
+	case TypeObject, TypeArray, TypeArrayOf, TypeEndOfObject, TypeEndOfArray, TypeScheduledCommand:
+		return false
+	}
+	return true
+}
+
+type scheduledCommandJSON struct {
+	Delay float64         `json:"delay"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MarshalJSON marshals the ScheduledCommand as {"delay":1200,"type":"digitaloutput","value":...}, with the delay in seconds.
+// A ScheduledCommand without Value has the type "" and the value null.
+func (v ScheduledCommand) MarshalJSON() ([]byte, error) {
+	if v.Value == nil {
+		return json.Marshal(scheduledCommandJSON{v.Delay.Seconds(), "", json.RawMessage("null")})
+	}
+	value, err := json.Marshal(v.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(scheduledCommandJSON{v.Delay.Seconds(), NameOf(v.Value), value})
+}
+
+// UnmarshalJSON unmarshals the ScheduledCommand from {"delay":1200,"type":"digitaloutput","value":...}.
+func (v *ScheduledCommand) UnmarshalJSON(data []byte) error {
+	var s scheduledCommandJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	f := LookupName(s.Type)
+	if f == nil {
+		return fmt.Errorf("xlpp: unknown ScheduledCommand type %q", s.Type)
+	}
+	value := f()
+	if !commandType(value.XLPPType()) {
+		return errCommandNested
+	}
+	if err := json.Unmarshal(s.Value, value); err != nil {
+		return err
+	}
+	v.Delay = time.Duration(s.Delay * float64(time.Second))
+	v.Value = value
+	return nil
+}
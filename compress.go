@@ -0,0 +1,95 @@
+package xlpp
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// ChanCompressed is the first byte of a compressed frame, see WithWriterCompression.
+// It is a free channel: only Readers with WithDecompression read it as start of a compressed frame,
+// and only as the first byte of the payload.
+const ChanCompressed = 248
+
+// MaxDecompressedSize is the max. size of the decompressed data of a compressed frame.
+// Larger frames are rejected with a *LimitError, so that small payloads can not expand into huge allocations.
+const MaxDecompressedSize = 64 << 10
+
+var errCompressedTrailer = errors.New("xlpp: data after compressed frame")
+
+// WithWriterCompression makes the Writer compress the payload into a compressed frame:
+// the byte ChanCompressed 248 followed by the DEFLATE (RFC 1951) compressed XLPP data, that Readers with WithDecompression read.
+// Added entries are buffered and written by Flush. If compression does not make the payload smaller, it is written uncompressed.
+// Payloads with many repeated channels and types, e.g. history uploads, usually compress well.
+// Channel 248 can not be used with WithWriterCompression, as an uncompressed payload could start with it.
+func WithWriterCompression() WriterOption {
+	return func(w *Writer) {
+		w.compress = new(compressor)
+	}
+}
+
+// WithDecompression makes the Reader decompress compressed frames, see WithWriterCompression.
+// Payloads that start with ChanCompressed 248 are decompressed, so values on channel 248 can not be the first entry.
+// Without WithDecompression, 248 is a free channel like all channels below 250.
+func WithDecompression() ReaderOption {
+	return func(r *Reader) {
+		r.decompress = true
+	}
+}
+
+// compressor buffers the payload of a Writer with WithWriterCompression.
+type compressor struct {
+	buf bytes.Buffer
+	w   io.Writer
+}
+
+// flush writes the buffered payload, compressed if that makes it smaller.
+func (c *compressor) flush(l Logger) (n int, err error) {
+	defer c.buf.Reset()
+	var compressed bytes.Buffer
+	compressed.WriteByte(ChanCompressed)
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(c.buf.Bytes())
+	fw.Close()
+	if compressed.Len() < c.buf.Len() {
+		return writeTo(c.w, compressed.Bytes())
+	}
+	l.Printf("xlpp: compressed frame of %d bytes is not smaller than the payload, writing %d bytes uncompressed", compressed.Len(), c.buf.Len())
+	return writeTo(c.w, c.buf.Bytes())
+}
+
+// compressed reports whether the channel byte at the offset start is the start of a compressed frame.
+func (r *Reader) compressed(channel int, start int64) bool {
+	return channel == ChanCompressed && start == 0 && r.decompress && !r.inflated && !r.strictLPP
+}
+
+// inflate decompresses the compressed frame that starts the payload,
+// and makes the Reader read the decompressed data. Offsets of later errors refer to the decompressed data.
+func (r *Reader) inflate() error {
+	fr := flate.NewReader(r.r)
+	data, err := ioutil.ReadAll(io.LimitReader(fr, MaxDecompressedSize+1))
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if len(data) > MaxDecompressedSize {
+		return &LimitError{Limit: "MaxDecompressedSize", Max: MaxDecompressedSize}
+	}
+	if _, err := r.r.ReadByte(); err != io.EOF {
+		return errCompressedTrailer
+	}
+	r.inflated = true
+	r.consumed = 0
+	r.b = bytesSource{data: data}
+	var src source = &r.b
+	if len(r.hooks) != 0 {
+		r.rec = &recordingSource{source: src}
+		src = r.rec
+	}
+	r.setSource(src)
+	return nil
+}
@@ -0,0 +1,110 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// CRCSize is the size of the CRC trailer, see WithCRC.
+const CRCSize = 2
+
+// ErrCRC is returned by a Reader with WithCRC for a payload with a wrong or missing CRC trailer.
+var ErrCRC = errors.New("xlpp: CRC mismatch")
+
+// CRC16 returns the CRC-16/CCITT (polynomial 0x1021, initial value 0xffff, also known as CRC-16/CCITT-FALSE) of data.
+func CRC16(data []byte) uint16 {
+	return updateCRC16(0xffff, data)
+}
+
+func updateCRC16(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// WithCRC makes the Reader verify the CRC trailer of the payload, that has been written with WithWriterCRC:
+// the last two bytes are the CRC16 of the payload, big endian. A payload with a wrong CRC fails with ErrCRC
+// before any value is decoded, so corrupted frames never decode into garbage values.
+// The Reader reads the whole payload into memory before decoding, so use it for single frames, not for streams.
+func WithCRC() ReaderOption {
+	return func(r *Reader) {
+		r.crc = true
+	}
+}
+
+// readCRC reads the payload of a Reader with WithCRC into memory and verifies it.
+func (r *Reader) readCRC(src source) {
+	if src != &r.b {
+		data, err := ioutil.ReadAll(src)
+		if err != nil {
+			r.setSource(errSource{err})
+			return
+		}
+		r.b = bytesSource{data: data}
+	}
+	r.verifyCRC()
+}
+
+// verifyCRC verifies the CRC trailer of the in-memory payload, and strips it.
+func (r *Reader) verifyCRC() {
+	data := r.b.data
+	if len(data) < CRCSize || CRC16(data[:len(data)-CRCSize]) != binary.BigEndian.Uint16(data[len(data)-CRCSize:]) {
+		r.setSource(errSource{ErrCRC})
+		return
+	}
+	r.b.data = data[:len(data)-CRCSize]
+	r.setSource(&r.b)
+}
+
+func (r *Reader) setSource(src source) {
+	r.r = src
+	r.d.source = src
+}
+
+// errSource is a source that fails with err.
+type errSource struct {
+	err error
+}
+
+func (s errSource) Read(p []byte) (int, error)           { return 0, s.err }
+func (s errSource) ReadByte() (byte, error)              { return 0, s.err }
+func (s errSource) ReadSlice(delim byte) ([]byte, error) { return nil, s.err }
+func (s errSource) Buffered() int                        { return 0 }
+
+// WithWriterCRC makes the Writer append a CRC trailer to the payload, that Readers with WithCRC verify.
+// The trailer is written by Flush, which ends the frame: values added after Flush start a new frame.
+func WithWriterCRC() WriterOption {
+	return func(w *Writer) {
+		w.crc = &crcWriter{crc: 0xffff}
+	}
+}
+
+// crcWriter computes the CRC16 of the bytes written to w.
+type crcWriter struct {
+	w   io.Writer
+	crc uint16
+}
+
+func (c *crcWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.crc = updateCRC16(c.crc, p[:n])
+	return
+}
+
+// writeTrailer writes the CRC trailer and starts a new frame.
+func (c *crcWriter) writeTrailer() (n int, err error) {
+	var b [CRCSize]byte
+	binary.BigEndian.PutUint16(b[:], c.crc)
+	c.crc = 0xffff
+	return writeTo(c.w, b[:])
+}
@@ -0,0 +1,261 @@
+package xlpp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/waziup/xlpp"
+)
+
+// A differential is another implementation of (X)LPP that is compared with this package.
+// The implementation runs as a subprocess that handles one golden vector per line:
+//   - decoders read a hex payload and write the decoded entries in the MessageJSON format,
+//   - encoders read the entries as "channel type field field ...", separated by ";", and write the hex payload.
+//
+// Both write a line starting with "error" for vectors they do not support.
+type differential struct {
+	name string
+	// env is the environment variable that enables the implementation.
+	env string
+	// command returns the command line of the implementation for the env variable value.
+	command func(env string) []string
+	encoder bool
+}
+
+var differentials = []differential{
+	{
+		// XLPP_CAYENNE_DECODER is the path to a Cayenne LPP JavaScript decoder, e.g. the TTN payload formatter
+		// or testdata/differential/lpp-decoder.js, whose output is recorded.
+		name: "cayenne-js",
+		env:  "XLPP_CAYENNE_DECODER",
+		command: func(env string) []string {
+			return []string{"node", "testdata/differential/cayenne.js", env}
+		},
+	},
+	{
+		// XLPP_ARDUINO_ENCODER is the path to testdata/differential/arduino.cpp compiled with the Arduino XLPP library.
+		name: "arduino-xlpp",
+		env:  "XLPP_ARDUINO_ENCODER",
+		command: func(env string) []string {
+			return []string{env}
+		},
+		encoder: true,
+	},
+}
+
+// TestDifferential compares the decoded golden vectors with other implementations.
+// An implementation is run if its env variable is set, or compared with its recorded output from testdata/differential/<name>.out otherwise.
+// Use -update to record the output.
+func TestDifferential(t *testing.T) {
+	for _, d := range differentials {
+		d := d
+		t.Run(d.name, func(t *testing.T) {
+			vectors, input := differentialInput(t, d)
+			output := d.run(t, vectors, input)
+			for i, v := range vectors {
+				out := output[v.Name]
+				if strings.HasPrefix(out, "error") {
+					t.Logf("%s: not supported: %s", v.Name, out)
+					continue
+				}
+				if d.encoder {
+					if out != v.Payload {
+						t.Errorf("%s: encoded %s\n got: %s\nwant: %s", v.Name, input[i], out, v.Payload)
+					}
+					continue
+				}
+				got, err := decodeEntries(out)
+				if err != nil {
+					t.Errorf("%s: %v", v.Name, err)
+					continue
+				}
+				want := goldenMessage(t, v)
+				if !equalEntries(got, want) {
+					t.Errorf("%s: decoded %s\n got: %s\nwant: %s", v.Name, v.Payload, out, v.JSON)
+				}
+			}
+		})
+	}
+}
+
+// run runs the implementation with the input lines and returns its output per vector name.
+func (d differential) run(t *testing.T, vectors []xlpp.GoldenVector, input []string) map[string]string {
+	file := "testdata/differential/" + d.name + ".out"
+	output := make(map[string]string, len(vectors))
+
+	env := os.Getenv(d.env)
+	if env == "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Skipf("set %s to run %s", d.env, d.name)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			if i := strings.IndexByte(line, '\t'); i != -1 {
+				output[line[:i]] = line[i+1:]
+			}
+		}
+		return output
+	}
+
+	args := d.command(env)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(strings.Join(input, "\n") + "\n")
+	cmd.Stderr = os.Stderr
+	data, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("can not run %s: %v", d.name, err)
+	}
+	var record bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for _, v := range vectors {
+		if !scanner.Scan() {
+			t.Fatalf("%s: missing output for %s", d.name, v.Name)
+		}
+		output[v.Name] = scanner.Text()
+		fmt.Fprintf(&record, "%s\t%s\n", v.Name, scanner.Text())
+	}
+	if *updateGolden {
+		if err := ioutil.WriteFile(file, record.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return output
+}
+
+// differentialInput returns the vectors supported by the implementation and their input lines.
+func differentialInput(t *testing.T, d differential) (vectors []xlpp.GoldenVector, input []string) {
+	for _, v := range xlpp.GoldenVectors {
+		if !d.encoder {
+			vectors = append(vectors, v)
+			input = append(input, v.Payload)
+			continue
+		}
+		if !v.Canonical {
+			continue
+		}
+		var entries []string
+		for _, e := range goldenMessage(t, v) {
+			f, ok := fields(e.Value)
+			if !ok {
+				entries = nil
+				break
+			}
+			entries = append(entries, strconv.Itoa(e.Channel)+" "+xlpp.NameOf(e.Value)+" "+strings.Join(f, " "))
+		}
+		if entries != nil {
+			vectors = append(vectors, v)
+			input = append(input, strings.Join(entries, ";"))
+		}
+	}
+	return
+}
+
+// fields returns the numeric fields of a value, if the value consists of numbers only.
+func fields(v xlpp.Value) (f []string, ok bool) {
+	if d, ok := v.(*xlpp.Delay); ok {
+		return []string{strconv.Itoa(d.Hours()), strconv.Itoa(d.Minutes()), strconv.Itoa(d.Seconds())}, true
+	}
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() == reflect.Struct {
+		for i := 0; i < rv.NumField(); i++ {
+			s, ok := number(rv.Field(i))
+			if !ok {
+				return nil, false
+			}
+			f = append(f, s)
+		}
+		return f, len(f) != 0
+	}
+	s, ok := number(rv)
+	if !ok {
+		return nil, false
+	}
+	return []string{s}, true
+}
+
+func number(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
+
+func goldenMessage(t *testing.T, v xlpp.GoldenVector) xlpp.Message {
+	payload, _ := hex.DecodeString(v.Payload)
+	m, err := xlpp.ReferenceCodec.Decode(payload)
+	if err != nil {
+		t.Fatalf("%s: %v", v.Name, err)
+	}
+	return m
+}
+
+// decodeEntries decodes entries in the MessageJSON format.
+func decodeEntries(data string) (m xlpp.Message, err error) {
+	var entries []struct {
+		Channel int
+		Type    string
+		Value   json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(data), &entries); err != nil {
+		return nil, fmt.Errorf("bad output %q: %v", data, err)
+	}
+	for _, e := range entries {
+		var v xlpp.Value
+		switch e.Type {
+		case "delay":
+			// nanoseconds
+			v = new(xlpp.Delay)
+			err = json.Unmarshal(e.Value, v)
+		default:
+			f, ok := xlpp.RegistryByName[e.Type]
+			if !ok {
+				return nil, fmt.Errorf("unknown type %q", e.Type)
+			}
+			v = f()
+			err = json.Unmarshal(e.Value, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad %s value %s: %v", e.Type, e.Value, err)
+		}
+		m = append(m, xlpp.Entry{Channel: e.Channel, Value: v})
+	}
+	return
+}
+
+// equalEntries reports whether a and b hold the same entries, ignoring the order.
+// The values are compared by their encoding, so that rounding differences below the type resolution are ignored.
+func equalEntries(a, b xlpp.Message) bool {
+	return reflect.DeepEqual(encodeEntries(a), encodeEntries(b))
+}
+
+func encodeEntries(m xlpp.Message) []string {
+	s := make([]string, len(m))
+	for i, e := range m {
+		var buf bytes.Buffer
+		xlpp.NewWriter(&buf).Add(e.Channel, e.Value)
+		s[i] = buf.String()
+	}
+	sort.Strings(s)
+	return s
+}
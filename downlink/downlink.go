@@ -0,0 +1,130 @@
+// Package downlink splits actuator commands into downlink payloads and tracks their acknowledgements.
+//
+// Downlinks are small: large device reconfigurations often need multiple downlinks. A Scheduler packs the commands
+// into payloads of at most the max. downlink size, ordered by priority, and tags each payload with an ActuatorAck marker.
+// Devices echo the marker in an uplink, and Ack removes the acknowledged downlinks from the pending downlinks.
+package downlink
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/waziup/xlpp"
+)
+
+// MaxSize is the max. application payload size of a downlink at the lowest data rate of some LoRaWAN regions,
+// which is the size that fits in all conditions.
+var MaxSize = map[string]int{
+	"EU868": 51,
+	"IN865": 51,
+	"KR920": 51,
+	"US915": 53,
+	"AU915": 53,
+}
+
+// A Command is an actuator command.
+type Command struct {
+	Channel int
+	Value   xlpp.Value
+	// Priority orders the commands: commands with a higher priority are sent first.
+	Priority int
+}
+
+// A Downlink is a downlink payload with the commands it holds.
+type Downlink struct {
+	Seq      uint32
+	Payload  []byte
+	Commands []Command
+}
+
+// A Scheduler splits commands into downlinks and tracks which downlinks have been acknowledged.
+// It is safe for concurrent use.
+type Scheduler struct {
+	mu      sync.Mutex
+	seq     uint32
+	pending []*Downlink
+}
+
+// NewScheduler creates a Scheduler. The first downlink has the sequence number seq,
+// e.g. a number that has been stored before a restart.
+func NewScheduler(seq uint32) *Scheduler {
+	return &Scheduler{seq: seq}
+}
+
+// Schedule splits the commands into downlinks of at most maxSize bytes, ordered by priority,
+// and adds them to the pending downlinks.
+// Commands with the same priority keep their order. It fails if a single command does not fit into a downlink.
+func (s *Scheduler) Schedule(commands []Command, maxSize int) ([]*Downlink, error) {
+	sorted := make([]Command, len(commands))
+	copy(sorted, commands)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var downlinks []*Downlink
+	var d *Downlink
+	var buf, entry bytes.Buffer
+	for _, c := range sorted {
+		entry.Reset()
+		if _, err := xlpp.NewWriter(&entry).AddActuation(c.Channel, c.Value); err != nil {
+			return nil, err
+		}
+		if d != nil && buf.Len()+entry.Len() > maxSize {
+			d.Payload = append([]byte(nil), buf.Bytes()...)
+			d = nil
+		}
+		if d == nil {
+			d = &Downlink{Seq: s.seq + uint32(len(downlinks))}
+			downlinks = append(downlinks, d)
+			buf.Reset()
+			ack := xlpp.ActuatorAck(d.Seq)
+			xlpp.NewWriter(&buf).Add(xlpp.ChanActuatorAck, &ack)
+		}
+		if buf.Len()+entry.Len() > maxSize {
+			return nil, fmt.Errorf("downlink: command %v on channel %d does not fit into %d bytes", c.Value, c.Channel, maxSize)
+		}
+		buf.Write(entry.Bytes())
+		d.Commands = append(d.Commands, c)
+	}
+	if d != nil {
+		d.Payload = append([]byte(nil), buf.Bytes()...)
+	}
+	s.seq += uint32(len(downlinks))
+	s.pending = append(s.pending, downlinks...)
+	return downlinks, nil
+}
+
+// Pending returns the downlinks that have not been acknowledged yet, in the order they have been scheduled.
+func (s *Scheduler) Pending() []*Downlink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Downlink(nil), s.pending...)
+}
+
+// Ack removes the downlinks acknowledged by the ActuatorAck markers of an uplink from the pending downlinks,
+// and returns them.
+func (s *Scheduler) Ack(uplink xlpp.Message) []*Downlink {
+	acks := make(map[uint32]bool)
+	for _, e := range uplink {
+		if a, ok := e.Value.(*xlpp.ActuatorAck); ok {
+			acks[uint32(*a)] = true
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var acked []*Downlink
+	pending := s.pending[:0]
+	for _, d := range s.pending {
+		if acks[d.Seq] {
+			acked = append(acked, d)
+		} else {
+			pending = append(pending, d)
+		}
+	}
+	s.pending = pending
+	return acked
+}
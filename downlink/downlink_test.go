@@ -0,0 +1,53 @@
+package downlink_test
+
+import (
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/downlink"
+)
+
+func TestScheduler(t *testing.T) {
+	var commands []downlink.Command
+	for i := 0; i < 30; i++ {
+		v := xlpp.AnalogOutput(float64(i))
+		commands = append(commands, downlink.Command{Channel: i, Value: &v})
+	}
+	on := xlpp.Switch(true)
+	commands = append(commands, downlink.Command{Channel: 99, Value: &on, Priority: 1})
+
+	s := downlink.NewScheduler(7)
+	downlinks, err := s.Schedule(commands, downlink.MaxSize["EU868"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 bytes ack marker, 3 bytes switch, 4 bytes per analog output
+	if len(downlinks) != 3 || len(downlinks[0].Commands) != 12 || downlinks[0].Commands[0].Channel != 99 {
+		t.Fatalf("%d downlinks", len(downlinks))
+	}
+	for i, d := range downlinks {
+		if len(d.Payload) > 51 || d.Seq != uint32(7+i) {
+			t.Fatalf("downlink %d: seq %d, %d bytes", i, d.Seq, len(d.Payload))
+		}
+		m, err := xlpp.NewBytesReader(d.Payload).ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ack, ok := m[0].Value.(*xlpp.ActuatorAck); !ok || uint32(*ack) != d.Seq || len(m) != len(d.Commands)+1 {
+			t.Fatalf("downlink %d: %v", i, m)
+		}
+	}
+
+	ack7, ack9 := xlpp.ActuatorAck(7), xlpp.ActuatorAck(9)
+	acked := s.Ack(xlpp.Message{{Channel: xlpp.ChanActuatorAck, Value: &ack7}, {Channel: xlpp.ChanActuatorAck, Value: &ack9}})
+	if len(acked) != 2 {
+		t.Fatalf("%d acked", len(acked))
+	}
+	if pending := s.Pending(); len(pending) != 1 || pending[0].Seq != 8 {
+		t.Fatalf("pending: %v", pending)
+	}
+
+	if _, err := s.Schedule([]downlink.Command{{Channel: 1, Value: &on}}, 4); err == nil {
+		t.Fatal("expected error for command that does not fit")
+	}
+}
@@ -0,0 +1,56 @@
+package xlpp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrReservedChannel is returned by Writers for values that are added on a reserved marker channel (250 to 255),
+// as decoders would read them as markers.
+var ErrReservedChannel = errors.New("xlpp: reserved channel")
+
+// An UnknownTypeError reports a value of a type that is neither in the Registry nor in the TypeRegistry of the Reader,
+// e.g. a private type of another vendor or a corrupt payload.
+type UnknownTypeError struct {
+	Type Type
+	// Offset is the payload offset of the type byte, or -1 if the value was not read by a Reader.
+	Offset int64
+}
+
+func (e *UnknownTypeError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("xlpp: unregistered XLPP type 0x%02x", e.Type)
+	}
+	return fmt.Sprintf("xlpp: unregistered XLPP type 0x%02x at offset %d", e.Type, e.Offset)
+}
+
+// A TruncatedError reports a payload that ends in the middle of an entry. It wraps io.ErrUnexpectedEOF.
+type TruncatedError struct {
+	// Offset is the payload offset of the truncated entry (its channel byte),
+	// or of the truncated token for Reader.Token.
+	Offset int64
+	Err    error
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("xlpp: truncated entry at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *TruncatedError) Unwrap() error {
+	return e.Err
+}
+
+// offsetError adds the payload offsets to the error of reading the entry or token from start to end (exclusive).
+// Unknown types are always the last byte read, as the Reader stops at them.
+func offsetError(err error, start, end int64) error {
+	var u *UnknownTypeError
+	if errors.As(err, &u) && u.Offset < 0 {
+		u.Offset = end - 1
+	}
+	var t *TruncatedError
+	if errors.Is(err, io.ErrUnexpectedEOF) && !errors.As(err, &t) {
+		err = &TruncatedError{Offset: start, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,244 @@
+package xlpp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// A Formatter formats values as human readable text.
+// The zero Formatter formats values like their String methods, with the physical unit of the type (see Type.Unit).
+type Formatter struct {
+	// Precision is the number of decimals of floating point numbers.
+	// Zero uses the default precision of the type, a negative Precision formats numbers without decimals.
+	Precision int
+	// NoUnits omits the physical units.
+	NoUnits bool
+	// DecimalSeparator replaces the decimal point, e.g. "," for most European locales.
+	// An empty DecimalSeparator uses ".".
+	DecimalSeparator string
+	// ColourNames appends the nearest CSS colour name to colours, e.g. "R:255 G:0 B:0 (#ff0000, red)".
+	ColourNames bool
+}
+
+// precisions are the default number of decimals of the floating point types.
+var precisions = map[Type]int{
+	TypeAnalogInput:        2,
+	TypeAnalogOutput:       2,
+	TypeTemperature:        2,
+	TypeRelativeHumidity:   1,
+	TypeAccelerometer:      3,
+	TypeBarometricPressure: 1,
+	TypeGyrometer:          2,
+	TypeGPS:                2,
+	TypeVoltage:            2,
+	TypeCurrent:            3,
+	TypeAltitude:           0,
+	TypeDistance:           4,
+	TypeEnergy:             4,
+	TypeDirection:          0,
+
+	TypeBarometricPressure24: 2,
+	TypeDistanceLong:         3,
+	TypePowerPrecise:         1,
+	TypeCurrentHiRange:       2,
+	TypeVoltageSigned:        2,
+	TypeAccelerometerHiG:     2,
+	TypeGyrometerHiRate:      0,
+}
+
+// Format formats the value.
+// Values of unknown types are formatted with their String method.
+func (f Formatter) Format(v Value) string {
+	switch v := v.(type) {
+	case *AnalogInput:
+		return f.float(float64(*v), TypeAnalogInput)
+	case *AnalogOutput:
+		return f.float(float64(*v), TypeAnalogOutput)
+	case *Temperature:
+		return f.float(float64(*v), TypeTemperature)
+	case *RelativeHumidity:
+		return f.float(float64(*v), TypeRelativeHumidity)
+	case *BarometricPressure:
+		return f.float(float64(*v), TypeBarometricPressure)
+	case *Voltage:
+		return f.float(float64(*v), TypeVoltage)
+	case *Current:
+		return f.float(float64(*v), TypeCurrent)
+	case *Altitude:
+		return f.float(float64(*v), TypeAltitude)
+	case *Distance:
+		return f.float(float64(*v), TypeDistance)
+	case *Energy:
+		return f.float(float64(*v), TypeEnergy)
+	case *Direction:
+		return f.float(float64(*v), TypeDirection)
+	case *BarometricPressure24:
+		return f.float(float64(*v), TypeBarometricPressure24)
+	case *DistanceLong:
+		return f.float(float64(*v), TypeDistanceLong)
+	case *PowerPrecise:
+		return f.float(float64(*v), TypePowerPrecise)
+	case *CurrentHiRange:
+		return f.float(float64(*v), TypeCurrentHiRange)
+	case *VoltageSigned:
+		return f.float(float64(*v), TypeVoltageSigned)
+	case *AnalogUnit:
+		return f.analogUnit(v)
+	case *GasConcentration:
+		return f.gasConcentration(v)
+	case *AccelerometerHiG:
+		return fmt.Sprintf("X: %s, Y: %s, Z: %s", f.float(v.X, TypeAccelerometerHiG), f.float(v.Y, TypeAccelerometerHiG), f.float(v.Z, TypeAccelerometerHiG))
+	case *GyrometerHiRate:
+		return fmt.Sprintf("X: %s, Y: %s, Z: %s", f.float(float64(v.X), TypeGyrometerHiRate), f.float(float64(v.Y), TypeGyrometerHiRate), f.float(float64(v.Z), TypeGyrometerHiRate))
+	case *Luminosity:
+		return f.unit(strconv.Itoa(int(*v)), TypeLuminosity)
+	case *Frequency:
+		return f.unit(strconv.Itoa(int(*v)), TypeFrequency)
+	case *Concentration:
+		return f.unit(strconv.Itoa(int(*v)), TypeConcentration)
+	case *Power:
+		return f.unit(strconv.Itoa(int(*v)), TypePower)
+	case *Accelerometer:
+		return fmt.Sprintf("X: %s, Y: %s, Z: %s", f.float(v.X, TypeAccelerometer), f.float(v.Y, TypeAccelerometer), f.float(v.Z, TypeAccelerometer))
+	case *Gyrometer:
+		return fmt.Sprintf("X: %s, Y: %s, Z: %s", f.float(float64(v.X), TypeGyrometer), f.float(float64(v.Y), TypeGyrometer), f.float(float64(v.Z), TypeGyrometer))
+	case *Colour:
+		if f.ColourNames {
+			return fmt.Sprintf("R:%d G:%d B:%d (#%02x%02x%02x, %s)", v.R, v.G, v.B, v.R, v.G, v.B, v.NearestName())
+		}
+	case *GPS:
+		return fmt.Sprintf("%s, %s, %s", dms(v.Latitude, "N", "S"), dms(v.Longitude, "E", "W"), f.float(v.Meters, TypeGPS))
+	case *Object:
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, key := range v.keys() {
+			if i != 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(key)
+			b.WriteString(": ")
+			b.WriteString(f.Format((*v)[key]))
+		}
+		b.WriteByte('}')
+		return b.String()
+	case *Array:
+		var b strings.Builder
+		b.WriteByte('[')
+		for i, item := range *v {
+			if i != 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(f.Format(item))
+		}
+		b.WriteByte(']')
+		return b.String()
+	}
+	return v.String()
+}
+
+// float formats the floating point number of type t.
+func (f Formatter) float(n float64, t Type) string {
+	return f.unit(f.decimal(n, precisions[t]), t)
+}
+
+// decimal formats the number with the Precision, or with prec decimals if the Precision is zero.
+func (f Formatter) decimal(n float64, prec int) string {
+	if f.Precision > 0 {
+		prec = f.Precision
+	} else if f.Precision < 0 {
+		prec = 0
+	}
+	s := strconv.FormatFloat(n, 'f', prec, 64)
+	if f.DecimalSeparator != "" {
+		s = strings.Replace(s, ".", f.DecimalSeparator, 1)
+	}
+	return s
+}
+
+// unit appends the unit of type t to the number s.
+func (f Formatter) unit(s string, t Type) string {
+	if u := t.Unit(); u != "" && !f.NoUnits {
+		return s + " " + u
+	}
+	return s
+}
+
+// Dump writes all remaining values of the Reader to w, one line with channel, type name and value per value.
+func (f Formatter) Dump(w io.Writer, r *Reader) error {
+	for {
+		channel, value, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "%-4d %-20s %s\n", channel, NameOf(value), f.Format(value)); err != nil {
+			return err
+		}
+	}
+}
+
+// Print prints all remaining values of the Reader to the Logger, one line with channel and value per value,
+// between a header and a line with the number of values. Errors are printed before they are returned.
+func (f Formatter) Print(l Logger, r *Reader) error {
+	l.Printf("chan | value")
+	i := 0
+	for {
+		channel, value, err := r.Next()
+		if err != nil {
+			l.Printf("xlpp error: %v", err)
+			return err
+		}
+		if value == nil {
+			l.Printf("end (%d values)", i)
+			return nil
+		}
+		i++
+		l.Printf("%-4d  %s", channel, f.Format(value))
+	}
+}
+
+// Table writes all remaining values of the Reader to w as a table with aligned columns:
+// channel, type name, value and unit. Values without unit, and all values if NoUnits is set, have an empty unit.
+func (f Formatter) Table(w io.Writer, r *Reader) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANNEL\tTYPE\tVALUE\tUNIT")
+	values := f
+	values.NoUnits = true
+	for {
+		channel, value, err := r.Next()
+		if err != nil {
+			tw.Flush()
+			return err
+		}
+		if value == nil {
+			return tw.Flush()
+		}
+		unit := value.XLPPType().Unit()
+		if a, ok := value.(*AnalogUnit); ok {
+			unit = a.Unit.Symbol()
+		}
+		if f.NoUnits {
+			unit = ""
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", channel, NameOf(value), values.Format(value), unit)
+	}
+}
+
+// analogUnit formats the AnalogUnit with its own unit.
+func (f Formatter) analogUnit(v *AnalogUnit) string {
+	s := f.decimal(v.Value, 3)
+	if u := v.Unit.Symbol(); u != "" && !f.NoUnits {
+		s += " " + u
+	}
+	return s
+}
+
+// gasConcentration formats the GasConcentration with its gas.
+func (f Formatter) gasConcentration(v *GasConcentration) string {
+	return v.Gas.String() + " " + f.unit(f.decimal(v.PPM, 3), TypeGasConcentration)
+}
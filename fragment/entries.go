@@ -0,0 +1,112 @@
+package fragment
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/waziup/xlpp"
+)
+
+// SplitEntries splits the XLPP payload into payloads of at most maxSize bytes, without cutting entries.
+// Unlike the fragments of a Fragmenter, each payload is a valid XLPP payload on its own: the accumulated Delay
+// of the previous payloads is repeated at the start of every payload, so that all values keep their historical context.
+// It fails for entries that do not fit into a payload, together with the Delay that precedes them.
+func SplitEntries(payload []byte, maxSize int) ([][]byte, error) {
+	var payloads [][]byte
+	var frag bytes.Buffer
+	var delay time.Duration
+	// values is true if frag has entries besides its Delay
+	var values bool
+
+	r := xlpp.NewBytesReader(payload)
+	for {
+		channel, value, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			break
+		}
+		if isDelay(value) {
+			delay += delayOf(value)
+		}
+		entry, err := encodeEntry(channel, value)
+		if err != nil {
+			return nil, err
+		}
+		if values && frag.Len()+len(entry) > maxSize {
+			payloads = append(payloads, append([]byte(nil), frag.Bytes()...))
+			frag.Reset()
+			values = false
+		}
+		if !values && delay != 0 {
+			// start of a payload: write the total delay, that replaces the delays before the first value
+			prefix, err := encodeEntry(delayMarker(delay))
+			if err != nil {
+				return nil, err
+			}
+			if len(prefix) > maxSize {
+				return nil, fmt.Errorf("fragment: the Delay of %s does not fit into %d bytes", delay, maxSize)
+			}
+			frag.Reset()
+			frag.Write(prefix)
+			if isDelay(value) {
+				continue
+			}
+		}
+		if frag.Len()+len(entry) > maxSize {
+			if frag.Len() != 0 {
+				return nil, fmt.Errorf("fragment: entry %v on channel %d does not fit into %d bytes after the Delay of %s", value, channel, maxSize, delay)
+			}
+			return nil, fmt.Errorf("fragment: entry %v on channel %d does not fit into %d bytes", value, channel, maxSize)
+		}
+		frag.Write(entry)
+		if !isDelay(value) {
+			values = true
+		}
+	}
+	// a trailing Delay without values is dropped
+	if values {
+		payloads = append(payloads, frag.Bytes())
+	}
+	return payloads, nil
+}
+
+// encodeEntry returns the XLPP encoding of the entry.
+func encodeEntry(channel int, v xlpp.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := xlpp.NewWriter(&buf).Add(channel, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func isDelay(v xlpp.Value) bool {
+	switch v.(type) {
+	case *xlpp.Delay, *xlpp.MilliDelay:
+		return true
+	}
+	return false
+}
+
+func delayOf(v xlpp.Value) time.Duration {
+	switch v := v.(type) {
+	case *xlpp.Delay:
+		return time.Duration(*v)
+	case *xlpp.MilliDelay:
+		return time.Duration(*v)
+	}
+	return 0
+}
+
+// delayMarker returns the channel and marker of the total delay d.
+// A MilliDelay is used if d is not a whole number of seconds.
+func delayMarker(d time.Duration) (int, xlpp.Value) {
+	if d%time.Second != 0 {
+		v := xlpp.MilliDelay(d)
+		return xlpp.ChanMilliDelay, &v
+	}
+	v := xlpp.Delay(d)
+	return xlpp.ChanDelay, &v
+}
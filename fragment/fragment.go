@@ -0,0 +1,154 @@
+// Package fragment splits large XLPP frames into fragments that fit the LoRaWAN payload size, and reassembles them.
+//
+// Unlike SplitEntries (used by the split command), that never cuts entries, a Fragmenter cuts the frame at any byte, so that
+// single entries larger than the payload size (e.g. Images or long Samples) can be sent, too.
+// Each fragment starts with a 2 byte header:
+//
+//	byte 0: message id, incremented for every fragmented frame
+//	byte 1: bit 7 set for the last fragment of the frame, bits 0..6 the index of the fragment
+//
+// So a frame can have at most 128 fragments. Fragments should be sent on a dedicated FPort,
+// as they are not valid XLPP payloads on their own.
+package fragment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// HeaderSize is the size of the fragment header.
+const HeaderSize = 2
+
+// MaxFragments is the max. number of fragments of a frame.
+const MaxFragments = 128
+
+const lastFlag = 0x80
+
+var errShort = errors.New("fragment: fragment too short")
+
+// A Fragmenter splits frames into fragments of at most a max. size.
+// It is safe for concurrent use.
+type Fragmenter struct {
+	mu      sync.Mutex
+	maxSize int
+	id      byte
+}
+
+// NewFragmenter creates a Fragmenter for fragments of at most maxSize bytes, including the header,
+// e.g. 51 bytes for EU868 at SF12.
+func NewFragmenter(maxSize int) *Fragmenter {
+	return &Fragmenter{maxSize: maxSize}
+}
+
+// Fragment splits the frame into fragments. All fragments have the max. size, except the last one.
+// A frame that fits into one fragment results in a single fragment, with the header.
+// It fails if the frame needs more than MaxFragments fragments.
+func (f *Fragmenter) Fragment(frame []byte) ([][]byte, error) {
+	chunk := f.maxSize - HeaderSize
+	if chunk <= 0 {
+		return nil, fmt.Errorf("fragment: max. size %d does not fit the header", f.maxSize)
+	}
+	count := (len(frame) + chunk - 1) / chunk
+	if count == 0 {
+		count = 1
+	}
+	if count > MaxFragments {
+		return nil, fmt.Errorf("fragment: frame of %d bytes needs %d fragments, max. %d", len(frame), count, MaxFragments)
+	}
+	f.mu.Lock()
+	id := f.id
+	f.id++
+	f.mu.Unlock()
+
+	fragments := make([][]byte, count)
+	for i := range fragments {
+		data := frame[i*chunk:]
+		if len(data) > chunk {
+			data = data[:chunk]
+		}
+		header := byte(i)
+		if i == count-1 {
+			header |= lastFlag
+		}
+		fragments[i] = append([]byte{id, header}, data...)
+	}
+	return fragments, nil
+}
+
+// partial is a frame of which not all fragments have been received.
+type partial struct {
+	fragments [MaxFragments][]byte
+	// count is the number of fragments, or 0 if the last fragment has not been received.
+	count    int
+	received int
+}
+
+// conflicts reports whether the fragment does not belong to the partial frame.
+func (p *partial) conflicts(index int, last bool, data []byte) bool {
+	if p.fragments[index] != nil && !bytes.Equal(p.fragments[index], data) {
+		return true
+	}
+	if p.count != 0 {
+		return index >= p.count || last && index != p.count-1
+	}
+	if last {
+		for _, f := range p.fragments[index+1:] {
+			if f != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// A Reassembler reassembles the frames of one device from their fragments.
+// Fragments may arrive in any order and may be duplicated.
+// Incomplete frames are kept until their message id is reused, so at most 256 incomplete frames are kept.
+// It is safe for concurrent use.
+type Reassembler struct {
+	mu      sync.Mutex
+	pending map[byte]*partial
+}
+
+// NewReassembler creates a Reassembler.
+func NewReassembler() *Reassembler {
+	return &Reassembler{pending: make(map[byte]*partial)}
+}
+
+// Add adds a fragment, and returns the frame once all of its fragments have been received.
+// It returns nil while the frame is incomplete.
+// A fragment that conflicts with a received fragment of the same message id (because the id has been reused
+// after fragments were lost) discards the incomplete frame.
+func (r *Reassembler) Add(fragment []byte) ([]byte, error) {
+	if len(fragment) < HeaderSize {
+		return nil, errShort
+	}
+	id, index, last := fragment[0], int(fragment[1]&^lastFlag), fragment[1]&lastFlag != 0
+	data := fragment[HeaderSize:]
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.pending[id]
+	if p == nil || p.conflicts(index, last, data) {
+		p = new(partial)
+		r.pending[id] = p
+	}
+	if p.fragments[index] == nil {
+		p.fragments[index] = append([]byte{}, data...)
+		p.received++
+	}
+	if last {
+		p.count = index + 1
+	}
+	if p.count == 0 || p.received != p.count {
+		return nil, nil
+	}
+	delete(r.pending, id)
+	var frame []byte
+	for _, data := range p.fragments[:p.count] {
+		frame = append(frame, data...)
+	}
+	return frame, nil
+}
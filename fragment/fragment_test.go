@@ -0,0 +1,107 @@
+package fragment_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/waziup/xlpp/fragment"
+)
+
+func TestFragment(t *testing.T) {
+	frame := make([]byte, 200)
+	for i := range frame {
+		frame[i] = byte(i)
+	}
+	f := fragment.NewFragmenter(51)
+	fragments, err := f.Fragment(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fragments) != 5 {
+		t.Fatalf("%d fragments", len(fragments))
+	}
+	for i, frag := range fragments {
+		if len(frag) > 51 {
+			t.Fatalf("fragment %d: %d bytes", i, len(frag))
+		}
+	}
+
+	r := fragment.NewReassembler()
+	// out of order, with a duplicate
+	for _, i := range []int{4, 1, 0, 1, 3} {
+		if got, err := r.Add(fragments[i]); err != nil || got != nil {
+			t.Fatalf("fragment %d: %v, %v", i, got, err)
+		}
+	}
+	got, err := r.Add(fragments[2])
+	if err != nil || !bytes.Equal(got, frame) {
+		t.Fatalf("reassembled %x, %v", got, err)
+	}
+
+	// the next frame has a new message id
+	small, _ := f.Fragment([]byte{1, 2, 3})
+	if len(small) != 1 || small[0][0] == fragments[0][0] {
+		t.Fatalf("fragments %x", small)
+	}
+	if got, err := r.Add(small[0]); err != nil || !bytes.Equal(got, []byte{1, 2, 3}) {
+		t.Fatalf("reassembled %x, %v", got, err)
+	}
+
+	// lost fragments are discarded when the message id is reused
+	r.Add(fragments[1])
+	for i := 0; i < 254; i++ {
+		f.Fragment(nil)
+	}
+	other := bytes.Repeat([]byte{0xff}, 60)
+	reused, _ := f.Fragment(other)
+	if reused[0][0] != fragments[0][0] {
+		t.Fatalf("message id %d not reused", reused[0][0])
+	}
+	if got, err := r.Add(reused[1]); err != nil || got != nil {
+		t.Fatalf("fragment 1: %x, %v", got, err)
+	}
+	if got, err := r.Add(reused[0]); err != nil || !bytes.Equal(got, other) {
+		t.Fatalf("reassembled %x, %v", got, err)
+	}
+
+	if _, err := fragment.NewFragmenter(51).Fragment(make([]byte, 49*129)); err == nil {
+		t.Fatal("expected error for too many fragments")
+	}
+	if _, err := r.Add([]byte{1}); err == nil {
+		t.Fatal("expected error for short fragment")
+	}
+}
+
+func TestSplitEntries(t *testing.T) {
+	payload, _ := hex.DecodeString("016700ebfd00000a0274014a033308043468656c6c6f00")
+	payloads, err := fragment.SplitEntries(payload, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range payloads {
+		got = append(got, hex.EncodeToString(p))
+	}
+	want := []string{"016700ebfd00000a0274014a", "fd00000a033308", "fd00000a043468656c6c6f00"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("payloads %v, want %v", got, want)
+	}
+
+	// a payload that has room for the first entry, but not for the following Delay
+	payloads, err = fragment.SplitEntries(payload, 8)
+	if err == nil || !strings.Contains(err.Error(), "after the Delay of 10s") {
+		t.Fatalf("got %x, %v, want error for the string", payloads, err)
+	}
+	// no payload has the Delay only
+	payloads, err = fragment.SplitEntries(payload[:11], 7)
+	if err == nil {
+		t.Fatalf("got %x, want error for the voltage", payloads)
+	}
+	payloads, err = fragment.SplitEntries(payload[:8], 4)
+	if err != nil || len(payloads) != 1 || hex.EncodeToString(payloads[0]) != "016700eb" {
+		t.Fatalf("got %x, %v", payloads, err)
+	}
+}
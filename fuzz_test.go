@@ -0,0 +1,117 @@
+//go:build go1.18
+// +build go1.18
+
+package xlpp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/waziup/xlpp"
+)
+
+func addSeeds(f *testing.F) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for i, v := range values {
+		var b bytes.Buffer
+		xlpp.NewWriter(&b).Add(i, v)
+		f.Add(b.Bytes())
+		w.Add(i, v)
+	}
+	f.Add(buf.Bytes())
+}
+
+func FuzzDecode(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := xlpp.NewBytesReader(data)
+		m1, err1 := r.ReadMessage()
+		if r.BytesConsumed() > int64(len(data)) {
+			t.Fatalf("consumed %d bytes of %d", r.BytesConsumed(), len(data))
+		}
+		m2, err2 := xlpp.NewReader(bytes.NewReader(data)).ReadMessage()
+		if (err1 == nil) != (err2 == nil) || len(m1) != len(m2) {
+			t.Fatalf("bytes reader <> reader: %v (%v) <> %v (%v)", m1, err1, m2, err2)
+		}
+		for _, e := range m1 {
+			_ = e.Value.String()
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m1, err := xlpp.NewBytesReader(data).ReadMessage()
+		if err != nil {
+			return
+		}
+		// the first encoding normalizes the payload (e.g. Bool forms), from then on it must be stable
+		data2 := encode(t, m1)
+		m2, err := xlpp.NewBytesReader(data2).ReadMessage()
+		if err != nil {
+			t.Fatalf("can not read encoded message %v: %v", m1, err)
+		}
+		data3 := encode(t, m2)
+		if !bytes.Equal(data2, data3) {
+			t.Fatalf("encoding is not stable:\n%x\n%x", data2, data3)
+		}
+	})
+}
+
+func FuzzJSON(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := xlpp.NewBytesReader(data).ReadMessage()
+		if err != nil {
+			return
+		}
+		for _, e := range m {
+			js, err := json.Marshal(e.Value)
+			if err != nil {
+				t.Fatalf("can not marshal %T (%v): %v", e.Value, e.Value, err)
+			}
+			switch v := e.Value.(type) {
+			case *xlpp.Object, *xlpp.Array, *xlpp.Null:
+				// no JSON round trip
+				continue
+			case *xlpp.String:
+				if !utf8.ValidString(string(*v)) {
+					// JSON replaces invalid UTF-8
+					continue
+				}
+			case *xlpp.ScheduledCommand:
+				switch c := v.Value.(type) {
+				case *xlpp.Null:
+					continue
+				case *xlpp.String:
+					if !utf8.ValidString(string(*c)) {
+						continue
+					}
+				}
+			}
+			v := reflect.New(reflect.TypeOf(e.Value).Elem()).Interface()
+			if err := json.Unmarshal(js, v); err != nil {
+				t.Fatalf("can not unmarshal %T %s: %v", e.Value, js, err)
+			}
+			if !reflect.DeepEqual(v, e.Value) {
+				t.Fatalf("json round trip: %T (%v) <> (%v)", e.Value, deref(e.Value), deref(v))
+			}
+		}
+	})
+}
+
+func encode(t *testing.T, m xlpp.Message) []byte {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for _, e := range m {
+		if _, err := w.Add(e.Channel, e.Value); err != nil {
+			t.Fatalf("can not write %v: %v", e.Value, err)
+		}
+	}
+	return buf.Bytes()
+}
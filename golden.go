@@ -0,0 +1,98 @@
+package xlpp
+
+// GoldenVersion is the version of the GoldenVectors.
+// It is incremented whenever existing vectors change.
+const GoldenVersion = 4
+
+// A GoldenVector is a XLPP payload with its JSON representation (see MessageJSON).
+type GoldenVector struct {
+	Name string
+	// Source is the implementation that produced the payload.
+	Source string
+	// Payload is the hex encoded payload.
+	Payload string
+	// JSON is the decoded payload.
+	JSON string
+	// Canonical payloads are reproduced byte-for-byte when encoding the decoded payload.
+	Canonical bool
+}
+
+// GoldenVectors is a corpus of payloads with their decoded JSON.
+// The vectors are used by xlpptest.Conformance to verify Codecs, and must never change for a GoldenVersion:
+// the vectors of every version are kept unchanged in testdata/golden/v<version>.json.
+//
+// Sources:
+//   - cayenne-lpp: examples of the Cayenne Low Power Payload documentation
+//   - xlpp-go: payloads encoded by this package
+//
+// There are no vectors produced by the Arduino XLPP library yet, so the xlpp-go vectors are not independent
+// of this package. TestDifferential compares them with the recorded output of a Cayenne LPP decoder in testdata/differential.
+var GoldenVectors = []GoldenVector{
+	{Name: "cayenne-temperature", Source: "cayenne-lpp", Payload: "03670110056700ff", JSON: `[{"channel":3,"type":"temperature","value":27.2},{"channel":5,"type":"temperature","value":25.5}]`, Canonical: true},
+	{Name: "cayenne-temperature-negative", Source: "cayenne-lpp", Payload: "0167ffd7", JSON: `[{"channel":1,"type":"temperature","value":-4.1}]`, Canonical: true},
+	{Name: "cayenne-accelerometer", Source: "cayenne-lpp", Payload: "067104d2fb2e0000", JSON: `[{"channel":6,"type":"accelerometer","value":{"X":1.234,"Y":-1.234,"Z":0}}]`, Canonical: true},
+	{Name: "cayenne-gps", Source: "cayenne-lpp", Payload: "018806765ff2960a0003e8", JSON: `[{"channel":1,"type":"gps","value":{"Latitude":42.3519,"Longitude":-87.9094,"Meters":10}}]`, Canonical: true},
+	{Name: "digitalinput", Source: "xlpp-go", Payload: "00000c", JSON: `[{"channel":0,"type":"digitalinput","value":12}]`, Canonical: true},
+	{Name: "digitaloutput", Source: "xlpp-go", Payload: "01010c", JSON: `[{"channel":1,"type":"digitaloutput","value":12}]`, Canonical: true},
+	{Name: "analoginput", Source: "xlpp-go", Payload: "02020177", JSON: `[{"channel":2,"type":"analoginput","value":3.75}]`, Canonical: true},
+	{Name: "analoginput-negative", Source: "xlpp-go", Payload: "0402fb2e", JSON: `[{"channel":4,"type":"analoginput","value":-12.34}]`, Canonical: true},
+	{Name: "analogoutput", Source: "xlpp-go", Payload: "030301a9", JSON: `[{"channel":3,"type":"analogoutput","value":4.25}]`, Canonical: true},
+	{Name: "analogunit", Source: "xlpp-go", Payload: "04420100000ce4", JSON: `[{"channel":4,"type":"analogunit","value":{"unit":"V","value":3.3}}]`, Canonical: true},
+	{Name: "luminosity", Source: "xlpp-go", Payload: "0465002d", JSON: `[{"channel":4,"type":"luminosity","value":45}]`, Canonical: true},
+	{Name: "presence", Source: "xlpp-go", Payload: "056605", JSON: `[{"channel":5,"type":"presence","value":5}]`, Canonical: true},
+	{Name: "temperature", Source: "xlpp-go", Payload: "0667013c", JSON: `[{"channel":6,"type":"temperature","value":31.6}]`, Canonical: true},
+	{Name: "relativehumidity", Source: "xlpp-go", Payload: "07682d", JSON: `[{"channel":7,"type":"relativehumidity","value":22.5}]`, Canonical: true},
+	{Name: "accelerometer", Source: "xlpp-go", Payload: "08710cadff55038d", JSON: `[{"channel":8,"type":"accelerometer","value":{"X":3.245,"Y":-0.171,"Z":0.909}}]`, Canonical: true},
+	{Name: "accelerometerhig", Source: "xlpp-go", Payload: "08453ab1fea20064", JSON: `[{"channel":8,"type":"accelerometerhig","value":{"X":150.25,"Y":-3.5,"Z":1}}]`, Canonical: true},
+	{Name: "barometricpressure", Source: "xlpp-go", Payload: "09730029", JSON: `[{"channel":9,"type":"barometricpressure","value":4.1}]`, Canonical: true},
+	{Name: "barometricpressurehr", Source: "xlpp-go", Payload: "093d018bcd", JSON: `[{"channel":9,"type":"barometricpressurehr","value":1013.25}]`, Canonical: true},
+	{Name: "gyrometer", Source: "xlpp-go", Payload: "0a8601a901fe0015", JSON: `[{"channel":10,"type":"gyrometer","value":{"X":4.25,"Y":5.1,"Z":0.21}}]`, Canonical: true},
+	{Name: "gyrometerhirate", Source: "xlpp-go", Payload: "0a4605dcff060003", JSON: `[{"channel":10,"type":"gyrometerhirate","value":{"X":1500,"Y":-250,"Z":3}}]`, Canonical: true},
+	{Name: "gps", Source: "xlpp-go", Payload: "0b8807ca1d0218a5002fa8", JSON: `[{"channel":11,"type":"gps","value":{"Latitude":51.0493,"Longitude":13.7381,"Meters":122}}]`, Canonical: true},
+	{Name: "gpscompact", Source: "xlpp-go", Payload: "0b4407ca1d0218a5", JSON: `[{"channel":11,"type":"gpscompact","value":{"Latitude":51.0493,"Longitude":13.7381}}]`, Canonical: true},
+	{Name: "gps-negative", Source: "xlpp-go", Payload: "0388fad50017129dfffdda", JSON: `[{"channel":3,"type":"gps","value":{"Latitude":-33.8688,"Longitude":151.2093,"Meters":-5.5}}]`, Canonical: true},
+	{Name: "voltage", Source: "xlpp-go", Payload: "0c740091", JSON: `[{"channel":12,"type":"voltage","value":1.45}]`, Canonical: true},
+	{Name: "voltagesigned", Source: "xlpp-go", Payload: "0c41ffffed27", JSON: `[{"channel":12,"type":"voltagesigned","value":-48.25}]`, Canonical: true},
+	{Name: "current", Source: "xlpp-go", Payload: "0d75113a", JSON: `[{"channel":13,"type":"current","value":4.41}]`, Canonical: true},
+	{Name: "currenthirange", Source: "xlpp-go", Payload: "0d4000003106", JSON: `[{"channel":13,"type":"currenthirange","value":125.5}]`, Canonical: true},
+	{Name: "frequency", Source: "xlpp-go", Payload: "0e7600001fa4", JSON: `[{"channel":14,"type":"frequency","value":8100}]`, Canonical: true},
+	{Name: "percentage", Source: "xlpp-go", Payload: "0f7811", JSON: `[{"channel":15,"type":"percentage","value":17}]`, Canonical: true},
+	{Name: "extendedpercentage", Source: "xlpp-go", Payload: "0f3cc8", JSON: `[{"channel":15,"type":"extendedpercentage","value":200}]`, Canonical: true},
+	{Name: "altitude", Source: "xlpp-go", Payload: "10792291", JSON: `[{"channel":16,"type":"altitude","value":8849}]`, Canonical: true},
+	{Name: "concentration", Source: "xlpp-go", Payload: "117d09d0", JSON: `[{"channel":17,"type":"concentration","value":2512}]`, Canonical: true},
+	{Name: "gasconcentration", Source: "xlpp-go", Payload: "11430200064b54", JSON: `[{"channel":17,"type":"gasconcentration","value":{"gas":"CO2","ppm":412.5}}]`, Canonical: true},
+	{Name: "power", Source: "xlpp-go", Payload: "12800476", JSON: `[{"channel":18,"type":"power","value":1142}]`, Canonical: true},
+	{Name: "powerprecise", Source: "xlpp-go", Payload: "123f0000007b", JSON: `[{"channel":18,"type":"powerprecise","value":12.3}]`, Canonical: true},
+	{Name: "distance", Source: "xlpp-go", Payload: "13820000096b", JSON: `[{"channel":19,"type":"distance","value":2.411}]`, Canonical: true},
+	{Name: "distance-large", Source: "xlpp-go", Payload: "1382fffffffe", JSON: `[{"channel":19,"type":"distance","value":4294967.294}]`, Canonical: true},
+	{Name: "distancelong", Source: "xlpp-go", Payload: "133e959aef3a", JSON: `[{"channel":19,"type":"distancelong","value":123456.789}]`, Canonical: true},
+	{Name: "energy", Source: "xlpp-go", Payload: "148300000b3c", JSON: `[{"channel":20,"type":"energy","value":2.876}]`, Canonical: true},
+	{Name: "energy-large", Source: "xlpp-go", Payload: "1483fffffffe", JSON: `[{"channel":20,"type":"energy","value":4294967.294}]`, Canonical: true},
+	{Name: "direction", Source: "xlpp-go", Payload: "1584005a", JSON: `[{"channel":21,"type":"direction","value":90}]`, Canonical: true},
+	{Name: "unixtime", Source: "xlpp-go", Payload: "168543b9a355", JSON: `[{"channel":22,"type":"unixtime","value":1136239445}]`, Canonical: true},
+	{Name: "colour", Source: "xlpp-go", Payload: "17877b3659", JSON: `[{"channel":23,"type":"colour","value":"#7b3659"}]`, Canonical: true},
+	{Name: "switch", Source: "xlpp-go", Payload: "188e01", JSON: `[{"channel":24,"type":"switch","value":true}]`, Canonical: true},
+	{Name: "samples", Source: "xlpp-go", Payload: "0147673c03ae030401", JSON: `[{"channel":1,"type":"samples","value":{"type":"temperature","interval":60,"values":[21.5,21.7,21.6]}}]`, Canonical: true},
+	{Name: "spectrum", Source: "xlpp-go", Payload: "0148e2090410804020", JSON: `[{"channel":1,"type":"spectrum","value":{"binWidth":12.5,"bins":[16,128,64,32]}}]`, Canonical: true},
+	{Name: "imagechunk", Source: "xlpp-go", Payload: "014907010304ffd8ffe0", JSON: `[{"channel":1,"type":"imagechunk","value":{"id":7,"index":1,"total":3,"data":"/9j/4A=="}}]`, Canonical: true},
+	{Name: "track", Source: "xlpp-go", Payload: "014a0278d0db3af0840ec0ac06770401ac02", JSON: `[{"channel":1,"type":"track","value":[{"lat":48.1,"lon":11.5,"alt":520,"offset":60},{"lat":48.1002,"lon":11.4999,"alt":521.5,"offset":0}]}]`, Canonical: true},
+	{Name: "scheduledcommand", Source: "xlpp-go", Payload: "034bb0090101", JSON: `[{"channel":3,"type":"scheduledcommand","value":{"delay":1200,"type":"digitaloutput","value":1}}]`, Canonical: true},
+	{Name: "null", Source: "xlpp-go", Payload: "193a", JSON: `[{"channel":25,"type":"null","value":{}}]`, Canonical: true},
+	{Name: "binary", Source: "xlpp-go", Payload: "1a3906010203070809", JSON: `[{"channel":26,"type":"binary","value":"AQIDBwgJ"}]`, Canonical: true},
+	{Name: "flags", Source: "xlpp-go", Payload: "1a388904", JSON: `[{"channel":26,"type":"flags","value":[0,3,9]}]`, Canonical: true},
+	{Name: "integer", Source: "xlpp-go", Payload: "1b33fc50", JSON: `[{"channel":27,"type":"integer","value":5182}]`, Canonical: true},
+	{Name: "integer-negative", Source: "xlpp-go", Payload: "073301", JSON: `[{"channel":7,"type":"integer","value":-1}]`, Canonical: true},
+	{Name: "string", Source: "xlpp-go", Payload: "1c3474657374203a2900", JSON: `[{"channel":28,"type":"string","value":"test :)"}]`, Canonical: true},
+	{Name: "string-empty", Source: "xlpp-go", Payload: "063400", JSON: `[{"channel":6,"type":"string","value":""}]`, Canonical: true},
+	{Name: "bool-true", Source: "xlpp-go", Payload: "1d36", JSON: `[{"channel":29,"type":"bool","value":true}]`, Canonical: true},
+	{Name: "bool-false", Source: "xlpp-go", Payload: "0537", JSON: `[{"channel":5,"type":"bool","value":false}]`, Canonical: true},
+	{Name: "object", Source: "xlpp-go", Payload: "1e7b636f756e740033fc50706f73008807ca1d0218a5002fa876616c00000c00", JSON: `[{"channel":30,"type":"object","value":{"count":5182,"pos":{"Latitude":51.0493,"Longitude":13.7381,"Meters":122},"val":12}}]`, Canonical: true},
+	{Name: "array", Source: "xlpp-go", Payload: "1f5b660565002d67013c5d", JSON: `[{"channel":31,"type":"array","value":[5,45,31.6]}]`, Canonical: true},
+	{Name: "arrayof", Source: "xlpp-go", Payload: "015c670300d700d800d9", JSON: `[{"channel":1,"type":"array","value":[21.5,21.6,21.7]}]`, Canonical: false},
+	{Name: "array-empty", Source: "xlpp-go", Payload: "085b5d", JSON: `[{"channel":8,"type":"array","value":[]}]`, Canonical: true},
+	{Name: "delay", Source: "xlpp-go", Payload: "fd010a23", JSON: `[{"channel":253,"type":"delay","value":4235000000000}]`, Canonical: true},
+	{Name: "actuators", Source: "xlpp-go", Payload: "fc0387038e", JSON: `[{"channel":252,"type":"actuators","value":"hwOO"}]`, Canonical: true},
+	{Name: "actuatorswithchannel", Source: "xlpp-go", Payload: "fb0203741187", JSON: `[{"channel":251,"type":"actuatorswithchannel","value":[{"Channel":3,"Type":116},{"Channel":17,"Type":135}]}]`, Canonical: true},
+	{Name: "history", Source: "xlpp-go", Payload: "016700d502686ffd011e00016700d0", JSON: `[{"channel":1,"type":"temperature","value":21.3},{"channel":2,"type":"relativehumidity","value":55.5},{"channel":253,"type":"delay","value":5400000000000},{"channel":1,"type":"temperature","value":20.8}]`, Canonical: true},
+	{Name: "bool-type", Source: "xlpp-go", Payload: "0035", JSON: `[{"channel":0,"type":"bool","value":false}]`, Canonical: false},
+}
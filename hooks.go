@@ -0,0 +1,75 @@
+package xlpp
+
+// A Hook is called for every entry decoded by Reader.Next, with the channel, the type and value, and the raw bytes of the entry
+// (including the channel and type). The raw bytes are only valid until the next call of Next.
+//
+// The Hook returns the value that Next returns, which can be the decoded value or a replacement.
+// If the Hook returns a nil Value, the entry is skipped, and if it returns an error, Next fails with the error.
+// Hooks can be used for auditing, metrics or on-the-fly transformations, without wrapping the Reader.
+type Hook func(channel int, t Type, v Value, raw []byte) (Value, error)
+
+// WithHook adds a Hook to the Reader. Multiple hooks are called in the order they have been added,
+// each with the value returned by the previous hook.
+func WithHook(h Hook) ReaderOption {
+	return func(r *Reader) {
+		r.hooks = append(r.hooks, h)
+	}
+}
+
+// nextHooked reads the next entry and passes it through the hooks of the Reader.
+func (r *Reader) nextHooked() (channel int, v Value, err error) {
+	for {
+		start := r.b.off
+		if r.rec != nil {
+			r.rec.buf = r.rec.buf[:0]
+		}
+		channel, v, err = r.next()
+		if err != nil || v == nil {
+			return
+		}
+		var raw []byte
+		if r.rec != nil {
+			raw = r.rec.buf
+		} else {
+			raw = r.b.data[start:r.b.off]
+		}
+		for _, h := range r.hooks {
+			v, err = h(channel, v.XLPPType(), v, raw)
+			if err != nil {
+				return channel, nil, err
+			}
+			if v == nil {
+				break
+			}
+		}
+		if v != nil {
+			return
+		}
+	}
+}
+
+// recordingSource is a source that records the bytes read from it.
+type recordingSource struct {
+	source
+	buf []byte
+}
+
+func (s *recordingSource) Read(p []byte) (n int, err error) {
+	n, err = s.source.Read(p)
+	s.buf = append(s.buf, p[:n]...)
+	return
+}
+
+func (s *recordingSource) ReadByte() (byte, error) {
+	b, err := s.source.ReadByte()
+	if err == nil {
+		s.buf = append(s.buf, b)
+	}
+	return b, err
+}
+
+func (s *recordingSource) ReadSlice(delim byte) (line []byte, err error) {
+	line, err = s.source.ReadSlice(delim)
+	s.buf = append(s.buf, line...)
+	return
+}
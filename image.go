@@ -0,0 +1,161 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// TypeImageChunk is the type of ImageChunk.
+const TypeImageChunk Type = 73 // uvarint image id, index, total and length, followed by the data
+
+// An ImageChunk is a part of an image (e.g. a thumbnail of a camera trap), that is too large for a single payload.
+// The chunks of an image are sent over multiple uplinks and stitched together with an ImageAssembler.
+type ImageChunk struct {
+	// ImageID identifies the image among the images of the device.
+	ImageID uint32 `json:"id"`
+	// Index is the index of the chunk, from 0 to Total-1.
+	Index uint32 `json:"index"`
+	// Total is the number of chunks of the image.
+	Total uint32 `json:"total"`
+	Data  []byte `json:"data"`
+}
+
+// XLPPType for ImageChunk returns TypeImageChunk.
+func (v ImageChunk) XLPPType() Type {
+	return TypeImageChunk
+}
+
+func (v ImageChunk) String() string {
+	return fmt.Sprintf("image %d chunk %d/%d (%d bytes)", v.ImageID, v.Index+1, v.Total, len(v.Data))
+}
+
+// ReadFrom reads the ImageChunk from the reader.
+func (v *ImageChunk) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	var h [4]uint64
+	for i := range h {
+		if h[i], err = binary.ReadUvarint(&brc); err != nil {
+			return int64(brc.Count), err
+		}
+	}
+	v.ImageID, v.Index, v.Total = uint32(h[0]), uint32(h[1]), uint32(h[2])
+	if err = checkLength(r, h[3]); err != nil {
+		return int64(brc.Count), err
+	}
+	v.Data = make([]byte, h[3])
+	var m int
+	m, err = io.ReadFull(r, v.Data)
+	return int64(brc.Count + m), err
+}
+
+// WriteTo writes the ImageChunk to the writer.
+func (v ImageChunk) WriteTo(w io.Writer) (n int64, err error) {
+	var buf [4 * binary.MaxVarintLen32]byte
+	m := binary.PutUvarint(buf[:], uint64(v.ImageID))
+	m += binary.PutUvarint(buf[m:], uint64(v.Index))
+	m += binary.PutUvarint(buf[m:], uint64(v.Total))
+	m += binary.PutUvarint(buf[m:], uint64(len(v.Data)))
+	m, err = writeTo(w, buf[:m])
+	n = int64(m)
+	if err == nil {
+		m, err = w.Write(v.Data)
+		n += int64(m)
+	}
+	return
+}
+
+// ImageChunks splits the image into chunks of at most size bytes.
+// It fails if size is not positive, or if the image has more chunks than an ImageAssembler accepts.
+func ImageChunks(id uint32, image []byte, size int) ([]ImageChunk, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("xlpp: invalid image chunk size %d", size)
+	}
+	total := (len(image) + size - 1) / size
+	if total > maxImageChunks {
+		return nil, fmt.Errorf("xlpp: image of %d bytes has more than %d chunks of %d bytes", len(image), maxImageChunks, size)
+	}
+	chunks := make([]ImageChunk, total)
+	for i := range chunks {
+		end := (i + 1) * size
+		if end > len(image) {
+			end = len(image)
+		}
+		chunks[i] = ImageChunk{ImageID: id, Index: uint32(i), Total: uint32(total), Data: image[i*size : end]}
+	}
+	return chunks, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+var errImageChunk = errors.New("xlpp: invalid image chunk")
+
+// An ImageAssembler stitches images back together from their chunks, that may arrive in any order.
+// Use one ImageAssembler per device, as image ids are only unique per device.
+// It is safe for concurrent use.
+type ImageAssembler struct {
+	mu     sync.Mutex
+	images map[uint32]*partialImage
+}
+
+type partialImage struct {
+	chunks   [][]byte
+	received int
+}
+
+// maxImageChunks is the max. number of chunks of an image, that limits the memory allocated for corrupt chunks.
+const maxImageChunks = 1 << 16
+
+// Add adds the chunk and returns the complete image once all chunks of the image have been added.
+// Duplicate chunks are ignored.
+func (a *ImageAssembler) Add(c *ImageChunk) (image []byte, complete bool, err error) {
+	if c.Total == 0 || c.Total > maxImageChunks || c.Index >= c.Total {
+		return nil, false, errImageChunk
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.images == nil {
+		a.images = make(map[uint32]*partialImage)
+	}
+	img := a.images[c.ImageID]
+	if img == nil {
+		img = &partialImage{chunks: make([][]byte, c.Total)}
+		a.images[c.ImageID] = img
+	}
+	if len(img.chunks) != int(c.Total) {
+		return nil, false, fmt.Errorf("xlpp: image %d has %d chunks, not %d", c.ImageID, len(img.chunks), c.Total)
+	}
+	if img.chunks[c.Index] == nil {
+		img.chunks[c.Index] = append([]byte{}, c.Data...)
+		img.received++
+	}
+	if img.received != len(img.chunks) {
+		return nil, false, nil
+	}
+	delete(a.images, c.ImageID)
+	for _, chunk := range img.chunks {
+		image = append(image, chunk...)
+	}
+	return image, true, nil
+}
+
+// Pending returns the ids of the images that are not yet complete.
+func (a *ImageAssembler) Pending() []uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ids := make([]uint32, 0, len(a.images))
+	for id := range a.images {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Drop discards the chunks of an incomplete image, e.g. after a timeout.
+func (a *ImageAssembler) Drop(id uint32) {
+	a.mu.Lock()
+	delete(a.images, id)
+	a.mu.Unlock()
+}
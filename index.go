@@ -0,0 +1,119 @@
+package xlpp
+
+import (
+	"fmt"
+	"io"
+)
+
+// An IndexEntry locates an entry of a payload.
+type IndexEntry struct {
+	Channel int
+	// Type is the XLPPType of the value, e.g. TypeTemperature.
+	Type Type
+	// Offset is the position of the entry (the channel byte) in the payload.
+	Offset int64
+	// Size is the size of the entry in bytes, including the channel and type.
+	Size int64
+}
+
+// An Index holds the positions of the entries of a payload, in payload order.
+// It is built once with BuildIndex, and can be stored next to large payloads, so that an IndexedReader can
+// read single entries without decoding the whole payload.
+type Index []IndexEntry
+
+// BuildIndex decodes the payload from r and returns the positions of its entries.
+func BuildIndex(r io.Reader) (Index, error) {
+	var index Index
+	reader := NewReader(r)
+	for {
+		offset := reader.BytesConsumed()
+		channel, v, err := reader.Next()
+		if err != nil {
+			return index, err
+		}
+		if v == nil {
+			return index, nil
+		}
+		index = append(index, IndexEntry{
+			Channel: channel,
+			Type:    v.XLPPType(),
+			Offset:  offset,
+			Size:    reader.BytesConsumed() - offset,
+		})
+	}
+}
+
+// Channel returns the entries on the channel.
+func (x Index) Channel(channel int) []IndexEntry {
+	var entries []IndexEntry
+	for _, e := range x {
+		if e.Channel == channel {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Type returns the entries with values of type t.
+func (x Index) Type(t Type) []IndexEntry {
+	var entries []IndexEntry
+	for _, e := range x {
+		if e.Type == t {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// An IndexedReader reads single entries of a stored payload, using its Index.
+// Entries are decoded on their own: markers like Delay that precede an entry are not applied,
+// but they can be read from the Index like any other entry.
+type IndexedReader struct {
+	r     io.ReaderAt
+	index Index
+	opts  []ReaderOption
+}
+
+// NewIndexedReader creates an IndexedReader for the payload r with the Index of the payload.
+// The options are applied to each entry that is read.
+func NewIndexedReader(r io.ReaderAt, index Index, opts ...ReaderOption) *IndexedReader {
+	return &IndexedReader{r: r, index: index, opts: opts}
+}
+
+// Index returns the Index of the payload.
+func (ir *IndexedReader) Index() Index {
+	return ir.index
+}
+
+// ReadEntry reads the value of the entry.
+func (ir *IndexedReader) ReadEntry(e IndexEntry) (Value, error) {
+	buf := make([]byte, e.Size)
+	// ReadAt may return io.EOF with a full buffer if the entry ends the payload.
+	if n, err := ir.r.ReadAt(buf, e.Offset); err != nil && !(err == io.EOF && n == len(buf)) {
+		return nil, toErr(err)
+	}
+	channel, v, err := NewBytesReader(buf, ir.opts...).Next()
+	if err != nil {
+		return nil, err
+	}
+	if v == nil || channel != e.Channel || v.XLPPType() != e.Type {
+		return nil, fmt.Errorf("xlpp: index entry at offset %d does not match the payload", e.Offset)
+	}
+	return v, nil
+}
+
+// Channel reads the values on the channel.
+func (ir *IndexedReader) Channel(channel int) ([]Value, error) {
+	entries := ir.index.Channel(channel)
+	values := make([]Value, len(entries))
+	for i, e := range entries {
+		v, err := ir.ReadEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
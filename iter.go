@@ -0,0 +1,36 @@
+//go:build go1.23
+// +build go1.23
+
+package xlpp
+
+import "iter"
+
+// Values returns an iterator over the channels and values of the reader, e.g.
+//
+//	for channel, v := range r.Values() {
+//		...
+//	}
+//	if err := r.Err(); err != nil {
+//		...
+//	}
+//
+// The iteration stops at the end of the input or at the first error, see Err.
+func (r *Reader) Values() iter.Seq2[int, Value] {
+	return func(yield func(int, Value) bool) {
+		for r.err == nil {
+			channel, v, err := r.Next()
+			if err != nil {
+				r.err = err
+				return
+			}
+			if v == nil || !yield(channel, v) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the first error that stopped the iteration of Values.
+func (r *Reader) Err() error {
+	return r.err
+}
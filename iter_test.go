@@ -0,0 +1,38 @@
+//go:build go1.23
+// +build go1.23
+
+package xlpp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/waziup/xlpp"
+)
+
+func TestValues(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	w.Add(1, &temperature)
+	w.Add(2, &str)
+	var channels []int
+	r := xlpp.NewBytesReader(buf.Bytes())
+	for channel, v := range r.Values() {
+		if v == nil {
+			t.Fatal("nil value")
+		}
+		channels = append(channels, channel)
+	}
+	if r.Err() != nil || len(channels) != 2 || channels[1] != 2 {
+		t.Fatalf("channels %v, err %v", channels, r.Err())
+	}
+
+	r = xlpp.NewBytesReader(append(buf.Bytes(), 3, byte(xlpp.TypeTemperature), 0))
+	n := 0
+	for range r.Values() {
+		n++
+	}
+	if r.Err() == nil || n != 2 {
+		t.Fatalf("truncated: %d values, err %v", n, r.Err())
+	}
+}
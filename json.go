@@ -0,0 +1,247 @@
+package xlpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// JSONNaming selects the JSON field names of struct values (Accelerometer, AccelerometerHiG, Gyrometer, GyrometerHiRate, GPS, GPS2D and Actuator).
+type JSONNaming int
+
+const (
+	// LegacyJSONNaming uses the Go field names, e.g. {"X":1,"Y":2,"Z":3} or {"Latitude":..,"Longitude":..,"Meters":..}.
+	LegacyJSONNaming JSONNaming = iota
+	// CanonicalJSONNaming uses lowercase names, e.g. {"x":1,"y":2,"z":3} or {"latitude":..,"longitude":..,"altitude":..}.
+	CanonicalJSONNaming
+)
+
+// A JSONOption configures MarshalJSON and MarshalJSONUnits.
+type JSONOption func(*jsonOptions)
+
+type jsonOptions struct {
+	naming JSONNaming
+}
+
+// WithJSONNaming selects the field names of struct values. The default is LegacyJSONNaming,
+// to keep existing integrations working. Unmarshaling accepts both namings.
+func WithJSONNaming(naming JSONNaming) JSONOption {
+	return func(o *jsonOptions) {
+		o.naming = naming
+	}
+}
+
+func newJSONOptions(opts []JSONOption) *jsonOptions {
+	o := &jsonOptions{naming: LegacyJSONNaming}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// xyz64JSON and xyz32JSON are the CanonicalJSONNaming of Accelerometer, AccelerometerHiG, Gyrometer and GyrometerHiRate.
+type xyz64JSON struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+type xyz32JSON struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+	Z float32 `json:"z"`
+}
+
+type gpsJSON struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+type gps2DJSON struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type actuatorJSON struct {
+	Channel int  `json:"channel"`
+	Type    Type `json:"type"`
+}
+
+type unknownValueJSON struct {
+	Type Type   `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// value returns the value to marshal for v with the naming of the options.
+// Object, Array and ScheduledCommand values are renamed recursively.
+func (o *jsonOptions) value(v Value) interface{} {
+	if o.naming != CanonicalJSONNaming {
+		return v
+	}
+	switch v := v.(type) {
+	case *Accelerometer:
+		return xyz64JSON{v.X, v.Y, v.Z}
+	case *AccelerometerHiG:
+		return xyz64JSON{v.X, v.Y, v.Z}
+	case *Gyrometer:
+		return xyz32JSON{v.X, v.Y, v.Z}
+	case *GyrometerHiRate:
+		return xyz32JSON{v.X, v.Y, v.Z}
+	case *GPS:
+		return gpsJSON{v.Latitude, v.Longitude, v.Meters}
+	case *GPS2D:
+		return gps2DJSON{v.Latitude, v.Longitude}
+	case *ActuatorsWithChannel:
+		a := make([]actuatorJSON, len(*v))
+		for i, actuator := range *v {
+			a[i] = actuatorJSON{actuator.Channel, actuator.Type}
+		}
+		return a
+	case *UnknownValue:
+		return unknownValueJSON{v.Type, v.Data}
+	case *Object:
+		m := make(map[string]interface{}, len(*v))
+		for key, item := range *v {
+			m[key] = o.value(item)
+		}
+		return m
+	case *Array:
+		a := make([]interface{}, len(*v))
+		for i, item := range *v {
+			a[i] = o.value(item)
+		}
+		return a
+	case *ScheduledCommand:
+		if v.Value == nil {
+			return v
+		}
+		return struct {
+			Delay float64     `json:"delay"`
+			Type  string      `json:"type"`
+			Value interface{} `json:"value"`
+		}{v.Delay.Seconds(), NameOf(v.Value), o.value(v.Value)}
+	}
+	return v
+}
+
+// UnmarshalJSON unmarshals the GPS from both the legacy and the canonical naming.
+func (v *GPS) UnmarshalJSON(data []byte) error {
+	var gps struct {
+		Latitude  float64
+		Longitude float64
+		Meters    *float64
+		Altitude  *float64
+	}
+	if err := json.Unmarshal(data, &gps); err != nil {
+		return err
+	}
+	v.Latitude, v.Longitude = gps.Latitude, gps.Longitude
+	if gps.Meters != nil {
+		v.Meters = *gps.Meters
+	} else if gps.Altitude != nil {
+		v.Meters = *gps.Altitude
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// jsonKeyRegexp matches the keys of the JSON format, e.g. "temperature5".
+var jsonKeyRegexp = regexp.MustCompile(`^([a-zA-Z]+)([0-9]+)$`)
+
+// MarshalJSON encodes the message in the JSON format of the xlpp command: an object with the type name and channel
+// of each entry as key, e.g. {"temperature5":23.5}. If the message has multiple entries with the same type and channel,
+// the last entry wins.
+func MarshalJSON(m Message, opts ...JSONOption) ([]byte, error) {
+	o := newJSONOptions(opts)
+	values := make(map[string]interface{}, len(m))
+	for _, e := range m {
+		values[NameOf(e.Value)+strconv.Itoa(e.Channel)] = o.value(e.Value)
+	}
+	return json.Marshal(values)
+}
+
+// MarshalJSONUnits is MarshalJSON with the values annotated with their physical unit,
+// e.g. {"temperature5":{"value":23.5,"unit":"°C"}}. Values without unit are not annotated.
+func MarshalJSONUnits(m Message, opts ...JSONOption) ([]byte, error) {
+	o := newJSONOptions(opts)
+	values := make(map[string]interface{}, len(m))
+	for _, e := range m {
+		values[NameOf(e.Value)+strconv.Itoa(e.Channel)] = o.withUnit(e.Value)
+	}
+	return json.Marshal(values)
+}
+
+// unitValue is a value annotated with its physical unit.
+type unitValue struct {
+	Value interface{} `json:"value"`
+	Unit  string      `json:"unit"`
+}
+
+// withUnit annotates the value with its unit, if it has one.
+// Object and Array items are annotated recursively.
+func (o *jsonOptions) withUnit(v Value) interface{} {
+	switch v := v.(type) {
+	case *Object:
+		m := make(map[string]interface{}, len(*v))
+		for key, item := range *v {
+			m[key] = o.withUnit(item)
+		}
+		return m
+	case *Array:
+		a := make([]interface{}, len(*v))
+		for i, item := range *v {
+			a[i] = o.withUnit(item)
+		}
+		return a
+	}
+	if unit := v.XLPPType().Unit(); unit != "" {
+		return unitValue{Value: o.value(v), Unit: unit}
+	}
+	return o.value(v)
+}
+
+// UnmarshalJSON decodes the JSON format of the xlpp command, e.g. {"temperature5":23.5}, see MarshalJSON.
+// The entries are ordered by channel and type name. As the position of markers would be lost,
+// entries on the marker channels (e.g. "delay253" of MarshalJSON) are rejected with ErrReservedChannel.
+// Objects and Arrays must be empty, as the JSON of their items has no type names.
+func UnmarshalJSON(data []byte) (Message, error) {
+	values := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	m := make(Message, 0, len(values))
+	for key, raw := range values {
+		match := jsonKeyRegexp.FindStringSubmatch(key)
+		if match == nil {
+			return nil, fmt.Errorf("xlpp: bad json entry: %s", key)
+		}
+		name := match[1]
+		channel, err := strconv.Atoi(match[2])
+		if err != nil || channel > 255 {
+			return nil, fmt.Errorf("xlpp: bad channel: %s", key)
+		}
+		if isMarkerChannel(channel) {
+			return nil, fmt.Errorf("%w: %s", ErrReservedChannel, key)
+		}
+		f := LookupName(name)
+		if f == nil {
+			return nil, fmt.Errorf("xlpp: unknown type: %s", name)
+		}
+		v := f()
+		if err := json.Unmarshal(raw, v); err != nil {
+			return nil, fmt.Errorf("xlpp: can not unmarshal %q: %v", name, err)
+		}
+		m = append(m, Entry{Channel: channel, Value: v})
+	}
+	sort.Slice(m, func(i, j int) bool {
+		if m[i].Channel != m[j].Channel {
+			return m[i].Channel < m[j].Channel
+		}
+		return NameOf(m[i].Value) < NameOf(m[j].Value)
+	})
+	return m, nil
+}
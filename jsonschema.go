@@ -0,0 +1,240 @@
+package xlpp
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect of JSONSchema.
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonUnmarshalerType is the reflect.Type of the json.Unmarshaler interface.
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// channelPattern matches the channels 0 to 249 of the JSON keys, with optional leading zeros as accepted by UnmarshalJSON.
+// The marker channels 250 to 255 are rejected by UnmarshalJSON.
+const channelPattern = `0*([0-9]|[1-9][0-9]|1[0-9][0-9]|2[0-4][0-9])`
+
+// JSONSchema returns a JSON Schema (draft 2020-12) of the JSON format of UnmarshalJSON and the xlpp command,
+// e.g. {"temperature5":23.5}, with the types of the Registry, including custom types.
+// The schema of each type is in "$defs", with the type name as key. Object and Array items are any JSON value
+// of the item schema, as the JSON format does not name the types of nested values.
+//
+// The result can be marshaled with encoding/json, e.g. to validate encode requests before calling UnmarshalJSON.
+func JSONSchema() map[string]interface{} {
+	registryMu.RLock()
+	types := make([]Type, 0, len(Registry))
+	for t := range Registry {
+		types = append(types, t)
+	}
+	registryMu.RUnlock()
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	defs := map[string]interface{}{
+		"item": jsonItemSchema(),
+	}
+	props := make(map[string]interface{})
+	for _, t := range types {
+		name := t.Name()
+		if name == "" || defs[name] != nil {
+			continue
+		}
+		defs[name] = typeSchema(t)
+		props["^"+name+channelPattern+"$"] = map[string]interface{}{"$ref": "#/$defs/" + name}
+	}
+	return map[string]interface{}{
+		"$schema":              JSONSchemaDraft,
+		"title":                "XLPP JSON",
+		"description":          "XLPP values with the type name and channel as key, e.g. {\"temperature5\":23.5}.",
+		"type":                 "object",
+		"patternProperties":    props,
+		"additionalProperties": false,
+		"$defs":                defs,
+	}
+}
+
+// jsonItemSchema is the schema of the items of Objects and Arrays.
+func jsonItemSchema() map[string]interface{} {
+	ref := map[string]interface{}{"$ref": "#/$defs/item"}
+	return map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": []string{"null", "boolean", "number", "string"}},
+			map[string]interface{}{"type": "object", "additionalProperties": ref},
+			map[string]interface{}{"type": "array", "items": ref},
+		},
+	}
+}
+
+// typeSchema returns the JSON Schema of the JSON representation of the values of the type.
+func typeSchema(t Type) map[string]interface{} {
+	number := map[string]interface{}{"type": "number"}
+	xyz := jsonObjectSchema(number, "X", "Y", "Z", "x", "y", "z")
+	unitOrGas := map[string]interface{}{"anyOf": []interface{}{
+		map[string]interface{}{"type": "string"},
+		jsonUintSchema(8),
+	}}
+	s := map[string]interface{}{}
+	switch t {
+	case TypeAccelerometer, TypeAccelerometerHiG, TypeGyrometer, TypeGyrometerHiRate:
+		s = xyz
+	case TypeGPS:
+		s = jsonObjectSchema(number, "Latitude", "Longitude", "Meters", "latitude", "longitude", "altitude")
+	case TypeGPS2D:
+		// GPS2D.WriteTo rejects coordinates out of range with ErrGPSRange
+		latitude := map[string]interface{}{"type": "number", "minimum": -90, "maximum": 90}
+		longitude := map[string]interface{}{"type": "number", "minimum": -180, "maximum": 180}
+		s = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"Latitude": latitude, "Longitude": longitude, "latitude": latitude, "longitude": longitude,
+			},
+		}
+	case TypePercentage:
+		// Percentage.WriteTo rejects values above 100 with ErrPercentageRange
+		s = map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 100}
+	case TypeUnixTime:
+		s = jsonUintSchema(32)
+		s["description"] = "seconds since 1970-01-01"
+	case TypeColour:
+		s = map[string]interface{}{"type": "string", "description": `"#rrggbb", "#rgb" or a CSS colour name`}
+	case TypeAnalogUnit:
+		s = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"unit": unitOrGas, "value": number},
+		}
+	case TypeGasConcentration:
+		s = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"gas": unitOrGas, "ppm": number},
+		}
+	case TypeSamples:
+		s = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"type":     map[string]interface{}{"type": "string"},
+				"interval": number,
+				"values":   map[string]interface{}{"type": "array", "items": number},
+			},
+		}
+	case TypeSpectrum:
+		s = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"binWidth": number,
+				"bins":     map[string]interface{}{"type": "array", "items": jsonUintSchema(8)},
+			},
+		}
+	case TypeImageChunk:
+		s = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":    jsonUintSchema(32),
+				"index": jsonUintSchema(32),
+				"total": jsonUintSchema(32),
+				"data":  jsonBytesSchema(),
+			},
+		}
+	case TypeTrack:
+		s = map[string]interface{}{
+			"type":  "array",
+			"items": jsonObjectSchema(number, "lat", "lon", "alt", "offset"),
+		}
+	case TypeScheduledCommand:
+		s = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"delay": number,
+				"type":  map[string]interface{}{"type": "string"},
+				"value": map[string]interface{}{},
+			},
+		}
+	case TypeNull:
+		s = map[string]interface{}{"type": []string{"object", "null"}}
+	case TypeBinary:
+		s = jsonBytesSchema()
+	case TypeObject:
+		s = map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"$ref": "#/$defs/item"}}
+	case TypeArray, TypeArrayOf:
+		s = map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/$defs/item"}}
+	case TypeFlags:
+		s = map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 63}},
+			jsonUintSchema(64),
+		}}
+	default:
+		if f := LookupType(t); f != nil {
+			s = jsonKindSchema(reflect.TypeOf(f()))
+		}
+	}
+	if unit := t.Unit(); unit != "" {
+		s["description"] = "[" + unit + "]"
+	}
+	return s
+}
+
+// jsonObjectSchema returns the schema of an object whose properties have the same schema.
+// Struct values list the names of both LegacyJSONNaming and CanonicalJSONNaming.
+func jsonObjectSchema(item map[string]interface{}, names ...string) map[string]interface{} {
+	props := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		props[name] = item
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+}
+
+// jsonUintSchema returns the schema of an unsigned integer with the given number of bits.
+func jsonUintSchema(bits int) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": uint64(1)<<uint(bits) - 1}
+}
+
+// jsonBytesSchema returns the schema of a []byte, a base64 string.
+func jsonBytesSchema() map[string]interface{} {
+	return map[string]interface{}{"type": []string{"string", "null"}, "contentEncoding": "base64"}
+}
+
+// jsonKindSchema returns the schema of the JSON representation of a Go type, for custom types.
+// It returns the empty schema, that allows any value, for types that can not be described.
+func jsonKindSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonUintSchema(t.Bits())
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := f.Name
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				if tag == "-" {
+					continue
+				}
+				if n := strings.Split(tag, ",")[0]; n != "" {
+					name = n
+				}
+			}
+			if f.PkgPath == "" {
+				props[name] = jsonKindSchema(f.Type)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,165 @@
+package xlpp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxNestingDepth is the max. nesting depth of Objects and Arrays of Readers created without WithMaxNestingDepth.
+const DefaultMaxNestingDepth = 32
+
+// ErrMaxDepth is returned for Objects and Arrays that are nested deeper than the max. nesting depth of the Reader,
+// as *LimitError that wraps both ErrMaxDepth and ErrTooLarge.
+var ErrMaxDepth = errors.New("xlpp: max. nesting depth exceeded")
+
+// A LimitError reports a payload that exceeds a resource limit of the Reader,
+// see WithMaxStringLen, WithMaxBinaryLen, WithMaxValues and WithMaxNestingDepth.
+// It wraps ErrTooLarge.
+type LimitError struct {
+	// Limit is the name of the exceeded limit, e.g. "MaxStringLen".
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("xlpp: payload exceeds %s of %d", e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrTooLarge
+}
+
+// Is reports whether the error is ErrMaxDepth, for errors.Is.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrMaxDepth && e.Limit == "MaxNestingDepth"
+}
+
+// limits are the resource limits of a Reader. A limit <= 0 means no limit, except for the depth, see WithMaxNestingDepth.
+type limits struct {
+	stringLen int
+	binaryLen int
+	values    int
+	depth     int
+}
+
+// limitState counts the resources used by a Reader for the current payload.
+type limitState struct {
+	values int
+	depth  int
+}
+
+// WithMaxStringLen limits the length of Strings and Object keys to max bytes.
+// Longer strings are rejected with a *LimitError as soon as the limit is exceeded,
+// without reading up to the terminating null byte.
+func WithMaxStringLen(max int) ReaderOption {
+	return func(r *Reader) {
+		r.limits.stringLen = max
+	}
+}
+
+// WithMaxBinaryLen limits the length of Binary values to max bytes.
+// Longer values are rejected with a *LimitError before reading or allocating their data.
+func WithMaxBinaryLen(max int) ReaderOption {
+	return func(r *Reader) {
+		r.limits.binaryLen = max
+	}
+}
+
+// WithMaxValues limits the number of values of a payload, including the items of Objects and Arrays, to max.
+// The payload is rejected with a *LimitError when the limit is exceeded.
+// Use ResetBytes to start counting again for the next payload.
+func WithMaxValues(max int) ReaderOption {
+	return func(r *Reader) {
+		r.limits.values = max
+	}
+}
+
+// WithMaxNestingDepth limits the nesting depth of Objects and Arrays to max,
+// e.g. 1 allows Objects and Arrays on channels, but no Objects or Arrays inside of them.
+// Deeper payloads are rejected with ErrMaxDepth, before they can exhaust the stack.
+// The default is DefaultMaxNestingDepth, a max < 0 means no limit.
+// Token streams are only limited by an explicit max, as they do not recurse.
+func WithMaxNestingDepth(max int) ReaderOption {
+	return func(r *Reader) {
+		r.limits.depth = max
+	}
+}
+
+// countValue counts a value of type t read from r, and enters Objects and Arrays (nested is true).
+// leaveNested must be called after reading nested values.
+func countValue(r io.Reader, t Type) (nested bool, err error) {
+	opts := options(r)
+	if opts == nil || t == TypeEndOfArray {
+		return false, nil
+	}
+	if err = opts.countValues(); err != nil {
+		return false, err
+	}
+	switch t {
+	case TypeObject, TypeArray, TypeArrayOf:
+		if err = opts.checkDepth(); err != nil {
+			return false, err
+		}
+		opts.limitState.depth++
+		return true, nil
+	}
+	return false, nil
+}
+
+// leaveNested leaves the Object or Array entered by countValue.
+func leaveNested(r io.Reader) {
+	options(r).limitState.depth--
+}
+
+// countToken counts an Object or Array started in the token stream, see Token.
+// The token stream does not recurse, so only an explicit max. nesting depth applies.
+func (r *Reader) countToken() error {
+	if err := r.countValues(); err != nil {
+		return err
+	}
+	if r.limits.depth > 0 {
+		return r.checkDepth()
+	}
+	return nil
+}
+
+func (r *Reader) countValues() error {
+	if r.limits.values <= 0 {
+		return nil
+	}
+	r.limitState.values++
+	if r.limitState.values > r.limits.values {
+		return &LimitError{Limit: "MaxValues", Max: r.limits.values}
+	}
+	return nil
+}
+
+// checkDepth checks the nesting depth of an Object or Array that the Reader is about to enter,
+// including the Objects and Arrays of the token stream.
+func (r *Reader) checkDepth() error {
+	max := r.limits.depth
+	if max == 0 {
+		max = DefaultMaxNestingDepth
+	}
+	if max > 0 && r.limitState.depth+len(r.tokens.stack)+1 > max {
+		return &LimitError{Limit: "MaxNestingDepth", Max: max}
+	}
+	return nil
+}
+
+// checkBinaryLen checks the length l (bytes) of a Binary value read from r, see WithMaxBinaryLen.
+func checkBinaryLen(r io.Reader, l uint64) error {
+	if opts := options(r); opts != nil && opts.limits.binaryLen > 0 && l > uint64(opts.limits.binaryLen) {
+		return &LimitError{Limit: "MaxBinaryLen", Max: opts.limits.binaryLen}
+	}
+	return nil
+}
+
+// maxStringLen returns the max. length of strings read from r, or 0 for no limit.
+func maxStringLen(r io.Reader) int {
+	if opts := options(r); opts != nil {
+		return opts.limits.stringLen
+	}
+	return 0
+}
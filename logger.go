@@ -0,0 +1,68 @@
+package xlpp
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// A Logger receives the diagnostic output of the package, e.g. a *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// NopLogger is a Logger that discards all output.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// stringLogger is a Logger that collects the output, one line per Printf.
+type stringLogger struct {
+	strings.Builder
+}
+
+func (l *stringLogger) Printf(format string, v ...interface{}) {
+	fmt.Fprintf(l, format, v...)
+	l.WriteByte('\n')
+}
+
+// stdLogger is the default Logger, that writes to the standard log package.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// WithLogger sets the Logger of the Reader, that is used by Print.
+// The default Logger writes to the standard log package.
+func WithLogger(l Logger) ReaderOption {
+	return func(r *Reader) {
+		r.log = l
+	}
+}
+
+// WithWriterLogger sets the Logger of the Writer, that receives diagnostics like compressed frames that are written
+// uncompressed (see WithWriterCompression). Without WithWriterLogger, the Writer does not log.
+func WithWriterLogger(l Logger) WriterOption {
+	return func(w *Writer) {
+		w.log = l
+	}
+}
+
+// logger returns the Logger of the Reader.
+func (r *Reader) logger() Logger {
+	if r.log == nil {
+		return stdLogger{}
+	}
+	return r.log
+}
+
+// logger returns the Logger of the Writer.
+func (w *Writer) logger() Logger {
+	if w.log == nil {
+		return NopLogger
+	}
+	return w.log
+}
@@ -0,0 +1,113 @@
+// Package lorawan extracts XLPP payloads from LoRaWAN frames, e.g. raw frames captured from a gateway.
+//
+// Parse splits a PHYPayload into its fields, and Decrypt decrypts the FRMPayload with the AppSKey (or NwkSKey for FPort 0),
+// as specified by LoRaWAN 1.0.x. The MIC is not verified.
+package lorawan
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/waziup/xlpp"
+)
+
+// MType is the message type of a frame.
+type MType byte
+
+// Message types of data frames.
+const (
+	UnconfirmedDataUp   MType = 2
+	UnconfirmedDataDown MType = 3
+	ConfirmedDataUp     MType = 4
+	ConfirmedDataDown   MType = 5
+)
+
+// Uplink reports whether the message type is an uplink.
+func (t MType) Uplink() bool {
+	return t == UnconfirmedDataUp || t == ConfirmedDataUp
+}
+
+// A Frame is a LoRaWAN data frame.
+type Frame struct {
+	MType   MType
+	DevAddr uint32
+	FCtrl   byte
+	// FCnt holds the 16 lower bits of the frame counter.
+	FCnt  uint16
+	FOpts []byte
+	// FPort is the port of the frame, or -1 if the frame has no FPort.
+	FPort      int
+	FRMPayload []byte
+	MIC        [4]byte
+}
+
+var errShort = errors.New("lorawan: frame too short")
+
+// Parse parses a PHYPayload. Only data frames are supported.
+// The FRMPayload is still encrypted, see Decrypt. FOpts and FRMPayload are slices of phy.
+func Parse(phy []byte) (*Frame, error) {
+	if len(phy) < 12 {
+		return nil, errShort
+	}
+	f := &Frame{MType: MType(phy[0] >> 5)}
+	if f.MType < UnconfirmedDataUp || f.MType > ConfirmedDataDown {
+		return nil, fmt.Errorf("lorawan: MType %d is not a data frame", f.MType)
+	}
+	f.DevAddr = binary.LittleEndian.Uint32(phy[1:5])
+	f.FCtrl = phy[5]
+	f.FCnt = binary.LittleEndian.Uint16(phy[6:8])
+	fopts := 8 + int(f.FCtrl&0x0f)
+	if len(phy) < fopts+4 {
+		return nil, errShort
+	}
+	f.FOpts = phy[8:fopts]
+	copy(f.MIC[:], phy[len(phy)-4:])
+	f.FPort = -1
+	if rest := phy[fopts : len(phy)-4]; len(rest) != 0 {
+		f.FPort = int(rest[0])
+		f.FRMPayload = rest[1:]
+	}
+	return f, nil
+}
+
+// Decrypt decrypts (or encrypts) the FRMPayload in place with the 16 byte AppSKey, or NwkSKey if FPort is 0.
+// fcntHigh are the upper 16 bits of the 32 bit frame counter, that are not transmitted. They are 0 for most devices.
+func (f *Frame) Decrypt(key []byte, fcntHigh uint16) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	var a, s [16]byte
+	a[0] = 0x01
+	if !f.MType.Uplink() {
+		a[5] = 1
+	}
+	binary.LittleEndian.PutUint32(a[6:10], f.DevAddr)
+	binary.LittleEndian.PutUint32(a[10:14], uint32(fcntHigh)<<16|uint32(f.FCnt))
+	for i := 0; i < len(f.FRMPayload); i += 16 {
+		a[15] = byte(i/16 + 1)
+		block.Encrypt(s[:], a[:])
+		for j := 0; j < 16 && i+j < len(f.FRMPayload); j++ {
+			f.FRMPayload[i+j] ^= s[j]
+		}
+	}
+	return nil
+}
+
+// Decode parses the PHYPayload, decrypts the FRMPayload with the key if the key is not nil,
+// and decodes the XLPP message. It returns the message and the FPort.
+func Decode(phy []byte, key []byte) (m xlpp.Message, fport int, err error) {
+	f, err := Parse(append([]byte(nil), phy...))
+	if err != nil {
+		return nil, -1, err
+	}
+	if key != nil {
+		if err = f.Decrypt(key, 0); err != nil {
+			return nil, f.FPort, err
+		}
+	}
+	m, err = xlpp.NewBytesReader(f.FRMPayload).ReadMessage()
+	return m, f.FPort, err
+}
@@ -0,0 +1,58 @@
+package lorawan_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/lorawan"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestDecrypt(t *testing.T) {
+	f, err := lorawan.Parse(unhex("40F17DBE4900020001954378762B11FF0D"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.MType != lorawan.UnconfirmedDataUp || f.DevAddr != 0x49be7df1 || f.FCnt != 2 || f.FPort != 1 {
+		t.Fatalf("frame: %+v", f)
+	}
+	if err := f.Decrypt(unhex("ec925802ae430ca77fd3dd73cb2cc588"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(f.FRMPayload) != "test" {
+		t.Fatalf("FRMPayload: %q", f.FRMPayload)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	key := unhex("ec925802ae430ca77fd3dd73cb2cc588")
+	var buf bytes.Buffer
+	temp := xlpp.Temperature(23.5)
+	xlpp.NewWriter(&buf).Add(3, &temp)
+
+	// encrypt an uplink on FPort 2
+	phy := append(unhex("40F17DBE49000A0002"), buf.Bytes()...)
+	phy = append(phy, 0, 0, 0, 0)
+	f, _ := lorawan.Parse(phy)
+	f.Decrypt(key, 0) // in place
+
+	m, fport, err := lorawan.Decode(phy, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fport != 2 || len(m) != 1 || m[0].Channel != 3 || *m[0].Value.(*xlpp.Temperature) != temp {
+		t.Fatalf("decoded: %d %v", fport, m)
+	}
+	if _, err := lorawan.Parse(unhex("20F17DBE4900")); err == nil {
+		t.Fatal("expected error for short frame")
+	}
+}
@@ -47,7 +47,7 @@ func (v *DigitalInput) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the DigitalInput to the writer.
 func (v DigitalInput) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v)})
+	m, err := writeTo(w, []byte{byte(v)})
 	return int64(m), err
 }
 
@@ -75,7 +75,7 @@ func (v *DigitalOutput) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the DigitalOutput to the writer.
 func (v DigitalOutput) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v)})
+	m, err := writeTo(w, []byte{byte(v)})
 	return int64(m), err
 }
 
@@ -104,8 +104,8 @@ func (v *AnalogInput) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the AnalogInput to the writer.
 func (v AnalogInput) WriteTo(w io.Writer) (n int64, err error) {
-	i := int16(v * 100)
-	m, err := w.Write([]byte{byte(i >> 8), byte(i)})
+	i := int16(trunc(float64(v) * 100))
+	m, err := writeTo(w, []byte{byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -134,8 +134,8 @@ func (v *AnalogOutput) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the AnalogOutput to the writer.
 func (v AnalogOutput) WriteTo(w io.Writer) (n int64, err error) {
-	d := int16(v * 100)
-	m, err := w.Write([]byte{byte(d >> 8), byte(d)})
+	d := int16(trunc(float64(v) * 100))
+	m, err := writeTo(w, []byte{byte(d >> 8), byte(d)})
 	return int64(m), err
 }
 
@@ -163,7 +163,7 @@ func (v *Luminosity) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Luminosity to the writer.
 func (v Luminosity) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v >> 8), byte(v)})
+	m, err := writeTo(w, []byte{byte(v >> 8), byte(v)})
 	return int64(m), err
 }
 
@@ -194,7 +194,7 @@ func (v *Presence) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Presence to the writer.
 func (v Presence) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v)})
+	m, err := writeTo(w, []byte{byte(v)})
 	return int64(m), err
 }
 
@@ -224,8 +224,8 @@ func (v *Temperature) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Temperature to the writer.
 func (v Temperature) WriteTo(w io.Writer) (n int64, err error) {
-	i := int16(v * 10)
-	m, err := w.Write([]byte{byte(i >> 8), byte(i)})
+	i := int16(trunc(float64(v) * 10))
+	m, err := writeTo(w, []byte{byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -254,7 +254,7 @@ func (v *RelativeHumidity) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the RelativeHumidity to the writer.
 func (v RelativeHumidity) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v * 2)})
+	m, err := writeTo(w, []byte{byte(trunc(float64(v) * 2))})
 	return int64(m), err
 }
 
@@ -289,10 +289,10 @@ func (v *Accelerometer) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Accelerometer to the writer.
 func (v Accelerometer) WriteTo(w io.Writer) (n int64, err error) {
-	vx := int16(v.X * 1000)
-	vy := int16(v.Y * 1000)
-	vz := int16(v.Z * 1000)
-	m, err := w.Write([]byte{byte(vx >> 8), byte(vx), byte(vy >> 8), byte(vy), byte(vz >> 8), byte(vz)})
+	vx := int16(trunc(v.X * 1000))
+	vy := int16(trunc(v.Y * 1000))
+	vz := int16(trunc(v.Z * 1000))
+	m, err := writeTo(w, []byte{byte(vx >> 8), byte(vx), byte(vy >> 8), byte(vy), byte(vz >> 8), byte(vz)})
 	return int64(m), err
 }
 
@@ -321,8 +321,8 @@ func (v *BarometricPressure) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the BarometricPressure to the writer.
 func (v BarometricPressure) WriteTo(w io.Writer) (n int64, err error) {
-	i := int16(v * 10)
-	m, err := w.Write([]byte{byte(i >> 8), byte(i)})
+	i := int16(trunc(float64(v) * 10))
+	m, err := writeTo(w, []byte{byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -357,10 +357,10 @@ func (v *Gyrometer) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Gyrometer to the writer.
 func (v Gyrometer) WriteTo(w io.Writer) (n int64, err error) {
-	vx := int16(v.X * 100)
-	vy := int16(v.Y * 100)
-	vz := int16(v.Z * 100)
-	m, err := w.Write([]byte{byte(vx >> 8), byte(vx), byte(vy >> 8), byte(vy), byte(vz >> 8), byte(vz)})
+	vx := int16(trunc32(float64(v.X) * 100))
+	vy := int16(trunc32(float64(v.Y) * 100))
+	vz := int16(trunc32(float64(v.Z) * 100))
+	m, err := writeTo(w, []byte{byte(vx >> 8), byte(vx), byte(vy >> 8), byte(vy), byte(vz >> 8), byte(vz)})
 	return int64(m), err
 }
 
@@ -402,13 +402,29 @@ func abs32(f float64) float64 {
 	return float64(math.Abs(float64(f)))
 }
 
+// int24 returns the signed 24-bit big endian integer of b.
+func int24(b []byte) int32 {
+	return int32(uint32(b[0])<<24|uint32(b[1])<<16|uint32(b[2])<<8) >> 8
+}
+
+// trunc truncates the scaled value f like an integer conversion,
+// but ignores floating point errors, so that 1.15*100 truncates to 115 and not 114.
+func trunc(f float64) float64 {
+	return math.Trunc(math.Round(f*1e6) / 1e6)
+}
+
+// trunc32 is trunc for values scaled from float32, which have a lower precision.
+func trunc32(f float64) float64 {
+	return math.Trunc(math.Round(f*1e2) / 1e2)
+}
+
 // ReadFrom reads the GPS from the reader.
 func (v *GPS) ReadFrom(r io.Reader) (n int64, err error) {
 	var b [9]byte
 	n, err = readFrom(r, b[:])
-	lat := int32(b[0])<<16 + int32(b[1])<<8 + int32(b[2])
-	lon := int32(b[3])<<16 + int32(b[4])<<8 + int32(b[5])
-	alt := int32(b[6])<<16 + int32(b[7])<<8 + int32(b[8])
+	lat := int24(b[0:3])
+	lon := int24(b[3:6])
+	alt := int24(b[6:9])
 	v.Latitude = float64(lat) / 10000
 	v.Longitude = float64(lon) / 10000
 	v.Meters = float64(alt) / 100
@@ -417,18 +433,50 @@ func (v *GPS) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the GPS to the writer.
 func (v GPS) WriteTo(w io.Writer) (n int64, err error) {
-	lat := int32(v.Latitude * 10000)
-	lon := int32(v.Longitude * 10000)
-	alt := int32(v.Meters * 100)
-	m, err := w.Write([]byte{byte(lat >> 16), byte(lat >> 8), byte(lat), byte(lon >> 16), byte(lon >> 8), byte(lon), byte(alt >> 16), byte(alt >> 8), byte(alt)})
+	lat := int32(trunc(v.Latitude * 10000))
+	lon := int32(trunc(v.Longitude * 10000))
+	alt := int32(trunc(v.Meters * 100))
+	m, err := writeTo(w, []byte{byte(lat >> 16), byte(lat >> 8), byte(lat), byte(lon >> 16), byte(lon >> 8), byte(lon), byte(alt >> 16), byte(alt >> 8), byte(alt)})
 	return int64(m), err
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// readFrom reads exactly len(b) bytes from the reader.
+// Readers that implement io.ByteReader (like the Reader's bufio.Reader) are read byte by byte, so that b does not escape to the heap.
 func readFrom(r io.Reader, b []byte) (n int64, err error) {
+	if br, ok := r.(io.ByteReader); ok {
+		for i := range b {
+			b[i], err = br.ReadByte()
+			if err != nil {
+				if err == io.EOF && i != 0 {
+					err = io.ErrUnexpectedEOF
+				}
+				return
+			}
+			n++
+		}
+		return
+	}
+	buf := make([]byte, len(b))
 	var m int
-	m, err = io.ReadFull(r, b[:])
+	m, err = io.ReadFull(r, buf)
+	copy(b, buf[:m])
 	n += int64(m)
 	return
 }
+
+// writeTo writes b to the writer.
+// Writers that implement io.ByteWriter (like bytes.Buffer and bufio.Writer) are written byte by byte, so that b does not escape to the heap.
+func writeTo(w io.Writer, b []byte) (n int, err error) {
+	if bw, ok := w.(io.ByteWriter); ok {
+		for _, c := range b {
+			if err = bw.WriteByte(c); err != nil {
+				return
+			}
+			n++
+		}
+		return
+	}
+	return w.Write(append([]byte(nil), b...))
+}
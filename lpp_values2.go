@@ -2,8 +2,10 @@ package xlpp
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"time"
 )
 
@@ -50,8 +52,8 @@ func (v *Voltage) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Voltage to the writer.
 func (v Voltage) WriteTo(w io.Writer) (n int64, err error) {
-	i := int16(v * 100)
-	m, err := w.Write([]byte{byte(i >> 8), byte(i)})
+	i := int16(trunc(float64(v) * 100))
+	m, err := writeTo(w, []byte{byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -81,8 +83,8 @@ func (v *Current) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Current to the writer.
 func (v Current) WriteTo(w io.Writer) (n int64, err error) {
-	i := int16(v * 1000)
-	m, err := w.Write([]byte{byte(i >> 8), byte(i)})
+	i := int16(trunc(float64(v) * 1000))
+	m, err := writeTo(w, []byte{byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -110,14 +112,20 @@ func (v *Frequency) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Frequency to the writer.
 func (v Frequency) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	m, err := writeTo(w, []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
 	return int64(m), err
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
-// Percentage is a one byte integer value (unsigned).
-type Percentage int8
+// Percentage is a one byte integer value 0-100% (unsigned).
+// Values above 100 are rejected when writing, see ErrPercentageRange. Readers decode them,
+// unless WithMarkerValidation is used, so that payloads of devices that exceed the range can still be read.
+// Use ExtendedPercentage for values up to 255%.
+type Percentage uint8
+
+// ErrPercentageRange is returned for Percentage values above 100.
+var ErrPercentageRange = errors.New("xlpp: percentage out of range 0..100")
 
 // XLPPType for Percentage returns TypePercentage.
 func (v Percentage) XLPPType() Type {
@@ -128,6 +136,11 @@ func (v Percentage) String() string {
 	return fmt.Sprintf("%d", v)
 }
 
+// Valid reports whether the Percentage is in the range 0..100.
+func (v Percentage) Valid() bool {
+	return v <= 100
+}
+
 // ReadFrom reads the Percentage from the reader.
 func (v *Percentage) ReadFrom(r io.Reader) (n int64, err error) {
 	var b [1]byte
@@ -138,7 +151,10 @@ func (v *Percentage) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Percentage to the writer.
 func (v Percentage) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v)})
+	if !v.Valid() {
+		return 0, ErrPercentageRange
+	}
+	m, err := writeTo(w, []byte{byte(v)})
 	return int64(m), err
 }
 
@@ -169,7 +185,7 @@ func (v *Altitude) ReadFrom(r io.Reader) (n int64, err error) {
 // WriteTo writes the Altitude to the writer.
 func (v Altitude) WriteTo(w io.Writer) (n int64, err error) {
 	i := int16(v)
-	m, err := w.Write([]byte{byte(i >> 8), byte(i)})
+	m, err := writeTo(w, []byte{byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -197,7 +213,7 @@ func (v *Concentration) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Concentration to the writer.
 func (v Concentration) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v >> 8), byte(v)})
+	m, err := writeTo(w, []byte{byte(v >> 8), byte(v)})
 	return int64(m), err
 }
 
@@ -225,13 +241,14 @@ func (v *Power) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Power to the writer.
 func (v Power) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v >> 8), byte(v)})
+	m, err := writeTo(w, []byte{byte(v >> 8), byte(v)})
 	return int64(m), err
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
 // Distance is a 4-byte floating point number [m] with 0.001 data resolution (unsigned).
+// Negative values are written as zero.
 type Distance float64
 
 // XLPPType for Distance returns TypeDistance.
@@ -247,21 +264,22 @@ func (v Distance) String() string {
 func (v *Distance) ReadFrom(r io.Reader) (n int64, err error) {
 	var b [4]byte
 	n, err = readFrom(r, b[:])
-	d := int32(b[0])<<24 + int32(b[1])<<16 + int32(b[2])<<8 + int32(b[3])
+	d := uint32(b[0])<<24 + uint32(b[1])<<16 + uint32(b[2])<<8 + uint32(b[3])
 	*v = Distance(d) / 1000
 	return
 }
 
 // WriteTo writes the Distance to the writer.
 func (v Distance) WriteTo(w io.Writer) (n int64, err error) {
-	i := int32(v * 1000)
-	m, err := w.Write([]byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+	i := uint32(math.Max(0, math.Min(trunc(float64(v)*1000), math.MaxUint32)))
+	m, err := writeTo(w, []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 
 // Energy is a 4-byte floating point number [kWh] with 0.001 data resolution (unsigned).
+// Negative values are written as zero.
 type Energy float64
 
 // XLPPType for Energy returns TypeEnergy.
@@ -270,22 +288,22 @@ func (v Energy) XLPPType() Type {
 }
 
 func (v Energy) String() string {
-	return fmt.Sprintf("%.4f m", v)
+	return fmt.Sprintf("%.4f kWh", v)
 }
 
 // ReadFrom reads the Energy from the reader.
 func (v *Energy) ReadFrom(r io.Reader) (n int64, err error) {
 	var b [4]byte
 	n, err = readFrom(r, b[:])
-	d := int32(b[0])<<24 + int32(b[1])<<16 + int32(b[2])<<8 + int32(b[3])
+	d := uint32(b[0])<<24 + uint32(b[1])<<16 + uint32(b[2])<<8 + uint32(b[3])
 	*v = Energy(d) / 1000
 	return
 }
 
 // WriteTo writes the Energy to the writer.
 func (v Energy) WriteTo(w io.Writer) (n int64, err error) {
-	i := int32(v * 1000)
-	m, err := w.Write([]byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+	i := uint32(math.Max(0, math.Min(trunc(float64(v)*1000), math.MaxUint32)))
+	m, err := writeTo(w, []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -315,7 +333,7 @@ func (v *Direction) ReadFrom(r io.Reader) (n int64, err error) {
 // WriteTo writes the Direction to the writer.
 func (v Direction) WriteTo(w io.Writer) (n int64, err error) {
 	i := uint16(v)
-	m, err := w.Write([]byte{byte(i >> 8), byte(i)})
+	m, err := writeTo(w, []byte{byte(i >> 8), byte(i)})
 	return int64(m), err
 }
 
@@ -345,10 +363,25 @@ func (v *UnixTime) ReadFrom(r io.Reader) (n int64, err error) {
 // WriteTo writes the UnixTime to the writer.
 func (v UnixTime) WriteTo(w io.Writer) (n int64, err error) {
 	u := uint32(time.Time(v).Unix())
-	m, err := w.Write([]byte{byte(u >> 24), byte(u >> 16), byte(u >> 8), byte(u)})
+	m, err := writeTo(w, []byte{byte(u >> 24), byte(u >> 16), byte(u >> 8), byte(u)})
 	return int64(m), err
 }
 
+// MarshalJSON marshals the UnixTime as seconds since 1970-01-01.
+func (v UnixTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(v).Unix())
+}
+
+// UnmarshalJSON unmarshals the UnixTime from seconds since 1970-01-01.
+func (v *UnixTime) UnmarshalJSON(data []byte) error {
+	var sec uint32
+	if err := json.Unmarshal(data, &sec); err != nil {
+		return err
+	}
+	*v = UnixTime(time.Unix(int64(sec), 0))
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 // Colour is a struct of {R, G, B} integer numbers with 1 byte each.
@@ -377,7 +410,7 @@ func (v *Colour) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Colour to the writer.
 func (v Colour) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v.R), byte(v.G), byte(v.B)})
+	m, err := writeTo(w, []byte{byte(v.R), byte(v.G), byte(v.B)})
 	return int64(m), err
 }
 
@@ -386,13 +419,18 @@ func (v Colour) MarshalJSON() ([]byte, error) {
 	return json.Marshal(str)
 }
 
+// UnmarshalJSON unmarshals the Colour from a string like "#ffaa00", "#fa0" or "orange", see ParseColour.
 func (v *Colour) UnmarshalJSON(data []byte) error {
 	var str string
 	if err := json.Unmarshal(data, &str); err != nil {
 		return err
 	}
-	_, err := fmt.Sscanf(str, "#%02x%02x%02x", &v.R, &v.G, &v.B)
-	return err
+	c, err := ParseColour(str)
+	if err != nil {
+		return err
+	}
+	*v = c
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -424,9 +462,9 @@ func (v *Switch) ReadFrom(r io.Reader) (n int64, err error) {
 func (v Switch) WriteTo(w io.Writer) (n int64, err error) {
 	var m int
 	if v {
-		m, err = w.Write([]byte{byte(1)})
+		m, err = writeTo(w, []byte{byte(1)})
 	} else {
-		m, err = w.Write([]byte{byte(0)})
+		m, err = writeTo(w, []byte{byte(0)})
 	}
 	return int64(m), err
 }
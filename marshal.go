@@ -0,0 +1,227 @@
+package xlpp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var errMarshalType = errors.New("xlpp: Marshal requires a struct or a pointer to a struct")
+var errUnmarshalType = errors.New("xlpp: Unmarshal requires a non-nil pointer to a struct")
+
+// valueType is the reflect.Type of the Value interface.
+var valueType = reflect.TypeOf((*Value)(nil)).Elem()
+
+// fieldTag is the parsed `xlpp:"name,channel=N,omitempty"` tag of a struct field.
+type fieldTag struct {
+	index     int
+	name      string
+	channel   int
+	omitEmpty bool
+}
+
+// parseTags returns the tagged fields of the struct type.
+func parseTags(t reflect.Type) ([]fieldTag, error) {
+	var tags []fieldTag
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("xlpp")
+		if !ok || tag == "-" {
+			continue
+		}
+		if f.PkgPath != "" {
+			return nil, fmt.Errorf("xlpp: field %s: unexported fields can not be tagged", f.Name)
+		}
+		parts := strings.Split(tag, ",")
+		ft := fieldTag{index: i, name: parts[0], channel: -1}
+		for _, part := range parts[1:] {
+			switch {
+			case strings.HasPrefix(part, "channel="):
+				c, err := strconv.Atoi(strings.TrimPrefix(part, "channel="))
+				if err != nil || c < 0 || c > 255 {
+					return nil, fmt.Errorf("xlpp: field %s: bad channel %q", f.Name, part)
+				}
+				ft.channel = c
+			case part == "omitempty":
+				ft.omitEmpty = true
+			default:
+				return nil, fmt.Errorf("xlpp: field %s: unknown tag option %q", f.Name, part)
+			}
+		}
+		if ft.channel == -1 {
+			return nil, fmt.Errorf("xlpp: field %s: missing channel", f.Name)
+		}
+		if ft.name == "" && !implementsValue(f.Type) {
+			return nil, fmt.Errorf("xlpp: field %s: missing type name", f.Name)
+		}
+		if ft.name != "" && LookupName(ft.name) == nil {
+			return nil, fmt.Errorf("xlpp: field %s: unknown type %q", f.Name, ft.name)
+		}
+		tags = append(tags, ft)
+	}
+	return tags, nil
+}
+
+// implementsValue reports whether t, or a pointer to t, implements Value.
+func implementsValue(t reflect.Type) bool {
+	return t.Implements(valueType) || reflect.PtrTo(t).Implements(valueType)
+}
+
+// Marshal encodes the tagged fields of the struct v, in the order of the fields.
+// The tag `xlpp:"temperature,channel=3"` encodes a field as Temperature on channel 3.
+// Fields of Value types (e.g. xlpp.GPS) need no type name, e.g. `xlpp:",channel=4"`.
+// Other fields are converted to the named type: numbers to numeric types, bools to Bools or Switches,
+// strings to Strings, []byte to Binary and time.Time to UnixTime.
+// Nil pointers, and zero values of fields with the omitempty option, are left out. Untagged fields are ignored,
+// tagged fields must be exported.
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errMarshalType
+	}
+	tags, err := parseTags(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, tag := range tags {
+		fv, ok := indirect(rv.Field(tag.index))
+		if !ok {
+			continue
+		}
+		if tag.omitEmpty && fv.IsZero() {
+			continue
+		}
+		value, err := toValue(fv, tag.name)
+		if err != nil {
+			return nil, fmt.Errorf("xlpp: field %s: %w", rv.Type().Field(tag.index).Name, err)
+		}
+		if _, err = w.Add(tag.channel, value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// indirect follows the pointers and interfaces of the field value. It returns false for nil values.
+func indirect(fv reflect.Value) (reflect.Value, bool) {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return fv, false
+		}
+		fv = fv.Elem()
+	}
+	return fv, true
+}
+
+// toValue converts the field value to a Value of the named type.
+func toValue(fv reflect.Value, name string) (Value, error) {
+	if name == "" || implementsValue(fv.Type()) {
+		p := reflect.New(fv.Type())
+		p.Elem().Set(fv)
+		value, ok := p.Interface().(Value)
+		if !ok {
+			value = fv.Interface().(Value)
+		}
+		if name != "" && NameOf(value) != name {
+			return nil, fmt.Errorf("%s is not %s", NameOf(value), name)
+		}
+		return value, nil
+	}
+	value := LookupName(name)()
+	target := reflect.ValueOf(value).Elem()
+	if !convertible(fv.Type(), target.Type()) {
+		return nil, fmt.Errorf("can not convert %v to %s", fv.Type(), name)
+	}
+	target.Set(fv.Convert(target.Type()))
+	return value, nil
+}
+
+// Unmarshal decodes the payload into the tagged fields of the struct that v points to, see Marshal.
+// Values on channels without field are ignored. If a channel has multiple values, the last value wins.
+// It fails if a value can not be converted to the type of its field, or if its type is not the type named in the tag.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errUnmarshalType
+	}
+	rv = rv.Elem()
+	tags, err := parseTags(rv.Type())
+	if err != nil {
+		return err
+	}
+	m, err := NewBytesReader(data).ReadMessage()
+	if err != nil {
+		return err
+	}
+	for _, e := range m {
+		if _, ok := e.Value.(Marker); ok {
+			continue
+		}
+		for _, tag := range tags {
+			if tag.channel != e.Channel {
+				continue
+			}
+			if tag.name != "" && tag.name != NameOf(e.Value) {
+				return fmt.Errorf("xlpp: channel %d: %s is not %s", e.Channel, NameOf(e.Value), tag.name)
+			}
+			fv := rv.Field(tag.index)
+			if err := setField(fv, e.Value); err != nil {
+				return fmt.Errorf("xlpp: field %s: %w", rv.Type().Field(tag.index).Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setField sets the field to the value, converting it to the type of the field.
+func setField(fv reflect.Value, v Value) error {
+	if fv.Kind() == reflect.Ptr {
+		p := reflect.New(fv.Type().Elem())
+		if err := setField(p.Elem(), v); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(fv.Type()) {
+		fv.Set(rv)
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if !convertible(rv.Type(), fv.Type()) {
+		return fmt.Errorf("can not convert %s to %v", NameOf(v), fv.Type())
+	}
+	fv.Set(rv.Convert(fv.Type()))
+	return nil
+}
+
+// convertible reports whether values of type from can be converted to type to without changing their meaning,
+// e.g. float64 to Temperature, but not int to String.
+func convertible(from, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	return kindClass(from.Kind()) == kindClass(to.Kind())
+}
+
+// kindClass groups the numeric kinds.
+func kindClass(k reflect.Kind) reflect.Kind {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+	return k
+}
@@ -0,0 +1,289 @@
+package xlpp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An Entry is a value or marker of a message, with its channel.
+type Entry struct {
+	Channel int
+	Value   Value
+}
+
+// A Message is the ordered list of entries (values and markers) of a XLPP payload, i.e. a whole frame.
+// It can be encoded and decoded with MarshalBinary and UnmarshalBinary, and modified with Set and Delete.
+type Message []Entry
+
+// ReadMessage reads all remaining entries from the reader.
+func (r *Reader) ReadMessage() (m Message, err error) {
+	for {
+		channel, value, err := r.Next()
+		if err != nil {
+			return m, err
+		}
+		if value == nil {
+			return m, nil
+		}
+		m = append(m, Entry{Channel: channel, Value: value})
+	}
+}
+
+// Priority returns the priority of the message, the last Priority marker, or PriorityRoutine if there is none.
+func (m Message) Priority() Priority {
+	p := PriorityRoutine
+	for _, e := range m {
+		if v, ok := e.Value.(*Priority); ok {
+			p = *v
+		}
+	}
+	return p
+}
+
+// Get returns the first value on the channel. Markers are ignored.
+func (m Message) Get(channel int) (Value, bool) {
+	for _, e := range m {
+		if _, ok := e.Value.(Marker); !ok && e.Channel == channel {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetByType returns the entries with values of type t, in message order.
+func (m Message) GetByType(t Type) []Entry {
+	var entries []Entry
+	for _, e := range m {
+		if _, ok := e.Value.(Marker); !ok && e.Value.XLPPType() == t {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Channels returns the channels of the values of the message, in order of their first occurrence. Markers are ignored.
+func (m Message) Channels() []int {
+	var channels []int
+	seen := make(map[int]bool)
+	for _, e := range m {
+		if _, ok := e.Value.(Marker); ok || seen[e.Channel] {
+			continue
+		}
+		seen[e.Channel] = true
+		channels = append(channels, e.Channel)
+	}
+	return channels
+}
+
+// Len returns the number of values of the message. Markers are not counted.
+func (m Message) Len() int {
+	n := 0
+	for _, e := range m {
+		if _, ok := e.Value.(Marker); !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Range calls f for the values of the message in message order, until f returns false. Markers are skipped.
+func (m Message) Range(f func(channel int, v Value) bool) {
+	for _, e := range m {
+		if _, ok := e.Value.(Marker); !ok && !f(e.Channel, e.Value) {
+			return
+		}
+	}
+}
+
+// Set replaces the first value on the channel, or appends the value if the channel has no value.
+// Markers are not values: append them to the message instead.
+func (m *Message) Set(channel int, v Value) {
+	for i, e := range *m {
+		if _, ok := e.Value.(Marker); !ok && e.Channel == channel {
+			(*m)[i].Value = v
+			return
+		}
+	}
+	*m = append(*m, Entry{Channel: channel, Value: v})
+}
+
+// Delete removes all values on the channel. Markers are kept.
+func (m *Message) Delete(channel int) {
+	kept := (*m)[:0]
+	for _, e := range *m {
+		if _, ok := e.Value.(Marker); ok || e.Channel != channel {
+			kept = append(kept, e)
+		}
+	}
+	for i := len(kept); i < len(*m); i++ {
+		(*m)[i] = Entry{}
+	}
+	*m = kept
+}
+
+// MarshalBinary encodes the message.
+func (m Message) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range m {
+		if _, err := w.Add(e.Channel, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the payload into the message, replacing all entries.
+func (m *Message) UnmarshalBinary(data []byte) error {
+	decoded, err := NewBytesReader(data).ReadMessage()
+	if err != nil {
+		return err
+	}
+	*m = decoded
+	return nil
+}
+
+// Canonicalize returns the canonical form of the message, for hashing, deduplication and comparison:
+// messages with the same values at the same times have the same canonical form.
+//   - Priority, ActuatorAck and Actuators markers come first, ordered by channel.
+//   - Consecutive Delay and MilliDelay markers are merged into one marker (a Delay if the sum is a whole number of seconds),
+//     zero delays and delays at the end of the message are removed.
+//   - Values between two delays are ordered by channel, keeping the order of values on the same channel.
+//
+// Bools are always encoded as TypeBoolTrue or TypeBoolFalse, and Object keys in sorted order,
+// so the encoded canonical forms of two messages are byte-identical if the canonical forms are equal.
+// The values are not copied.
+func (m Message) Canonicalize() Message {
+	var markers Message
+	var groups []Message
+	var delay time.Duration
+	for _, e := range m {
+		switch v := e.Value.(type) {
+		case *Delay:
+			delay += time.Duration(*v)
+			continue
+		case *MilliDelay:
+			delay += time.Duration(*v)
+			continue
+		case Marker:
+			markers = append(markers, e)
+			continue
+		}
+		if delay != 0 || len(groups) == 0 {
+			var group Message
+			if delay != 0 {
+				group = append(group, delayEntry(delay))
+			}
+			groups = append(groups, group)
+			delay = 0
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], e)
+	}
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].Channel < markers[j].Channel
+	})
+	c := make(Message, 0, len(m))
+	c = append(c, markers...)
+	for _, group := range groups {
+		values := group
+		if _, ok := group[0].Value.(Marker); ok {
+			c = append(c, group[0])
+			values = group[1:]
+		}
+		sort.SliceStable(values, func(i, j int) bool {
+			return values[i].Channel < values[j].Channel
+		})
+		c = append(c, values...)
+	}
+	return c
+}
+
+// delayEntry returns the marker entry of the delay d.
+// A MilliDelay is used if d is not a whole number of seconds.
+func delayEntry(d time.Duration) Entry {
+	if d%time.Second != 0 {
+		v := MilliDelay(d)
+		return Entry{Channel: ChanMilliDelay, Value: &v}
+	}
+	v := Delay(d)
+	return Entry{Channel: ChanDelay, Value: &v}
+}
+
+// Encode encodes the values, ordered by channel.
+// Markers can not be encoded with Encode, as the order of the values matters for markers: use a Writer instead.
+func Encode(values map[int]Value) ([]byte, error) {
+	channels := make([]int, 0, len(values))
+	for channel, v := range values {
+		if _, ok := v.(Marker); ok {
+			return nil, errEncodeMarker
+		}
+		if channel < 0 || channel > 255 {
+			return nil, fmt.Errorf("xlpp: channel %d out of range", channel)
+		}
+		channels = append(channels, channel)
+	}
+	sort.Ints(channels)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, channel := range channels {
+		if _, err := w.Add(channel, values[channel]); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+var errEncodeMarker = errors.New("xlpp: Encode can not encode markers")
+
+// Decode decodes the payload into a map of channels to values.
+// Markers are ignored. If a channel has multiple values, the last value wins: use ReadMessage to get all values.
+func Decode(data []byte) (map[int]Value, error) {
+	m, err := NewBytesReader(data).ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[int]Value, len(m))
+	for _, e := range m {
+		if _, ok := e.Value.(Marker); !ok {
+			values[e.Channel] = e.Value
+		}
+	}
+	return values, nil
+}
+
+// DecodeHexString decodes a payload in hex, as shown by the consoles of most LoRaWAN network servers,
+// e.g. "0067 00EB" or "0x006700eb". Whitespace and an optional "0x" prefix are ignored.
+func DecodeHexString(s string) (Message, error) {
+	data, err := DecodeHex(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewBytesReader(data).ReadMessage()
+}
+
+// DecodeHex decodes a hex payload, e.g. "0067 00EB" or "0x006700eb", ignoring whitespace and an optional "0x" prefix.
+func DecodeHex(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("xlpp: bad hex payload: %v", err)
+	}
+	return data, nil
+}
+
+// EncodeToHexString encodes the message as lowercase hex string, e.g. "006700eb".
+func EncodeToHexString(m Message) (string, error) {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
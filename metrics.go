@@ -0,0 +1,89 @@
+package xlpp
+
+import (
+	"errors"
+	"expvar"
+	"io"
+	"time"
+)
+
+// Metrics receives instrumentation events from Readers and Writers, see WithMetrics and WithWriterMetrics.
+// ExpvarMetrics publishes them with the expvar package, and other monitoring systems (e.g. OpenTelemetry or Prometheus)
+// can be connected by implementing the interface. Implementations must be safe for concurrent use
+// if they are shared by multiple Readers or Writers.
+type Metrics interface {
+	// Entry is called for every entry that has been decoded or encoded, with its type and size in bytes.
+	// Markers have the type 255.
+	Entry(t Type, size int)
+	// Error is called for every failed decode or encode.
+	Error(err error)
+	// Latency is called with the time it took to decode or encode an entry.
+	Latency(d time.Duration)
+}
+
+// WithMetrics reports the decoded entries, errors and latencies of the Reader to m.
+func WithMetrics(m Metrics) ReaderOption {
+	return func(r *Reader) {
+		r.metrics = m
+	}
+}
+
+// WithWriterMetrics reports the encoded entries, errors and latencies of the Writer to m.
+func WithWriterMetrics(m Metrics) WriterOption {
+	return func(w *Writer) {
+		w.metrics = m
+	}
+}
+
+// ExpvarMetrics are Metrics published with the expvar package, e.g. at /debug/vars.
+type ExpvarMetrics struct {
+	// Entries counts the entries by type name.
+	Entries expvar.Map
+	// Bytes counts the size of all entries.
+	Bytes expvar.Int
+	// Errors counts the errors by kind: "truncated", "too_large" and "invalid".
+	Errors expvar.Map
+	// Seconds is the total time spent decoding or encoding.
+	Seconds expvar.Float
+}
+
+// NewExpvarMetrics creates ExpvarMetrics and publishes them as expvar map with the name, e.g. "xlpp_decoder".
+// Like expvar.Publish, it panics if the name is already in use.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := new(ExpvarMetrics)
+	m.Entries.Init()
+	m.Errors.Init()
+	v := expvar.NewMap(name)
+	v.Set("entries", &m.Entries)
+	v.Set("bytes", &m.Bytes)
+	v.Set("errors", &m.Errors)
+	v.Set("seconds", &m.Seconds)
+	return m
+}
+
+// Entry counts the entry and its bytes.
+func (m *ExpvarMetrics) Entry(t Type, size int) {
+	name := t.Name()
+	if t == 255 {
+		name = "marker"
+	}
+	m.Entries.Add(name, 1)
+	m.Bytes.Add(int64(size))
+}
+
+// Error counts the error by kind.
+func (m *ExpvarMetrics) Error(err error) {
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		m.Errors.Add("truncated", 1)
+	case errors.Is(err, ErrTooLarge):
+		m.Errors.Add("too_large", 1)
+	default:
+		m.Errors.Add("invalid", 1)
+	}
+}
+
+// Latency adds the duration to the total time.
+func (m *ExpvarMetrics) Latency(d time.Duration) {
+	m.Seconds.Add(d.Seconds())
+}
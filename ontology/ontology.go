@@ -0,0 +1,102 @@
+// Package ontology annotates decoded XLPP messages with semantic metadata, as JSON-LD documents of
+// SOSA observations (see https://www.w3.org/TR/vocab-ssn/), for semantic-web and FIWARE-style platforms.
+//
+// Observed properties and units are taken from the M3-lite taxonomy (http://purl.org/iot/vocab/m3-lite#),
+// whose quantity kinds and units (e.g. "AirTemperature" and "DegreeCelsius") are also used by the Waziup platform.
+// Types with a fixed meaning are mapped with Properties, other channels are mapped with the Channels of an Annotator.
+package ontology
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/recorder"
+)
+
+// Context is the JSON-LD context of the annotated documents.
+var Context = map[string]interface{}{
+	"sosa": "http://www.w3.org/ns/sosa/",
+	"m3":   "http://purl.org/iot/vocab/m3-lite#",
+	"xsd":  "http://www.w3.org/2001/XMLSchema#",
+	"xlpp": "https://github.com/waziup/xlpp#",
+	"sosa:resultTime": map[string]interface{}{
+		"@type": "xsd:dateTime",
+	},
+}
+
+// A Property is an observable property with its unit, as compact IRIs, e.g. "m3:AirTemperature" and "m3:DegreeCelsius".
+type Property struct {
+	QuantityKind string
+	Unit         string
+}
+
+// Properties maps the XLPP types with a fixed meaning to their observable properties.
+var Properties = map[xlpp.Type]Property{
+	xlpp.TypeLuminosity:           {"m3:Illuminance", "m3:Lux"},
+	xlpp.TypePresence:             {"m3:Presence", ""},
+	xlpp.TypeTemperature:          {"m3:AirTemperature", "m3:DegreeCelsius"},
+	xlpp.TypeRelativeHumidity:     {"m3:RelativeHumidity", "m3:Percent"},
+	xlpp.TypeBarometricPressure:   {"m3:AtmosphericPressure", "m3:HectoPascal"},
+	xlpp.TypeBarometricPressure24: {"m3:AtmosphericPressure", "m3:HectoPascal"},
+	xlpp.TypeVoltage:              {"m3:Voltage", "m3:Volt"},
+	xlpp.TypeVoltageSigned:        {"m3:Voltage", "m3:Volt"},
+	xlpp.TypeCurrent:              {"m3:ElectricCurrent", "m3:Ampere"},
+	xlpp.TypeCurrentHiRange:       {"m3:ElectricCurrent", "m3:Ampere"},
+	xlpp.TypeFrequency:            {"m3:Frequency", "m3:Hertz"},
+	xlpp.TypeAltitude:             {"m3:Altitude", "m3:Metre"},
+	xlpp.TypePower:                {"m3:Power", "m3:Watt"},
+	xlpp.TypePowerPrecise:         {"m3:Power", "m3:Watt"},
+	xlpp.TypeDistance:             {"m3:Distance", "m3:Metre"},
+	xlpp.TypeDistanceLong:         {"m3:Distance", "m3:Metre"},
+	xlpp.TypeEnergy:               {"m3:Energy", "m3:KiloWattHour"},
+	xlpp.TypeDirection:            {"m3:Direction", "m3:DegreeAngle"},
+	xlpp.TypeGPS:                  {"m3:Position", ""},
+	xlpp.TypeGPS2D:                {"m3:Position", ""},
+}
+
+// An Annotator converts messages of a device to JSON-LD documents.
+type Annotator struct {
+	// Sensor is the IRI of the device. The sensors are the channels of the device, e.g. Sensor + "/5" for channel 5.
+	Sensor string
+	// Channels maps channels to observable properties, e.g. 4 to {"m3:SoilMoisture", "m3:Percent"} for a Percentage.
+	// They take precedence over the Properties of the types.
+	Channels map[int]Property
+}
+
+// Annotate converts the message, received at the given time, to a JSON-LD document with one sosa:Observation
+// per value. The decoded value is the sosa:hasSimpleResult, and the time the value has been measured
+// (see xlpp.Delay) the sosa:resultTime. Values without an observable property are typed xlpp:<type name>.
+// Markers are left out.
+func (a *Annotator) Annotate(received time.Time, m xlpp.Message) map[string]interface{} {
+	sensor := strings.TrimSuffix(a.Sensor, "/")
+	entries := recorder.Resolve(received, m)
+	graph := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		t := e.Value.XLPPType()
+		p, ok := a.Channels[e.Channel]
+		if !ok {
+			p, ok = Properties[t]
+		}
+		o := map[string]interface{}{
+			"@type":                "sosa:Observation",
+			"sosa:madeBySensor":    map[string]interface{}{"@id": sensor + "/" + strconv.Itoa(e.Channel)},
+			"sosa:hasSimpleResult": e.Value,
+			"sosa:resultTime":      e.Time.UTC().Format(time.RFC3339Nano),
+		}
+		if ok {
+			o["sosa:observedProperty"] = map[string]interface{}{"@id": p.QuantityKind}
+			if p.Unit != "" {
+				o["m3:hasUnit"] = map[string]interface{}{"@id": p.Unit}
+			}
+		} else {
+			o["sosa:observedProperty"] = map[string]interface{}{"@id": "xlpp:" + xlpp.NameOf(e.Value)}
+		}
+		graph = append(graph, o)
+	}
+	return map[string]interface{}{
+		"@context": Context,
+		"@graph":   graph,
+	}
+}
@@ -0,0 +1,41 @@
+package ontology_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/ontology"
+)
+
+func TestAnnotate(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	temp := xlpp.Temperature(21.5)
+	moisture := xlpp.Percentage(35)
+	delay := xlpp.Delay(time.Minute)
+	count := xlpp.Integer(3)
+	a := ontology.Annotator{
+		Sensor:   "urn:dev:eui:0102030405060708",
+		Channels: map[int]ontology.Property{2: {QuantityKind: "m3:SoilMoisture", Unit: "m3:Percent"}},
+	}
+	doc := a.Annotate(received, xlpp.Message{
+		{Channel: 1, Value: &temp},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 2, Value: &moisture},
+		{Channel: 3, Value: &count},
+	})
+	data, err := json.Marshal(doc["@graph"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"@type":"sosa:Observation","m3:hasUnit":{"@id":"m3:DegreeCelsius"},"sosa:hasSimpleResult":21.5,"sosa:madeBySensor":{"@id":"urn:dev:eui:0102030405060708/1"},"sosa:observedProperty":{"@id":"m3:AirTemperature"},"sosa:resultTime":"2024-05-01T12:00:00Z"},` +
+		`{"@type":"sosa:Observation","m3:hasUnit":{"@id":"m3:Percent"},"sosa:hasSimpleResult":35,"sosa:madeBySensor":{"@id":"urn:dev:eui:0102030405060708/2"},"sosa:observedProperty":{"@id":"m3:SoilMoisture"},"sosa:resultTime":"2024-05-01T11:59:00Z"},` +
+		`{"@type":"sosa:Observation","sosa:hasSimpleResult":3,"sosa:madeBySensor":{"@id":"urn:dev:eui:0102030405060708/3"},"sosa:observedProperty":{"@id":"xlpp:integer"},"sosa:resultTime":"2024-05-01T11:59:00Z"}]`
+	if string(data) != want {
+		t.Fatalf("graph:\n%s\nwant\n%s", data, want)
+	}
+	if doc["@context"] == nil {
+		t.Fatal("missing @context")
+	}
+}
@@ -0,0 +1,142 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+// A Suggestion is a way to encode a message with less bytes, see Optimize.
+type Suggestion struct {
+	// Index is the index of the entry in the message.
+	Index int
+	// Path is the path of the value inside the Objects and Arrays of the entry, e.g. "sensors[2].temperature".
+	// It is empty for the entry value itself.
+	Path string
+	// Message describes the suggested change.
+	Message string
+	// Savings is the estimated number of bytes saved.
+	Savings int
+}
+
+func (s Suggestion) String() string {
+	where := "entry " + strconv.Itoa(s.Index)
+	if s.Path != "" {
+		where += " " + s.Path
+	}
+	return fmt.Sprintf("%s: %s (saves %d bytes)", where, s.Message, s.Savings)
+}
+
+// maxKeyLen is the length of Object keys above which Optimize suggests shorter keys.
+const maxKeyLen = 4
+
+// Optimize analyzes the message and suggests smaller encodings, with the estimated byte savings:
+//   - Integers that fit into a DigitalInput,
+//   - Arrays of values of the same type, that could be an ArrayOf,
+//   - long Object keys,
+//   - redundant markers, e.g. zero or consecutive Delays, and repeated Actuators markers.
+//
+// The suggestions are ordered by entry.
+func Optimize(m Message) []Suggestion {
+	var s []Suggestion
+	var prevDelay bool
+	var actuators, actuatorsWithChannel bool
+	for i, e := range m {
+		switch v := e.Value.(type) {
+		case *Delay, *MilliDelay:
+			if isZeroDelay(v) {
+				s = append(s, Suggestion{Index: i, Message: "remove zero Delay", Savings: 1 + size(v)})
+			} else if prevDelay {
+				s = append(s, Suggestion{Index: i, Message: "merge with the previous Delay", Savings: 1 + size(v)})
+			}
+			prevDelay = true
+			continue
+		case *Actuators:
+			if actuators {
+				s = append(s, Suggestion{Index: i, Message: "remove repeated Actuators marker", Savings: 1 + size(v)})
+			}
+			actuators = true
+		case *ActuatorsWithChannel:
+			if actuatorsWithChannel {
+				s = append(s, Suggestion{Index: i, Message: "remove repeated ActuatorsWithChannel marker", Savings: 1 + size(v)})
+			}
+			actuatorsWithChannel = true
+		default:
+			s = optimizeValue(s, i, "", e.Value)
+		}
+		prevDelay = false
+	}
+	return s
+}
+
+func isZeroDelay(v Value) bool {
+	switch v := v.(type) {
+	case *Delay:
+		return *v == 0
+	case *MilliDelay:
+		return *v == 0
+	}
+	return false
+}
+
+// optimizeValue appends the suggestions for the (nested) value v of entry i.
+func optimizeValue(s []Suggestion, i int, path string, v Value) []Suggestion {
+	switch v := v.(type) {
+	case *Integer:
+		if *v >= 0 && *v <= 255 {
+			if n := varintLen(int64(*v)); n > 1 {
+				s = append(s, Suggestion{Index: i, Path: path, Message: "use DigitalInput instead of Integer", Savings: n - 1})
+			}
+		}
+	case *Object:
+		for _, key := range v.keys() {
+			p := key
+			if path != "" {
+				p = path + "." + key
+			}
+			if len(key) > maxKeyLen {
+				s = append(s, Suggestion{Index: i, Path: p, Message: fmt.Sprintf("shorten key %q", key), Savings: len(key) - maxKeyLen})
+			}
+			s = optimizeValue(s, i, p, (*v)[key])
+		}
+	case *Array:
+		if len(*v) > 1 && sameType(*v) {
+			s = append(s, Suggestion{Index: i, Path: path, Message: "use ArrayOf " + NameOf((*v)[0]), Savings: len(*v) - 1})
+		}
+		for j, item := range *v {
+			s = optimizeValue(s, i, path+"["+strconv.Itoa(j)+"]", item)
+		}
+	}
+	return s
+}
+
+// sameType returns true if all values are of the same, non nested type,
+// that can be the item type of an ArrayOf.
+func sameType(values []Value) bool {
+	t := values[0].XLPPType()
+	if !arrayOfItemType(t) {
+		return false
+	}
+	for _, v := range values {
+		switch v.(type) {
+		case *Object, *Array, *Bool:
+			return false
+		}
+		if v.XLPPType() != t {
+			return false
+		}
+	}
+	return true
+}
+
+func varintLen(i int64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutVarint(buf[:], i)
+}
+
+// size returns the encoded size of the value, without its type.
+func size(v Value) int {
+	n, _ := v.WriteTo(ioutil.Discard)
+	return int(n)
+}
@@ -0,0 +1,373 @@
+// Package pipeline chains transformations of decoded XLPP messages, e.g. on a gateway that forwards payloads.
+//
+// A Pipeline decodes a payload, passes the message through its stages (filter, rename, convert, redact, ...)
+// and encodes the result. Pipelines are built programmatically from Stages, or parsed from a textual description,
+// see Parse:
+//
+//	keep 1,2,5
+//	rename 5=3
+//	convert distance=distancelong
+//	redact 2
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/waziup/xlpp"
+)
+
+// A Stage transforms a message.
+// Stages must not modify the values of the message they are given, but may return new values.
+type Stage interface {
+	Apply(m xlpp.Message) (xlpp.Message, error)
+}
+
+// StageFunc is a function that implements Stage.
+type StageFunc func(m xlpp.Message) (xlpp.Message, error)
+
+// Apply calls f(m).
+func (f StageFunc) Apply(m xlpp.Message) (xlpp.Message, error) {
+	return f(m)
+}
+
+// A Pipeline decodes payloads, transforms the messages with its Stages and encodes them again.
+type Pipeline struct {
+	Stages []Stage
+
+	// Decode decodes a payload. If nil, the payload is decoded with a xlpp.Reader.
+	Decode func(data []byte) (xlpp.Message, error)
+	// Encode encodes a message. If nil, the message is encoded with a xlpp.Writer.
+	Encode func(m xlpp.Message) ([]byte, error)
+}
+
+// New creates a Pipeline with the stages.
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{Stages: stages}
+}
+
+// Apply passes the message through all stages.
+func (p *Pipeline) Apply(m xlpp.Message) (xlpp.Message, error) {
+	var err error
+	for _, s := range p.Stages {
+		if m, err = s.Apply(m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Process decodes the payload, applies the stages and encodes the result.
+func (p *Pipeline) Process(data []byte) ([]byte, error) {
+	decode, encode := p.Decode, p.Encode
+	if decode == nil {
+		decode = func(data []byte) (xlpp.Message, error) {
+			return xlpp.NewBytesReader(data).ReadMessage()
+		}
+	}
+	if encode == nil {
+		encode = Encode
+	}
+	m, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if m, err = p.Apply(m); err != nil {
+		return nil, err
+	}
+	return encode(m)
+}
+
+// Encode encodes the message with a xlpp.Writer.
+func Encode(m xlpp.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for _, e := range m {
+		if _, err := w.Add(e.Channel, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Filter keeps the entries for which keep returns true.
+func Filter(keep func(e xlpp.Entry) bool) Stage {
+	return StageFunc(func(m xlpp.Message) (xlpp.Message, error) {
+		f := make(xlpp.Message, 0, len(m))
+		for _, e := range m {
+			if keep(e) {
+				f = append(f, e)
+			}
+		}
+		return f, nil
+	})
+}
+
+// isMarker reports whether the entry is a marker.
+func isMarker(e xlpp.Entry) bool {
+	_, ok := e.Value.(xlpp.Marker)
+	return ok
+}
+
+// Keep keeps the values on the channels, and drops all other values. Markers are kept.
+func Keep(channels ...int) Stage {
+	set := channelSet(channels)
+	return Filter(func(e xlpp.Entry) bool {
+		return isMarker(e) || set[e.Channel]
+	})
+}
+
+// Drop drops the values on the channels. Markers are kept.
+func Drop(channels ...int) Stage {
+	set := channelSet(channels)
+	return Filter(func(e xlpp.Entry) bool {
+		return isMarker(e) || !set[e.Channel]
+	})
+}
+
+func channelSet(channels []int) map[int]bool {
+	set := make(map[int]bool, len(channels))
+	for _, c := range channels {
+		set[c] = true
+	}
+	return set
+}
+
+// Rename moves the values on channel from to channel to.
+func Rename(from, to int) Stage {
+	return StageFunc(func(m xlpp.Message) (xlpp.Message, error) {
+		r := make(xlpp.Message, len(m))
+		for i, e := range m {
+			if !isMarker(e) && e.Channel == from {
+				e.Channel = to
+			}
+			r[i] = e
+		}
+		return r, nil
+	})
+}
+
+// Redact replaces the values on the channels with Null, e.g. to hide personal data before forwarding a payload.
+func Redact(channels ...int) Stage {
+	set := channelSet(channels)
+	return StageFunc(func(m xlpp.Message) (xlpp.Message, error) {
+		r := make(xlpp.Message, len(m))
+		for i, e := range m {
+			if !isMarker(e) && set[e.Channel] {
+				e.Value = &xlpp.Null{}
+			}
+			r[i] = e
+		}
+		return r, nil
+	})
+}
+
+var errConvert = errors.New("pipeline: can only convert between number types and AnalogUnit")
+
+// Convert converts all values of type from to type to, e.g. Distance to DistanceLong, or Voltage to AnalogUnit.
+// Both must be number types, like TypeTemperature or TypeInteger, or TypeAnalogUnit.
+//
+// Values keep their physical quantity: both types must have the same unit (see xlpp.Type.Unit, or the Unit of an
+// AnalogUnit), or one of them must have no unit. Values that the target type can not represent, e.g. negative
+// values for an unsigned type, are errors. Converting to an integer type rounds toward zero.
+func Convert(from, to xlpp.Type) Stage {
+	return StageFunc(func(m xlpp.Message) (xlpp.Message, error) {
+		f := xlpp.LookupType(to)
+		if f == nil {
+			return nil, fmt.Errorf("pipeline: unknown type %d", to)
+		}
+		r := make(xlpp.Message, len(m))
+		for i, e := range m {
+			if !isMarker(e) && e.Value.XLPPType() == from {
+				v := f()
+				if err := convert(e.Value, v); err != nil {
+					return nil, err
+				}
+				e.Value = v
+			}
+			r[i] = e
+		}
+		return r, nil
+	})
+}
+
+// convert sets dst to the number src, converted to the unit of dst.
+func convert(src, dst xlpp.Value) error {
+	n, unit, err := number(src)
+	if err != nil {
+		return err
+	}
+	if u, ok := dst.(*xlpp.AnalogUnit); ok {
+		code, ok := unitCode(unit)
+		if !ok {
+			return fmt.Errorf("pipeline: can not convert %s to %s: no unit code for %q", src.XLPPType().Name(), dst.XLPPType().Name(), unit)
+		}
+		*u = xlpp.AnalogUnit{Unit: code, Value: n}
+		return nil
+	}
+	if to := dst.XLPPType().Unit(); unit != "" && to != "" && unit != to {
+		return fmt.Errorf("pipeline: can not convert %s in %s to %s in %s", src.XLPPType().Name(), unit, dst.XLPPType().Name(), to)
+	}
+	if err := setNumber(reflect.ValueOf(dst).Elem(), n); err != nil {
+		return fmt.Errorf("pipeline: can not convert %v to %s: %v", src, dst.XLPPType().Name(), err)
+	}
+	return nil
+}
+
+// number returns the number of the value and its unit.
+func number(v xlpp.Value) (n float64, unit string, err error) {
+	if u, ok := v.(*xlpp.AnalogUnit); ok {
+		return u.Value, u.Unit.Symbol(), nil
+	}
+	rv := reflect.ValueOf(v).Elem()
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = rv.Float()
+	default:
+		return 0, "", errConvert
+	}
+	return n, v.XLPPType().Unit(), nil
+}
+
+// setNumber sets the number v to n, if v can represent n.
+func setNumber(v reflect.Value, n float64) error {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return errors.New("not a finite number")
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// float64 can not represent math.MaxInt64, so the upper bound is exclusive
+		if n < math.MinInt64 || n >= math.MaxInt64 || v.OverflowInt(int64(n)) {
+			return errors.New("out of range")
+		}
+		v.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n <= -1 || n >= math.MaxUint64 || v.OverflowUint(uint64(n)) {
+			return errors.New("out of range")
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		if v.OverflowFloat(n) {
+			return errors.New("out of range")
+		}
+		v.SetFloat(n)
+	default:
+		return errConvert
+	}
+	return nil
+}
+
+// unitCode returns the UnitCode of the unit symbol, e.g. xlpp.UnitVolt for "V".
+func unitCode(unit string) (xlpp.UnitCode, bool) {
+	if unit == "" {
+		return xlpp.UnitNone, true
+	}
+	for u := xlpp.UnitNone + 1; u.Symbol() != ""; u++ {
+		if u.Symbol() == unit {
+			return u, true
+		}
+	}
+	return 0, false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Parse parses a Pipeline from its textual description, with one stage per line or separated by ';':
+//
+//	keep 1,2,5                      # keep the values on channels 1, 2 and 5
+//	drop 3                          # drop the values on channel 3
+//	rename 5=3                      # move the values on channel 5 to channel 3
+//	redact 2                        # replace the values on channel 2 with null
+//	convert distance=distancelong   # convert all Distance values to DistanceLong, see Convert
+//
+// Type names are the names of the JSON format, e.g. "temperature". Text after '#' is a comment.
+func Parse(desc string) (*Pipeline, error) {
+	p := New()
+	s := bufio.NewScanner(strings.NewReader(strings.Replace(desc, ";", "\n", -1)))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("pipeline: %q: expected a stage and its argument", line)
+		}
+		stage, err := parseStage(fields[0], fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: %q: %v", line, err)
+		}
+		p.Stages = append(p.Stages, stage)
+	}
+	return p, s.Err()
+}
+
+func parseStage(name, arg string) (Stage, error) {
+	switch name {
+	case "keep", "drop", "redact":
+		var channels []int
+		for _, c := range strings.Split(arg, ",") {
+			channel, err := strconv.Atoi(c)
+			if err != nil {
+				return nil, err
+			}
+			channels = append(channels, channel)
+		}
+		switch name {
+		case "keep":
+			return Keep(channels...), nil
+		case "drop":
+			return Drop(channels...), nil
+		}
+		return Redact(channels...), nil
+	case "rename":
+		from, to, err := pair(arg)
+		if err != nil {
+			return nil, err
+		}
+		f, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, err
+		}
+		t, err := strconv.Atoi(to)
+		if err != nil {
+			return nil, err
+		}
+		return Rename(f, t), nil
+	case "convert":
+		from, to, err := pair(arg)
+		if err != nil {
+			return nil, err
+		}
+		f, t := xlpp.LookupName(from), xlpp.LookupName(to)
+		if f == nil || t == nil {
+			return nil, errors.New("unknown type")
+		}
+		return Convert(f().XLPPType(), t().XLPPType()), nil
+	}
+	return nil, errors.New("unknown stage")
+}
+
+// pair splits "a=b".
+func pair(arg string) (a, b string, err error) {
+	i := strings.IndexByte(arg, '=')
+	if i == -1 {
+		return "", "", errors.New("expected FROM=TO")
+	}
+	return arg[:i], arg[i+1:], nil
+}
@@ -0,0 +1,92 @@
+package pipeline_test
+
+import (
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/pipeline"
+)
+
+func TestPipeline(t *testing.T) {
+	temp := xlpp.Temperature(21.5)
+	dist := xlpp.Distance(12.345)
+	name := xlpp.String("Alice")
+	delay := xlpp.Delay(60e9)
+	data, err := pipeline.Encode(xlpp.Message{
+		{Channel: 1, Value: &temp},
+		{Channel: 2, Value: &name},
+		{Channel: 3, Value: &dist},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 4, Value: &temp},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := pipeline.Parse("drop 4; redact 2 # personal data\nrename 3=5\nconvert distance=distancelong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.Process(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := xlpp.NewBytesReader(out).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 4 {
+		t.Fatalf("message: %v", m)
+	}
+	if _, ok := m[1].Value.(*xlpp.Null); !ok {
+		t.Fatalf("not redacted: %v", m[1].Value)
+	}
+	if v, ok := m[2].Value.(*xlpp.DistanceLong); !ok || m[2].Channel != 5 || *v != 12.345 {
+		t.Fatalf("not renamed or converted: %d %#v", m[2].Channel, m[2].Value)
+	}
+	if _, ok := m[3].Value.(*xlpp.Delay); !ok {
+		t.Fatalf("marker dropped: %v", m[3].Value)
+	}
+
+	if _, err := pipeline.Convert(xlpp.TypeTemperature, xlpp.TypeGPS).Apply(m); err == nil {
+		t.Fatal("expected error converting to GPS")
+	}
+	for _, desc := range []string{"keep", "keep a", "rename 1", "convert foo=bar", "frobnicate 1"} {
+		if _, err := pipeline.Parse(desc); err == nil {
+			t.Fatalf("expected error for %q", desc)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	volts, analog, negative := xlpp.Voltage(3.3), xlpp.AnalogUnit{Unit: xlpp.UnitCelsius, Value: -4.5}, xlpp.AnalogInput(-2)
+	m := xlpp.Message{{Channel: 1, Value: &volts}, {Channel: 2, Value: &analog}, {Channel: 3, Value: &negative}}
+
+	out, err := pipeline.Convert(xlpp.TypeVoltage, xlpp.TypeAnalogUnit).Apply(m)
+	if err != nil || *out[0].Value.(*xlpp.AnalogUnit) != (xlpp.AnalogUnit{Unit: xlpp.UnitVolt, Value: 3.3}) {
+		t.Fatalf("voltage: %v, %v", out, err)
+	}
+	out, err = pipeline.Convert(xlpp.TypeAnalogUnit, xlpp.TypeTemperature).Apply(m)
+	if err != nil || *out[1].Value.(*xlpp.Temperature) != -4.5 {
+		t.Fatalf("analog unit: %v, %v", out, err)
+	}
+	out, err = pipeline.Convert(xlpp.TypeAnalogInput, xlpp.TypeInteger).Apply(m)
+	if err != nil || *out[2].Value.(*xlpp.Integer) != -2 {
+		t.Fatalf("analog input: %v, %v", out, err)
+	}
+
+	var accelerometer xlpp.Accelerometer
+	for _, c := range []struct {
+		v  xlpp.Value
+		to xlpp.Type
+	}{
+		{&volts, xlpp.TypeTemperature},        // V to °C
+		{&analog, xlpp.TypeVoltage},           // °C to V
+		{&negative, xlpp.TypeLuminosity},      // negative to unsigned
+		{&accelerometer, xlpp.TypeAnalogUnit}, // not a number
+	} {
+		if _, err := pipeline.Convert(c.v.XLPPType(), c.to).Apply(xlpp.Message{{Channel: 1, Value: c.v}}); err == nil {
+			t.Errorf("%s to %s: expected error", c.v.XLPPType().Name(), c.to.Name())
+		}
+	}
+}
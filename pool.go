@@ -0,0 +1,71 @@
+package xlpp
+
+import (
+	"io"
+	"reflect"
+	"sync"
+)
+
+// pools holds released values by type.
+var pools [256]sync.Pool
+
+// poolTypes are the Go types of the values of the builtin types, the only values that Readers take from the pools.
+var poolTypes = func() (types [256]reflect.Type) {
+	for t, f := range Registry {
+		types[t] = reflect.TypeOf(f())
+	}
+	return types
+}()
+
+// bufPool holds scratch buffers for decoding strings and object keys.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 32)
+		return &buf
+	},
+}
+
+// WithPool makes the Reader take values from a pool instead of allocating a new value for every entry.
+// Values that are no longer used should be given back to the pool with Release.
+func WithPool() ReaderOption {
+	return func(r *Reader) {
+		r.pool = true
+	}
+}
+
+// pooled returns true if values read from r are taken from the pool.
+func pooled(r io.Reader) bool {
+	opts := options(r)
+	return opts != nil && opts.pool
+}
+
+// getValue returns a released value of type t, or nil if there is none.
+func getValue(t Type) Value {
+	v, _ := pools[t].Get().(Value)
+	return v
+}
+
+// Release puts the value back to the pool of values used by Readers created with WithPool.
+// Object and Array items are released as well.
+// Values of custom types and other values that Readers would not return for their type, e.g. an *UnknownValue,
+// are not pooled. The value must not be used after calling Release.
+func Release(v Value) {
+	switch v := v.(type) {
+	case nil, Marker:
+		return
+	case *Object:
+		for key, item := range *v {
+			Release(item)
+			delete(*v, key)
+		}
+	case *Array:
+		for i, item := range *v {
+			Release(item)
+			(*v)[i] = nil
+		}
+		*v = (*v)[:0]
+	}
+	if t := v.XLPPType(); poolTypes[t] == reflect.TypeOf(v) {
+		pools[t].Put(v)
+	}
+}
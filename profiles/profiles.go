@@ -0,0 +1,220 @@
+// Package profiles maps device models to the channels, types and units they send and the actuators they accept.
+//
+// Profiles are loaded from JSON, e.g.
+//
+//	[{
+//	  "model": "weather-station",
+//	  "channels": [
+//	    {"channel": 1, "type": "temperature", "name": "outdoor temperature"},
+//	    {"channel": 2, "type": "relativehumidity"}
+//	  ],
+//	  "actuators": [
+//	    {"channel": 10, "type": "switch", "name": "heater"}
+//	  ]
+//	}]
+//
+// and used to annotate decoded messages and to validate uplinks and commands.
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/waziup/xlpp"
+)
+
+// A Profile describes a device model.
+type Profile struct {
+	Model       string `json:"model"`
+	Description string `json:"description,omitempty"`
+	// Channels are the values the device sends.
+	Channels []Channel `json:"channels"`
+	// Actuators are the values the device accepts as commands.
+	Actuators []Channel `json:"actuators,omitempty"`
+}
+
+// A Channel is a channel of a device with its type.
+type Channel struct {
+	Channel int `json:"channel"`
+	// Type is the type name, see xlpp.Type.Name.
+	Type string `json:"type"`
+	// Name is a human readable name, e.g. "outdoor temperature".
+	Name string `json:"name,omitempty"`
+	// Unit overrides the unit of the type, see xlpp.Type.Unit.
+	Unit string `json:"unit,omitempty"`
+}
+
+// Validate checks that the profile has a model and that all types are known.
+func (p *Profile) Validate() error {
+	if p.Model == "" {
+		return fmt.Errorf("profiles: profile without model")
+	}
+	for _, list := range [][]Channel{p.Channels, p.Actuators} {
+		seen := make(map[int]bool, len(list))
+		for _, c := range list {
+			if xlpp.LookupName(c.Type) == nil {
+				return fmt.Errorf("profiles: %s: channel %d: unknown type %q", p.Model, c.Channel, c.Type)
+			}
+			if c.Channel < 0 || c.Channel > 255 {
+				return fmt.Errorf("profiles: %s: invalid channel %d", p.Model, c.Channel)
+			}
+			if seen[c.Channel] {
+				return fmt.Errorf("profiles: %s: duplicate channel %d", p.Model, c.Channel)
+			}
+			seen[c.Channel] = true
+		}
+	}
+	return nil
+}
+
+// channel returns the channel c of the list.
+func channel(list []Channel, c int) (Channel, bool) {
+	for _, ch := range list {
+		if ch.Channel == c {
+			return ch, true
+		}
+	}
+	return Channel{}, false
+}
+
+// An Annotated is an entry of a decoded message with the information of the profile.
+type Annotated struct {
+	xlpp.Entry
+	// Name is the name of the channel from the profile.
+	Name string
+	// Unit is the unit of the channel from the profile, or the unit of the type.
+	Unit string
+	// Expected reports whether the profile has the channel with the type of the value.
+	// Markers are always expected.
+	Expected bool
+}
+
+// Annotate annotates the entries of the message with the names and units of the profile.
+func (p *Profile) Annotate(m xlpp.Message) []Annotated {
+	a := make([]Annotated, len(m))
+	for i, e := range m {
+		a[i].Entry = e
+		if _, ok := e.Value.(xlpp.Marker); ok {
+			a[i].Expected = true
+			continue
+		}
+		a[i].Unit = e.Value.XLPPType().Unit()
+		if c, ok := channel(p.Channels, e.Channel); ok && c.Type == xlpp.NameOf(e.Value) {
+			a[i].Name = c.Name
+			if c.Unit != "" {
+				a[i].Unit = c.Unit
+			}
+			a[i].Expected = true
+		}
+	}
+	return a
+}
+
+// Check checks that all values of an uplink message are expected by the profile,
+// and that the actuators declared by the message (Actuators marker) are actuators of the profile.
+func (p *Profile) Check(m xlpp.Message) error {
+	for _, e := range m {
+		switch v := e.Value.(type) {
+		case *xlpp.ActuatorsWithChannel:
+			for _, a := range *v {
+				if c, ok := channel(p.Actuators, a.Channel); !ok || c.Type != a.Type.Name() {
+					return fmt.Errorf("profiles: %s: unexpected actuator %s on channel %d", p.Model, a.Type.Name(), a.Channel)
+				}
+			}
+		case xlpp.Marker:
+		default:
+			if err := check(p.Model, p.Channels, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateCommand checks that all values of a downlink command are actuators of the profile.
+func (p *Profile) ValidateCommand(m xlpp.Message) error {
+	for _, e := range m {
+		if _, ok := e.Value.(xlpp.Marker); ok {
+			return fmt.Errorf("profiles: %s: markers are not allowed in commands", p.Model)
+		}
+		if err := check(p.Model, p.Actuators, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func check(model string, list []Channel, e xlpp.Entry) error {
+	c, ok := channel(list, e.Channel)
+	if !ok {
+		return fmt.Errorf("profiles: %s: unexpected channel %d", model, e.Channel)
+	}
+	if name := xlpp.NameOf(e.Value); name != c.Type {
+		return fmt.Errorf("profiles: %s: channel %d: unexpected type %s, want %s", model, e.Channel, name, c.Type)
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A Registry holds profiles by model.
+type Registry struct {
+	profiles map[string]*Profile
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{profiles: make(map[string]*Profile)}
+}
+
+// Add validates the profile and adds it to the registry, replacing profiles with the same model.
+func (r *Registry) Add(p *Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	r.profiles[p.Model] = p
+	return nil
+}
+
+// Get returns the profile of the model.
+func (r *Registry) Get(model string) (p *Profile, ok bool) {
+	p, ok = r.profiles[model]
+	return
+}
+
+// Models returns the sorted models of all profiles.
+func (r *Registry) Models() []string {
+	models := make([]string, 0, len(r.profiles))
+	for model := range r.profiles {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
+
+// Load adds the profiles from a JSON list of profiles to the registry.
+func (r *Registry) Load(rd io.Reader) error {
+	var profiles []*Profile
+	if err := json.NewDecoder(rd).Decode(&profiles); err != nil {
+		return fmt.Errorf("profiles: %v", err)
+	}
+	for _, p := range profiles {
+		if err := r.Add(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile adds the profiles from a JSON file to the registry.
+func (r *Registry) LoadFile(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return r.Load(file)
+}
@@ -0,0 +1,63 @@
+package profiles_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/profiles"
+)
+
+const weatherStation = `[{
+	"model": "weather-station",
+	"channels": [
+		{"channel": 1, "type": "temperature", "name": "outdoor temperature"},
+		{"channel": 2, "type": "relativehumidity", "unit": "%RH"}
+	],
+	"actuators": [
+		{"channel": 10, "type": "switch", "name": "heater"}
+	]
+}]`
+
+func TestProfiles(t *testing.T) {
+	r := profiles.NewRegistry()
+	if err := r.Load(strings.NewReader(weatherStation)); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := r.Get("weather-station")
+	if !ok || len(r.Models()) != 1 {
+		t.Fatalf("models: %v", r.Models())
+	}
+
+	temperature := xlpp.Temperature(21.5)
+	humidity := xlpp.RelativeHumidity(60)
+	voltage := xlpp.Voltage(3.3)
+	delay := xlpp.Delay(0)
+	m := xlpp.Message{{Channel: 1, Value: &temperature}, {Channel: 2, Value: &humidity}, {Channel: xlpp.ChanDelay, Value: &delay}}
+	if err := p.Check(m); err != nil {
+		t.Fatal(err)
+	}
+	a := p.Annotate(append(m, xlpp.Entry{Channel: 3, Value: &voltage}))
+	if a[0].Name != "outdoor temperature" || a[0].Unit != "°C" || a[1].Unit != "%RH" || !a[2].Expected || a[3].Expected || a[3].Unit != "V" {
+		t.Fatalf("annotated: %+v", a)
+	}
+	if err := p.Check(xlpp.Message{{Channel: 1, Value: &humidity}}); err == nil {
+		t.Fatal("expected error for unexpected type")
+	}
+	actuators := xlpp.ActuatorsWithChannel{{Channel: 11, Type: xlpp.TypeSwitch}}
+	if err := p.Check(xlpp.Message{{Channel: xlpp.ChanActuatorsWithChannel, Value: &actuators}}); err == nil {
+		t.Fatal("expected error for unexpected actuator")
+	}
+
+	on := xlpp.Switch(true)
+	if err := p.ValidateCommand(xlpp.Message{{Channel: 10, Value: &on}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ValidateCommand(xlpp.Message{{Channel: 1, Value: &on}}); err == nil {
+		t.Fatal("expected error for a command on a sensor channel")
+	}
+
+	if err := r.Add(&profiles.Profile{Model: "bad", Channels: []profiles.Channel{{Channel: 1, Type: "unknown"}}}); err == nil {
+		t.Fatal("expected error for unknown type")
+	}
+}
@@ -0,0 +1,460 @@
+// Package protobuf converts XLPP messages to Protocol Buffers and back, so decoded uplinks can be sent over gRPC
+// pipelines. The messages are described in xlpp.proto: Marshal encodes an xlpp.Frame message in the wire format,
+// that can be decoded with code generated from xlpp.proto in any language, and Unmarshal decodes it.
+//
+// Each entry holds the channel, the XLPP type and the value in the field of the Value oneof that matches the type,
+// e.g. the number field for Temperature and the location field for GPS. Objects and Arrays hold their items with
+// their types. Values without matching field (e.g. Colour or Samples) are held in their JSON representation.
+//
+// Marshal is deterministic: Object keys are sorted, and zero values are omitted as in proto3.
+package protobuf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/waziup/xlpp"
+)
+
+// Field numbers of the xlpp.proto messages.
+const (
+	frameEntries = 1
+
+	entryChannel = 1
+	entryType    = 2
+	entryValue   = 3
+
+	itemType  = 1
+	itemValue = 2
+
+	valueNumber   = 1
+	valueInteger  = 2
+	valueBool     = 3
+	valueString   = 4
+	valueBinary   = 5
+	valueNull     = 6
+	valueTime     = 7
+	valueObject   = 8
+	valueArray    = 9
+	valueVector   = 10
+	valueLocation = 11
+	valueJSON     = 15
+)
+
+var errNoValue = errors.New("protobuf: value not set")
+
+// Marshal encodes the message as xlpp.Frame.
+func Marshal(m xlpp.Message) ([]byte, error) {
+	var frame buffer
+	for _, e := range m {
+		value, err := marshalValue(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("protobuf: channel %d: %v", e.Channel, err)
+		}
+		var entry buffer
+		entry.uint(entryChannel, uint64(e.Channel))
+		entry.uint(entryType, uint64(e.Value.XLPPType()))
+		entry.bytes(entryValue, value)
+		frame.bytes(frameEntries, entry)
+	}
+	return frame, nil
+}
+
+// marshalItem encodes the value as xlpp.Item.
+func marshalItem(v xlpp.Value) (buffer, error) {
+	value, err := marshalValue(v)
+	if err != nil {
+		return nil, err
+	}
+	var item buffer
+	item.uint(itemType, uint64(v.XLPPType()))
+	item.bytes(itemValue, value)
+	return item, nil
+}
+
+// marshalValue encodes the value as xlpp.Value.
+func marshalValue(v xlpp.Value) (buffer, error) {
+	var b buffer
+	switch v := v.(type) {
+	case *xlpp.Object:
+		keys := make([]string, 0, len(*v))
+		for key := range *v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var object buffer
+		for _, key := range keys {
+			item, err := marshalItem((*v)[key])
+			if err != nil {
+				return nil, err
+			}
+			var entry buffer
+			entry.bytes(1, []byte(key))
+			entry.bytes(2, item)
+			object.bytes(1, entry)
+		}
+		b.bytes(valueObject, object)
+	case *xlpp.Array:
+		var array buffer
+		for _, v := range *v {
+			item, err := marshalItem(v)
+			if err != nil {
+				return nil, err
+			}
+			array.bytes(1, item)
+		}
+		b.bytes(valueArray, array)
+	case *xlpp.Binary:
+		b.bytes(valueBinary, *v)
+	case *xlpp.UnixTime:
+		b.tag(valueTime, wireVarint)
+		b.varint(uint64(time.Time(*v).Unix()))
+	case *xlpp.Null:
+		b.bytes(valueNull, nil)
+	case *xlpp.Accelerometer:
+		b.bytes(valueVector, vector(v.X, v.Y, v.Z))
+	case *xlpp.AccelerometerHiG:
+		b.bytes(valueVector, vector(v.X, v.Y, v.Z))
+	case *xlpp.Gyrometer:
+		b.bytes(valueVector, vector(float64(v.X), float64(v.Y), float64(v.Z)))
+	case *xlpp.GyrometerHiRate:
+		b.bytes(valueVector, vector(float64(v.X), float64(v.Y), float64(v.Z)))
+	case *xlpp.GPS:
+		b.bytes(valueLocation, vector(v.Latitude, v.Longitude, v.Meters))
+	case *xlpp.GPS2D:
+		b.bytes(valueLocation, vector(v.Latitude, v.Longitude, 0))
+	default:
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			b.fixed64(valueNumber, rv.Float())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			b.tag(valueInteger, wireVarint)
+			b.varint(zigzag(rv.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			b.tag(valueInteger, wireVarint)
+			b.varint(zigzag(int64(rv.Uint())))
+		case reflect.Bool:
+			b.tag(valueBool, wireVarint)
+			if rv.Bool() {
+				b.varint(1)
+			} else {
+				b.varint(0)
+			}
+		case reflect.String:
+			b.bytes(valueString, []byte(rv.String()))
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			b.bytes(valueJSON, data)
+		}
+	}
+	return b, nil
+}
+
+// vector encodes an xlpp.Vector or xlpp.Location, that have the same fields.
+func vector(x, y, z float64) buffer {
+	var b buffer
+	b.double(1, x)
+	b.double(2, y)
+	b.double(3, z)
+	return b
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Unmarshal decodes a message from xlpp.Frame. Types are looked up in the xlpp.Registry.
+// Unknown fields are ignored.
+func Unmarshal(data []byte) (xlpp.Message, error) {
+	fs, err := fields(data)
+	if err != nil {
+		return nil, err
+	}
+	var m xlpp.Message
+	for _, f := range fs {
+		if f.num != frameEntries {
+			continue
+		}
+		if f.wire != wireBytes {
+			return nil, fmt.Errorf("protobuf: entry %d: bad wire type %d", len(m), f.wire)
+		}
+		e, err := unmarshalEntry(f.data)
+		if err != nil {
+			return nil, fmt.Errorf("protobuf: entry %d: %v", len(m), err)
+		}
+		m = append(m, e)
+	}
+	return m, nil
+}
+
+func unmarshalEntry(data []byte) (e xlpp.Entry, err error) {
+	fs, err := fields(data)
+	if err != nil {
+		return e, err
+	}
+	var t uint64
+	var value []byte
+	for _, f := range fs {
+		switch {
+		case f.num == entryChannel && f.wire == wireVarint:
+			if f.u > 255 {
+				return e, fmt.Errorf("bad channel %d", f.u)
+			}
+			e.Channel = int(f.u)
+		case f.num == entryType && f.wire == wireVarint:
+			t = f.u
+		case f.num == entryValue && f.wire == wireBytes:
+			value = f.data
+		}
+	}
+	if t == 255 {
+		if e.Value = newMarker(e.Channel); e.Value == nil {
+			return e, fmt.Errorf("channel %d is not a marker channel", e.Channel)
+		}
+		return e, unmarshalValue(e.Value, value)
+	}
+	e.Value, err = newValue(t)
+	if err != nil {
+		return e, err
+	}
+	return e, unmarshalValue(e.Value, value)
+}
+
+// newMarker returns a new Marker for the marker channel.
+func newMarker(channel int) xlpp.Value {
+	switch channel {
+	case xlpp.ChanDelay:
+		return new(xlpp.Delay)
+	case xlpp.ChanMilliDelay:
+		return new(xlpp.MilliDelay)
+	case xlpp.ChanPriority:
+		return new(xlpp.Priority)
+	case xlpp.ChanActuators:
+		return new(xlpp.Actuators)
+	case xlpp.ChanActuatorsWithChannel:
+		return new(xlpp.ActuatorsWithChannel)
+	case xlpp.ChanActuatorAck:
+		return new(xlpp.ActuatorAck)
+	}
+	return nil
+}
+
+// newValue returns a new value of the type.
+func newValue(t uint64) (xlpp.Value, error) {
+	f := xlpp.LookupType(xlpp.Type(t))
+	if t > 255 || f == nil || xlpp.Type(t) == xlpp.TypeEndOfArray {
+		return nil, fmt.Errorf("unknown type %d", t)
+	}
+	return f(), nil
+}
+
+func unmarshalItem(data []byte) (xlpp.Value, error) {
+	fs, err := fields(data)
+	if err != nil {
+		return nil, err
+	}
+	var t uint64
+	var value []byte
+	for _, f := range fs {
+		switch {
+		case f.num == itemType && f.wire == wireVarint:
+			t = f.u
+		case f.num == itemValue && f.wire == wireBytes:
+			value = f.data
+		}
+	}
+	v, err := newValue(t)
+	if err != nil {
+		return nil, err
+	}
+	return v, unmarshalValue(v, value)
+}
+
+// unmarshalValue sets the value from an xlpp.Value. The field of the oneof must match the type of the value.
+func unmarshalValue(v xlpp.Value, data []byte) error {
+	fs, err := fields(data)
+	if err != nil {
+		return err
+	}
+	// the last field of the oneof wins
+	var f field
+	for _, g := range fs {
+		if g.num <= valueLocation || g.num == valueJSON {
+			f = g
+		}
+	}
+	if f.num == 0 {
+		return errNoValue
+	}
+	bad := fmt.Errorf("can not set %s from field %d", xlpp.NameOf(v), f.num)
+	switch v := v.(type) {
+	case *xlpp.Object:
+		if f.num != valueObject || f.wire != wireBytes {
+			return bad
+		}
+		entries, err := fields(f.data)
+		if err != nil {
+			return err
+		}
+		*v = make(xlpp.Object, len(entries))
+		for _, entry := range entries {
+			kv, err := fields(entry.data)
+			if entry.num != 1 || entry.wire != wireBytes || err != nil {
+				return fmt.Errorf("bad object entry")
+			}
+			var key string
+			var value xlpp.Value
+			for _, g := range kv {
+				switch {
+				case g.num == 1 && g.wire == wireBytes:
+					key = string(g.data)
+				case g.num == 2 && g.wire == wireBytes:
+					if value, err = unmarshalItem(g.data); err != nil {
+						return fmt.Errorf("object key %q: %v", key, err)
+					}
+				}
+			}
+			if value == nil {
+				return fmt.Errorf("object key %q: %v", key, errNoValue)
+			}
+			(*v)[key] = value
+		}
+		return nil
+	case *xlpp.Array:
+		if f.num != valueArray || f.wire != wireBytes {
+			return bad
+		}
+		items, err := fields(f.data)
+		if err != nil {
+			return err
+		}
+		*v = make(xlpp.Array, 0, len(items))
+		for i, item := range items {
+			if item.num != 1 || item.wire != wireBytes {
+				continue
+			}
+			value, err := unmarshalItem(item.data)
+			if err != nil {
+				return fmt.Errorf("array item %d: %v", i, err)
+			}
+			*v = append(*v, value)
+		}
+		return nil
+	case *xlpp.Binary:
+		if f.num != valueBinary || f.wire != wireBytes {
+			return bad
+		}
+		*v = append(xlpp.Binary{}, f.data...)
+		return nil
+	case *xlpp.UnixTime:
+		if f.num != valueTime || f.wire != wireVarint {
+			return bad
+		}
+		*v = xlpp.UnixTime(time.Unix(int64(f.u), 0))
+		return nil
+	case *xlpp.Null:
+		if f.num != valueNull {
+			return bad
+		}
+		return nil
+	case *xlpp.Accelerometer, *xlpp.AccelerometerHiG, *xlpp.Gyrometer, *xlpp.GyrometerHiRate, *xlpp.GPS, *xlpp.GPS2D:
+		var x, y, z float64
+		switch v.(type) {
+		case *xlpp.GPS, *xlpp.GPS2D:
+			if f.num != valueLocation {
+				return bad
+			}
+		default:
+			if f.num != valueVector {
+				return bad
+			}
+		}
+		if f.wire != wireBytes {
+			return bad
+		}
+		xyz, err := fields(f.data)
+		if err != nil {
+			return err
+		}
+		for _, g := range xyz {
+			if g.wire != wireFixed64 {
+				continue
+			}
+			switch g.num {
+			case 1:
+				x = g.double()
+			case 2:
+				y = g.double()
+			case 3:
+				z = g.double()
+			}
+		}
+		switch v := v.(type) {
+		case *xlpp.Accelerometer:
+			*v = xlpp.Accelerometer{X: x, Y: y, Z: z}
+		case *xlpp.AccelerometerHiG:
+			*v = xlpp.AccelerometerHiG{X: x, Y: y, Z: z}
+		case *xlpp.Gyrometer:
+			*v = xlpp.Gyrometer{X: float32(x), Y: float32(y), Z: float32(z)}
+		case *xlpp.GyrometerHiRate:
+			*v = xlpp.GyrometerHiRate{X: float32(x), Y: float32(y), Z: float32(z)}
+		case *xlpp.GPS:
+			*v = xlpp.GPS{Latitude: x, Longitude: y, Meters: z}
+		case *xlpp.GPS2D:
+			*v = xlpp.GPS2D{Latitude: x, Longitude: y}
+		}
+		return nil
+	}
+	if f.num == valueJSON && f.wire == wireBytes {
+		return json.Unmarshal(f.data, v)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return bad
+	}
+	rv = rv.Elem()
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f.num != valueNumber || f.wire != wireFixed64 {
+			return bad
+		}
+		rv.SetFloat(f.double())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.num != valueInteger || f.wire != wireVarint {
+			return bad
+		}
+		i := unzigzag(f.u)
+		if rv.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s", i, xlpp.NameOf(v))
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f.num != valueInteger || f.wire != wireVarint {
+			return bad
+		}
+		u := uint64(unzigzag(f.u))
+		if rv.OverflowUint(u) {
+			return fmt.Errorf("%d overflows %s", u, xlpp.NameOf(v))
+		}
+		rv.SetUint(u)
+	case reflect.Bool:
+		if f.num != valueBool || f.wire != wireVarint {
+			return bad
+		}
+		rv.SetBool(f.u != 0)
+	case reflect.String:
+		if f.num != valueString || f.wire != wireBytes {
+			return bad
+		}
+		rv.SetString(string(f.data))
+	default:
+		return bad
+	}
+	return nil
+}
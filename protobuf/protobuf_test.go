@@ -0,0 +1,90 @@
+package protobuf_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/protobuf"
+)
+
+func TestMarshal(t *testing.T) {
+	temp := xlpp.Temperature(23.5)
+	data, err := protobuf.Marshal(xlpp.Message{{Channel: 5, Value: &temp}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// entries {channel: 5, type: 103, value {number: 23.5}}
+	if hex.EncodeToString(data) != "0a0f080510671a09090000000000803740" {
+		t.Fatalf("Marshal: %x", data)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	temp := xlpp.Temperature(-12.3)
+	input := xlpp.DigitalInput(200)
+	gps := xlpp.GPS{Latitude: 52.5219, Longitude: 13.4132, Meters: 34.5}
+	gyro := xlpp.Gyrometer{X: 1.5, Y: -2.25}
+	colour := xlpp.Colour{R: 255, G: 128}
+	unix := xlpp.UnixTime(time.Unix(1700000000, 0))
+	bin := xlpp.Binary{0, 1, 0xff}
+	null := xlpp.Null{}
+	on := xlpp.Bool(true)
+	off := xlpp.Switch(false)
+	str := xlpp.String("hello")
+	integer := xlpp.Integer(-70000)
+	flags := xlpp.Flags(1<<63 | 9)
+	delay := xlpp.Delay(90 * time.Second)
+	actuators := xlpp.Actuators{xlpp.TypeSwitch, xlpp.TypeColour}
+	object := xlpp.Object{"a": &temp, "b": &xlpp.Array{&on, &str, &null}}
+	m := xlpp.Message{
+		{Channel: 1, Value: &temp},
+		{Channel: 2, Value: &gps},
+		{Channel: 3, Value: &colour},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 4, Value: &unix},
+		{Channel: 5, Value: &bin},
+		{Channel: 6, Value: &object},
+		{Channel: 7, Value: &integer},
+		{Channel: 8, Value: &flags},
+		{Channel: 9, Value: &input},
+		{Channel: 10, Value: &gyro},
+		{Channel: 11, Value: &off},
+		{Channel: xlpp.ChanActuators, Value: &actuators},
+	}
+	data, err := protobuf.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := protobuf.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Fatalf("round trip:\n%v\n%v", decoded, m)
+	}
+	again, _ := protobuf.Marshal(decoded)
+	if !bytes.Equal(again, data) {
+		t.Fatalf("not deterministic:\n%x\n%x", again, data)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, bad := range []string{
+		"0a",                   // truncated
+		"0a020805",             // entry without value
+		"0a08080510671a021801", // temperature in the bool field
+		"0a07080510ff011a00",   // marker on channel 5
+		"0a07080110c8011a00",   // unknown type 200
+		"0a0808011a0410feff07", // 65535 overflows DigitalInput
+		"0a06080510671a00",     // empty value
+	} {
+		data, _ := hex.DecodeString(bad)
+		if _, err := protobuf.Unmarshal(data); err == nil {
+			t.Fatalf("Unmarshal(%s): no error", bad)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protocol Buffers wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("protobuf: unexpected end of data")
+
+// buffer builds a message in the wire format.
+type buffer []byte
+
+func (b *buffer) varint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	*b = append(*b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func (b *buffer) tag(field int, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// uint writes a varint field, if it is not zero.
+func (b *buffer) uint(field int, v uint64) {
+	if v != 0 {
+		b.tag(field, wireVarint)
+		b.varint(v)
+	}
+}
+
+// double writes a fixed64 field, if it is not zero.
+func (b *buffer) double(field int, f float64) {
+	if f != 0 || math.Signbit(f) {
+		b.fixed64(field, f)
+	}
+}
+
+func (b *buffer) fixed64(field int, f float64) {
+	b.tag(field, wireFixed64)
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(f))
+	*b = append(*b, tmp[:]...)
+}
+
+func (b *buffer) bytes(field int, data []byte) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(data)))
+	*b = append(*b, data...)
+}
+
+// zigzag encodes a sint64.
+func zigzag(i int64) uint64 {
+	return uint64(i<<1) ^ uint64(i>>63)
+}
+
+// unzigzag decodes a sint64.
+func unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A field is a field of a message in the wire format.
+type field struct {
+	num  int
+	wire int
+	// u is the value of varint and fixed fields, data the value of length-delimited fields.
+	u    uint64
+	data []byte
+}
+
+func (f field) double() float64 {
+	return math.Float64frombits(f.u)
+}
+
+// fields parses the fields of a message.
+func fields(data []byte) ([]field, error) {
+	var fs []field
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		data = data[n:]
+		f := field{num: int(key >> 3), wire: int(key & 7)}
+		if f.num == 0 {
+			return nil, fmt.Errorf("protobuf: bad field number 0")
+		}
+		switch f.wire {
+		case wireVarint:
+			if f.u, n = binary.Uvarint(data); n <= 0 {
+				return nil, errTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, errTruncated
+			}
+			f.u = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, errTruncated
+			}
+			f.u = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || l > uint64(len(data)-n) {
+				return nil, errTruncated
+			}
+			f.data = data[n : n+int(l)]
+			data = data[n+int(l):]
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d", f.wire)
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
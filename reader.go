@@ -2,25 +2,261 @@ package xlpp
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"strings"
+	"time"
 )
 
 // A Reader decodes values from the underlying reader.
 type Reader struct {
-	r *bufio.Reader
+	r source
+	d decoder
+	b bytesSource
+
+	consumed int64
+
+	pool        bool
+	alloc       Allocator
+	objectHint  int
+	arrayHint   int
+	maxSize     int
+	validate    bool
+	strictLPP   bool
+	skipUnknown bool
+	crc         bool
+	decompress  bool
+	inflated    bool
+	limits      limits
+	limitState  limitState
+	markers     markerState
+	priority    Priority
+
+	tokens tokenState
+
+	// err is the first error of the iterator returned by Values.
+	err error
+
+	types *TypeRegistry
+
+	hooks   []Hook
+	metrics Metrics
+	log     Logger
+	// rec records the raw entries for the hooks, if the Reader does not read from memory.
+	rec *recordingSource
 }
 
+// A ReaderOption configures a Reader.
+type ReaderOption func(*Reader)
+
 // NewReader constructs a new XLPP reader to get XLPP values from a underlying reader.
-func NewReader(r io.Reader) *Reader {
-	if br, ok := r.(*bufio.Reader); ok {
-		return &Reader{r: br}
+func NewReader(r io.Reader, opts ...ReaderOption) *Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		size := 4096
+		if l, ok := r.(interface{ Len() int }); ok && l.Len() < size {
+			// in-memory payloads (bytes.Reader, bytes.Buffer, strings.Reader) need no large buffer
+			size = l.Len()
+		}
+		br = bufio.NewReaderSize(r, size)
+	}
+	reader := new(Reader)
+	reader.init(br, opts)
+	return reader
+}
+
+// NewBytesReader constructs a new XLPP reader to get XLPP values from an in-memory payload.
+// It reads directly from data, without the buffering of a Reader created with NewReader.
+// The data must not be modified while the Reader is in use.
+func NewBytesReader(data []byte, opts ...ReaderOption) *Reader {
+	reader := new(Reader)
+	reader.b = bytesSource{data: data}
+	reader.init(&reader.b, opts)
+	return reader
+}
+
+// ResetBytes makes the Reader read from the in-memory payload data, keeping the Reader options.
+// It allows decoding many payloads with a single Reader.
+func (r *Reader) ResetBytes(data []byte) {
+	r.b = bytesSource{data: data}
+	r.r = &r.b
+	r.d.source = &r.b
+	r.rec = nil
+	r.consumed = 0
+	r.markers = markerState{}
+	r.limitState = limitState{}
+	r.priority = PriorityRoutine
+	r.tokens = tokenState{}
+	r.inflated = false
+	r.err = nil
+	if r.crc {
+		r.verifyCRC()
+	}
+}
+
+func (r *Reader) init(src source, opts []ReaderOption) {
+	r.r = src
+	r.d = decoder{source: src, opts: r}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.crc {
+		r.readCRC(src)
+		return
+	}
+	if len(r.hooks) != 0 && src != &r.b {
+		r.rec = &recordingSource{source: src}
+		r.r = r.rec
+		r.d.source = r.rec
+	}
+}
+
+// source is the buffered input of a Reader.
+// It is implemented by bufio.Reader and bytesSource.
+type source interface {
+	io.Reader
+	io.ByteReader
+	sliceReader
+	Buffered() int
+}
+
+// decoder is the io.Reader that the Reader hands to the values' ReadFrom methods.
+// Nested values (Object, Array) use it to access the Reader's options.
+type decoder struct {
+	source
+	opts *Reader
+}
+
+// bytesSource is a source that reads from a byte slice.
+type bytesSource struct {
+	data []byte
+	off  int
+}
+
+func (s *bytesSource) Read(p []byte) (n int, err error) {
+	if s.off >= len(s.data) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n = copy(p, s.data[s.off:])
+	s.off += n
+	return
+}
+
+func (s *bytesSource) ReadByte() (byte, error) {
+	if s.off >= len(s.data) {
+		return 0, io.EOF
+	}
+	b := s.data[s.off]
+	s.off++
+	return b, nil
+}
+
+// ReadSlice reads until the first occurrence of delim, returning a slice of the underlying data.
+func (s *bytesSource) ReadSlice(delim byte) (line []byte, err error) {
+	i := bytes.IndexByte(s.data[s.off:], delim)
+	if i == -1 {
+		line = s.data[s.off:]
+		s.off = len(s.data)
+		return line, io.EOF
+	}
+	line = s.data[s.off : s.off+i+1]
+	s.off += i + 1
+	return line, nil
+}
+
+// Buffered returns the number of bytes that have not been read yet.
+func (s *bytesSource) Buffered() int {
+	return len(s.data) - s.off
+}
+
+// WithSizeHints sets the expected number of entries of decoded Objects and Arrays.
+// Callers that know the schema of their payloads can use it to avoid growing maps and slices while decoding.
+// A hint <= 0 keeps the default.
+func WithSizeHints(object, array int) ReaderOption {
+	return func(r *Reader) {
+		r.objectHint = object
+		r.arrayHint = array
+	}
+}
+
+// sizeHints returns the expected number of entries of Objects and Arrays read from r.
+func sizeHints(r io.Reader) (object, array int) {
+	object, array = 0, 8
+	if d, ok := r.(*decoder); ok {
+		if d.opts.objectHint > 0 {
+			object = d.opts.objectHint
+		}
+		if d.opts.arrayHint > 0 {
+			array = d.opts.arrayHint
+		} else if b := d.Buffered(); b < array {
+			// each item is at least one byte, so small payloads can not hold more items
+			array = b
+		}
+	}
+	return
+}
+
+// ErrTooLarge is returned when a length-prefixed value exceeds the max. size of the Reader, see WithMaxSize.
+// The *LimitError of the other resource limits of the Reader wraps it.
+var ErrTooLarge = errors.New("xlpp: value exceeds the max. size")
+
+// WithMaxSize limits the length of length-prefixed values (Binary, Actuators) to max bytes.
+// Larger values are rejected with ErrTooLarge before reading or allocating their data.
+// A max <= 0 means no limit, but lengths are still checked against the remaining input if the Reader reads from memory.
+func WithMaxSize(max int) ReaderOption {
+	return func(r *Reader) {
+		r.maxSize = max
+	}
+}
+
+// checkLength checks the length l (bytes) of a length-prefixed value read from r,
+// against the max. size of the Reader and the remaining input.
+func checkLength(r io.Reader, l uint64) error {
+	if opts := options(r); opts != nil && opts.maxSize > 0 && l > uint64(opts.maxSize) {
+		return ErrTooLarge
+	}
+	if rem, ok := remaining(r); ok && l > uint64(rem) {
+		// the payload is truncated or the length is corrupt: do not read or allocate anything
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// remaining returns the number of bytes left in r, if r reads from memory.
+func remaining(r io.Reader) (n int, ok bool) {
+	switch r := r.(type) {
+	case *decoder:
+		if b, ok := r.source.(*bytesSource); ok {
+			return b.Buffered(), true
+		}
+	case *bytesSource:
+		return r.Buffered(), true
+	case interface{ Len() int }:
+		// bytes.Reader, bytes.Buffer, strings.Reader
+		return r.Len(), true
 	}
-	return &Reader{
-		r: bufio.NewReader(r),
+	return 0, false
+}
+
+// options returns the Reader options if r is the decoder of a Reader.
+func options(r io.Reader) *Reader {
+	if d, ok := r.(*decoder); ok {
+		return d.opts
+	}
+	return nil
+}
+
+// lookup returns the factory of type t, from the TypeRegistry of the Reader or the global Registry.
+// custom is true for types that are not types of this package.
+func lookup(r io.Reader, t Type) (f func() Value, custom bool) {
+	if opts := options(r); opts != nil && opts.types != nil {
+		return opts.types.Lookup(t), true
 	}
+	return LookupType(t), !builtinTypes[t]
 }
 
 func toErr(err error) error {
@@ -35,34 +271,79 @@ func read(r io.Reader) (v Value, n int64, err error) {
 	{
 		// read Type byte
 		var buf [1]byte
-		var m int
-		m, err = r.Read(buf[:])
-		n += int64(m)
+		n, err = readFrom(r, buf[:])
 		if err != nil {
 			err = toErr(err)
 			return
 		}
 		t = Type(buf[0])
 	}
+	var m int64
+	v, m, err = readValue(r, t)
+	n += m
+	return
+}
+
+// readValue reads the value of type t, after the type byte has been read.
+func readValue(r io.Reader, t Type) (v Value, n int64, err error) {
+	c, custom := lookup(r, t)
 	{
 		// init zero Type
-		c := Registry[t]
 		if c == nil {
-			err = fmt.Errorf("unregistered XLPP type 0x%02x", t)
+			if err = nestedMarker(options(r), t); err != nil {
+				return
+			}
+			if size, ok := skipSize(r, t); ok {
+				u := &UnknownValue{Type: t, Data: make([]byte, size)}
+				n, err = u.ReadFrom(r)
+				return u, n, err
+			}
+			err = &UnknownTypeError{Type: t, Offset: -1}
 			return
 		}
-		v = c()
+		if pooled(r) && !custom {
+			// values of a TypeRegistry may have other Go types than the pooled values
+			v = getValue(t)
+		}
+		if v == nil {
+			v = c()
+		}
 		if v == nil {
-			panic(fmt.Errorf("registered XLPP type 0x%02x returned nil value", t))
+			err = fmt.Errorf("registered XLPP type 0x%02x returned nil value", t)
+			return
+		}
+	}
+	{
+		var nested bool
+		if nested, err = countValue(r, t); err != nil {
+			return nil, 0, err
 		}
+		if nested {
+			defer leaveNested(r)
+		}
+	}
+	if custom {
+		defer func() {
+			// custom types are not under our control: never let them crash the program
+			if p := recover(); p != nil {
+				v, err = nil, fmt.Errorf("can not read XLPP type 0x%02x: panic: %v", t, p)
+			}
+		}()
 	}
 	{
 		// read value
 		var m int64
-		m, err = v.ReadFrom(r)
+		if a, ok := v.(*Array); ok && t == TypeArrayOf {
+			m, err = a.readArrayOf(r)
+		} else {
+			m, err = v.ReadFrom(r)
+		}
 		n += m
+		if err == nil {
+			err = validateValue(options(r), v)
+		}
 		if err != nil {
-			err = fmt.Errorf("can not read XLPP type 0x%02x: %v", t, err)
+			err = fmt.Errorf("can not read XLPP type 0x%02x: %w", t, toErr(err))
 			return
 		}
 	}
@@ -70,7 +351,33 @@ func read(r io.Reader) (v Value, n int64, err error) {
 }
 
 // Next reads the next channel and value from the reader.
+// The value is nil at the end of the input.
 func (r *Reader) Next() (channel int, v Value, err error) {
+	if r.metrics == nil {
+		return r.nextEntry()
+	}
+	start, consumed := time.Now(), r.consumed
+	channel, v, err = r.nextEntry()
+	r.metrics.Latency(time.Since(start))
+	if err != nil {
+		r.metrics.Error(err)
+	} else if v != nil {
+		r.metrics.Entry(v.XLPPType(), int(r.consumed-consumed))
+	}
+	return
+}
+
+func (r *Reader) nextEntry() (channel int, v Value, err error) {
+	if len(r.hooks) == 0 {
+		return r.next()
+	}
+	return r.nextHooked()
+}
+
+func (r *Reader) next() (channel int, v Value, err error) {
+	if len(r.tokens.stack) != 0 {
+		return 0, nil, errTokenDepth
+	}
 	var c byte
 	c, err = r.r.ReadByte()
 	channel = int(c)
@@ -80,56 +387,92 @@ func (r *Reader) Next() (channel int, v Value, err error) {
 		}
 		return
 	}
+	start := r.consumed
+	r.consumed++
+	if r.compressed(channel, start) {
+		if err = r.inflate(); err != nil {
+			err = offsetError(err, start, r.consumed)
+			return
+		}
+		return r.next()
+	}
+	var n int64
+	switch {
+	case r.strictLPP:
+		v, n, err = readStrictLPP(&r.d)
+	case isMarkerChannel(channel):
+		v = newMarker(channel)
+		n, err = v.ReadFrom(&r.d)
+		if p, ok := v.(*Priority); ok {
+			r.priority = *p
+		}
+	default:
+		v, n, err = read(&r.d)
+		if _, ok := v.(endOfArray); ok {
+			v, err = nil, errUnexpectedEndOfArray
+		}
+	}
+	r.consumed += n
+	if err != nil {
+		err = offsetError(err, start, r.consumed)
+	}
+	if r.validate {
+		if err == nil {
+			err = r.validateMarker(channel, v)
+		}
+		markerOffset(err, start)
+	}
+	return
+}
+
+// isMarkerChannel reports whether the channel is reserved for a marker.
+func isMarkerChannel(channel int) bool {
+	switch channel {
+	case ChanDelay, ChanMilliDelay, ChanPriority, ChanActuators, ChanActuatorsWithChannel, ChanActuatorAck:
+		return true
+	}
+	return false
+}
+
+// newMarker returns a new Marker for the marker channel.
+func newMarker(channel int) Value {
 	switch channel {
 	case ChanDelay:
-		v = new(Delay)
-		_, err = v.ReadFrom(r.r)
+		return new(Delay)
+	case ChanMilliDelay:
+		return new(MilliDelay)
+	case ChanPriority:
+		return new(Priority)
 	case ChanActuators:
-		v = new(Actuators)
-		_, err = v.ReadFrom(r.r)
+		return new(Actuators)
 	case ChanActuatorsWithChannel:
-		v = new(ActuatorsWithChannel)
-		_, err = v.ReadFrom(r.r)
-	default:
-		v, _, err = read(r.r)
+		return new(ActuatorsWithChannel)
+	case ChanActuatorAck:
+		return new(ActuatorAck)
 	}
+	return nil
+}
 
-	return
+// Priority returns the priority of the last Priority marker read so far, or PriorityRoutine.
+// Priority markers are usually the first entry of a message.
+func (r *Reader) Priority() Priority {
+	return r.priority
+}
+
+// BytesConsumed returns the number of bytes that have been read by Next so far.
+func (r *Reader) BytesConsumed() int64 {
+	return r.consumed
 }
 
+// Print reads all remaining values and prints them to the Logger of the Reader, see WithLogger.
+// The values are formatted by the zero Formatter, see Formatter.Print.
 func (r *Reader) Print() error {
-	log.Printf("chan | value")
-	i := 0
-	for {
-		channel, value, err := r.Next()
-		if err != nil {
-			log.Printf("xlpp error: %v", err)
-			return err
-		}
-		if value == nil {
-			log.Printf("end (%d values)", i)
-			return nil
-		}
-		i++
-		log.Printf("%-4d  %+v", channel, value)
-	}
+	return Formatter{}.Print(r.logger(), r)
 }
 
+// Sprint reads all remaining values and returns them as text, like Print.
 func (r *Reader) Sprint() (string, error) {
-	var s strings.Builder
-	log.Printf("chan | value\n")
-	i := 0
-	for {
-		channel, value, err := r.Next()
-		if err != nil {
-			s.WriteString(fmt.Sprintf("xlpp error: %v\n", err))
-			return s.String(), err
-		}
-		if value == nil {
-			s.WriteString(fmt.Sprintf("end (%d values)\n", i))
-			return s.String(), err
-		}
-		i++
-		s.WriteString(fmt.Sprintf("%-4d  %+v\n", channel, value))
-	}
+	var s stringLogger
+	err := Formatter{}.Print(&s, r)
+	return s.String(), err
 }
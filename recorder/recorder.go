@@ -0,0 +1,171 @@
+// Package recorder keeps the recent history of decoded XLPP messages in memory.
+//
+// A Recorder is fed with the messages of many devices, along with their reception times.
+// Delay markers are resolved into absolute timestamps, and the values are kept in a ring buffer per device and channel,
+// so that edge gateways can query the recent history without a database.
+package recorder
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/waziup/xlpp"
+)
+
+// A Sample is a value with the time it has been measured.
+type Sample struct {
+	Time  time.Time
+	Value xlpp.Value
+}
+
+// An Entry is a Sample with its channel.
+type Entry struct {
+	Channel int
+	Sample
+}
+
+// Resolve resolves the Delay and MilliDelay markers of a message received at the given time,
+// and returns the values with their absolute timestamps, see xlpp.Message.Timed.
+// Values after a Delay have been measured at the sum of all preceding Delays before the reception time.
+// Samples are expanded to one entry per reading.
+// Markers are not returned.
+func Resolve(received time.Time, m xlpp.Message) []Entry {
+	samples := m.Timed(received)
+	entries := make([]Entry, len(samples))
+	for i, s := range samples {
+		entries[i] = Entry{s.Channel, Sample{s.Time, s.Value}}
+	}
+	return entries
+}
+
+// A Recorder keeps the last samples of each device and channel.
+// It is safe for concurrent use.
+type Recorder struct {
+	size    int
+	mu      sync.RWMutex
+	devices map[string]map[int]*ring
+}
+
+// New returns a Recorder that keeps at most size samples per device and channel.
+func New(size int) *Recorder {
+	if size <= 0 {
+		size = 1
+	}
+	return &Recorder{
+		size:    size,
+		devices: make(map[string]map[int]*ring),
+	}
+}
+
+// Record records the values of a message that has been received from the device at the given time.
+func (r *Recorder) Record(device string, received time.Time, m xlpp.Message) {
+	entries := Resolve(received, m)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	channels := r.devices[device]
+	if channels == nil {
+		channels = make(map[int]*ring)
+		r.devices[device] = channels
+	}
+	for _, e := range entries {
+		buf := channels[e.Channel]
+		if buf == nil {
+			buf = &ring{samples: make([]Sample, 0, r.size)}
+			channels[e.Channel] = buf
+		}
+		buf.add(e.Sample)
+	}
+}
+
+// Devices returns the sorted names of all recorded devices.
+func (r *Recorder) Devices() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	devices := make([]string, 0, len(r.devices))
+	for device := range r.devices {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// Channels returns the sorted channels recorded for the device.
+func (r *Recorder) Channels(device string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	channels := make([]int, 0, len(r.devices[device]))
+	for channel := range r.devices[device] {
+		channels = append(channels, channel)
+	}
+	sort.Ints(channels)
+	return channels
+}
+
+// Latest returns the most recently measured sample of the device channel.
+func (r *Recorder) Latest(device string, channel int) (s Sample, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	buf := r.devices[device][channel]
+	if buf == nil {
+		return s, false
+	}
+	for i, sample := range buf.samples {
+		if i == 0 || sample.Time.After(s.Time) {
+			s = sample
+		}
+	}
+	return s, len(buf.samples) != 0
+}
+
+// Query returns the samples of the device channel measured in [from, to), sorted by time.
+// A zero from or to leaves the range open.
+func (r *Recorder) Query(device string, channel int, from, to time.Time) []Sample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	buf := r.devices[device][channel]
+	if buf == nil {
+		return nil
+	}
+	var samples []Sample
+	for _, s := range buf.ordered() {
+		if (from.IsZero() || !s.Time.Before(from)) && (to.IsZero() || s.Time.Before(to)) {
+			samples = append(samples, s)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+	return samples
+}
+
+// Forget removes all samples of the device.
+func (r *Recorder) Forget(device string) {
+	r.mu.Lock()
+	delete(r.devices, device)
+	r.mu.Unlock()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ring is a fixed size ring buffer of samples.
+type ring struct {
+	samples []Sample
+	next    int
+}
+
+func (b *ring) add(s Sample) {
+	if len(b.samples) < cap(b.samples) {
+		b.samples = append(b.samples, s)
+		return
+	}
+	b.samples[b.next] = s
+	b.next = (b.next + 1) % len(b.samples)
+}
+
+// ordered returns the samples in the order they have been recorded.
+func (b *ring) ordered() []Sample {
+	samples := make([]Sample, 0, len(b.samples))
+	samples = append(samples, b.samples[b.next:]...)
+	return append(samples, b.samples[:b.next]...)
+}
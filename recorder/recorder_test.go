@@ -0,0 +1,66 @@
+package recorder_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/recorder"
+)
+
+func TestRecorder(t *testing.T) {
+	received := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	t1, t2, t3 := xlpp.Temperature(20), xlpp.Temperature(21), xlpp.Temperature(22)
+	delay := xlpp.Delay(10 * time.Minute)
+	m := xlpp.Message{
+		{Channel: 1, Value: &t3},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 1, Value: &t2},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 1, Value: &t1},
+	}
+
+	entries := recorder.Resolve(received, m)
+	if len(entries) != 3 || !entries[2].Time.Equal(received.Add(-20*time.Minute)) {
+		t.Fatalf("resolved: %+v", entries)
+	}
+
+	r := recorder.New(2)
+	r.Record("node-1", received, m)
+	if devices := r.Devices(); len(devices) != 1 || devices[0] != "node-1" {
+		t.Fatalf("devices: %v", devices)
+	}
+	if channels := r.Channels("node-1"); len(channels) != 1 || channels[0] != 1 {
+		t.Fatalf("channels: %v", channels)
+	}
+	// The buffer keeps the last 2 recorded samples, t3 has been evicted.
+	samples := r.Query("node-1", 1, time.Time{}, time.Time{})
+	if len(samples) != 2 || samples[0].Value != &t1 || samples[1].Value != &t2 {
+		t.Fatalf("samples: %+v", samples)
+	}
+	if s, ok := r.Latest("node-1", 1); !ok || s.Value != &t2 || !s.Time.Equal(received.Add(-10*time.Minute)) {
+		t.Fatalf("latest: %+v", s)
+	}
+	if samples := r.Query("node-1", 1, received.Add(-time.Hour), received.Add(-15*time.Minute)); len(samples) != 1 || samples[0].Value != &t1 {
+		t.Fatalf("query range: %+v", samples)
+	}
+	if _, ok := r.Latest("node-2", 1); ok {
+		t.Fatal("unexpected sample for unknown device")
+	}
+	r.Forget("node-1")
+	if len(r.Devices()) != 0 {
+		t.Fatal("device not forgotten")
+	}
+}
+
+func TestResolveSamples(t *testing.T) {
+	received := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	samples := xlpp.Samples{Type: xlpp.TypeTemperature, Interval: time.Minute, Values: []float64{20, 21, 22}}
+	entries := recorder.Resolve(received, xlpp.Message{{Channel: 1, Value: &samples}})
+	if len(entries) != 3 || !entries[0].Time.Equal(received.Add(-2*time.Minute)) || !entries[2].Time.Equal(received) {
+		t.Fatalf("resolved: %+v", entries)
+	}
+	if v, ok := entries[1].Value.(*xlpp.Temperature); !ok || *v != 21 {
+		t.Fatalf("value: %v", entries[1].Value)
+	}
+}
@@ -1,5 +1,15 @@
 package xlpp
 
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Registry maps the XLPP types to factories of their values.
+// Use RegisterType to add custom types instead of modifying the map directly, and LookupType to read it
+// while types may be registered.
 var Registry = map[Type]func() Value{
 	// LPP Types
 	TypeDigitalInput:       func() Value { return new(DigitalInput) },
@@ -30,6 +40,24 @@ var Registry = map[Type]func() Value{
 	TypeColour:        func() Value { return new(Colour) },
 	TypeSwitch:        func() Value { return new(Switch) },
 
+	// extended-range Types
+	TypeExtendedPercentage:   func() Value { return new(ExtendedPercentage) },
+	TypeBarometricPressure24: func() Value { return new(BarometricPressure24) },
+	TypeDistanceLong:         func() Value { return new(DistanceLong) },
+	TypePowerPrecise:         func() Value { return new(PowerPrecise) },
+	TypeCurrentHiRange:       func() Value { return new(CurrentHiRange) },
+	TypeVoltageSigned:        func() Value { return new(VoltageSigned) },
+	TypeAnalogUnit:           func() Value { return new(AnalogUnit) },
+	TypeGasConcentration:     func() Value { return new(GasConcentration) },
+	TypeGPS2D:                func() Value { return new(GPS2D) },
+	TypeAccelerometerHiG:     func() Value { return new(AccelerometerHiG) },
+	TypeGyrometerHiRate:      func() Value { return new(GyrometerHiRate) },
+	TypeSamples:              func() Value { return new(Samples) },
+	TypeSpectrum:             func() Value { return new(Spectrum) },
+	TypeImageChunk:           func() Value { return new(ImageChunk) },
+	TypeTrack:                func() Value { return new(Track) },
+	TypeScheduledCommand:     func() Value { return new(ScheduledCommand) },
+
 	// XLPP Types
 	TypeInteger: func() Value { return new(Integer) },
 	TypeNull:    func() Value { return new(Null) },
@@ -44,7 +72,220 @@ var Registry = map[Type]func() Value{
 	TypeObject:     func() Value { return new(Object) },
 	TypeArray:      func() Value { return new(Array) },
 	TypeEndOfArray: func() Value { return endOfArray{} },
-	// TypeArrayOf: func() Value { return new(Array) },
-	// TypeFlags: func() Value { return new(Flags) },
-	TypeBinary: func() Value { return new(Binary) },
+	TypeArrayOf:    func() Value { return new(Array) },
+	TypeFlags:      func() Value { return new(Flags) },
+	TypeBinary:     func() Value { return new(Binary) },
+}
+
+// TypePrivateMin and TypePrivateMax are the range of types reserved for private (vendor) types.
+// This library will never use these types, so custom types in this range do not collide with future XLPP types.
+const (
+	TypePrivateMin Type = 200
+	TypePrivateMax Type = 248
+)
+
+// ErrTypeRegistered is returned by RegisterType if the type or the name is already registered.
+var ErrTypeRegistered = errors.New("xlpp: type already registered")
+
+// ErrRegistryFrozen is returned by RegisterType and UnregisterType after FreezeRegistry.
+var ErrRegistryFrozen = errors.New("xlpp: registry is frozen")
+
+var errTypeInvalid = errors.New("xlpp: type can not be registered")
+var errTypeNotPrivate = fmt.Errorf("xlpp: custom types must be in the private range %d to %d", TypePrivateMin, TypePrivateMax)
+var errTypeNotCustom = errors.New("xlpp: type has not been registered with RegisterType")
+
+// registryMu guards the Registry, RegistryByName and typeInfos.
+var registryMu sync.RWMutex
+
+var registryFrozen bool
+
+// customTypes are the types registered with RegisterType.
+var customTypes = make(map[Type]bool)
+
+// builtinTypes are the types of this package, i.e. the Registry before any type has been registered.
+var builtinTypes = func() map[Type]bool {
+	types := make(map[Type]bool, len(Registry))
+	for t := range Registry {
+		types[t] = true
+	}
+	return types
+}()
+
+// RegisterType registers a custom type with its canonical lowercase name and the factory of its values.
+// Custom types must be in the private range [TypePrivateMin, TypePrivateMax]. The name must consist of
+// the letters a to z only, as the JSON keys append the channel number to it, e.g. "vendor5".
+// It fails with ErrTypeRegistered if the type or the name is already registered.
+//
+// Readers decoding concurrently see the type once RegisterType returns. Register all types before decoding starts,
+// e.g. in an init function, and call FreezeRegistry afterwards, so that payloads decode the same way for the whole run.
+func RegisterType(t Type, name string, factory func() Value) error {
+	if t < TypePrivateMin || t > TypePrivateMax {
+		return errTypeNotPrivate
+	}
+	if !validName(name) || factory == nil {
+		return errTypeInvalid
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registryFrozen {
+		return ErrRegistryFrozen
+	}
+	if _, ok := Registry[t]; ok {
+		return fmt.Errorf("%w: type %d is %q", ErrTypeRegistered, t, typeInfos[t].name)
+	}
+	if _, ok := RegistryByName[name]; ok {
+		return fmt.Errorf("%w: name %q", ErrTypeRegistered, name)
+	}
+	Registry[t] = factory
+	RegistryByName[name] = factory
+	typeInfos[t] = typeInfo{name: name}
+	customTypes[t] = true
+	return nil
+}
+
+// validName reports whether the type name is not empty and consists of the letters a to z only.
+func validName(name string) bool {
+	for _, c := range name {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// UnregisterType removes a custom type that has been registered with RegisterType.
+func UnregisterType(t Type) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registryFrozen {
+		return ErrRegistryFrozen
+	}
+	if !customTypes[t] {
+		return errTypeNotCustom
+	}
+	delete(RegistryByName, typeInfos[t].name)
+	delete(Registry, t)
+	delete(typeInfos, t)
+	delete(customTypes, t)
+	return nil
+}
+
+// FreezeRegistry prevents all further modifications with RegisterType and UnregisterType.
+// It can not prevent writes to the Registry maps, which must not be modified directly.
+func FreezeRegistry() {
+	registryMu.Lock()
+	registryFrozen = true
+	registryMu.Unlock()
+}
+
+// LookupType returns the factory of the type from the Registry, or nil if the type is not registered.
+// Unlike reading the Registry map, it is safe while types are registered.
+func LookupType(t Type) func() Value {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return Registry[t]
+}
+
+// LookupName returns the factory of the type with the canonical name from RegistryByName, or nil if there is no such type.
+// Unlike reading the RegistryByName map, it is safe while types are registered.
+func LookupName(name string) func() Value {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return RegistryByName[name]
+}
+
+// typeInfoOf returns the typeInfo of the type.
+func typeInfoOf(t Type) typeInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return typeInfos[t]
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A TypeRegistry is a set of types for a Reader or Writer, that is used instead of the global Registry.
+// It allows decoding payloads of device families that use the same (private) types for different values.
+// It is safe for concurrent use.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types map[Type]func() Value
+}
+
+var errTypeNotInRegistry = errors.New("xlpp: type not in the TypeRegistry")
+
+// NewTypeRegistry returns a TypeRegistry with all types of the global Registry.
+func NewTypeRegistry() *TypeRegistry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	reg := &TypeRegistry{types: make(map[Type]func() Value, len(Registry))}
+	for t, f := range Registry {
+		reg.types[t] = f
+	}
+	return reg
+}
+
+// Register adds the type to the registry, replacing a type with the same id.
+func (reg *TypeRegistry) Register(t Type, factory func() Value) {
+	reg.mu.Lock()
+	reg.types[t] = factory
+	reg.mu.Unlock()
+}
+
+// Unregister removes the type from the registry.
+func (reg *TypeRegistry) Unregister(t Type) {
+	reg.mu.Lock()
+	delete(reg.types, t)
+	reg.mu.Unlock()
+}
+
+// Lookup returns the factory of the type, or nil if the type is not registered.
+func (reg *TypeRegistry) Lookup(t Type) func() Value {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.types[t]
+}
+
+// check checks that the value, and all values of Objects and Arrays, have the Go types of the registered types.
+func (reg *TypeRegistry) check(v Value) error {
+	switch v := v.(type) {
+	case Marker:
+		return nil
+	case *Object:
+		for _, item := range *v {
+			if err := reg.check(item); err != nil {
+				return err
+			}
+		}
+	case *Array:
+		for _, item := range *v {
+			if err := reg.check(item); err != nil {
+				return err
+			}
+		}
+	}
+	t := v.XLPPType()
+	f := reg.Lookup(t)
+	if f == nil {
+		return fmt.Errorf("%w: type %d", errTypeNotInRegistry, t)
+	}
+	if rt := reflect.TypeOf(f()); rt != reflect.TypeOf(v) {
+		return fmt.Errorf("%w: type %d is %v, not %T", errTypeNotInRegistry, t, rt, v)
+	}
+	return nil
+}
+
+// WithTypeRegistry makes the Reader decode the types of the registry instead of the global Registry.
+// Values are not taken from the pool of WithPool, as they may have other Go types.
+func WithTypeRegistry(reg *TypeRegistry) ReaderOption {
+	return func(r *Reader) {
+		r.types = reg
+	}
+}
+
+// WithWriterTypeRegistry makes the Writer check that all values have the Go types of the types in the registry,
+// which catches values of another device family.
+func WithWriterTypeRegistry(reg *TypeRegistry) WriterOption {
+	return func(w *Writer) {
+		w.types = reg
+	}
 }
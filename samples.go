@@ -0,0 +1,189 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// TypeSamples is the type of Samples.
+const TypeSamples Type = 71 // item type, uvarint interval [s], uvarint count, varint base value and deltas
+
+// sampleScales are the number of steps per unit of the types that can be used with Samples,
+// e.g. 10 for TypeTemperature with 0.1°C data resolution.
+var sampleScales = map[Type]float64{
+	TypeDigitalInput:       1,
+	TypeDigitalOutput:      1,
+	TypeAnalogInput:        100,
+	TypeAnalogOutput:       100,
+	TypeLuminosity:         1,
+	TypePresence:           1,
+	TypeTemperature:        10,
+	TypeRelativeHumidity:   2,
+	TypeBarometricPressure: 10,
+	TypeVoltage:            100,
+	TypeCurrent:            1000,
+	TypeFrequency:          1,
+	TypePercentage:         1,
+	TypeAltitude:           1,
+	TypeConcentration:      1,
+	TypePower:              1,
+	TypeDistance:           1000,
+	TypeEnergy:             1000,
+	TypeDirection:          1,
+	TypeInteger:            1,
+}
+
+var errSamplesType = errors.New("xlpp: unsupported Samples type")
+
+// Samples are consecutive readings of the same sensor, measured at a fixed interval.
+// Values are ordered from the oldest to the newest reading. The newest reading has been measured at the time of the entry
+// (see Delay), and each previous reading one Interval earlier.
+//
+// The first value is encoded as varint, all following values as varint delta to the previous value,
+// using the data resolution of the Type. So 60 slowly changing temperature readings take about 65 bytes,
+// instead of 60 values with 59 Delay markers that take more than 500 bytes.
+// Supported types are the scalar number types like TypeTemperature, TypeVoltage or TypeInteger.
+type Samples struct {
+	Type Type
+	// Interval is the time between two readings, with 1s resolution.
+	Interval time.Duration
+	Values   []float64
+}
+
+// XLPPType for Samples returns TypeSamples.
+func (v Samples) XLPPType() Type {
+	return TypeSamples
+}
+
+func (v Samples) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s every %s: [", len(v.Values), v.Type.Name(), v.Interval)
+	for i, f := range v.Values {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%g", f)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// At returns the i-th reading as a Value of the Samples Type, e.g. a *Temperature.
+// It returns nil if the Type is not registered, or its values are not numbers.
+func (v Samples) At(i int) Value {
+	f := LookupType(v.Type)
+	if f == nil {
+		return nil
+	}
+	value := f()
+	if value == nil || reflect.TypeOf(value).Kind() != reflect.Ptr {
+		return nil
+	}
+	elem := reflect.ValueOf(value).Elem()
+	if kindClass(elem.Kind()) != reflect.Float64 {
+		return nil
+	}
+	elem.Set(reflect.ValueOf(v.Values[i]).Convert(elem.Type()))
+	return value
+}
+
+// ReadFrom reads the Samples from the reader.
+func (v *Samples) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	defer func() {
+		n = int64(brc.Count)
+	}()
+	t, err := brc.ReadByte()
+	if err != nil {
+		return
+	}
+	v.Type = Type(t)
+	scale, ok := sampleScales[v.Type]
+	if !ok {
+		return n, errSamplesType
+	}
+	interval, err := binary.ReadUvarint(&brc)
+	if err != nil {
+		return
+	}
+	v.Interval = time.Duration(interval) * time.Second
+	count, err := binary.ReadUvarint(&brc)
+	if err == nil {
+		// every value takes at least one byte
+		err = checkLength(r, count)
+	}
+	if err != nil {
+		return
+	}
+	v.Values = make([]float64, count)
+	var i int64
+	for j := range v.Values {
+		var d int64
+		d, err = binary.ReadVarint(&brc)
+		if err != nil {
+			return
+		}
+		i += d
+		v.Values[j] = float64(i) / scale
+	}
+	return
+}
+
+// WriteTo writes the Samples to the writer.
+func (v Samples) WriteTo(w io.Writer) (n int64, err error) {
+	scale, ok := sampleScales[v.Type]
+	if !ok {
+		return 0, errSamplesType
+	}
+	buf := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(v.Values)*2)
+	var tmp [binary.MaxVarintLen64]byte
+	buf = append(buf, byte(v.Type))
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(v.Interval/time.Second))]...)
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(v.Values)))]...)
+	var prev int64
+	for _, f := range v.Values {
+		i := int64(trunc(f * scale))
+		buf = append(buf, tmp[:binary.PutVarint(tmp[:], i-prev)]...)
+		prev = i
+	}
+	m, err := writeTo(w, buf)
+	return int64(m), err
+}
+
+type samplesJSON struct {
+	Type     string    `json:"type"`
+	Interval float64   `json:"interval"`
+	Values   []float64 `json:"values"`
+}
+
+// MarshalJSON marshals the Samples as {"type":"temperature","interval":60,"values":[...]}, with the interval in seconds.
+func (v Samples) MarshalJSON() ([]byte, error) {
+	values := v.Values
+	if values == nil {
+		values = []float64{}
+	}
+	return json.Marshal(samplesJSON{v.Type.Name(), v.Interval.Seconds(), values})
+}
+
+// UnmarshalJSON unmarshals the Samples from {"type":"temperature","interval":60,"values":[...]}.
+func (v *Samples) UnmarshalJSON(data []byte) error {
+	var s samplesJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	f := LookupName(s.Type)
+	if f == nil {
+		return fmt.Errorf("xlpp: unknown Samples type %q", s.Type)
+	}
+	v.Type = f().XLPPType()
+	v.Interval = time.Duration(s.Interval * float64(time.Second))
+	v.Values = s.Values
+	return nil
+}
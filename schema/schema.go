@@ -0,0 +1,290 @@
+// Package schema parses schema files that describe the payload of a device:
+// its channels, types, value ranges and metadata.
+// A schema is the single input for payload validation, code generation and documentation.
+//
+// Schema files are line based. Empty lines and lines starting with # are ignored:
+//
+//	# Outdoor weather station
+//	model weather-station
+//	description "Weather station with heater"
+//
+//	channel 1 temperature "outdoor temperature" range -40..85
+//	channel 2 relativehumidity range 0..100
+//	channel 3 gps
+//	actuator 10 switch heater
+//
+// A channel or actuator line holds the channel number and type name (see xlpp.Type.Name),
+// followed by an optional name and the optional attributes "range MIN..MAX" and "unit UNIT".
+// Names with spaces are quoted.
+package schema
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/profiles"
+)
+
+// A Schema describes the payload of a device model.
+type Schema struct {
+	Model       string
+	Description string
+	// Channels are the values the device sends.
+	Channels []Field
+	// Actuators are the values the device accepts as commands.
+	Actuators []Field
+}
+
+// A Field is a channel of a schema.
+type Field struct {
+	Channel int
+	// Type is the type name, see xlpp.Type.Name.
+	Type string
+	Name string
+	// Unit overrides the unit of the type, see xlpp.Type.Unit.
+	Unit string
+	// Min and Max are the valid range of the value, if HasRange is set.
+	Min, Max float64
+	HasRange bool
+	// Line is the line of the field in the schema file.
+	Line int
+}
+
+// A SyntaxError is a syntax error in a schema file.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("schema: line %d: %s", e.Line, e.Msg)
+}
+
+// ParseFile parses and validates the schema file.
+func ParseFile(name string) (*Schema, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Parse parses and validates a schema.
+func Parse(r io.Reader) (*Schema, error) {
+	s := new(Schema)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		words, err := split(text)
+		if err != nil {
+			return nil, &SyntaxError{line, err.Error()}
+		}
+		switch words[0] {
+		case "model", "description":
+			if len(words) != 2 {
+				return nil, &SyntaxError{line, words[0] + " requires one argument"}
+			}
+			if words[0] == "model" {
+				s.Model = words[1]
+			} else {
+				s.Description = words[1]
+			}
+		case "channel", "actuator":
+			f, err := parseField(words[1:])
+			if err != nil {
+				return nil, &SyntaxError{line, err.Error()}
+			}
+			f.Line = line
+			if words[0] == "channel" {
+				s.Channels = append(s.Channels, f)
+			} else {
+				s.Actuators = append(s.Actuators, f)
+			}
+		default:
+			return nil, &SyntaxError{line, fmt.Sprintf("unknown directive %q", words[0])}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// split splits a line into words. Words with spaces are quoted.
+func split(line string) (words []string, err error) {
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] == '"' {
+			var word string
+			word, err = strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("bad quoted string %s", line)
+			}
+			line = line[len(word):]
+			word, _ = strconv.Unquote(word)
+			words = append(words, word)
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i == -1 {
+			i = len(line)
+		}
+		words = append(words, line[:i])
+		line = line[i:]
+	}
+	return
+}
+
+func parseField(words []string) (f Field, err error) {
+	if len(words) < 2 {
+		return f, fmt.Errorf("channel and type required")
+	}
+	if f.Channel, err = strconv.Atoi(words[0]); err != nil {
+		return f, fmt.Errorf("bad channel %q", words[0])
+	}
+	f.Type = words[1]
+	words = words[2:]
+	if len(words)%2 == 1 {
+		f.Name = words[0]
+		words = words[1:]
+	}
+	for i := 0; i < len(words); i += 2 {
+		switch key, value := words[i], words[i+1]; key {
+		case "range":
+			r := strings.SplitN(value, "..", 2)
+			if len(r) != 2 {
+				return f, fmt.Errorf("bad range %q, want MIN..MAX", value)
+			}
+			var err1, err2 error
+			f.Min, err1 = strconv.ParseFloat(r[0], 64)
+			f.Max, err2 = strconv.ParseFloat(r[1], 64)
+			if err1 != nil || err2 != nil {
+				return f, fmt.Errorf("bad range %q, want MIN..MAX", value)
+			}
+			f.HasRange = true
+		case "unit":
+			f.Unit = value
+		default:
+			return f, fmt.Errorf("unknown attribute %q", key)
+		}
+	}
+	return
+}
+
+// Validate checks that the schema has a model, that all types are known and the ranges are valid.
+func (s *Schema) Validate() error {
+	if s.Model == "" {
+		return fmt.Errorf("schema: no model")
+	}
+	for _, fields := range [][]Field{s.Channels, s.Actuators} {
+		seen := make(map[int]bool, len(fields))
+		for _, f := range fields {
+			if xlpp.LookupName(f.Type) == nil {
+				return &SyntaxError{f.Line, fmt.Sprintf("unknown type %q", f.Type)}
+			}
+			if f.Channel < 0 || f.Channel > 255 {
+				return &SyntaxError{f.Line, fmt.Sprintf("invalid channel %d", f.Channel)}
+			}
+			if seen[f.Channel] {
+				return &SyntaxError{f.Line, fmt.Sprintf("duplicate channel %d", f.Channel)}
+			}
+			seen[f.Channel] = true
+			if f.HasRange && f.Min > f.Max {
+				return &SyntaxError{f.Line, fmt.Sprintf("invalid range %g..%g", f.Min, f.Max)}
+			}
+		}
+	}
+	return nil
+}
+
+// Check checks that all values of an uplink message are described by the schema and within their ranges.
+// Markers are not checked.
+func (s *Schema) Check(m xlpp.Message) error {
+	return check(s.Model, s.Channels, m)
+}
+
+// CheckCommand checks that all values of a downlink command are actuators of the schema and within their ranges.
+func (s *Schema) CheckCommand(m xlpp.Message) error {
+	return check(s.Model, s.Actuators, m)
+}
+
+func check(model string, fields []Field, m xlpp.Message) error {
+	for _, e := range m {
+		if _, ok := e.Value.(xlpp.Marker); ok {
+			continue
+		}
+		f, ok := field(fields, e.Channel)
+		if !ok {
+			return fmt.Errorf("schema: %s: unexpected channel %d", model, e.Channel)
+		}
+		if name := xlpp.NameOf(e.Value); name != f.Type {
+			return fmt.Errorf("schema: %s: channel %d: unexpected type %s, want %s", model, e.Channel, name, f.Type)
+		}
+		if n, ok := number(e.Value); ok && f.HasRange && (n < f.Min || n > f.Max) {
+			return fmt.Errorf("schema: %s: channel %d: %s out of range %g..%g", model, e.Channel, e.Value, f.Min, f.Max)
+		}
+	}
+	return nil
+}
+
+func field(fields []Field, channel int) (Field, bool) {
+	for _, f := range fields {
+		if f.Channel == channel {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// number returns the numeric value of scalar values.
+func number(v xlpp.Value) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// Profile returns the device profile of the schema.
+func (s *Schema) Profile() *profiles.Profile {
+	p := &profiles.Profile{
+		Model:       s.Model,
+		Description: s.Description,
+		Channels:    channels(s.Channels),
+		Actuators:   channels(s.Actuators),
+	}
+	return p
+}
+
+func channels(fields []Field) []profiles.Channel {
+	if len(fields) == 0 {
+		return nil
+	}
+	c := make([]profiles.Channel, len(fields))
+	for i, f := range fields {
+		c[i] = profiles.Channel{Channel: f.Channel, Type: f.Type, Name: f.Name, Unit: f.Unit}
+	}
+	return c
+}
@@ -0,0 +1,85 @@
+package schema_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/schema"
+)
+
+const weatherStation = `# Outdoor weather station
+model weather-station
+description "Weather station with heater"
+
+channel 1 temperature "outdoor temperature" range -40..85
+channel 2 relativehumidity range 0..100 unit %RH
+channel 3 gps
+actuator 10 switch heater
+`
+
+func TestSchema(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(weatherStation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Model != "weather-station" || s.Description != "Weather station with heater" || len(s.Channels) != 3 || len(s.Actuators) != 1 {
+		t.Fatalf("schema: %+v", s)
+	}
+	if f := s.Channels[0]; f.Name != "outdoor temperature" || !f.HasRange || f.Min != -40 || f.Max != 85 || f.Line != 5 {
+		t.Fatalf("field: %+v", f)
+	}
+	if f := s.Channels[1]; f.Name != "" || f.Unit != "%RH" || f.Max != 100 {
+		t.Fatalf("field: %+v", f)
+	}
+
+	temperature := xlpp.Temperature(21.5)
+	humidity := xlpp.RelativeHumidity(60)
+	if err := s.Check(xlpp.Message{{Channel: 1, Value: &temperature}, {Channel: 2, Value: &humidity}}); err != nil {
+		t.Fatal(err)
+	}
+	hot := xlpp.Temperature(90)
+	if err := s.Check(xlpp.Message{{Channel: 1, Value: &hot}}); err == nil {
+		t.Fatal("expected error for value out of range")
+	}
+	if err := s.Check(xlpp.Message{{Channel: 1, Value: &humidity}}); err == nil {
+		t.Fatal("expected error for unexpected type")
+	}
+	on := xlpp.Switch(true)
+	if err := s.CheckCommand(xlpp.Message{{Channel: 10, Value: &on}}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := s.Profile()
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Channels) != 3 || p.Channels[1].Unit != "%RH" || p.Actuators[0].Name != "heater" {
+		t.Fatalf("profile: %+v", p)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	for _, test := range []struct {
+		schema string
+		line   int
+	}{
+		{"model m\nchannel 1 unknown", 2},
+		{"model m\nchannel 1 temperature\nchannel 1 voltage", 3},
+		{"model m\nchannel 1 temperature range 5..1", 2},
+		{"model m\nchannel 1 temperature range 5", 2},
+		{"model m\n\nchannel x temperature", 3},
+		{"model m\nsensor 1 temperature", 2},
+		{"model m\nchannel 1 temperature \"unterminated", 2},
+	} {
+		_, err := schema.Parse(strings.NewReader(test.schema))
+		var e *schema.SyntaxError
+		if !errors.As(err, &e) || e.Line != test.line {
+			t.Errorf("%q: got %v, want error on line %d", test.schema, err, test.line)
+		}
+	}
+	if _, err := schema.Parse(strings.NewReader("channel 1 temperature")); err == nil {
+		t.Error("expected error for missing model")
+	}
+}
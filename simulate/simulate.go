@@ -0,0 +1,163 @@
+// Package simulate generates realistic, time-varying XLPP payloads for the channels of a schema,
+// for load-testing and demoing backends without hardware.
+//
+// Temperatures follow a diurnal curve, GPS positions move along a circular track,
+// batteries (Voltage) decay slowly, and all other numbers do a random walk within the range of their channel.
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/schema"
+)
+
+// A Simulator simulates a device described by a schema.
+// It is not safe for concurrent use.
+type Simulator struct {
+	schema *schema.Schema
+	rand   *rand.Rand
+	start  time.Time
+	// walks are the current values of the random walks, by channel.
+	walks map[int]float64
+}
+
+// New returns a Simulator for the channels of the schema.
+// Simulators with the same seed generate the same values.
+func New(s *schema.Schema, seed int64) *Simulator {
+	return &Simulator{
+		schema: s,
+		rand:   rand.New(rand.NewSource(seed)),
+		walks:  make(map[int]float64),
+	}
+}
+
+// Message returns the simulated values of all channels at time t.
+// The first call sets the start time of the simulation, e.g. for the battery decay.
+func (sim *Simulator) Message(t time.Time) xlpp.Message {
+	if sim.start.IsZero() {
+		sim.start = t
+	}
+	m := make(xlpp.Message, 0, len(sim.schema.Channels))
+	for _, f := range sim.schema.Channels {
+		m = append(m, xlpp.Entry{Channel: f.Channel, Value: sim.value(f, t)})
+	}
+	return m
+}
+
+// Payload returns the encoded simulated values of all channels at time t.
+func (sim *Simulator) Payload(t time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for _, e := range sim.Message(t) {
+		if _, err := w.Add(e.Channel, e.Value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Run generates a payload every interval and calls fn with it, until the context is done or fn returns an error.
+func (sim *Simulator) Run(ctx context.Context, interval time.Duration, fn func(t time.Time, payload []byte) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	t := time.Now()
+	for {
+		payload, err := sim.Payload(t)
+		if err != nil {
+			return err
+		}
+		if err := fn(t, payload); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case t = <-ticker.C:
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// batteryLife is the time the simulated battery takes to decay from full to empty.
+const batteryLife = 30 * 24 * time.Hour
+
+// value returns the simulated value of the field at time t.
+func (sim *Simulator) value(f schema.Field, t time.Time) xlpp.Value {
+	v := xlpp.LookupName(f.Type)()
+	switch v := v.(type) {
+	case *xlpp.Temperature:
+		*v = xlpp.Temperature(sim.clamp(f, 15+8*diurnal(t)+sim.noise(0.2)))
+	case *xlpp.RelativeHumidity:
+		*v = xlpp.RelativeHumidity(sim.clamp(f, 60-20*diurnal(t)+sim.noise(1)))
+	case *xlpp.Luminosity:
+		*v = xlpp.Luminosity(sim.clamp(f, math.Max(0, 20000*diurnal(t)+sim.noise(100))))
+	case *xlpp.Voltage:
+		elapsed := float64(t.Sub(sim.start)) / float64(batteryLife)
+		*v = xlpp.Voltage(sim.clamp(f, math.Max(3.0, 4.2-1.2*elapsed)))
+	case *xlpp.GPS:
+		// one round of 500m radius per hour
+		a := 2 * math.Pi * float64(t.Sub(sim.start)%time.Hour) / float64(time.Hour)
+		v.Latitude = 48.1372 + 0.0045*math.Sin(a)
+		v.Longitude = 11.5756 + 0.0067*math.Cos(a)
+		v.Meters = 520 + sim.noise(2)
+	case *xlpp.Accelerometer:
+		v.X, v.Y, v.Z = sim.noise(0.01), sim.noise(0.01), 1+sim.noise(0.01)
+	case *xlpp.Gyrometer:
+		v.X, v.Y, v.Z = float32(sim.noise(1)), float32(sim.noise(1)), float32(sim.noise(1))
+	default:
+		sim.walk(f, v)
+	}
+	return v
+}
+
+// diurnal returns the daily curve at time t, from -1 at 3:00 to 1 at 15:00.
+func diurnal(t time.Time) float64 {
+	hours := float64(t.Hour()) + float64(t.Minute())/60
+	return math.Sin(2 * math.Pi * (hours - 9) / 24)
+}
+
+func (sim *Simulator) noise(stddev float64) float64 {
+	return sim.rand.NormFloat64() * stddev
+}
+
+// clamp limits n to the range of the field, if any.
+func (sim *Simulator) clamp(f schema.Field, n float64) float64 {
+	if f.HasRange {
+		n = math.Min(math.Max(n, f.Min), f.Max)
+	}
+	return n
+}
+
+// walk sets numbers to the next step of a random walk within the range of the field, or within 0..100.
+// Booleans toggle now and then, other values are left zero.
+func (sim *Simulator) walk(f schema.Field, v xlpp.Value) {
+	min, max := 0.0, 100.0
+	if f.HasRange {
+		min, max = f.Min, f.Max
+	}
+	n, ok := sim.walks[f.Channel]
+	if !ok {
+		n = min + sim.rand.Float64()*(max-min)
+	}
+	n = math.Min(math.Max(n+sim.noise((max-min)/50), min), max)
+	sim.walks[f.Channel] = n
+
+	rv := reflect.ValueOf(v).Elem()
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		rv.SetFloat(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rv.SetInt(int64(math.Round(n)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rv.SetUint(uint64(math.Max(0, math.Round(n))))
+	case reflect.Bool:
+		rv.SetBool(sim.rand.Intn(10) == 0)
+	}
+}
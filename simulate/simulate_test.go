@@ -0,0 +1,56 @@
+package simulate_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/schema"
+	"github.com/waziup/xlpp/simulate"
+)
+
+const tracker = `model tracker
+channel 1 temperature range -40..85
+channel 2 voltage "battery"
+channel 3 gps
+channel 4 percentage range 0..100
+channel 5 luminosity
+`
+
+func TestSimulator(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(tracker))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 6, 1, 3, 0, 0, 0, time.UTC)
+	a, b := simulate.New(s, 1), simulate.New(s, 1)
+	for i := 0; i < 48; i++ {
+		now := start.Add(time.Duration(i) * time.Hour)
+		payload, err := a.Payload(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, _ := b.Payload(now)
+		if !bytes.Equal(payload, other) {
+			t.Fatal("simulators with the same seed differ")
+		}
+		m, err := xlpp.NewBytesReader(payload).ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Check(m); err != nil {
+			t.Fatalf("hour %d: %v", i, err)
+		}
+		if i == 0 && float64(*m[0].Value.(*xlpp.Temperature)) > 10 {
+			t.Fatalf("temperature at 3:00: %v", m[0].Value)
+		}
+		if i == 12 && float64(*m[0].Value.(*xlpp.Temperature)) < 20 {
+			t.Fatalf("temperature at 15:00: %v", m[0].Value)
+		}
+		if v := *m[1].Value.(*xlpp.Voltage); i != 0 && v >= 4.2 {
+			t.Fatalf("battery does not decay: %v", v)
+		}
+	}
+}
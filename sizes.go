@@ -0,0 +1,77 @@
+package xlpp
+
+// TypeGenericSensor is the generic sensor type of Cayenne LPP extensions (4 bytes, unsigned).
+// It is not in the Registry, but can be skipped with WithSkipUnknownTypes.
+const TypeGenericSensor Type = 100
+
+// VariableSize is the wire size of types whose values have different sizes, e.g. TypeString.
+const VariableSize = -1
+
+// Sizes are the wire sizes of the values of all known types: the number of bytes after the type byte,
+// or VariableSize. They can be used to plan the size of payloads, e.g. 4 bytes for a Temperature entry
+// (channel, type and 2 bytes of value), and to skip unknown types (see WithSkipUnknownTypes).
+// Sizes include types of other LPP implementations that are not in the Registry, see TypeGenericSensor.
+// Add the sizes of custom types before decoding starts, like their RegisterType.
+var Sizes = map[Type]int{
+	// LPP Types
+	TypeDigitalInput:       1,
+	TypeDigitalOutput:      1,
+	TypeAnalogInput:        2,
+	TypeAnalogOutput:       2,
+	TypeGenericSensor:      4,
+	TypeLuminosity:         2,
+	TypePresence:           1,
+	TypeTemperature:        2,
+	TypeRelativeHumidity:   1,
+	TypeAccelerometer:      6,
+	TypeBarometricPressure: 2,
+	TypeGyrometer:          6,
+	TypeGPS:                9,
+
+	// more LPP Types
+	TypeVoltage:       2,
+	TypeCurrent:       2,
+	TypeFrequency:     4,
+	TypePercentage:    1,
+	TypeAltitude:      2,
+	TypeConcentration: 2,
+	TypePower:         2,
+	TypeDistance:      4,
+	TypeEnergy:        4,
+	TypeDirection:     2,
+	TypeUnixTime:      4,
+	TypeColour:        3,
+	TypeSwitch:        1,
+
+	// extended-range Types
+	TypeExtendedPercentage:   1,
+	TypeBarometricPressure24: 3,
+	TypeDistanceLong:         VariableSize,
+	TypePowerPrecise:         4,
+	TypeCurrentHiRange:       4,
+	TypeVoltageSigned:        4,
+	TypeAnalogUnit:           5,
+	TypeGasConcentration:     5,
+	TypeGPS2D:                6,
+	TypeAccelerometerHiG:     6,
+	TypeGyrometerHiRate:      6,
+	TypeSamples:              VariableSize,
+	TypeSpectrum:             VariableSize,
+	TypeImageChunk:           VariableSize,
+	TypeTrack:                VariableSize,
+	TypeScheduledCommand:     VariableSize,
+
+	// XLPP Types
+	TypeInteger:    VariableSize,
+	TypeNull:       0,
+	TypeString:     VariableSize,
+	TypeBool:       0,
+	TypeBoolTrue:   0,
+	TypeBoolFalse:  0,
+	TypeObject:     VariableSize,
+	TypeArray:      VariableSize,
+	TypeArrayOf:    VariableSize,
+	TypeEndOfArray: 0,
+	TypeFlags:      VariableSize,
+	TypeBinary:     VariableSize,
+}
@@ -0,0 +1,56 @@
+package xlpp
+
+import (
+	"fmt"
+	"io"
+)
+
+// WithSkipUnknownTypes makes the Reader skip values of unknown types with a fixed wire size (see Sizes),
+// instead of failing with an UnknownTypeError. This keeps the rest of the payload readable,
+// e.g. if the sender uses a type that is missing in the TypeRegistry of the Reader (see WithTypeRegistry),
+// or a type of another LPP implementation like TypeGenericSensor.
+// The skipped values are returned as *UnknownValue. Unknown types of variable size still fail.
+func WithSkipUnknownTypes() ReaderOption {
+	return func(r *Reader) {
+		r.skipUnknown = true
+	}
+}
+
+// skipSize returns the wire size of the unknown type t, if the Reader skips unknown types and t has a fixed size.
+func skipSize(r io.Reader, t Type) (size int, ok bool) {
+	if opts := options(r); opts == nil || !opts.skipUnknown {
+		return 0, false
+	}
+	size, ok = Sizes[t]
+	return size, ok && size != VariableSize
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// UnknownValue is the raw data of a value of an unknown type, see WithSkipUnknownTypes.
+type UnknownValue struct {
+	Type Type
+	Data []byte
+}
+
+// XLPPType for UnknownValue returns its Type.
+func (v UnknownValue) XLPPType() Type {
+	return v.Type
+}
+
+func (v UnknownValue) String() string {
+	return fmt.Sprintf("unknown type 0x%02x: %X", v.Type, v.Data)
+}
+
+// ReadFrom reads len(v.Data) bytes of data from the reader.
+func (v *UnknownValue) ReadFrom(r io.Reader) (n int64, err error) {
+	var m int
+	m, err = io.ReadFull(r, v.Data)
+	return int64(m), toErr(err)
+}
+
+// WriteTo writes the data to the writer.
+func (v UnknownValue) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := writeTo(w, v.Data)
+	return int64(m), err
+}
@@ -0,0 +1,116 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// TypeSpectrum is the type of Spectrum.
+const TypeSpectrum Type = 72 // uvarint bin width [0.01Hz], uvarint length, 1 byte per bin
+
+// Spectrum is a compact frequency spectrum, e.g. the FFT of an audio or vibration signal of a predictive-maintenance sensor.
+// Bin i covers the frequencies from i*BinWidth to (i+1)*BinWidth.
+// The bins are log-magnitudes with 1 byte each. Their scale is defined by the device, e.g. 0.5 dB per step.
+type Spectrum struct {
+	// BinWidth is the width of each bin [Hz] with 0.01Hz data resolution.
+	BinWidth float64
+	Bins     []uint8
+}
+
+// XLPPType for Spectrum returns TypeSpectrum.
+func (v Spectrum) XLPPType() Type {
+	return TypeSpectrum
+}
+
+func (v Spectrum) String() string {
+	f, m := v.Peak()
+	return fmt.Sprintf("%d bins of %.2f Hz, peak %d at %.2f Hz", len(v.Bins), v.BinWidth, m, f)
+}
+
+// Frequency returns the center frequency [Hz] of bin i.
+func (v Spectrum) Frequency(i int) float64 {
+	return (float64(i) + 0.5) * v.BinWidth
+}
+
+// Peak returns the center frequency and magnitude of the largest bin.
+func (v Spectrum) Peak() (frequency float64, magnitude uint8) {
+	peak := 0
+	for i, m := range v.Bins {
+		if m > v.Bins[peak] {
+			peak = i
+		}
+	}
+	if len(v.Bins) == 0 {
+		return 0, 0
+	}
+	return v.Frequency(peak), v.Bins[peak]
+}
+
+// ReadFrom reads the Spectrum from the reader.
+func (v *Spectrum) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	width, err := binary.ReadUvarint(&brc)
+	if err != nil {
+		return int64(brc.Count), err
+	}
+	v.BinWidth = float64(width) / 100
+	l, err := binary.ReadUvarint(&brc)
+	if err == nil {
+		err = checkLength(r, l)
+	}
+	if err != nil {
+		return int64(brc.Count), err
+	}
+	v.Bins = make([]uint8, l)
+	var m int
+	m, err = io.ReadFull(r, v.Bins)
+	return int64(brc.Count + m), err
+}
+
+// WriteTo writes the Spectrum to the writer.
+func (v Spectrum) WriteTo(w io.Writer) (n int64, err error) {
+	var buf [2 * binary.MaxVarintLen64]byte
+	m := binary.PutUvarint(buf[:], uint64(trunc(v.BinWidth*100)))
+	m += binary.PutUvarint(buf[m:], uint64(len(v.Bins)))
+	m, err = writeTo(w, buf[:m])
+	n = int64(m)
+	if err == nil {
+		m, err = w.Write(v.Bins)
+		n += int64(m)
+	}
+	return
+}
+
+type spectrumJSON struct {
+	BinWidth float64 `json:"binWidth"`
+	Bins     []int   `json:"bins"`
+}
+
+// MarshalJSON marshals the Spectrum as {"binWidth":12.5,"bins":[...]}, with the bins as numbers.
+func (v Spectrum) MarshalJSON() ([]byte, error) {
+	s := spectrumJSON{BinWidth: v.BinWidth, Bins: make([]int, len(v.Bins))}
+	for i, b := range v.Bins {
+		s.Bins[i] = int(b)
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON unmarshals the Spectrum from {"binWidth":12.5,"bins":[...]}.
+func (v *Spectrum) UnmarshalJSON(data []byte) error {
+	var s spectrumJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v.BinWidth = s.BinWidth
+	v.Bins = make([]uint8, len(s.Bins))
+	for i, b := range s.Bins {
+		if b < 0 || b > 255 {
+			return fmt.Errorf("xlpp: spectrum bin %d out of range 0..255", b)
+		}
+		v.Bins[i] = uint8(b)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package xlpp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrStrictLPP is returned by Readers and Writers created with WithStrictLPP and WithWriterStrictLPP,
+// for types and markers that are not part of Cayenne LPP.
+var ErrStrictLPP = errors.New("xlpp: not a Cayenne LPP type")
+
+// IsCayenneLPP reports whether t is a type of the original Cayenne LPP, from TypeDigitalInput to TypeGPS.
+// These are the types of the Cayenne decoders of network servers, e.g. the built-in codec of ChirpStack.
+func (t Type) IsCayenneLPP() bool {
+	switch t {
+	case TypeDigitalInput, TypeDigitalOutput, TypeAnalogInput, TypeAnalogOutput, TypeLuminosity, TypePresence,
+		TypeTemperature, TypeRelativeHumidity, TypeAccelerometer, TypeBarometricPressure, TypeGyrometer, TypeGPS:
+		return true
+	}
+	return false
+}
+
+// WithStrictLPP makes the Reader decode payloads as Cayenne LPP: all types other than the Cayenne LPP types
+// (see Type.IsCayenneLPP) are rejected with ErrStrictLPP, and channels 250 to 255 are plain channels, not markers.
+func WithStrictLPP() ReaderOption {
+	return func(r *Reader) {
+		r.strictLPP = true
+	}
+}
+
+// WithWriterStrictLPP makes the Writer reject all types other than the Cayenne LPP types (see Type.IsCayenneLPP)
+// and all markers with ErrStrictLPP, so that the payload is guaranteed to decode with any Cayenne LPP decoder,
+// e.g. the built-in codec of ChirpStack.
+func WithWriterStrictLPP() WriterOption {
+	return func(w *Writer) {
+		w.strictLPP = true
+	}
+}
+
+// checkStrictLPP checks that t is a Cayenne LPP type.
+func checkStrictLPP(t Type) error {
+	if t.IsCayenneLPP() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s (0x%02x)", ErrStrictLPP, t.Name(), t)
+}
+
+// checkStrictLPP checks the value added to a Writer created with WithWriterStrictLPP.
+func (w *Writer) checkStrictLPP(v Value) error {
+	if !w.strictLPP {
+		return nil
+	}
+	if _, ok := v.(Marker); ok {
+		return fmt.Errorf("%w: %s marker", ErrStrictLPP, NameOf(v))
+	}
+	return checkStrictLPP(v.XLPPType())
+}
+
+// readStrictLPP reads a type and value like read, but only of the Cayenne LPP types.
+func readStrictLPP(r io.Reader) (v Value, n int64, err error) {
+	var buf [1]byte
+	n, err = readFrom(r, buf[:])
+	if err != nil {
+		err = toErr(err)
+		return
+	}
+	t := Type(buf[0])
+	if err = checkStrictLPP(t); err != nil {
+		return
+	}
+	var m int64
+	v, m, err = readValue(r, t)
+	n += m
+	return
+}
@@ -0,0 +1,109 @@
+// Adapter for the differential test: encodes entries with the Arduino XLPP library.
+//
+// Build it on the host against the library sources (with an Arduino.h stub for the host), e.g.
+//
+//	g++ -o xlpp-arduino -I path/to/arduino-xlpp/src testdata/differential/arduino.cpp path/to/arduino-xlpp/src/xlpp.cpp
+//
+// and run the test with XLPP_ARDUINO_ENCODER=./xlpp-arduino.
+// Each input line holds the entries of a payload as "channel type field field ...", separated by ";".
+// The payload is written as hex, one line per input line.
+
+#include <cstdio>
+#include <cstring>
+#include <sstream>
+#include <string>
+
+#include "xlpp.h"
+
+static bool add(XLPP &lpp, std::istringstream &entry)
+{
+	int channel;
+	std::string type;
+	double a = 0, b = 0, c = 0;
+	entry >> channel >> type >> a >> b >> c;
+
+	if (channel == 253 && type == "delay")
+		lpp.addDelay(a, b, c);
+	else if (type == "digitalinput")
+		lpp.addDigitalInput(channel, a);
+	else if (type == "digitaloutput")
+		lpp.addDigitalOutput(channel, a);
+	else if (type == "analoginput")
+		lpp.addAnalogInput(channel, a);
+	else if (type == "analogoutput")
+		lpp.addAnalogOutput(channel, a);
+	else if (type == "luminosity")
+		lpp.addLuminosity(channel, a);
+	else if (type == "presence")
+		lpp.addPresence(channel, a);
+	else if (type == "temperature")
+		lpp.addTemperature(channel, a);
+	else if (type == "relativehumidity")
+		lpp.addRelativeHumidity(channel, a);
+	else if (type == "accelerometer")
+		lpp.addAccelerometer(channel, a, b, c);
+	else if (type == "barometricpressure")
+		lpp.addBarometricPressure(channel, a);
+	else if (type == "gyrometer")
+		lpp.addGyrometer(channel, a, b, c);
+	else if (type == "gps")
+		lpp.addGPS(channel, a, b, c);
+	else if (type == "voltage")
+		lpp.addVoltage(channel, a);
+	else if (type == "current")
+		lpp.addCurrent(channel, a);
+	else if (type == "frequency")
+		lpp.addFrequency(channel, a);
+	else if (type == "percentage")
+		lpp.addPercentage(channel, a);
+	else if (type == "altitude")
+		lpp.addAltitude(channel, a);
+	else if (type == "concentration")
+		lpp.addConcentration(channel, a);
+	else if (type == "power")
+		lpp.addPower(channel, a);
+	else if (type == "distance")
+		lpp.addDistance(channel, a);
+	else if (type == "energy")
+		lpp.addEnergy(channel, a);
+	else if (type == "direction")
+		lpp.addDirection(channel, a);
+	else if (type == "unixtime")
+		lpp.addUnixTime(channel, a);
+	else if (type == "colour")
+		lpp.addColour(channel, a, b, c);
+	else if (type == "switch")
+		lpp.addSwitch(channel, a);
+	else if (type == "integer")
+		lpp.addInteger(channel, a);
+	else
+		return false;
+	return true;
+}
+
+int main()
+{
+	char line[4096];
+	while (fgets(line, sizeof(line), stdin))
+	{
+		line[strcspn(line, "\n")] = 0;
+		XLPP lpp(sizeof(line));
+		std::istringstream entries(line);
+		std::string entry;
+		bool ok = true;
+		while (ok && std::getline(entries, entry, ';'))
+		{
+			std::istringstream s(entry);
+			ok = add(lpp, s);
+		}
+		if (!ok)
+		{
+			printf("error: unsupported entry %s\n", entry.c_str());
+			continue;
+		}
+		for (size_t i = 0; i < lpp.getSize(); i++)
+			printf("%02x", lpp.getBuffer()[i]);
+		printf("\n");
+	}
+	return 0;
+}
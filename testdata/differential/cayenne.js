@@ -0,0 +1,110 @@
+// Adapter for the differential test: runs a Cayenne LPP JavaScript decoder on hex payloads.
+//
+// usage: node cayenne.js decoder.js < payloads
+//
+// The decoder script must define one of the functions decodeUplink(input) (TTN v3),
+// Decoder(bytes, port) (TTN v2) or lppDecode(bytes), returning the values as
+// {"<name>_<channel>": value}. The values are written in the xlpp MessageJSON format,
+// one line per payload.
+
+const fs = require("fs");
+const vm = require("vm");
+const readline = require("readline");
+
+const names = {
+  digital_in: "digitalinput",
+  digital_input: "digitalinput",
+  digital_out: "digitaloutput",
+  digital_output: "digitaloutput",
+  analog_in: "analoginput",
+  analog_input: "analoginput",
+  analog_out: "analogoutput",
+  analog_output: "analogoutput",
+  illuminance: "luminosity",
+  luminosity: "luminosity",
+  presence: "presence",
+  temperature: "temperature",
+  humidity: "relativehumidity",
+  relative_humidity: "relativehumidity",
+  accelerometer: "accelerometer",
+  barometer: "barometricpressure",
+  barometric_pressure: "barometricpressure",
+  gyrometer: "gyrometer",
+  gps: "gps",
+  voltage: "voltage",
+  current: "current",
+  frequency: "frequency",
+  percentage: "percentage",
+  altitude: "altitude",
+  concentration: "concentration",
+  power: "power",
+  distance: "distance",
+  energy: "energy",
+  direction: "direction",
+  time: "unixtime",
+  unixtime: "unixtime",
+  colour: "colour",
+  color: "colour",
+  switch: "switch",
+};
+
+const context = vm.createContext({});
+vm.runInContext(fs.readFileSync(process.argv[2], "utf8"), context);
+
+function decode(bytes) {
+  if (typeof context.decodeUplink === "function") {
+    const result = context.decodeUplink({ bytes: bytes, fPort: 1 });
+    if (result.errors && result.errors.length) {
+      throw new Error(result.errors.join(", "));
+    }
+    return result.data;
+  }
+  if (typeof context.Decoder === "function") {
+    return context.Decoder(bytes, 1);
+  }
+  if (typeof context.lppDecode === "function") {
+    return context.lppDecode(bytes);
+  }
+  throw new Error("no decoder function");
+}
+
+function hex(n) {
+  return ("0" + n.toString(16)).slice(-2);
+}
+
+function convert(type, value) {
+  switch (type) {
+    case "accelerometer":
+    case "gyrometer":
+      return { X: value.x, Y: value.y, Z: value.z };
+    case "gps":
+      return { Latitude: value.latitude, Longitude: value.longitude, Meters: value.altitude };
+    case "colour":
+      return "#" + hex(value.r) + hex(value.g) + hex(value.b);
+    case "switch":
+      return !!value;
+  }
+  return value;
+}
+
+function entries(data) {
+  const list = [];
+  for (const key of Object.keys(data)) {
+    const match = /^(.*)_(\d+)$/.exec(key);
+    if (!match || !names[match[1]]) {
+      throw new Error("unknown value " + key);
+    }
+    const type = names[match[1]];
+    list.push({ channel: Number(match[2]), type: type, value: convert(type, data[key]) });
+  }
+  return list;
+}
+
+readline.createInterface({ input: process.stdin }).on("line", (line) => {
+  try {
+    const bytes = Buffer.from(line.trim(), "hex");
+    console.log(JSON.stringify(entries(decode(Array.from(bytes)))));
+  } catch (err) {
+    console.log("error: " + String(err.message || err).replace(/\n/g, " "));
+  }
+});
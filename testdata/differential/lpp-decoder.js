@@ -0,0 +1,88 @@
+// A Cayenne LPP decoder for the differential test, written from the Cayenne LPP documentation
+// and the type table of the ElectronicCats CayenneLPP library. It does not share any code with
+// this package, and is used to record testdata/differential/cayenne-js.out:
+//
+//	XLPP_CAYENNE_DECODER=testdata/differential/lpp-decoder.js go test -run TestDifferential -update .
+//
+// It only knows the Cayenne LPP types, and fails for all other types.
+
+// types maps the type IDs to the value name, the size in bytes and the decoder of the value.
+var types = {
+  0: ["digital_in", 1, uint(1, 1)],
+  1: ["digital_out", 1, uint(1, 1)],
+  2: ["analog_in", 2, int(2, 100)],
+  3: ["analog_out", 2, int(2, 100)],
+  101: ["luminosity", 2, uint(2, 1)],
+  102: ["presence", 1, uint(1, 1)],
+  103: ["temperature", 2, int(2, 10)],
+  104: ["relative_humidity", 1, uint(1, 2)],
+  113: ["accelerometer", 6, xyz(1000)],
+  115: ["barometric_pressure", 2, uint(2, 10)],
+  116: ["voltage", 2, uint(2, 100)],
+  117: ["current", 2, uint(2, 1000)],
+  118: ["frequency", 4, uint(4, 1)],
+  120: ["percentage", 1, uint(1, 1)],
+  121: ["altitude", 2, int(2, 1)],
+  125: ["concentration", 2, uint(2, 1)],
+  128: ["power", 2, uint(2, 1)],
+  130: ["distance", 4, uint(4, 1000)],
+  131: ["energy", 4, uint(4, 1000)],
+  132: ["direction", 2, uint(2, 1)],
+  133: ["unixtime", 4, uint(4, 1)],
+  134: ["gyrometer", 6, xyz(100)],
+  135: ["colour", 3, colour],
+  136: ["gps", 9, gps],
+  142: ["switch", 1, uint(1, 1)],
+};
+
+function readUint(bytes, i, n) {
+  var v = 0;
+  for (var j = 0; j < n; j++) v = v * 256 + bytes[i + j];
+  return v;
+}
+
+function readInt(bytes, i, n) {
+  var v = readUint(bytes, i, n);
+  var max = Math.pow(2, 8 * n);
+  return v >= max / 2 ? v - max : v;
+}
+
+function uint(n, divisor) {
+  return function (bytes, i) {
+    return readUint(bytes, i, n) / divisor;
+  };
+}
+
+function int(n, divisor) {
+  return function (bytes, i) {
+    return readInt(bytes, i, n) / divisor;
+  };
+}
+
+function xyz(divisor) {
+  return function (bytes, i) {
+    return { x: readInt(bytes, i, 2) / divisor, y: readInt(bytes, i + 2, 2) / divisor, z: readInt(bytes, i + 4, 2) / divisor };
+  };
+}
+
+function colour(bytes, i) {
+  return { r: bytes[i], g: bytes[i + 1], b: bytes[i + 2] };
+}
+
+function gps(bytes, i) {
+  return { latitude: readInt(bytes, i, 3) / 10000, longitude: readInt(bytes, i + 3, 3) / 10000, altitude: readInt(bytes, i + 6, 3) / 100 };
+}
+
+function lppDecode(bytes) {
+  var data = {};
+  for (var i = 0; i < bytes.length; ) {
+    if (i + 2 > bytes.length) throw new Error("truncated payload");
+    var channel = bytes[i], t = types[bytes[i + 1]];
+    if (!t) throw new Error("unknown type " + bytes[i + 1]);
+    i += 2;
+    if (i + t[1] > bytes.length) throw new Error("truncated payload");
+    data[t[0] + "_" + channel] = t[2](bytes, i);
+    i += t[1];
+  }
+  return data;
+}
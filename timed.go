@@ -0,0 +1,102 @@
+package xlpp
+
+import "time"
+
+// A Sample is a value with its channel and the time it has been measured.
+type Sample struct {
+	Time    time.Time
+	Channel int
+	Value   Value
+}
+
+// timeline resolves the Delay and MilliDelay markers of a message into measurement times.
+// Values after a Delay have been measured at the sum of all preceding Delays before the reference time.
+type timeline struct {
+	reference time.Time
+	delay     time.Duration
+}
+
+// add appends the samples of a value to samples: one per reading for Samples values, none for markers,
+// and one for all other values. Track values are one sample, at the time of their newest point.
+func (tl *timeline) add(samples []Sample, channel int, v Value) []Sample {
+	switch v := v.(type) {
+	case *Delay:
+		tl.delay += time.Duration(*v)
+		return samples
+	case *MilliDelay:
+		tl.delay += time.Duration(*v)
+		return samples
+	case Marker:
+		return samples
+	case *Samples:
+		for i := range v.Values {
+			// the last reading is the newest
+			at := tl.reference.Add(-tl.delay - time.Duration(len(v.Values)-1-i)*v.Interval)
+			if item := v.At(i); item != nil {
+				samples = append(samples, Sample{Time: at, Channel: channel, Value: item})
+			}
+		}
+		return samples
+	}
+	return append(samples, Sample{Time: tl.reference.Add(-tl.delay), Channel: channel, Value: v})
+}
+
+// Timed returns the values of a message received at the reference time with their measurement times,
+// like a TimedReader.
+func (m Message) Timed(reference time.Time) []Sample {
+	tl := timeline{reference: reference}
+	samples := make([]Sample, 0, len(m))
+	for _, e := range m {
+		samples = tl.add(samples, e.Channel, e.Value)
+	}
+	return samples
+}
+
+// A TimedReader reads values with their absolute measurement times.
+// It accumulates the Delay and MilliDelay markers of the message, so that values after a Delay
+// have been measured at the sum of all preceding Delays before the reference time, e.g. the reception time of the message.
+// All other markers are skipped.
+type TimedReader struct {
+	r *Reader
+	timeline
+	// pending are the samples of the last value that have not been returned yet.
+	pending []Sample
+}
+
+// NewTimedReader constructs a TimedReader that reads the values of r, relative to the reference time.
+func NewTimedReader(r *Reader, reference time.Time) *TimedReader {
+	return &TimedReader{r: r, timeline: timeline{reference: reference}}
+}
+
+// Next returns the next value with its measurement time.
+// At the end of the message, Next returns a Sample with a nil Value and no error, like Reader.Next.
+// Samples values are returned as one Sample per reading, the newest reading at the time of the entry and the older readings
+// one Interval apart. Track values are returned as one Sample at the time of the entry, which is the time of their newest point.
+func (tr *TimedReader) Next() (s Sample, err error) {
+	for len(tr.pending) == 0 {
+		channel, v, err := tr.r.Next()
+		if err != nil || v == nil {
+			return s, err
+		}
+		tr.pending = tr.add(tr.pending[:0], channel, v)
+	}
+	s = tr.pending[0]
+	tr.pending = tr.pending[1:]
+	return s, nil
+}
+
+// Delay returns the total delay of the markers read so far.
+func (tr *TimedReader) Delay() time.Duration {
+	return tr.delay
+}
+
+// ReadSamples reads all values of the message with their measurement times.
+func (tr *TimedReader) ReadSamples() (samples []Sample, err error) {
+	for {
+		s, err := tr.Next()
+		if err != nil || s.Value == nil {
+			return samples, err
+		}
+		samples = append(samples, s)
+	}
+}
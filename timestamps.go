@@ -0,0 +1,57 @@
+package xlpp
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxDelay is the longest delay of a single Delay marker (255h59m59s).
+const MaxDelay = 255*time.Hour + 59*time.Minute + 59*time.Second
+
+// ErrTimestampOrder is returned by Writer.AddAt for a timestamp after the previous timestamp.
+var ErrTimestampOrder = errors.New("xlpp: timestamp after the previous timestamp")
+
+// AddAt writes a value measured at t, preceded by the Delay markers to put it in its historical context.
+// The first value added with AddAt is the newest value of the message (measured at the time the message is sent),
+// all following values must be added from the newest to the oldest: a timestamp after the previous timestamp returns ErrTimestampOrder.
+//
+// Time differences of more than MaxDelay are split across multiple Delay markers,
+// and sub-second differences are written as MilliDelay marker (the resolution is 1ms).
+func (w *Writer) AddAt(t time.Time, channel int, v Value) (n int, err error) {
+	if !w.at.IsZero() {
+		if t.After(w.at) {
+			return 0, ErrTimestampOrder
+		}
+		delay := w.at.Sub(t).Truncate(time.Millisecond)
+		for delay >= time.Second {
+			d := delay.Truncate(time.Second)
+			if d > MaxDelay {
+				d = MaxDelay
+			}
+			marker := Delay(d)
+			var m int
+			m, err = w.Add(ChanDelay, &marker)
+			n += m
+			if err != nil {
+				return
+			}
+			delay -= d
+			w.at = w.at.Add(-d)
+		}
+		if delay != 0 {
+			marker := MilliDelay(delay)
+			var m int
+			m, err = w.Add(ChanMilliDelay, &marker)
+			n += m
+			if err != nil {
+				return
+			}
+			w.at = w.at.Add(-delay)
+		}
+	} else {
+		w.at = t
+	}
+	m, err := w.Add(channel, v)
+	n += m
+	return
+}
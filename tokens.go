@@ -0,0 +1,165 @@
+package xlpp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A TokenKind is the kind of a Token.
+type TokenKind int
+
+const (
+	// TokenValue is a Value or a Marker.
+	TokenValue TokenKind = iota
+	// TokenBeginObject starts an Object. It is followed by pairs of TokenKey and values, and TokenEndObject.
+	TokenBeginObject
+	// TokenKey is the key of the next value of an Object.
+	TokenKey
+	// TokenEndObject ends an Object.
+	TokenEndObject
+	// TokenBeginArray starts an Array. It is followed by the values of the Array and TokenEndArray.
+	TokenBeginArray
+	// TokenEndArray ends an Array.
+	TokenEndArray
+)
+
+var tokenKindNames = [...]string{"value", "begin object", "key", "end object", "begin array", "end array"}
+
+func (k TokenKind) String() string {
+	if k >= 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// A Token is an event of the token stream of a Reader, see Reader.Token.
+type Token struct {
+	Kind TokenKind
+	// Channel is the channel of the top-level entry that the token belongs to.
+	Channel int
+	// Key is the Object key of a TokenKey.
+	Key string
+	// Value is the Value or Marker of a TokenValue.
+	Value Value
+}
+
+var errTokenDepth = errors.New("xlpp: Next can not be used inside an Object or Array read with Token")
+
+// tokenState is the state of the token stream of a Reader.
+type tokenState struct {
+	channel int
+	// stack holds the Objects and Arrays that have been started but not ended.
+	stack []nesting
+}
+
+// Token returns the next token of the payload.
+// Objects and Arrays are not decoded as a whole, but as a stream of tokens, e.g.
+// TokenBeginObject, TokenKey, TokenValue, ..., TokenEndObject.
+// This allows processing huge or deeply nested payloads incrementally, without building maps and slices.
+// At the end of the payload, Token returns io.EOF.
+//
+// Token and Next can be mixed, but Next must not be called while an Object or Array is not ended.
+func (r *Reader) Token() (tok Token, err error) {
+	start := r.consumed
+	tok, err = r.token()
+	if err != nil && err != io.EOF {
+		err = offsetError(err, start, r.consumed)
+	}
+	return
+}
+
+func (r *Reader) token() (tok Token, err error) {
+	s := &r.tokens
+	if len(s.stack) == 0 {
+		var c byte
+		c, err = r.r.ReadByte()
+		if err != nil {
+			return
+		}
+		r.consumed++
+		s.channel = int(c)
+		if r.compressed(s.channel, r.consumed-1) {
+			if err = r.inflate(); err != nil {
+				return
+			}
+			return r.token()
+		}
+		if isMarkerChannel(s.channel) && !r.strictLPP {
+			v := newMarker(s.channel)
+			var n int64
+			n, err = v.ReadFrom(&r.d)
+			r.consumed += n
+			if p, ok := v.(*Priority); ok {
+				r.priority = *p
+			}
+			if err == nil && r.validate {
+				err = r.validateMarker(s.channel, v)
+				markerOffset(err, r.consumed-n-1)
+			}
+			return Token{Kind: TokenValue, Channel: s.channel, Value: v}, err
+		}
+		return r.tokenValue()
+	}
+
+	top := &s.stack[len(s.stack)-1]
+	if top.t == TypeObject && !top.key {
+		var key string
+		var n int
+		key, n, err = readCString(&r.d, nil)
+		r.consumed += int64(n)
+		if err != nil {
+			return Token{}, toErr(err)
+		}
+		if key == "" {
+			s.stack = s.stack[:len(s.stack)-1]
+			return Token{Kind: TokenEndObject, Channel: s.channel}, nil
+		}
+		top.key = true
+		return Token{Kind: TokenKey, Channel: s.channel, Key: key}, nil
+	}
+	top.key = false
+	return r.tokenValue()
+}
+
+// tokenValue reads the next value (after the channel or key) of the token stream.
+func (r *Reader) tokenValue() (tok Token, err error) {
+	s := &r.tokens
+	tok.Channel = s.channel
+	var b [1]byte
+	var n int64
+	n, err = readFrom(&r.d, b[:])
+	r.consumed += n
+	if err != nil {
+		return tok, toErr(err)
+	}
+	if r.strictLPP {
+		if err = checkStrictLPP(Type(b[0])); err != nil {
+			return tok, err
+		}
+	}
+	if t := Type(b[0]); t == TypeObject || t == TypeArray {
+		if err = r.countToken(); err != nil {
+			return tok, err
+		}
+	}
+	switch t := Type(b[0]); t {
+	case TypeObject:
+		s.stack = append(s.stack, nesting{t: TypeObject})
+		tok.Kind = TokenBeginObject
+	case TypeArray:
+		s.stack = append(s.stack, nesting{t: TypeArray})
+		tok.Kind = TokenBeginArray
+	case TypeEndOfArray:
+		if len(s.stack) == 0 || s.stack[len(s.stack)-1].t != TypeArray {
+			return tok, errUnexpectedEndOfArray
+		}
+		s.stack = s.stack[:len(s.stack)-1]
+		tok.Kind = TokenEndArray
+	default:
+		tok.Kind = TokenValue
+		tok.Value, n, err = readValue(&r.d, t)
+		r.consumed += n
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// TypeTrack is the type of Track.
+const TypeTrack Type = 74 // uvarint count, per point varint deltas of offset [s], lat, lon [0.0001°] and alt [0.01m]
+
+// A TrackPoint is a GPS fix of a Track.
+type TrackPoint struct {
+	Latitude, Longitude, Meters float64
+	// Offset is the time of the fix before the time of the entry (see Delay), with 1s resolution.
+	Offset time.Duration
+}
+
+// A Track is a sequence of GPS fixes, e.g. the movement trace of a tracker since the last uplink.
+// Points are ordered from the oldest to the newest fix.
+//
+// The first point is encoded as varints, all following points as varint deltas to the previous point,
+// using the resolution of GPS. So a trace of closely spaced fixes takes about 5 bytes per point,
+// instead of 11 bytes for GPS plus a Delay marker per fix.
+type Track []TrackPoint
+
+// XLPPType for Track returns TypeTrack.
+func (v Track) XLPPType() Type {
+	return TypeTrack
+}
+
+func (v Track) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d points: [", len(v))
+	for i, p := range v {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s, %s, %.2fm -%s", dms(p.Latitude, "N", "S"), dms(p.Longitude, "E", "W"), p.Meters, p.Offset)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// ReadFrom reads the Track from the reader.
+func (v *Track) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	defer func() {
+		n = int64(brc.Count)
+	}()
+	count, err := binary.ReadUvarint(&brc)
+	if err == nil {
+		// every point takes at least four bytes
+		err = checkLength(r, count*4)
+	}
+	if err != nil {
+		return
+	}
+	*v = make(Track, count)
+	var i [4]int64
+	for j := range *v {
+		for k := range i {
+			var d int64
+			d, err = binary.ReadVarint(&brc)
+			if err != nil {
+				return
+			}
+			i[k] += d
+		}
+		(*v)[j] = TrackPoint{
+			Offset:    time.Duration(i[0]) * time.Second,
+			Latitude:  float64(i[1]) / 10000,
+			Longitude: float64(i[2]) / 10000,
+			Meters:    float64(i[3]) / 100,
+		}
+	}
+	return
+}
+
+// WriteTo writes the Track to the writer.
+func (v Track) WriteTo(w io.Writer) (n int64, err error) {
+	buf := make([]byte, 0, binary.MaxVarintLen64+len(v)*6)
+	var tmp [binary.MaxVarintLen64]byte
+	buf = append(buf, tmp[:binary.PutUvarint(tmp[:], uint64(len(v)))]...)
+	var prev [4]int64
+	for _, p := range v {
+		i := [4]int64{
+			int64(p.Offset / time.Second),
+			int64(trunc(p.Latitude * 10000)),
+			int64(trunc(p.Longitude * 10000)),
+			int64(trunc(p.Meters * 100)),
+		}
+		for k := range i {
+			buf = append(buf, tmp[:binary.PutVarint(tmp[:], i[k]-prev[k])]...)
+		}
+		prev = i
+	}
+	m, err := writeTo(w, buf)
+	return int64(m), err
+}
+
+type trackPointJSON struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	Meters    float64 `json:"alt"`
+	Offset    float64 `json:"offset"`
+}
+
+// MarshalJSON marshals the TrackPoint as {"lat":..,"lon":..,"alt":..,"offset":..}, with the offset in seconds.
+func (p TrackPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(trackPointJSON{p.Latitude, p.Longitude, p.Meters, p.Offset.Seconds()})
+}
+
+// UnmarshalJSON unmarshals the TrackPoint from {"lat":..,"lon":..,"alt":..,"offset":..}.
+func (p *TrackPoint) UnmarshalJSON(data []byte) error {
+	var j trackPointJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*p = TrackPoint{j.Latitude, j.Longitude, j.Meters, time.Duration(j.Offset * float64(time.Second))}
+	return nil
+}
+
+// MarshalJSON marshals the Track as a JSON array of points.
+func (v Track) MarshalJSON() ([]byte, error) {
+	if v == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]TrackPoint(v))
+}
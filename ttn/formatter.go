@@ -0,0 +1,1075 @@
+package ttn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/waziup/xlpp"
+)
+
+// JavaScriptFormatter returns a JavaScript payload formatter for The Things Stack, with the functions decodeUplink,
+// decodeDownlink and encodeDownlink. It decodes payloads to the JSON format of xlpp.MarshalJSON, e.g. {"temperature5":23.5},
+// with the struct field names of the given naming, and encodes downlinks from that format on the given FPort.
+//
+// The formatter is generated from the xlpp.Registry, so it decodes all types like the Reader: with the same values,
+// the same struct field names and errors for the same invalid payloads.
+// JavaScript numbers are 64-bit floats, so integers beyond ±2^53 lose precision.
+// encodeDownlink accepts what xlpp.UnmarshalJSON accepts, except colour names like "red".
+//
+// It fails if the Registry has types without JavaScript codec, e.g. custom types registered with xlpp.RegisterType.
+func JavaScriptFormatter(fPort int, naming xlpp.JSONNaming) ([]byte, error) {
+	if fPort < 1 || fPort > 223 {
+		return nil, fmt.Errorf("ttn: invalid FPort %d", fPort)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, jsHeader, fPort, naming == xlpp.CanonicalJSONNaming, xlpp.DefaultMaxNestingDepth)
+	b.WriteString(jsRuntime)
+
+	b.WriteString("\n// Types of the xlpp.Registry.\n")
+	for i := 0; i < 256; i++ {
+		t := xlpp.Type(i)
+		if xlpp.LookupType(t) == nil || t == xlpp.TypeEndOfArray {
+			continue
+		}
+		codec, ok := jsCodecs[t]
+		if !ok {
+			return nil, fmt.Errorf("ttn: no JavaScript codec for type %d (%q)", t, t.Name())
+		}
+		fmt.Fprintf(&b, "register(%d, %s, %s);\n", t, jsString(t.Name()), codec)
+	}
+
+	b.WriteString("\n// Markers.\n")
+	for _, m := range jsMarkers {
+		fmt.Fprintf(&b, "marker(%d, %s, %s);\n", m.channel, jsString(xlpp.NameOf(m.value)), m.codec)
+	}
+
+	b.WriteString("\n// Steps per unit of the Samples types.\n")
+	for i := 0; i < 256; i++ {
+		if scale, ok := sampleScale(xlpp.Type(i)); ok {
+			fmt.Fprintf(&b, "SCALES[%d] = %d;\n", i, scale)
+		}
+	}
+
+	b.WriteString("\n// Units of AnalogUnit and gases of GasConcentration.\n")
+	for i := 1; i < 256; i++ {
+		if s := xlpp.UnitCode(i).Symbol(); s != "" {
+			fmt.Fprintf(&b, "UNITS[%d] = %s;\n", i, jsString(s))
+		}
+	}
+	for i := 1; i < 256; i++ {
+		if data, err := xlpp.GasID(i).MarshalJSON(); err == nil && data[0] == '"' {
+			fmt.Fprintf(&b, "GASES[%d] = %s;\n", i, data)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// sampleScale returns the number of steps per unit of the type in Samples, e.g. 10 for TypeTemperature.
+// It writes a single reading of 1 and reads back the encoded steps.
+func sampleScale(t xlpp.Type) (int64, bool) {
+	if xlpp.LookupType(t) == nil {
+		return 0, false
+	}
+	var buf bytes.Buffer
+	if _, err := (xlpp.Samples{Type: t, Values: []float64{1}}).WriteTo(&buf); err != nil {
+		return 0, false
+	}
+	// type, interval 0, count 1, first value
+	scale, n := binary.Varint(buf.Bytes()[3:])
+	return scale, n > 0
+}
+
+func jsString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// jsCodecs are the JavaScript codecs of the types, see jsRuntime.
+var jsCodecs = map[xlpp.Type]string{
+	// LPP Types
+	xlpp.TypeDigitalInput:       "uint(1)",
+	xlpp.TypeDigitalOutput:      "uint(1)",
+	xlpp.TypeAnalogInput:        "fixed(2, true, 100)",
+	xlpp.TypeAnalogOutput:       "fixed(2, true, 100)",
+	xlpp.TypeLuminosity:         "uint(2)",
+	xlpp.TypePresence:           "uint(1)",
+	xlpp.TypeTemperature:        "fixed(2, true, 10)",
+	xlpp.TypeRelativeHumidity:   "fixed(1, false, 2)",
+	xlpp.TypeAccelerometer:      "xyz(1000, false)",
+	xlpp.TypeBarometricPressure: "fixed(2, true, 10)",
+	xlpp.TypeGyrometer:          "xyz(100, true)",
+	xlpp.TypeGPS:                "gps",
+
+	// more LPP Types
+	xlpp.TypeVoltage:       "fixed(2, true, 100)",
+	xlpp.TypeCurrent:       "fixed(2, true, 1000)",
+	xlpp.TypeFrequency:     "uint(4)",
+	xlpp.TypePercentage:    "percentage",
+	xlpp.TypeAltitude:      "whole(2, true)",
+	xlpp.TypeConcentration: "uint(2)",
+	xlpp.TypePower:         "uint(2)",
+	xlpp.TypeDistance:      "saturated(4, false, 1000)",
+	xlpp.TypeEnergy:        "saturated(4, false, 1000)",
+	xlpp.TypeDirection:     "whole(2, false)",
+	xlpp.TypeUnixTime:      "unixTime",
+	xlpp.TypeColour:        "colour",
+	xlpp.TypeSwitch:        "onOff",
+
+	// extended-range Types
+	xlpp.TypeExtendedPercentage:   "uint(1)",
+	xlpp.TypeBarometricPressure24: "pressure24",
+	xlpp.TypeDistanceLong:         "distanceLong",
+	xlpp.TypePowerPrecise:         "saturated(4, false, 10)",
+	xlpp.TypeCurrentHiRange:       "saturated(4, false, 100)",
+	xlpp.TypeVoltageSigned:        "saturated(4, true, 100)",
+	xlpp.TypeAnalogUnit:           "analogUnit",
+	xlpp.TypeGasConcentration:     "gasConcentration",
+	xlpp.TypeGPS2D:                "gps2d",
+	xlpp.TypeAccelerometerHiG:     "xyz(100, false, true)",
+	xlpp.TypeGyrometerHiRate:      "xyz(1, true, true)",
+	xlpp.TypeSamples:              "samples",
+	xlpp.TypeSpectrum:             "spectrum",
+	xlpp.TypeImageChunk:           "imageChunk",
+	xlpp.TypeTrack:                "track",
+	xlpp.TypeScheduledCommand:     "scheduledCommand",
+
+	// XLPP Types
+	xlpp.TypeInteger:   "integer",
+	xlpp.TypeNull:      "nil",
+	xlpp.TypeString:    "str",
+	xlpp.TypeBool:      "bool",
+	xlpp.TypeBoolTrue:  "bool",
+	xlpp.TypeBoolFalse: "bool",
+	xlpp.TypeObject:    "object",
+	xlpp.TypeArray:     "array",
+	xlpp.TypeArrayOf:   "arrayOf",
+	xlpp.TypeFlags:     "flags",
+	xlpp.TypeBinary:    "binary",
+}
+
+// jsMarkers are the JavaScript decoders of the markers.
+var jsMarkers = []struct {
+	channel int
+	value   xlpp.Value
+	codec   string
+}{
+	{xlpp.ChanActuatorAck, new(xlpp.ActuatorAck), "actuatorAck"},
+	{xlpp.ChanMilliDelay, new(xlpp.MilliDelay), "milliDelay"},
+	{xlpp.ChanActuatorsWithChannel, new(xlpp.ActuatorsWithChannel), "actuatorsWithChannel"},
+	{xlpp.ChanActuators, new(xlpp.Actuators), "actuators"},
+	{xlpp.ChanDelay, new(xlpp.Delay), "delay"},
+	{xlpp.ChanPriority, new(xlpp.Priority), "priority"},
+}
+
+const jsHeader = `// XLPP payload formatter for The Things Stack, generated by "xlpp gen js-formatter".
+// Do not edit: regenerate it with the xlpp version that encodes the payloads.
+//
+// Payloads are decoded to the JSON format of the xlpp command, with the type name and channel of each entry as key,
+// e.g. {"temperature5":23.5}, and downlinks are encoded from that format.
+
+var FPORT = %d;
+var CANONICAL = %t;
+var MAX_DEPTH = %d;
+`
+
+// jsRuntime is the JavaScript runtime of the formatter: the payload reader and writer,
+// and the codecs of jsCodecs and jsMarkers. It is plain ECMAScript 5, for the JavaScript engine of The Things Stack.
+const jsRuntime = `
+function decodeUplink(input) {
+  return decodePayload(input.bytes);
+}
+
+function decodeDownlink(input) {
+  return decodePayload(input.bytes);
+}
+
+function encodeDownlink(input) {
+  try {
+    return { bytes: encodeMessage(input.data), fPort: FPORT, warnings: [], errors: [] };
+  } catch (e) {
+    return { bytes: [], warnings: [], errors: [errorString(e)] };
+  }
+}
+
+function decodePayload(bytes) {
+  try {
+    return { data: decodeMessage(bytes), warnings: [], errors: [] };
+  } catch (e) {
+    return { data: {}, warnings: [], errors: [errorString(e)] };
+  }
+}
+
+var TYPES = {};
+var NAMES = {};
+var MARKERS = {};
+var SCALES = {};
+var UNITS = {};
+var GASES = {};
+
+function register(type, name, codec) {
+  TYPES[type] = { name: name, codec: codec };
+  if (!(name in NAMES)) NAMES[name] = type;
+}
+
+function marker(channel, name, codec) {
+  MARKERS[channel] = { name: name, codec: codec };
+}
+
+function fail(msg) {
+  throw new Error("xlpp: " + msg);
+}
+
+function errorString(e) {
+  return e instanceof Error ? e.message : String(e);
+}
+
+function hex(b) {
+  return (b < 16 ? "0" : "") + b.toString(16);
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+function decodeMessage(bytes) {
+  var r = { b: bytes, i: 0, depth: 0 };
+  var data = {};
+  while (r.i < r.b.length) {
+    var channel = readByte(r);
+    var m = MARKERS[channel];
+    if (m) {
+      data[m.name + channel] = m.codec.dec(r);
+      continue;
+    }
+    var type = readByte(r);
+    if (type == 93) fail("unexpected end of array");
+    data[TYPES[type] ? TYPES[type].name + channel : ""] = readValue(r, type);
+  }
+  return data;
+}
+
+// readValue reads a value of the type. Objects and Arrays are nested at most MAX_DEPTH levels deep, like in the Reader.
+function readValue(r, type) {
+  var t = TYPES[type];
+  if (!t) fail("unregistered XLPP type 0x" + hex(type));
+  if (type != 123 && type != 91 && type != 92) return t.codec.dec(r, type);
+  if (r.depth == MAX_DEPTH) fail("payload exceeds MaxNestingDepth of " + MAX_DEPTH);
+  r.depth++;
+  var v = t.codec.dec(r, type);
+  r.depth--;
+  return v;
+}
+
+function readByte(r) {
+  if (r.i >= r.b.length) fail("unexpected EOF");
+  return r.b[r.i++] & 0xff;
+}
+
+function readBytes(r, n) {
+  if (n > r.b.length - r.i) fail("unexpected EOF");
+  var b = [];
+  for (var i = 0; i < n; i++) b.push(r.b[r.i++] & 0xff);
+  return b;
+}
+
+// readUint reads a n byte big endian unsigned integer.
+function readUint(r, n) {
+  var v = 0;
+  for (var i = 0; i < n; i++) v = v * 256 + readByte(r);
+  return v;
+}
+
+// readInt reads a n byte big endian signed integer.
+function readInt(r, n) {
+  var v = readUint(r, n);
+  var m = Math.pow(2, 8 * n);
+  return v >= m / 2 ? v - m : v;
+}
+
+// readUvarint64 reads a uvarint like binary.ReadUvarint, and returns the bits above and below bit 28,
+// so that the value can be converted to a number with a single rounding.
+function readUvarint64(r) {
+  var hi = 0, lo = 0;
+  for (var i = 0; i < 10; i++) {
+    var b = readByte(r);
+    if (i == 9 && b > 1) break;
+    if (i < 4) lo += (b & 0x7f) * Math.pow(2, 7 * i);
+    else hi += (b & 0x7f) * Math.pow(2, 7 * i - 28);
+    if (b < 0x80) return [hi, lo];
+  }
+  fail("varint overflows a 64-bit integer");
+}
+
+function readUvarint(r) {
+  var p = readUvarint64(r);
+  return p[0] * 268435456 + p[1];
+}
+
+// readUvarint32 reads a uvarint, truncated to 32 bits like uint32(v).
+function readUvarint32(r) {
+  var p = readUvarint64(r);
+  return (p[0] % 16) * 268435456 + p[1];
+}
+
+function readVarint(r) {
+  var p = readUvarint64(r);
+  if (p[1] % 2 == 0) return p[0] * 134217728 + p[1] / 2;
+  return -(p[0] * 134217728 + (p[1] + 1) / 2);
+}
+
+// readString reads a null terminated string. Invalid UTF-8 bytes are replaced by U+FFFD, like encoding/json does.
+function readString(r) {
+  var b = [];
+  for (var c = readByte(r); c != 0; c = readByte(r)) b.push(c);
+  var s = "";
+  for (var i = 0; i < b.length; ) {
+    var c0 = b[i], n = 0, lo = 0x80, hi = 0xbf, cp = 0;
+    if (c0 < 0x80) {
+      s += String.fromCharCode(c0);
+      i++;
+      continue;
+    }
+    if (c0 >= 0xc2 && c0 <= 0xdf) {
+      n = 1;
+      cp = c0 & 0x1f;
+    } else if (c0 >= 0xe0 && c0 <= 0xef) {
+      n = 2;
+      cp = c0 & 0x0f;
+      if (c0 == 0xe0) lo = 0xa0;
+      if (c0 == 0xed) hi = 0x9f;
+    } else if (c0 >= 0xf0 && c0 <= 0xf4) {
+      n = 3;
+      cp = c0 & 0x07;
+      if (c0 == 0xf0) lo = 0x90;
+      if (c0 == 0xf4) hi = 0x8f;
+    }
+    var ok = n > 0 && i + n < b.length;
+    for (var j = 1; ok && j <= n; j++) {
+      var d = b[i + j];
+      if (d < (j == 1 ? lo : 0x80) || d > (j == 1 ? hi : 0xbf)) ok = false;
+      else cp = cp * 64 + (d & 0x3f);
+    }
+    if (!ok) {
+      s += "�";
+      i++;
+      continue;
+    }
+    if (cp > 0xffff) {
+      cp -= 0x10000;
+      s += String.fromCharCode(0xd800 + (cp >> 10), 0xdc00 + (cp & 0x3ff));
+    } else {
+      s += String.fromCharCode(cp);
+    }
+    i += n + 1;
+  }
+  return s;
+}
+
+var BASE64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+
+function base64(b) {
+  var s = "";
+  for (var i = 0; i < b.length; i += 3) {
+    var n = b[i] * 65536 + (i + 1 < b.length ? b[i + 1] * 256 : 0) + (i + 2 < b.length ? b[i + 2] : 0);
+    s += BASE64.charAt(n >> 18) + BASE64.charAt((n >> 12) & 63);
+    s += i + 1 < b.length ? BASE64.charAt((n >> 6) & 63) : "=";
+    s += i + 2 < b.length ? BASE64.charAt(n & 63) : "=";
+  }
+  return s;
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+function encodeMessage(data) {
+  if (data === null || data === undefined) return [];
+  if (typeof data != "object" || data instanceof Array) fail("data is not an object");
+  var entries = [];
+  for (var key in data) {
+    if (!Object.prototype.hasOwnProperty.call(data, key)) continue;
+    var m = /^([a-zA-Z]+)([0-9]+)$/.exec(key);
+    if (!m) fail("bad json entry: " + key);
+    var channel = parseInt(m[2], 10);
+    if (channel > 255) fail("bad channel: " + key);
+    if (channel in MARKERS) fail("reserved channel " + channel);
+    if (!(m[1] in NAMES)) fail("unknown type: " + m[1]);
+    entries.push({ channel: channel, name: m[1], type: NAMES[m[1]], value: data[key] });
+  }
+  entries.sort(function (a, b) {
+    if (a.channel != b.channel) return a.channel - b.channel;
+    return a.name < b.name ? -1 : a.name > b.name ? 1 : 0;
+  });
+  var w = [];
+  for (var i = 0; i < entries.length; i++) {
+    w.push(entries[i].channel);
+    writeValue(w, entries[i].type, entries[i].value);
+  }
+  return w;
+}
+
+// writeValue writes the type and the value, e.g. 54 for a bool that is true.
+function writeValue(w, type, v) {
+  var codec = TYPES[type].codec;
+  w.push(codec.type ? codec.type(v) : type);
+  codec.enc(w, v);
+}
+
+function putUint(w, v, n) {
+  var m = Math.pow(2, 8 * n);
+  v = ((v % m) + m) % m;
+  for (var i = n - 1; i >= 0; i--) w.push(Math.floor(v / Math.pow(2, 8 * i)) % 256);
+}
+
+// limit limits the integer v to the range of a n byte integer.
+function limit(v, n, signed) {
+  var m = Math.pow(2, 8 * n);
+  return signed ? Math.max(-m / 2, Math.min(v, m / 2 - 1)) : Math.max(0, Math.min(v, m - 1));
+}
+
+function putUvarint(w, v) {
+  if (v < 0) fail("negative value " + v);
+  while (v >= 128) {
+    w.push((v % 128) + 128);
+    v = Math.floor(v / 128);
+  }
+  w.push(v);
+}
+
+function putVarint(w, v) {
+  putUvarint(w, v < 0 ? -2 * v - 1 : 2 * v);
+}
+
+function putBytes(w, b) {
+  for (var i = 0; i < b.length; i++) w.push(b[i]);
+}
+
+// round rounds half away from zero, like math.Round.
+function round(f) {
+  var a = Math.abs(f), t = Math.floor(a);
+  if (a - t >= 0.5) t++;
+  return f < 0 ? -t : t;
+}
+
+function truncate(f) {
+  return f < 0 ? Math.ceil(f) : Math.floor(f);
+}
+
+// trunc truncates the scaled value f, but ignores floating point errors, so that 1.15*100 truncates to 115 and not 114.
+function trunc(f) {
+  return truncate(round(f * 1e6) / 1e6);
+}
+
+// trunc32 is trunc for values scaled from float32, which have a lower precision.
+function trunc32(f) {
+  return truncate(round(f * 1e2) / 1e2);
+}
+
+function fround(f) {
+  if (Math.fround) return Math.fround(f);
+  var b = new Float32Array(1);
+  b[0] = f;
+  return b[0];
+}
+
+// seconds converts seconds to whole seconds, like time.Duration(f * float64(time.Second)) / time.Second.
+function seconds(f) {
+  return truncate(truncate(number(f) * 1e9) / 1e9);
+}
+
+// field returns the value of the key of a JSON object, matched case-insensitively like encoding/json does.
+// It returns undefined for missing keys and null objects.
+function field(o, key) {
+  if (o === null || o === undefined) return undefined;
+  if (typeof o != "object" || o instanceof Array) fail("can not unmarshal " + JSON.stringify(o) + " into an object");
+  var v;
+  key = key.toLowerCase();
+  for (var k in o) {
+    if (Object.prototype.hasOwnProperty.call(o, k) && k.toLowerCase() == key) v = o[k];
+  }
+  return v;
+}
+
+function number(v) {
+  if (v === null || v === undefined) return 0;
+  if (typeof v != "number") fail("can not unmarshal " + JSON.stringify(v) + " into a number");
+  return v;
+}
+
+function uintValue(v, bits) {
+  v = number(v);
+  if (v % 1 != 0 || v < 0 || v >= Math.pow(2, bits)) fail("can not unmarshal " + v + " into a " + bits + "-bit unsigned integer");
+  return v;
+}
+
+function intValue(v) {
+  v = number(v);
+  if (v % 1 != 0 || Math.abs(v) > 9223372036854775807) fail("can not unmarshal " + v + " into an integer");
+  return v;
+}
+
+function boolValue(v) {
+  if (v === null || v === undefined) return false;
+  if (typeof v != "boolean") fail("can not unmarshal " + JSON.stringify(v) + " into a bool");
+  return v;
+}
+
+function stringValue(v) {
+  if (v === null || v === undefined) return "";
+  if (typeof v != "string") fail("can not unmarshal " + JSON.stringify(v) + " into a string");
+  return v;
+}
+
+function arrayValue(v) {
+  if (v === null || v === undefined) return [];
+  if (!(v instanceof Array)) fail("can not unmarshal " + JSON.stringify(v) + " into an array");
+  return v;
+}
+
+// bytesValue decodes a base64 string, like encoding/json decodes []byte.
+function bytesValue(v) {
+  var s = stringValue(v).replace(/[\r\n]/g, "");
+  if (s.length % 4 != 0 || !/^[A-Za-z0-9+\/]*={0,2}$/.test(s)) fail("illegal base64 data");
+  var b = [];
+  for (var i = 0; i < s.length; i += 4) {
+    var n = 0, pad = 0;
+    for (var j = 0; j < 4; j++) {
+      var c = s.charAt(i + j);
+      if (c == "=") pad++;
+      else if (pad) fail("illegal base64 data");
+      n = n * 64 + (c == "=" ? 0 : BASE64.indexOf(c));
+    }
+    if (pad && i + 4 < s.length) fail("illegal base64 data");
+    b.push(n >> 16);
+    if (pad < 2) b.push((n >> 8) & 255);
+    if (pad < 1) b.push(n & 255);
+  }
+  return b;
+}
+
+// utf8 encodes the string as UTF-8. Unpaired surrogates are replaced by U+FFFD, like encoding/json does.
+function utf8(s) {
+  var b = [];
+  for (var i = 0; i < s.length; i++) {
+    var c = s.charCodeAt(i);
+    if (c >= 0xd800 && c <= 0xdbff && i + 1 < s.length && s.charCodeAt(i + 1) >= 0xdc00 && s.charCodeAt(i + 1) <= 0xdfff) {
+      c = 0x10000 + (c - 0xd800) * 1024 + (s.charCodeAt(++i) - 0xdc00);
+    } else if (c >= 0xd800 && c <= 0xdfff) {
+      c = 0xfffd;
+    }
+    if (c < 0x80) b.push(c);
+    else if (c < 0x800) b.push(0xc0 | (c >> 6), 0x80 | (c & 63));
+    else if (c < 0x10000) b.push(0xe0 | (c >> 12), 0x80 | ((c >> 6) & 63), 0x80 | (c & 63));
+    else b.push(0xf0 | (c >> 18), 0x80 | ((c >> 12) & 63), 0x80 | ((c >> 6) & 63), 0x80 | (c & 63));
+  }
+  return b;
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Codecs: dec(r, type) reads a value, enc(w, v) writes it, and type(v) returns its type, if it depends on the value.
+
+// uint is a n byte unsigned integer.
+function uint(n) {
+  return {
+    dec: function (r) {
+      return readUint(r, n);
+    },
+    enc: function (w, v) {
+      putUint(w, uintValue(v, 8 * n), n);
+    }
+  };
+}
+
+// fixed is a n byte integer of 1/scale steps.
+function fixed(n, signed, scale) {
+  return {
+    dec: function (r) {
+      return (signed ? readInt(r, n) : readUint(r, n)) / scale;
+    },
+    enc: function (w, v) {
+      putUint(w, trunc(number(v) * scale), n);
+    }
+  };
+}
+
+// saturated is fixed for the types that write values out of range as the nearest value in range.
+function saturated(n, signed, scale) {
+  return {
+    dec: function (r) {
+      return (signed ? readInt(r, n) : readUint(r, n)) / scale;
+    },
+    enc: function (w, v) {
+      putUint(w, limit(trunc(number(v) * scale), n, signed), n);
+    }
+  };
+}
+
+// whole is a n byte integer, that is converted from a number without rounding.
+function whole(n, signed) {
+  return {
+    dec: function (r) {
+      return signed ? readInt(r, n) : readUint(r, n);
+    },
+    enc: function (w, v) {
+      putUint(w, truncate(number(v)), n);
+    }
+  };
+}
+
+// xyz is a {x, y, z} vector of 2 byte integers of 1/scale steps.
+// Saturated vectors write values out of range as the nearest value in range.
+function xyz(scale, float32, saturated) {
+  var names = CANONICAL ? ["x", "y", "z"] : ["X", "Y", "Z"];
+  return {
+    dec: function (r) {
+      var v = {};
+      for (var i = 0; i < 3; i++) v[names[i]] = readInt(r, 2) / scale;
+      return v;
+    },
+    enc: function (w, v) {
+      for (var i = 0; i < 3; i++) {
+        var f = number(field(v, names[i]));
+        var n = float32 ? trunc32(fround(f) * scale) : trunc(f * scale);
+        putUint(w, saturated ? limit(n, 2, true) : n, 2);
+      }
+    }
+  };
+}
+
+var percentage = {
+  dec: function (r) {
+    return readByte(r);
+  },
+  enc: function (w, v) {
+    v = uintValue(v, 8);
+    if (v > 100) fail("percentage out of range 0..100");
+    w.push(v);
+  }
+};
+
+var pressure24 = {
+  dec: function (r) {
+    return readUint(r, 3) / 100;
+  },
+  enc: function (w, v) {
+    putUint(w, Math.max(0, Math.min(trunc(number(v) * 100), 0xffffff)), 3);
+  }
+};
+
+var distanceLong = {
+  dec: function (r) {
+    return readUvarint(r) / 1000;
+  },
+  enc: function (w, v) {
+    putUvarint(w, Math.max(0, Math.min(trunc(number(v) * 1000), 18446744073709549568)));
+  }
+};
+
+var gps = {
+  dec: function (r) {
+    var lat = readInt(r, 3) / 10000, lon = readInt(r, 3) / 10000, alt = readInt(r, 3) / 100;
+    return CANONICAL ? { latitude: lat, longitude: lon, altitude: alt } : { Latitude: lat, Longitude: lon, Meters: alt };
+  },
+  enc: function (w, v) {
+    var alt = field(v, "Meters");
+    if (alt === null || alt === undefined) alt = field(v, "Altitude");
+    putUint(w, trunc(number(field(v, "Latitude")) * 10000), 3);
+    putUint(w, trunc(number(field(v, "Longitude")) * 10000), 3);
+    putUint(w, trunc(number(alt) * 100), 3);
+  }
+};
+
+var gps2d = {
+  dec: function (r) {
+    var lat = readInt(r, 3) / 10000, lon = readInt(r, 3) / 10000;
+    return CANONICAL ? { latitude: lat, longitude: lon } : { Latitude: lat, Longitude: lon };
+  },
+  enc: function (w, v) {
+    var lat = number(field(v, "Latitude")), lon = number(field(v, "Longitude"));
+    if (!(Math.abs(lat) <= 90 && Math.abs(lon) <= 180)) fail("GPS coordinates out of range");
+    putUint(w, trunc(lat * 10000), 3);
+    putUint(w, trunc(lon * 10000), 3);
+  }
+};
+
+// unixTime is given as seconds since 1970-01-01, like UnixTime.
+var unixTime = {
+  dec: function (r) {
+    return readUint(r, 4);
+  },
+  enc: function (w, v) {
+    putUint(w, uintValue(v, 32), 4);
+  }
+};
+
+var colour = {
+  dec: function (r) {
+    return "#" + hex(readByte(r)) + hex(readByte(r)) + hex(readByte(r));
+  },
+  enc: function (w, v) {
+    if (typeof v != "string") fail("can not unmarshal " + JSON.stringify(v) + " into a colour");
+    if (/^#[0-9a-fA-F]{6}$/.test(v)) {
+      for (var i = 1; i < 7; i += 2) w.push(parseInt(v.substr(i, 2), 16));
+    } else if (/^#[0-9a-fA-F]{3}$/.test(v)) {
+      for (var j = 1; j < 4; j++) w.push(parseInt(v.charAt(j), 16) * 0x11);
+    } else {
+      fail("unknown colour " + JSON.stringify(v));
+    }
+  }
+};
+
+var onOff = {
+  dec: function (r) {
+    return readByte(r) != 0;
+  },
+  enc: function (w, v) {
+    w.push(boolValue(v) ? 1 : 0);
+  }
+};
+
+// enumValue returns the code of a name of the table, or the code itself.
+function enumValue(v, table, what) {
+  if (v === undefined) return 0;
+  if (typeof v == "string") {
+    for (var code in table) {
+      if (table[code] == v) return parseInt(code, 10);
+    }
+    fail("unknown " + what + " " + JSON.stringify(v));
+  }
+  if (v === null) fail("unknown " + what + " \"\"");
+  return uintValue(v, 8);
+}
+
+var analogUnit = {
+  dec: function (r) {
+    var unit = readByte(r);
+    return { unit: UNITS[unit] || unit, value: readInt(r, 4) / 1000 };
+  },
+  enc: function (w, v) {
+    w.push(enumValue(field(v, "unit"), UNITS, "unit"));
+    putUint(w, limit(trunc(number(field(v, "value")) * 1000), 4, true), 4);
+  }
+};
+
+var gasConcentration = {
+  dec: function (r) {
+    var gas = readByte(r);
+    return { gas: GASES[gas] || gas, ppm: readUint(r, 4) / 1000 };
+  },
+  enc: function (w, v) {
+    w.push(enumValue(field(v, "gas"), GASES, "gas"));
+    putUint(w, limit(trunc(number(field(v, "ppm")) * 1000), 4, false), 4);
+  }
+};
+
+var samples = {
+  dec: function (r) {
+    var type = readByte(r);
+    if (!(type in SCALES)) fail("unsupported Samples type");
+    var interval = readUvarint(r);
+    var count = readUvarint(r);
+    if (count > r.b.length - r.i) fail("unexpected EOF");
+    var values = [], i = 0;
+    for (var j = 0; j < count; j++) {
+      i += readVarint(r);
+      values.push(i / SCALES[type]);
+    }
+    return { type: TYPES[type].name, interval: interval, values: values };
+  },
+  enc: function (w, v) {
+    if (v === null || v === undefined) fail("unknown Samples type \"\"");
+    var name = stringValue(field(v, "type"));
+    if (!(name in NAMES)) fail("unknown Samples type " + JSON.stringify(name));
+    var type = NAMES[name];
+    if (TYPES[type].codec.type) type = TYPES[type].codec.type(false);
+    var interval = seconds(field(v, "interval"));
+    var values = arrayValue(field(v, "values"));
+    if (!(type in SCALES)) fail("unsupported Samples type");
+    w.push(type);
+    putUvarint(w, interval);
+    putUvarint(w, values.length);
+    var prev = 0;
+    for (var i = 0; i < values.length; i++) {
+      var f = trunc(number(values[i]) * SCALES[type]);
+      putVarint(w, f - prev);
+      prev = f;
+    }
+  }
+};
+
+var spectrum = {
+  dec: function (r) {
+    var width = readUvarint(r) / 100;
+    return { binWidth: width, bins: readBytes(r, readUvarint(r)) };
+  },
+  enc: function (w, v) {
+    var width = number(field(v, "binWidth"));
+    var bins = arrayValue(field(v, "bins"));
+    for (var i = 0; i < bins.length; i++) {
+      if (number(bins[i]) % 1 != 0) fail("can not unmarshal " + bins[i] + " into an integer");
+      if (bins[i] < 0 || bins[i] > 255) fail("spectrum bin " + bins[i] + " out of range 0..255");
+    }
+    putUvarint(w, trunc(width * 100));
+    putUvarint(w, bins.length);
+    for (var j = 0; j < bins.length; j++) w.push(number(bins[j]));
+  }
+};
+
+var imageChunk = {
+  dec: function (r) {
+    var id = readUvarint32(r), index = readUvarint32(r), total = readUvarint32(r);
+    return { id: id, index: index, total: total, data: base64(readBytes(r, readUvarint(r))) };
+  },
+  enc: function (w, v) {
+    var h = [uintValue(field(v, "id"), 32), uintValue(field(v, "index"), 32), uintValue(field(v, "total"), 32)];
+    var data = bytesValue(field(v, "data"));
+    for (var i = 0; i < 3; i++) putUvarint(w, h[i]);
+    putUvarint(w, data.length);
+    putBytes(w, data);
+  }
+};
+
+var track = {
+  dec: function (r) {
+    var count = readUvarint(r);
+    if (count * 4 > r.b.length - r.i) fail("unexpected EOF");
+    var points = [], i = [0, 0, 0, 0];
+    for (var j = 0; j < count; j++) {
+      for (var k = 0; k < 4; k++) i[k] += readVarint(r);
+      points.push({ lat: i[1] / 10000, lon: i[2] / 10000, alt: i[3] / 100, offset: i[0] });
+    }
+    return points;
+  },
+  enc: function (w, v) {
+    var points = arrayValue(v), ints = [];
+    for (var j = 0; j < points.length; j++) {
+      var p = points[j];
+      ints.push([seconds(field(p, "offset")), trunc(number(field(p, "lat")) * 10000), trunc(number(field(p, "lon")) * 10000), trunc(number(field(p, "alt")) * 100)]);
+    }
+    putUvarint(w, points.length);
+    var prev = [0, 0, 0, 0];
+    for (var i = 0; i < ints.length; i++) {
+      for (var k = 0; k < 4; k++) putVarint(w, ints[i][k] - prev[k]);
+      prev = ints[i];
+    }
+  }
+};
+
+// commandType reports whether values of the type can be commands of a ScheduledCommand.
+function commandType(type) {
+  return type != 123 && type != 91 && type != 92 && type != 0 && type != 93 && type != 75;
+}
+
+var scheduledCommand = {
+  dec: function (r) {
+    var delay = readUvarint(r);
+    var type = readByte(r);
+    if (!commandType(type)) fail("ScheduledCommand requires a value that is not an Object, Array, marker or ScheduledCommand");
+    var value = readValue(r, type);
+    return { delay: delay, type: TYPES[type].name, value: value };
+  },
+  enc: function (w, v) {
+    if (v === null || v === undefined) fail("unknown ScheduledCommand type \"\"");
+    var name = stringValue(field(v, "type"));
+    if (!(name in NAMES)) fail("unknown ScheduledCommand type " + JSON.stringify(name));
+    if (!commandType(NAMES[name])) fail("ScheduledCommand requires a value that is not an Object, Array, marker or ScheduledCommand");
+    var delay = seconds(field(v, "delay"));
+    var value = [];
+    writeValue(value, NAMES[name], field(v, "value"));
+    putUvarint(w, delay);
+    putBytes(w, value);
+  }
+};
+
+var integer = {
+  dec: function (r) {
+    return readVarint(r);
+  },
+  enc: function (w, v) {
+    putVarint(w, intValue(v));
+  }
+};
+
+// nil is a Null, that is an empty struct in JSON.
+var nil = {
+  dec: function (r) {
+    return {};
+  },
+  enc: function (w, v) {
+    field(v, "");
+  }
+};
+
+var str = {
+  dec: function (r) {
+    return readString(r);
+  },
+  enc: function (w, v) {
+    putBytes(w, utf8(stringValue(v)));
+    w.push(0);
+  }
+};
+
+var bool = {
+  dec: function (r, type) {
+    return type == 54;
+  },
+  enc: function (w, v) {},
+  type: function (v) {
+    return boolValue(v) ? 54 : 55;
+  }
+};
+
+// object and array values are only encoded if they are empty, as their JSON has no types.
+var object = {
+  dec: function (r) {
+    var o = {};
+    for (;;) {
+      var key = readString(r);
+      if (key === "") return o;
+      var type = readByte(r);
+      if (type == 93) fail("unexpected end of array");
+      o[key] = readValue(r, type);
+    }
+  },
+  enc: function (w, v) {
+    for (var key in v) {
+      if (Object.prototype.hasOwnProperty.call(v, key)) fail("object values can not be encoded from JSON");
+    }
+    field(v, "");
+    w.push(0);
+  }
+};
+
+var array = {
+  dec: function (r) {
+    var a = [];
+    for (;;) {
+      var type = readByte(r);
+      if (type == 93) return a;
+      a.push(readValue(r, type));
+    }
+  },
+  enc: function (w, v) {
+    if (arrayValue(v).length != 0) fail("array values can not be encoded from JSON");
+    w.push(93);
+  }
+};
+
+// arrayOfType reports whether values of the type can be items of the compact TypeArrayOf form.
+function arrayOfType(type) {
+  return type != 0 && type != 58 && type != 53 && type != 54 && type != 55 && type != 93 && type != 255;
+}
+
+var arrayOf = {
+  dec: function (r) {
+    var type = readByte(r);
+    if (!arrayOfType(type)) fail("invalid ArrayOf item type");
+    var count = readUvarint(r);
+    if (count > r.b.length - r.i) fail("unexpected EOF");
+    var a = [];
+    for (var i = 0; i < count; i++) a.push(readValue(r, type));
+    return a;
+  },
+  enc: array.enc
+};
+
+var flags = {
+  dec: function (r) {
+    var indices = [];
+    for (var i = 0; i < 10; i++) {
+      var b = readByte(r);
+      if (i == 9 && b > 1) break;
+      for (var j = 0; j < 7; j++) {
+        if (b & (1 << j)) indices.push(7 * i + j);
+      }
+      if (b < 0x80) return indices;
+    }
+    fail("varint overflows a 64-bit integer");
+  },
+  enc: function (w, v) {
+    var bits = [], i;
+    if (v instanceof Array) {
+      for (i = 0; i < v.length; i++) {
+        var index = number(v[i]);
+        if (index % 1 != 0) fail("can not unmarshal " + index + " into an integer");
+        if (index < 0 || index >= 64) fail("flag index " + index + " out of range");
+        bits[index] = true;
+      }
+    } else {
+      var n = uintValue(v, 64);
+      for (i = 0; n > 0; i++) {
+        bits[i] = n % 2 == 1;
+        n = Math.floor(n / 2);
+      }
+    }
+    var last = 63;
+    while (last > 0 && !bits[last]) last--;
+    var groups = Math.floor(last / 7) + 1;
+    for (var g = 0; g < groups; g++) {
+      var b = 0;
+      for (var j = 0; j < 7; j++) {
+        if (bits[7 * g + j]) b |= 1 << j;
+      }
+      w.push(g < groups - 1 ? b | 0x80 : b);
+    }
+  }
+};
+
+var binary = {
+  dec: function (r) {
+    return base64(readBytes(r, readUvarint(r)));
+  },
+  enc: function (w, v) {
+    var b = bytesValue(v);
+    putUvarint(w, b.length);
+    putBytes(w, b);
+  }
+};
+
+////////////////////////////////////////////////////////////////////////////////
+// Markers, that are only decoded.
+
+var delay = {
+  dec: function (r) {
+    return (readByte(r) * 3600 + readByte(r) * 60 + readByte(r)) * 1e9;
+  }
+};
+
+var milliDelay = {
+  dec: function (r) {
+    return readUvarint(r) * 1e6;
+  }
+};
+
+var priority = {
+  dec: function (r) {
+    return readByte(r);
+  }
+};
+
+var actuatorAck = {
+  dec: function (r) {
+    var seq = readUvarint(r);
+    if (seq > 4294967295) fail("ActuatorAck sequence number overflows 32 bits");
+    return seq;
+  }
+};
+
+var actuators = {
+  dec: function (r) {
+    return base64(readBytes(r, readByte(r)));
+  }
+};
+
+var actuatorsWithChannel = {
+  dec: function (r) {
+    var l = readByte(r);
+    if (2 * l > r.b.length - r.i) fail("unexpected EOF");
+    var a = [];
+    for (var i = 0; i < l; i++) {
+      var channel = readByte(r), type = readByte(r);
+      a.push(CANONICAL ? { channel: channel, type: type } : { Channel: channel, Type: type });
+    }
+    return a;
+  }
+};
+`
@@ -0,0 +1,28 @@
+// Runs a payload formatter generated by ttn.JavaScriptFormatter for the test cases on stdin.
+// Each line is {"decode":"<hex payload>"} or {"encode":<data>}, and is answered with a line
+// {"data":<data>}, {"bytes":"<hex payload>"} or {"error":"<message>"}.
+var fs = require("fs");
+var readline = require("readline");
+
+eval(fs.readFileSync(process.argv[2], "utf8"));
+
+function toHex(bytes) {
+  return bytes.map(function (b) { return (b < 16 ? "0" : "") + b.toString(16); }).join("");
+}
+
+function fromHex(s) {
+  var b = [];
+  for (var i = 0; i < s.length; i += 2) b.push(parseInt(s.substr(i, 2), 16));
+  return b;
+}
+
+readline.createInterface({ input: process.stdin }).on("line", function (line) {
+  var c = JSON.parse(line), res;
+  if ("decode" in c) {
+    res = decodeUplink({ bytes: fromHex(c.decode), fPort: FPORT });
+    console.log(JSON.stringify(res.errors.length ? { error: res.errors[0] } : { data: res.data }));
+  } else {
+    res = encodeDownlink({ data: c.encode });
+    console.log(JSON.stringify(res.errors.length ? { error: res.errors[0] } : { bytes: toHex(res.bytes) }));
+  }
+});
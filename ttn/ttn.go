@@ -0,0 +1,96 @@
+// Package ttn converts decoded XLPP messages to the normalized payload of The Things Stack,
+// e.g. {"air":{"temperature":21.5,"relativeHumidity":40}}.
+//
+// Types with a fixed meaning are mapped with xlpp.Type.NormalizedField, e.g. TypeTemperature to "air.temperature".
+// Values of other types, e.g. a Percentage that is a soil moisture, are mapped with the Channels of a Normalizer.
+package ttn
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/recorder"
+)
+
+// A Normalizer converts messages to normalized payloads.
+type Normalizer struct {
+	// Channels maps channels to normalized payload fields, e.g. 4 to "soil.moisture".
+	// They take precedence over the fields of the types.
+	Channels map[int]string
+}
+
+// Normalize converts the message with the default Normalizer.
+func Normalize(received time.Time, m xlpp.Message) []map[string]interface{} {
+	return (&Normalizer{}).Normalize(received, m)
+}
+
+// Normalize converts the message, received at the given time, to a list of normalized measurements.
+// Values measured at the same time (see xlpp.Delay) are one measurement, with the time in the "time" field,
+// ordered from the most recent measurement. Values that can not be mapped are ignored.
+func (n *Normalizer) Normalize(received time.Time, m xlpp.Message) []map[string]interface{} {
+	var measurements []map[string]interface{}
+	var times []time.Time
+	for _, e := range recorder.Resolve(received, m) {
+		field := n.Channels[e.Channel]
+		if field == "" {
+			field = e.Value.XLPPType().NormalizedField()
+		}
+		if field == "" {
+			continue
+		}
+		v, ok := normalValue(field, e.Value)
+		if !ok {
+			continue
+		}
+		i := 0
+		for i < len(times) && !times[i].Equal(e.Time) {
+			i++
+		}
+		if i == len(times) {
+			times = append(times, e.Time)
+			measurements = append(measurements, map[string]interface{}{"time": e.Time.UTC().Format(time.RFC3339Nano)})
+		}
+		set(measurements[i], strings.Split(field, "."), v)
+	}
+	return measurements
+}
+
+// normalValue converts the value to a number, or a bool for fields like "action.motion.detected".
+func normalValue(field string, v xlpp.Value) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	var f float64
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f = rv.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f = float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f = float64(rv.Uint())
+	case reflect.Bool:
+		return rv.Bool(), true
+	default:
+		return nil, false
+	}
+	if strings.HasSuffix(field, ".detected") || strings.HasSuffix(field, "State") {
+		return f != 0, true
+	}
+	return f, true
+}
+
+// set sets the value at the path of nested maps.
+func set(m map[string]interface{}, path []string, v interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = v
+}
@@ -0,0 +1,196 @@
+package ttn_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/ttn"
+)
+
+func TestNormalize(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	temp, hum := xlpp.Temperature(21.5), xlpp.RelativeHumidity(40)
+	moisture := xlpp.Percentage(35)
+	presence := xlpp.Presence(1)
+	delay := xlpp.Delay(10 * time.Minute)
+	str := xlpp.String("ignored")
+	m := xlpp.Message{
+		{Channel: 1, Value: &temp},
+		{Channel: 2, Value: &hum},
+		{Channel: 3, Value: &moisture},
+		{Channel: 5, Value: &presence},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 1, Value: &temp},
+		{Channel: 6, Value: &str},
+	}
+	n := ttn.Normalizer{Channels: map[int]string{3: "soil.moisture"}}
+	data, err := json.Marshal(n.Normalize(received, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"action":{"motion":{"detected":true}},"air":{"relativeHumidity":40,"temperature":21.5},"soil":{"moisture":35},"time":"2024-05-01T12:00:00Z"},` +
+		`{"air":{"temperature":21.5},"time":"2024-05-01T11:50:00Z"}]`
+	if string(data) != want {
+		t.Fatalf("normalized:\n%s\nwant\n%s", data, want)
+	}
+}
+
+// TestJavaScriptFormatter runs the generated formatter with node, and compares it with the Reader and UnmarshalJSON
+// for the golden vectors, their truncations and corruptions, and the encoding of their JSON.
+func TestJavaScriptFormatter(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node not found")
+	}
+	formatter, err := ttn.JavaScriptFormatter(2, xlpp.LegacyJSONNaming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "xlpp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "formatter.js")
+	if err := ioutil.WriteFile(path, formatter, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := []string{
+		"fa00", "faac02", "fa80a8d6b907", "faffffffffffffffffff02", "fe05", "ff00", "ffffffffff0f", "ff80808080100",
+		"fc020167", "fb0201670274", "fb02016702",
+		"01", "0167", "015d", "01ff00",
+		"01" + strings.Repeat("5b", xlpp.DefaultMaxNestingDepth) + strings.Repeat("5d", xlpp.DefaultMaxNestingDepth),
+		"01" + strings.Repeat("5b", xlpp.DefaultMaxNestingDepth+1) + strings.Repeat("5d", xlpp.DefaultMaxNestingDepth+1),
+	}
+	for _, v := range xlpp.GoldenVectors {
+		payloads = append(payloads, v.Payload)
+		data, _ := hex.DecodeString(v.Payload)
+		for i := range data {
+			payloads = append(payloads, hex.EncodeToString(data[:i]))
+			for _, b := range []byte{0x00, 0x80, 0xff} {
+				c := append([]byte(nil), data...)
+				c[i] ^= b | 1
+				payloads = append(payloads, hex.EncodeToString(c))
+			}
+		}
+	}
+	var encodes []json.RawMessage
+	for _, data := range []string{
+		`{}`, `null`, `[]`, `{"temperature1":null}`, `{"temperature256":1}`, `{"foo1":1}`, `{"delay1":1}`,
+		`{"temperature1":1.15,"voltage2":-1.15,"altitude3":-1.9,"direction4":1.9,"digitalinput0":255}`,
+		`{"digitalinput0":256}`, `{"digitalinput0":1.5}`, `{"digitalinput0":"1"}`, `{"percentage1":101}`,
+		`{"bool1":true,"bool2":false,"switch3":true,"null4":null,"null5":{}}`,
+		`{"string1":"héllo \ud83d\ude00"}`, `{"string1":1}`, `{"binary1":"AAEC"}`, `{"binary1":"AAE"}`,
+		`{"integer1":-1234567,"integer2":1.5}`, `{"flags1":[0,9,63],"flags2":5,"flags3":[64]}`,
+		`{"object1":{},"array2":[],"arrayof3":null}`, `{"object1":{"a":1}}`, `{"array1":[1]}`,
+		`{"colour1":"#0a0B0c","colour2":"#abc","colour3":"red","colour4":null}`,
+		`{"gps1":{"latitude":1.5,"LONGITUDE":-2.25,"altitude":3.3}}`, `{"gps1":{"Latitude":1,"Meters":2,"altitude":3}}`,
+		`{"accelerometer1":{"x":1.001,"Y":-2},"gyrometer2":{"x":1.23,"y":-0.07,"z":300},"gyrometerhirate3":{"x":1.9}}`,
+		`{"voltagesigned1":3e7,"voltagesigned2":-3e7}`, `{"analogunit1":{"unit":"V","value":3e6},"analogunit2":{"value":-3e6}}`,
+		`{"accelerometerhig1":{"X":400,"Y":-400,"Z":1.5}}`, `{"gyrometerhirate1":{"X":40000,"Y":-40000,"Z":-2000}}`,
+		`{"distancelong1":1e20}`, `{"powerprecise1":-2.5,"powerprecise2":5e8}`, `{"distance1":-1,"energy2":-2.5,"energy3":5e6}`,
+		`{"currenthirange1":-1.25,"currenthirange2":5e7}`, `{"gasconcentration1":{"gas":"co","ppm":-0.5},"gasconcentration2":{"ppm":5e6}}`,
+		`{"barometricpressurehr1":-5,"barometricpressurehr2":200000,"distancelong3":-1,"distancelong4":12345.678}`,
+		`{"analogunit1":{"unit":"V","value":1.5},"analogunit2":{"unit":7},"analogunit3":{"unit":"parsec"},"analogunit4":{"unit":null}}`,
+		`{"gasconcentration1":{"gas":"co2","ppm":400.5},"gasconcentration2":{}}`,
+		`{"samples1":{"type":"temperature","interval":60,"values":[20.1,20.3,19.8]},"samples2":{"type":"bool","values":[1]}}`,
+		`{"samples1":null}`, `{"samples1":{"type":"string"}}`,
+		`{"spectrum1":{"binWidth":1.5,"bins":[0,255,7]},"spectrum2":{"bins":[256]}}`,
+		`{"imagechunk1":{"id":1,"index":2,"total":3,"data":"AQID"},"imagechunk2":{"id":4294967296}}`,
+		`{"track1":[{"lat":1,"lon":2,"alt":3,"offset":4},{"lat":1.5,"lon":-2,"alt":3.25,"offset":10}]}`,
+		`{"scheduledcommand1":{"delay":60,"type":"digitaloutput","value":1},"scheduledcommand2":{"type":"bool","value":true}}`,
+		`{"scheduledcommand1":{"type":"array","value":[]}}`, `{"scheduledcommand1":null}`,
+	} {
+		encodes = append(encodes, json.RawMessage(data))
+	}
+
+	var input bytes.Buffer
+	for _, p := range payloads {
+		fmt.Fprintf(&input, "{\"decode\":%q}\n", p)
+	}
+	for _, p := range payloads {
+		data, _ := hex.DecodeString(p)
+		m, err := xlpp.NewBytesReader(data).ReadMessage()
+		if err != nil {
+			continue
+		}
+		if data, err := xlpp.MarshalJSON(m); err == nil {
+			encodes = append(encodes, data)
+		}
+	}
+	for _, data := range encodes {
+		fmt.Fprintf(&input, "{\"encode\":%s}\n", data)
+	}
+
+	cmd := exec.Command("node", "testdata/formatter.js", path)
+	cmd.Stdin = &input
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if len(lines) != len(payloads)+len(encodes) {
+		t.Fatalf("formatter answered %d of %d cases", len(lines), len(payloads)+len(encodes))
+	}
+
+	type result struct {
+		Data  interface{} `json:"data"`
+		Bytes string      `json:"bytes"`
+		Error string      `json:"error"`
+	}
+	for i, p := range payloads {
+		var got result
+		if err := json.Unmarshal([]byte(lines[i]), &got); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := hex.DecodeString(p)
+		m, err := xlpp.NewBytesReader(data).ReadMessage()
+		if err != nil {
+			if got.Error == "" {
+				t.Errorf("decode %s: got %s, want error %v", p, lines[i], err)
+			}
+			continue
+		}
+		if got.Error != "" {
+			t.Errorf("decode %s: got error %s", p, got.Error)
+			continue
+		}
+		wantJSON, _ := xlpp.MarshalJSON(m)
+		var want interface{}
+		json.Unmarshal(wantJSON, &want)
+		if !reflect.DeepEqual(got.Data, want) {
+			t.Errorf("decode %s:\n got: %s\nwant: %s", p, lines[i], wantJSON)
+		}
+	}
+	for i, data := range encodes {
+		var got result
+		if err := json.Unmarshal([]byte(lines[len(payloads)+i]), &got); err != nil {
+			t.Fatal(err)
+		}
+		var want []byte
+		m, err := xlpp.UnmarshalJSON(data)
+		if err == nil {
+			want, err = m.MarshalBinary()
+		}
+		switch {
+		case err != nil && got.Error == "":
+			t.Errorf("encode %s: got %s, want error %v", data, got.Bytes, err)
+		case err == nil && got.Error != "":
+			t.Errorf("encode %s: got error %s, want %x", data, got.Error, want)
+		case err == nil && got.Bytes != hex.EncodeToString(want):
+			t.Errorf("encode %s:\n got: %s\nwant: %x", data, got.Bytes, want)
+		}
+	}
+}
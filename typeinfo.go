@@ -0,0 +1,61 @@
+package xlpp
+
+import "sort"
+
+// TypeMetadata describes a XLPP type, see TypeInfo.
+type TypeMetadata struct {
+	Type Type
+	// Name is the canonical lowercase name, e.g. "temperature", see Type.Name.
+	Name string
+	// Unit is the physical unit, e.g. "°C", see Type.Unit.
+	Unit string
+	// Resolution is the step of numeric values on the wire, e.g. 0.1 for TypeTemperature.
+	// It is 0 for non-numeric types, e.g. TypeString, and for types with mixed resolutions, e.g. TypeSamples.
+	// The resolution of GPS types is the one of the coordinates.
+	Resolution float64
+	// Signed is set for types with negative values.
+	Signed bool
+	// IsActuator is set for the outputs that are set by downlink commands, e.g. TypeSwitch.
+	IsActuator bool
+	// WireSize is the number of bytes of values after the type byte, see Sizes.
+	// It is VariableSize for types of variable or unknown size.
+	WireSize int
+}
+
+// TypeInfo returns the metadata of the type. The Name of unknown types is empty.
+// Custom types registered with RegisterType only have a Name and their Sizes entry.
+func TypeInfo(t Type) TypeMetadata {
+	info := typeInfoOf(t)
+	size, ok := Sizes[t]
+	if !ok {
+		size = VariableSize
+	}
+	return TypeMetadata{
+		Type:       t,
+		Name:       info.name,
+		Unit:       info.unit,
+		Resolution: info.resolution,
+		Signed:     info.signed,
+		IsActuator: info.actuator,
+		WireSize:   size,
+	}
+}
+
+// ListTypes returns the metadata of all types of the Registry, ordered by type.
+// TypeBool, TypeBoolTrue and TypeBoolFalse, and TypeArray and TypeArrayOf, share their names.
+func ListTypes() []TypeMetadata {
+	registryMu.RLock()
+	types := make([]Type, 0, len(Registry))
+	for t := range Registry {
+		if t != TypeEndOfArray {
+			types = append(types, t)
+		}
+	}
+	registryMu.RUnlock()
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	list := make([]TypeMetadata, len(types))
+	for i, t := range types {
+		list[i] = TypeInfo(t)
+	}
+	return list
+}
@@ -0,0 +1,134 @@
+package xlpp
+
+// typeInfo holds the metadata of a XLPP type.
+type typeInfo struct {
+	name string
+	unit string
+	// normalized is the field of The Things Stack normalized payload, e.g. "air.temperature".
+	normalized string
+	// resolution is the step of numeric values, e.g. 0.1 for TypeTemperature.
+	resolution float64
+	signed     bool
+	actuator   bool
+}
+
+// typeInfos is the metadata registry of all known XLPP types.
+var typeInfos = map[Type]typeInfo{
+	// LPP Types
+	TypeDigitalInput:       {name: "digitalinput", resolution: 1},
+	TypeDigitalOutput:      {name: "digitaloutput", resolution: 1, actuator: true},
+	TypeAnalogInput:        {name: "analoginput", resolution: 0.01, signed: true},
+	TypeAnalogOutput:       {name: "analogoutput", resolution: 0.01, signed: true, actuator: true},
+	TypeLuminosity:         {name: "luminosity", unit: "lux", normalized: "air.lightIntensity", resolution: 1},
+	TypePresence:           {name: "presence", normalized: "action.motion.detected", resolution: 1},
+	TypeTemperature:        {name: "temperature", unit: "°C", normalized: "air.temperature", resolution: 0.1, signed: true},
+	TypeRelativeHumidity:   {name: "relativehumidity", unit: "%", normalized: "air.relativeHumidity", resolution: 0.5},
+	TypeAccelerometer:      {name: "accelerometer", unit: "G", resolution: 0.001, signed: true},
+	TypeBarometricPressure: {name: "barometricpressure", unit: "hPa", normalized: "air.pressure", resolution: 0.1, signed: true},
+	TypeGyrometer:          {name: "gyrometer", unit: "°/s", resolution: 0.01, signed: true},
+	TypeGPS:                {name: "gps", resolution: 0.0001, signed: true},
+
+	// more LPP Types
+	TypeVoltage:       {name: "voltage", unit: "V", resolution: 0.01, signed: true},
+	TypeCurrent:       {name: "current", unit: "A", resolution: 0.001, signed: true},
+	TypeFrequency:     {name: "frequency", unit: "Hz", resolution: 1},
+	TypePercentage:    {name: "percentage", unit: "%", resolution: 1},
+	TypeAltitude:      {name: "altitude", unit: "m", resolution: 1, signed: true},
+	TypeConcentration: {name: "concentration", unit: "ppm", resolution: 1},
+	TypePower:         {name: "power", unit: "W", resolution: 1},
+	TypeDistance:      {name: "distance", unit: "m", resolution: 0.001},
+	TypeEnergy:        {name: "energy", unit: "kWh", resolution: 0.001},
+	TypeDirection:     {name: "direction", unit: "°", resolution: 1},
+	TypeUnixTime:      {name: "unixtime", resolution: 1},
+	TypeColour:        {name: "colour", actuator: true},
+	TypeSwitch:        {name: "switch", actuator: true},
+
+	// extended-range Types
+	TypeExtendedPercentage:   {name: "extendedpercentage", unit: "%", resolution: 1},
+	TypeBarometricPressure24: {name: "barometricpressurehr", unit: "hPa", normalized: "air.pressure", resolution: 0.01},
+	TypeDistanceLong:         {name: "distancelong", unit: "m", resolution: 0.001},
+	TypePowerPrecise:         {name: "powerprecise", unit: "W", resolution: 0.1},
+	TypeCurrentHiRange:       {name: "currenthirange", unit: "A", resolution: 0.01},
+	TypeVoltageSigned:        {name: "voltagesigned", unit: "V", resolution: 0.01, signed: true},
+	TypeAnalogUnit:           {name: "analogunit", resolution: 0.001, signed: true},
+	TypeGasConcentration:     {name: "gasconcentration", unit: "ppm", resolution: 0.001},
+	TypeGPS2D:                {name: "gpscompact", resolution: 0.0001, signed: true},
+	TypeAccelerometerHiG:     {name: "accelerometerhig", unit: "G", resolution: 0.01, signed: true},
+	TypeGyrometerHiRate:      {name: "gyrometerhirate", unit: "°/s", resolution: 1, signed: true},
+	TypeSamples:              {name: "samples"},
+	TypeSpectrum:             {name: "spectrum"},
+	TypeImageChunk:           {name: "imagechunk"},
+	TypeTrack:                {name: "track"},
+	TypeScheduledCommand:     {name: "scheduledcommand"},
+
+	// XLPP Types
+	TypeInteger:    {name: "integer", resolution: 1, signed: true},
+	TypeNull:       {name: "null"},
+	TypeString:     {name: "string"},
+	TypeBoolTrue:   {name: "bool"},
+	TypeBoolFalse:  {name: "bool"},
+	TypeBool:       {name: "bool"},
+	TypeObject:     {name: "object"},
+	TypeArray:      {name: "array"},
+	TypeArrayOf:    {name: "array"},
+	TypeEndOfArray: {},
+	TypeFlags:      {name: "flags"},
+	TypeBinary:     {name: "binary"},
+}
+
+// Unit returns the physical unit of the type, e.g. "°C" for TypeTemperature.
+// It returns an empty string for types without physical dimension.
+func (t Type) Unit() string {
+	return typeInfoOf(t).unit
+}
+
+// NormalizedField returns the field of The Things Stack normalized payload for values of the type, e.g. "air.temperature"
+// for TypeTemperature. It returns an empty string if the type has no fixed field, e.g. for TypePercentage.
+func (t Type) NormalizedField() string {
+	return typeInfoOf(t).normalized
+}
+
+// Name returns the canonical lowercase name of the type, e.g. "temperature" for TypeTemperature.
+// It returns an empty string for unknown types.
+func (t Type) Name() string {
+	return typeInfoOf(t).name
+}
+
+// NameOf returns the canonical lowercase name of the value's type.
+// Markers are named after their Go type, e.g. "delay" for Delay.
+func NameOf(v Value) string {
+	switch v.(type) {
+	case *Delay:
+		return "delay"
+	case *MilliDelay:
+		return "millidelay"
+	case *Priority:
+		return "priority"
+	case *Actuators:
+		return "actuators"
+	case *ActuatorsWithChannel:
+		return "actuatorswithchannel"
+	case *ActuatorAck:
+		return "actuatorack"
+	case *UnknownValue:
+		return "unknown"
+	}
+	return v.XLPPType().Name()
+}
+
+// RegistryByName maps the canonical type names to the Registry factories.
+// It is built from the Registry at program start, and RegisterType adds custom types.
+// Use LookupName to read it while types may be registered.
+var RegistryByName = make(map[string]func() Value, len(Registry))
+
+func init() {
+	for i := 0; i < 256; i++ {
+		t := Type(i)
+		f := Registry[t]
+		if name := t.Name(); f != nil && name != "" {
+			if _, ok := RegistryByName[name]; !ok {
+				RegistryByName[name] = f
+			}
+		}
+	}
+}
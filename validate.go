@@ -0,0 +1,104 @@
+package xlpp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A MarkerError reports a marker that violates the marker semantics, see WithMarkerValidation.
+type MarkerError struct {
+	// Channel is the marker channel, e.g. ChanDelay.
+	Channel int
+	// Offset is the payload offset of the entry with the marker.
+	Offset int64
+	Reason string
+}
+
+func (e *MarkerError) Error() string {
+	return fmt.Sprintf("xlpp: invalid marker on channel %d at offset %d: %s", e.Channel, e.Offset, e.Reason)
+}
+
+// WithMarkerValidation makes the Reader check the marker semantics of the payload:
+//   - Actuators, ActuatorsWithChannel and Priority markers occur at most once per payload,
+//   - Delay markers increase the total delay, so that the delays are monotonic,
+//   - markers are not nested inside Objects and Arrays,
+//   - Percentage values are in the range 0..100.
+//
+// Marker violations are returned as *MarkerError by Next, along with the marker,
+// Percentage values out of range as ErrPercentageRange.
+func WithMarkerValidation() ReaderOption {
+	return func(r *Reader) {
+		r.validate = true
+	}
+}
+
+// markerState is the state of the marker validation of a Reader.
+type markerState struct {
+	actuators            bool
+	actuatorsWithChannel bool
+	priority             bool
+}
+
+// validateMarker checks the marker read by the Reader.
+func (r *Reader) validateMarker(channel int, v Value) error {
+	var reason string
+	switch v := v.(type) {
+	case *Delay:
+		if *v <= 0 {
+			reason = "delay does not increase the total delay"
+		}
+	case *MilliDelay:
+		if *v <= 0 {
+			reason = "delay does not increase the total delay"
+		}
+	case *Actuators:
+		if r.markers.actuators {
+			reason = "duplicate actuators marker"
+		}
+		r.markers.actuators = true
+	case *ActuatorsWithChannel:
+		if r.markers.actuatorsWithChannel {
+			reason = "duplicate actuators marker"
+		}
+		r.markers.actuatorsWithChannel = true
+	case *Priority:
+		if r.markers.priority {
+			reason = "duplicate priority marker"
+		}
+		r.markers.priority = true
+	}
+	if reason == "" {
+		return nil
+	}
+	return &MarkerError{Channel: channel, Reason: reason}
+}
+
+// nestedMarker returns a MarkerError if t is a marker channel nested inside an Object or Array read from a validating Reader.
+func nestedMarker(opts *Reader, t Type) error {
+	if opts == nil || !opts.validate {
+		return nil
+	}
+	if isMarkerChannel(int(t)) {
+		return &MarkerError{Channel: int(t), Reason: "marker nested inside an Object or Array"}
+	}
+	return nil
+}
+
+// validateValue returns ErrPercentageRange for a Percentage above 100 read from a validating Reader.
+func validateValue(opts *Reader, v Value) error {
+	if opts == nil || !opts.validate {
+		return nil
+	}
+	if p, ok := v.(*Percentage); ok && !p.Valid() {
+		return ErrPercentageRange
+	}
+	return nil
+}
+
+// markerOffset sets the offset of a MarkerError in err.
+func markerOffset(err error, offset int64) {
+	var e *MarkerError
+	if errors.As(err, &e) {
+		e.Offset = offset
+	}
+}
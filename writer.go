@@ -2,27 +2,177 @@ package xlpp
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"time"
 )
 
 var errObjectKeyNoDepth = errors.New("xlpp: AddObjectKey requires AddObject first")
 var errEndObjectNoDepth = errors.New("xlpp: EndObject requires AddObject first")
 var errEndArrayNoDepth = errors.New("xlpp: EndArray requires AddArray first")
+var errObjectKeyEmpty = errors.New("xlpp: AddObjectKey requires a non-empty key")
+var errObjectKeyMissing = errors.New("xlpp: values in an Object require AddObjectKey first")
+var errObjectKeyNoValue = errors.New("xlpp: EndObject after AddObjectKey without value")
+var errMarkerNested = errors.New("xlpp: markers can not be nested in Objects or Arrays")
+
+// ErrChannelReused is returned by Writers created with WithUniqueChannels, if a channel is reused with a different type.
+var ErrChannelReused = errors.New("xlpp: channel reused with a different type")
+
+var errCanonicalStream = errors.New("xlpp: canonical encoding requires Objects and Arrays as values, not streamed")
 
 // Writer wrapps an [io.Writer](https://golang.org/pkg/io/#Writer) with simple LPP methods for known data types.
+//
+// Objects and Arrays can be streamed without building them in memory first:
+// AddObject and AddArray start a nested Object or Array, and all values that are added afterwards are items of it,
+// until it is closed with EndObject or EndArray. Values of an Object require a key, see AddObjectKey.
 type Writer struct {
 	io.Writer
+
+	// stack holds the nested Objects and Arrays started with AddObject and AddArray.
+	stack []nesting
+
+	canonical bool
+	compact   bool
+	strictLPP bool
+	// pending holds the entries added in canonical mode, until Flush.
+	pending Message
+
+	// channels holds the types of the channels written so far, if checkChannels is set.
+	channels      map[int]Type
+	checkChannels bool
+	warnChannel   func(channel int, old, new Type)
+
+	metrics   Metrics
+	log       Logger
+	types     *TypeRegistry
+	actuators actuators
+	crc       *crcWriter
+	compress  *compressor
+	// at is the timestamp of the last value written with AddAt, with the delays written so far.
+	at time.Time
+}
+
+// A WriterOption configures a Writer.
+type WriterOption func(*Writer)
+
+// WithCanonicalEncoding makes the Writer produce byte-identical output for messages with the same values at the same times,
+// e.g. for payload hashes used as idempotency keys.
+// Added entries are buffered and written in canonical form (see Message.Canonicalize) by Flush.
+// Objects and Arrays must be added as values, streaming them with AddObject and AddArray is not supported.
+func WithCanonicalEncoding() WriterOption {
+	return func(w *Writer) {
+		w.canonical = true
+	}
+}
+
+// WithCompactArrays makes the Writer encode Arrays of at least two values of the same type in the compact TypeArrayOf form,
+// which saves one type byte per item. Arrays of Nulls or Bools and Arrays streamed with AddArray are not compacted.
+// Decoders that do not know TypeArrayOf can not read the payload.
+func WithCompactArrays() WriterOption {
+	return func(w *Writer) {
+		w.compact = true
+	}
+}
+
+// nesting is an Object or Array of a Writer.
+type nesting struct {
+	t Type
+	// key is set if AddObjectKey was called and the value is not yet written.
+	key bool
+}
+
+// WithUniqueChannels makes the Writer check that each channel is used with a single type,
+// which catches channel numbers that have been reused by mistake, e.g. when porting firmware.
+// If warn is nil, reusing a channel with a different type fails with ErrChannelReused. Otherwise the value is written
+// and warn is called with the channel, the type of the first value and the type of the new value.
+// Channels are tracked for the lifetime of the Writer, so use one Writer per message.
+func WithUniqueChannels(warn func(channel int, old, new Type)) WriterOption {
+	return func(w *Writer) {
+		w.checkChannels = true
+		w.warnChannel = warn
+	}
 }
 
 // NewWriter creates a Writer that wrapps an [io.Writer](https://golang.org/pkg/io/#Writer).
-func NewWriter(w io.Writer) *Writer {
-	return &Writer{Writer: w}
+func NewWriter(w io.Writer, opts ...WriterOption) *Writer {
+	writer := &Writer{Writer: w}
+	for _, opt := range opts {
+		opt(writer)
+	}
+	if writer.crc != nil {
+		writer.crc.w = w
+		writer.Writer = writer.crc
+	}
+	if writer.compress != nil {
+		writer.compress.w = writer.Writer
+		writer.Writer = &writer.compress.buf
+	}
+	return writer
 }
 
 // Add writes a new Value to the Writer.
+// Inside of Objects and Arrays (see AddObject and AddArray), the channel is ignored.
+// With WithCanonicalEncoding, the value is not written before Flush.
 func (w *Writer) Add(channel int, v Value) (n int, err error) {
+	if err = w.checkStrictLPP(v); err != nil {
+		return
+	}
+	if len(w.stack) == 0 {
+		if _, ok := v.(Marker); !ok {
+			if err = w.checkReserved(channel); err != nil {
+				return
+			}
+			if err = w.checkChannel(channel, v.XLPPType()); err != nil {
+				return
+			}
+		}
+	}
+	if w.canonical {
+		if marker, ok := v.(Marker); ok {
+			channel = marker.XLPPChannel()
+		}
+		w.pending = append(w.pending, Entry{Channel: channel, Value: v})
+		return 0, nil
+	}
+	return w.addMeasured(channel, v)
+}
+
+// addMeasured writes the value and reports it to the Metrics of the Writer, if any.
+func (w *Writer) addMeasured(channel int, v Value) (n int, err error) {
+	if w.metrics == nil {
+		return w.add(channel, v)
+	}
+	start := time.Now()
+	n, err = w.add(channel, v)
+	w.metrics.Latency(time.Since(start))
+	if err != nil {
+		w.metrics.Error(err)
+	} else {
+		w.metrics.Entry(v.XLPPType(), n)
+	}
+	return
+}
+
+func (w *Writer) add(channel int, v Value) (n int, err error) {
+	if w.types != nil {
+		if err = w.types.check(v); err != nil {
+			return
+		}
+	}
+	if w.compact {
+		v = compactArrays(v)
+	}
+	if len(w.stack) != 0 {
+		if _, ok := v.(Marker); ok {
+			return 0, errMarkerNested
+		}
+		if err = w.item(); err != nil {
+			return
+		}
+		return write(w.Writer, v)
+	}
 	if marker, ok := v.(Marker); ok {
-		n, err = w.Write([]byte{byte(marker.XLPPChannel())})
+		n, err = writeTo(w.Writer, []byte{byte(marker.XLPPChannel())})
 		if err == nil {
 			var m int64
 			m, err = marker.WriteTo(w.Writer)
@@ -30,7 +180,7 @@ func (w *Writer) Add(channel int, v Value) (n int, err error) {
 		}
 		return
 	}
-	n, err = w.Write([]byte{byte(channel)})
+	n, err = writeTo(w.Writer, []byte{byte(channel)})
 	if err == nil {
 		var m int
 		m, err = write(w.Writer, v)
@@ -39,11 +189,52 @@ func (w *Writer) Add(channel int, v Value) (n int, err error) {
 	return
 }
 
+// Flush writes the entries buffered with WithCanonicalEncoding in canonical form.
+// Without WithCanonicalEncoding, all entries are written immediately.
+// With WithWriterCompression, Flush writes the compressed frame, and with WithWriterCRC, Flush ends the frame with the CRC trailer.
+func (w *Writer) Flush() (n int, err error) {
+	pending := w.pending.Canonicalize()
+	w.pending = nil
+	for _, e := range pending {
+		var m int
+		m, err = w.addMeasured(e.Channel, e.Value)
+		n += m
+		if err != nil {
+			return
+		}
+	}
+	if w.compress != nil {
+		var m int
+		m, err = w.compress.flush(w.logger())
+		n += m
+		if err != nil {
+			return
+		}
+	}
+	if w.crc != nil {
+		var m int
+		m, err = w.crc.writeTrailer()
+		n += m
+	}
+	return
+}
+
+// SetPriority writes a Priority marker.
+// It should be written before all values, so that receivers can route the message without decoding it first.
+func (w *Writer) SetPriority(p Priority) (n int, err error) {
+	return w.Add(ChanPriority, &p)
+}
+
+// Schedule writes a ScheduledCommand, that makes the actuator on the channel execute the command after the delay.
+func (w *Writer) Schedule(channel int, delay time.Duration, command Value) (n int, err error) {
+	return w.AddActuation(channel, &ScheduledCommand{Delay: delay, Value: command})
+}
+
 func write(w io.Writer, v Value) (n int, err error) {
 	{
 		var m int
 		t := v.XLPPType()
-		m, err = w.Write([]byte{byte(t)})
+		m, err = writeTo(w, []byte{byte(t)})
 		n += m
 		if err != nil {
 			return
@@ -59,3 +250,140 @@ func write(w io.Writer, v Value) (n int, err error) {
 	}
 	return
 }
+
+// checkChannel checks the type t of a value on the channel, see WithUniqueChannels.
+func (w *Writer) checkChannel(channel int, t Type) error {
+	if !w.checkChannels {
+		return nil
+	}
+	if t == TypeBoolFalse {
+		// true and false are the same type
+		t = TypeBoolTrue
+	}
+	old, ok := w.channels[channel]
+	if !ok {
+		if w.channels == nil {
+			w.channels = make(map[int]Type)
+		}
+		w.channels[channel] = t
+		return nil
+	}
+	if old == t {
+		return nil
+	}
+	if w.warnChannel == nil {
+		return fmt.Errorf("%w: channel %d is %s, not %s", ErrChannelReused, channel, old.Name(), t.Name())
+	}
+	w.warnChannel(channel, old, t)
+	return nil
+}
+
+// checkReserved checks that a value, that is not a marker, is not added on a marker channel.
+// Cayenne LPP has no markers, so all channels can be used with WithWriterStrictLPP.
+func (w *Writer) checkReserved(channel int) error {
+	if (isMarkerChannel(channel) || channel == ChanCompressed && w.compress != nil) && !w.strictLPP {
+		return fmt.Errorf("%w %d", ErrReservedChannel, channel)
+	}
+	return nil
+}
+
+// item checks that a value can be added to the current Object or Array.
+func (w *Writer) item() error {
+	top := &w.stack[len(w.stack)-1]
+	if top.t == TypeObject {
+		if !top.key {
+			return errObjectKeyMissing
+		}
+		top.key = false
+	}
+	return nil
+}
+
+// begin writes the head of a nested Object or Array.
+func (w *Writer) begin(channel int, t Type) (n int, err error) {
+	if w.canonical {
+		return 0, errCanonicalStream
+	}
+	if w.strictLPP {
+		return 0, checkStrictLPP(t)
+	}
+	if len(w.stack) == 0 {
+		if err = w.checkReserved(channel); err != nil {
+			return
+		}
+		if err = w.checkChannel(channel, t); err != nil {
+			return
+		}
+	}
+	if len(w.stack) != 0 {
+		if err = w.item(); err != nil {
+			return
+		}
+		n, err = writeTo(w.Writer, []byte{byte(t)})
+	} else {
+		n, err = writeTo(w.Writer, []byte{byte(channel), byte(t)})
+	}
+	if err == nil {
+		w.stack = append(w.stack, nesting{t: t})
+	}
+	return
+}
+
+// AddObject starts a new Object. All values added afterwards are values of the Object, until EndObject is called.
+// Each value requires a key, see AddObjectKey.
+// Inside of Objects and Arrays, the channel is ignored.
+func (w *Writer) AddObject(channel int) (n int, err error) {
+	return w.begin(channel, TypeObject)
+}
+
+// AddObjectKey writes the key of the next value of the current Object.
+func (w *Writer) AddObjectKey(key string) (n int, err error) {
+	if len(w.stack) == 0 || w.stack[len(w.stack)-1].t != TypeObject {
+		return 0, errObjectKeyNoDepth
+	}
+	if key == "" {
+		return 0, errObjectKeyEmpty
+	}
+	top := &w.stack[len(w.stack)-1]
+	if top.key {
+		return 0, errObjectKeyNoValue
+	}
+	m, err := String(key).WriteTo(w.Writer)
+	if err == nil {
+		top.key = true
+	}
+	return int(m), err
+}
+
+// EndObject ends the current Object.
+func (w *Writer) EndObject() (n int, err error) {
+	if len(w.stack) == 0 || w.stack[len(w.stack)-1].t != TypeObject {
+		return 0, errEndObjectNoDepth
+	}
+	if w.stack[len(w.stack)-1].key {
+		return 0, errObjectKeyNoValue
+	}
+	n, err = writeTo(w.Writer, []byte{byte(TypeEndOfObject)})
+	if err == nil {
+		w.stack = w.stack[:len(w.stack)-1]
+	}
+	return
+}
+
+// AddArray starts a new Array. All values added afterwards are items of the Array, until EndArray is called.
+// Inside of Objects and Arrays, the channel is ignored.
+func (w *Writer) AddArray(channel int) (n int, err error) {
+	return w.begin(channel, TypeArray)
+}
+
+// EndArray ends the current Array.
+func (w *Writer) EndArray() (n int, err error) {
+	if len(w.stack) == 0 || w.stack[len(w.stack)-1].t != TypeArray {
+		return 0, errEndArrayNoDepth
+	}
+	n, err = writeTo(w.Writer, []byte{byte(TypeEndOfArray)})
+	if err == nil {
+		w.stack = w.stack[:len(w.stack)-1]
+	}
+	return
+}
@@ -2,9 +2,21 @@ package xlpp_test
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"math"
 	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/waziup/xlpp"
@@ -25,6 +37,7 @@ var gps = xlpp.GPS{Latitude: 51.0493, Longitude: 13.7381, Meters: 122}
 
 var null = xlpp.Null{}
 var bin = xlpp.Binary([]byte{1, 2, 3, 7, 8, 9})
+var flags = xlpp.Flags(0x209)
 var integer = xlpp.Integer(5182)
 var str = xlpp.String("test :)")
 var boolean = xlpp.Bool(true)
@@ -45,6 +58,8 @@ var voltage = xlpp.Voltage(1.45)
 var current = xlpp.Current(4.41)
 var frequency = xlpp.Frequency(8100)
 var percentage = xlpp.Percentage(17)
+var extendedPercentage = xlpp.ExtendedPercentage(200)
+var barometricPressure24 = xlpp.BarometricPressure24(1013.25)
 var altitude = xlpp.Altitude(8849)
 var concentration = xlpp.Concentration(2512)
 var power = xlpp.Power(1142)
@@ -54,8 +69,25 @@ var direcion = xlpp.Direction(90)
 var unixtime = xlpp.UnixTime(exampleTime.Round(0))
 var color = xlpp.Colour{R: 123, G: 54, B: 89}
 var swithc = xlpp.Switch(true)
+var samples = xlpp.Samples{Type: xlpp.TypeTemperature, Interval: time.Minute, Values: []float64{21.5, 21.7, 21.6}}
+var spectrum = xlpp.Spectrum{BinWidth: 12.5, Bins: []uint8{16, 128, 64, 32}}
+var imageChunk = xlpp.ImageChunk{ImageID: 7, Index: 1, Total: 3, Data: []byte{0xff, 0xd8, 0xff, 0xe0}}
+var track = xlpp.Track{{Latitude: 48.1, Longitude: 11.5, Meters: 520, Offset: time.Minute}, {Latitude: 48.1002, Longitude: 11.4999, Meters: 521.5}}
+var scheduledCommand = xlpp.ScheduledCommand{Delay: 20 * time.Minute, Value: &digitalOutput}
+var distanceLong = xlpp.DistanceLong(123456.789)
+var powerPrecise = xlpp.PowerPrecise(12.3)
+var currentHiRange = xlpp.CurrentHiRange(125.5)
+var voltageSigned = xlpp.VoltageSigned(-48.25)
+var analogUnit = xlpp.AnalogUnit{Unit: xlpp.UnitVolt, Value: 3.3}
+var gasConcentration = xlpp.GasConcentration{Gas: xlpp.GasCO2, PPM: 412.5}
+var gPS2D = xlpp.GPS2D{Latitude: 51.0493, Longitude: 13.7381}
+var accelerometerHiG = xlpp.AccelerometerHiG{X: 150.25, Y: -3.5, Z: 1}
+var gyrometerHiRate = xlpp.GyrometerHiRate{X: 1500, Y: -250, Z: 3}
 
 var delay = xlpp.Delay(time.Second * 4235)
+var milliDelay = xlpp.MilliDelay(time.Millisecond * 1250)
+var priority = xlpp.PriorityAlarm
+var actuatorAck = xlpp.ActuatorAck(300)
 var actuators = xlpp.Actuators{xlpp.TypeColour, xlpp.TypeAnalogOutput, xlpp.TypeSwitch}
 var actuatorsWithChannel = xlpp.ActuatorsWithChannel{
 	xlpp.Actuator{
@@ -87,6 +119,8 @@ var values = []xlpp.Value{
 	&current,
 	&frequency,
 	&percentage,
+	&extendedPercentage,
+	&barometricPressure24,
 	&altitude,
 	&concentration,
 	&power,
@@ -96,9 +130,24 @@ var values = []xlpp.Value{
 	&unixtime,
 	&color,
 	&swithc,
+	&samples,
+	&spectrum,
+	&imageChunk,
+	&track,
+	&scheduledCommand,
+	&distanceLong,
+	&powerPrecise,
+	&currentHiRange,
+	&voltageSigned,
+	&analogUnit,
+	&gasConcentration,
+	&gPS2D,
+	&accelerometerHiG,
+	&gyrometerHiRate,
 	// XLPP types
 	&null,
 	&bin,
+	&flags,
 	&integer,
 	&str,
 	&boolean,
@@ -106,6 +155,9 @@ var values = []xlpp.Value{
 	&array,
 	// special XLPP types
 	&delay,
+	&milliDelay,
+	&priority,
+	&actuatorAck,
 	&actuators,
 	&actuatorsWithChannel,
 }
@@ -189,3 +241,2251 @@ func deref(i interface{}) interface{} {
 	}
 	return i
 }
+
+func TestPool(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for i, value := range values {
+		if _, err := w.Add(i, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	for round := 0; round < 3; round++ {
+		r := xlpp.NewReader(bytes.NewReader(data), xlpp.WithPool())
+		for i := 0; ; i++ {
+			_, value, err := r.Next()
+			if err != nil {
+				t.Fatalf("round %d: can not read: %v", round, err)
+			}
+			if value == nil {
+				break
+			}
+			if _, ok := value.(*xlpp.UnixTime); !ok && !reflect.DeepEqual(value, values[i]) {
+				t.Fatalf("round %d: write <> read: %T (%+v) <> (%+v)", round, deref(values[i]), deref(values[i]), deref(value))
+			}
+			xlpp.Release(value)
+		}
+	}
+}
+
+func TestPoolTypes(t *testing.T) {
+	// values of other Go types than the Registry returns for their type must not get into the pool
+	xlpp.Release(&xlpp.UnknownValue{Type: xlpp.TypeTemperature, Data: []byte{0, 235}})
+	xlpp.Release(&xlpp.UnknownValue{Type: xlpp.TypeTemperature, Data: []byte{0, 235}})
+	for i := 0; i < 3; i++ {
+		r := xlpp.NewReader(bytes.NewReader([]byte{1, 103, 0, 235}), xlpp.WithPool())
+		_, value, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if temp, ok := value.(*xlpp.Temperature); !ok || *temp != 23.5 {
+			t.Fatalf("got %T %v, want temperature 23.5", value, value)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	for name, f := range xlpp.RegistryByName {
+		if got := xlpp.NameOf(f()); got != name {
+			t.Errorf("NameOf(%T) = %q, want %q", f(), got, name)
+		}
+	}
+	if got := xlpp.NameOf(&delay); got != "delay" {
+		t.Errorf("NameOf(Delay) = %q, want %q", got, "delay")
+	}
+}
+
+func TestLongString(t *testing.T) {
+	long := xlpp.String(bytes.Repeat([]byte("xlpp "), 2000))
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	w.Add(1, &long)
+	w.Add(2, &xlpp.Object{string(long[:5000]): &long})
+
+	r := xlpp.NewReader(&buf)
+	_, value, err := r.Next()
+	if err != nil || !reflect.DeepEqual(value, &long) {
+		t.Fatalf("can not read long string: %v", err)
+	}
+	_, value, err = r.Next()
+	if err != nil || !reflect.DeepEqual(value, &xlpp.Object{string(long[:5000]): &long}) {
+		t.Fatalf("can not read long object key: %v", err)
+	}
+
+	buf.Reset()
+	long.WriteTo(&buf)
+	var s xlpp.String
+	n, err := s.ReadFrom(&buf)
+	if err != nil || s != long || n != int64(len(long)+1) {
+		t.Fatalf("can not read string from buffer: %v (%d bytes)", err, n)
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	payloads := make([][]byte, 100)
+	for i := range payloads {
+		var buf bytes.Buffer
+		w := xlpp.NewWriter(&buf)
+		w.Add(i, &temperature)
+		w.Add(i+1, &integer)
+		payloads[i] = buf.Bytes()
+	}
+	payloads[50] = []byte{1, 255}
+
+	messages, errs := xlpp.DecodeBatch(payloads, 4)
+	for i, m := range messages {
+		if i == 50 {
+			if errs[i] == nil {
+				t.Fatalf("payload %d: expected error", i)
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("payload %d: %v", i, errs[i])
+		}
+		if len(m) != 2 || m[0].Channel != i || m[1].Channel != i+1 || !reflect.DeepEqual(m[1].Value, &integer) {
+			t.Fatalf("payload %d: bad message %v", i, m)
+		}
+	}
+}
+
+func TestArena(t *testing.T) {
+	long := xlpp.Array{}
+	for i := 0; i < 20; i++ {
+		long = append(long, &integer)
+	}
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	w.Add(1, &object)
+	w.Add(2, &long)
+	w.Add(3, &str)
+	w.Add(4, &bin)
+	data := buf.Bytes()
+
+	var arena xlpp.Arena
+	for round := 0; round < 3; round++ {
+		r := xlpp.NewReader(bytes.NewReader(data), xlpp.WithAllocator(&arena))
+		m, err := r.ReadMessage()
+		if err != nil {
+			t.Fatalf("round %d: can not read: %v", round, err)
+		}
+		for i, value := range []xlpp.Value{&object, &long, &str, &bin} {
+			if !reflect.DeepEqual(m[i].Value, value) {
+				t.Fatalf("round %d: write <> read: %T (%+v) <> (%+v)", round, deref(value), deref(value), deref(m[i].Value))
+			}
+		}
+		arena.Reset()
+	}
+}
+
+func TestByteCount(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	r := xlpp.NewReader(&buf)
+	var total int64
+
+	for i, v := range values {
+		n, err := v.WriteTo(&buf)
+		if err != nil || int64(buf.Len()) != n {
+			t.Fatalf("%T: WriteTo returned %d, wrote %d bytes: %v", deref(v), n, buf.Len(), err)
+		}
+		out := reflect.New(reflect.TypeOf(v).Elem()).Interface().(xlpp.Value)
+		m, err := out.ReadFrom(&buf)
+		if err != nil || m != n || buf.Len() != 0 {
+			t.Fatalf("%T: ReadFrom returned %d, want %d: %v", deref(v), m, n, err)
+		}
+
+		a, err := w.Add(i, v)
+		if err != nil || a != buf.Len() {
+			t.Fatalf("%T: Add returned %d, wrote %d bytes: %v", deref(v), a, buf.Len(), err)
+		}
+		total += int64(a)
+		if _, _, err := r.Next(); err != nil {
+			t.Fatal(err)
+		}
+		if r.BytesConsumed() != total {
+			t.Fatalf("%T: BytesConsumed is %d, want %d", deref(v), r.BytesConsumed(), total)
+		}
+	}
+}
+
+func TestSizeHints(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	w.Add(1, &object)
+	w.Add(2, &array)
+	data := buf.Bytes()
+
+	for _, opt := range []xlpp.ReaderOption{xlpp.WithSizeHints(16, 64), xlpp.WithSizeHints(1, 1), xlpp.WithSizeHints(0, 0)} {
+		r := xlpp.NewReader(bytes.NewReader(data), opt)
+		m, err := r.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(m[0].Value, &object) || !reflect.DeepEqual(m[1].Value, &array) {
+			t.Fatalf("write <> read: %v <> %v", []xlpp.Value{&object, &array}, m)
+		}
+	}
+}
+
+func TestScaledValues(t *testing.T) {
+	// 1.15*100 and 2.3*100 are slightly below 115 and 230 in floating point
+	analog, voltage := xlpp.AnalogInput(1.15), xlpp.Voltage(2.3)
+	minInt := xlpp.Integer(-1 << 63)
+	for _, v := range []xlpp.Value{&analog, &voltage, &minInt} {
+		var buf bytes.Buffer
+		if _, err := xlpp.NewWriter(&buf).Add(1, v); err != nil {
+			t.Fatal(err)
+		}
+		_, got, err := xlpp.NewBytesReader(buf.Bytes()).Next()
+		if err != nil || !reflect.DeepEqual(got, v) {
+			t.Fatalf("%T: wrote %v, read %v (%v)", v, v, got, err)
+		}
+	}
+}
+
+func TestBinaryLength(t *testing.T) {
+	// a 1 GiB length prefix with 3 bytes of data must fail without allocating the announced length
+	data := []byte{1, byte(xlpp.TypeBinary), 0x80, 0x80, 0x80, 0x80, 0x04, 1, 2, 3}
+	if _, _, err := xlpp.NewBytesReader(data).Next(); err == nil {
+		t.Fatal("expected error for truncated Binary")
+	}
+}
+
+func TestNegativeGPS(t *testing.T) {
+	southWest := xlpp.GPS{Latitude: -33.8688, Longitude: -70.6693, Meters: -12.5}
+	var buf bytes.Buffer
+	xlpp.NewWriter(&buf).Add(1, &southWest)
+	_, got, err := xlpp.NewBytesReader(buf.Bytes()).Next()
+	if err != nil || !reflect.DeepEqual(got, &southWest) {
+		t.Fatalf("wrote %v, read %v (%v)", southWest, got, err)
+	}
+}
+
+func TestNegativeUnsigned(t *testing.T) {
+	power, zeroPower := xlpp.PowerPrecise(-2.5), xlpp.PowerPrecise(0)
+	current, zeroCurrent := xlpp.CurrentHiRange(-1.25), xlpp.CurrentHiRange(0)
+	gas, zeroGas := xlpp.GasConcentration{Gas: xlpp.GasCO, PPM: -0.5}, xlpp.GasConcentration{Gas: xlpp.GasCO}
+	distance, zeroDistance := xlpp.Distance(-1), xlpp.Distance(0)
+	energy, zeroEnergy := xlpp.Energy(-2.5), xlpp.Energy(0)
+	for _, test := range []struct{ v, want xlpp.Value }{
+		{&power, &zeroPower},
+		{&current, &zeroCurrent},
+		{&gas, &zeroGas},
+		{&distance, &zeroDistance},
+		{&energy, &zeroEnergy},
+	} {
+		var buf bytes.Buffer
+		xlpp.NewWriter(&buf).Add(1, test.v)
+		_, got, err := xlpp.NewBytesReader(buf.Bytes()).Next()
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%T: wrote %v, read %v (%v), want %v", test.v, test.v, got, err, test.want)
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	voltage, maxVoltage := xlpp.VoltageSigned(3e7), xlpp.VoltageSigned(21474836.47)
+	negVoltage, minVoltage := xlpp.VoltageSigned(-3e7), xlpp.VoltageSigned(-21474836.48)
+	analog, maxAnalog := xlpp.AnalogUnit{Unit: xlpp.UnitVolt, Value: 3e6}, xlpp.AnalogUnit{Unit: xlpp.UnitVolt, Value: 2147483.647}
+	negAnalog, minAnalog := xlpp.AnalogUnit{Value: -3e6}, xlpp.AnalogUnit{Value: -2147483.648}
+	distanceLong1e20, maxDistanceLong := xlpp.DistanceLong(1e20), xlpp.DistanceLong(float64(uint64(1<<64-1<<11))/1000)
+	for _, test := range []struct{ v, want xlpp.Value }{
+		{&voltage, &maxVoltage},
+		{&negVoltage, &minVoltage},
+		{&analog, &maxAnalog},
+		{&negAnalog, &minAnalog},
+		{&xlpp.AccelerometerHiG{X: 400, Y: -400, Z: 1.5}, &xlpp.AccelerometerHiG{X: 327.67, Y: -327.68, Z: 1.5}},
+		{&xlpp.GyrometerHiRate{X: 40000, Y: -40000, Z: -2000}, &xlpp.GyrometerHiRate{X: 32767, Y: -32768, Z: -2000}},
+		{&distanceLong1e20, &maxDistanceLong},
+	} {
+		var buf bytes.Buffer
+		xlpp.NewWriter(&buf).Add(1, test.v)
+		_, got, err := xlpp.NewBytesReader(buf.Bytes()).Next()
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%T: wrote %v, read %v (%v), want %v", test.v, test.v, got, err, test.want)
+		}
+	}
+}
+
+func TestGPS2DRange(t *testing.T) {
+	for _, v := range []xlpp.GPS2D{{Latitude: 90.5}, {Longitude: 2000}, {Longitude: -180.1}, {Latitude: math.NaN()}} {
+		if _, err := xlpp.NewWriter(ioutil.Discard).Add(1, &v); !errors.Is(err, xlpp.ErrGPSRange) {
+			t.Errorf("%v: got %v, want ErrGPSRange", v, err)
+		}
+	}
+	v := xlpp.GPS2D{Latitude: -90, Longitude: 180}
+	if _, err := xlpp.NewWriter(ioutil.Discard).Add(1, &v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnergyUnit(t *testing.T) {
+	if s := xlpp.Energy(1.5).String(); s != "1.5000 kWh" {
+		t.Fatalf("Energy: %q", s)
+	}
+}
+
+func TestBytesReader(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	for i, value := range values {
+		w.Add(i, value)
+	}
+	data := buf.Bytes()
+
+	r := xlpp.NewBytesReader(nil)
+	for round := 0; round < 2; round++ {
+		r.ResetBytes(data)
+		m, err := r.ReadMessage()
+		if err != nil {
+			t.Fatalf("round %d: can not read: %v", round, err)
+		}
+		if len(m) != len(values) || r.BytesConsumed() != int64(len(data)) {
+			t.Fatalf("round %d: read %d values (%d bytes), want %d values (%d bytes)", round, len(m), r.BytesConsumed(), len(values), len(data))
+		}
+		for i, e := range m {
+			if _, ok := e.Value.(*xlpp.UnixTime); !ok && !reflect.DeepEqual(e.Value, values[i]) {
+				t.Fatalf("round %d: write <> read: %T (%+v) <> (%+v)", round, deref(values[i]), deref(values[i]), deref(e.Value))
+			}
+		}
+	}
+
+	if _, err := xlpp.NewBytesReader(data[:len(data)-1]).ReadMessage(); err == nil {
+		t.Fatal("expected error for truncated payload")
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+func TestGoldenFile(t *testing.T) {
+	name := fmt.Sprintf("testdata/golden/v%d.json", xlpp.GoldenVersion)
+	type vector struct {
+		Name      string          `json:"name"`
+		Source    string          `json:"source"`
+		Payload   string          `json:"payload"`
+		JSON      json.RawMessage `json:"json"`
+		Canonical bool            `json:"canonical"`
+	}
+	vectors := make([]vector, len(xlpp.GoldenVectors))
+	for i, v := range xlpp.GoldenVectors {
+		vectors[i] = vector{v.Name, v.Source, v.Payload, json.RawMessage(v.JSON), v.Canonical}
+	}
+	data, err := json.MarshalIndent(vectors, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, '\n')
+	if *updateGolden {
+		if err := ioutil.WriteFile(name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%v (run 'go test -run TestGoldenFile -update' to create it)", err)
+	}
+	if !bytes.Equal(file, data) {
+		t.Fatalf("%s is out of date, run 'go test -run TestGoldenFile -update'", name)
+	}
+}
+
+// TestGoldenFrozen verifies that the vectors of older GoldenVersions are kept, and that their payloads still decode.
+func TestGoldenFrozen(t *testing.T) {
+	for version := 1; version < xlpp.GoldenVersion; version++ {
+		name := fmt.Sprintf("testdata/golden/v%d.json", version)
+		file, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vectors []struct {
+			Name      string `json:"name"`
+			Payload   string `json:"payload"`
+			Canonical bool   `json:"canonical"`
+		}
+		if err := json.Unmarshal(file, &vectors); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, v := range vectors {
+			payload, err := hex.DecodeString(v.Payload)
+			if err != nil {
+				t.Fatalf("%s %s: %v", name, v.Name, err)
+			}
+			m, err := xlpp.NewBytesReader(payload).ReadMessage()
+			if err != nil {
+				t.Errorf("%s %s: %v", name, v.Name, err)
+				continue
+			}
+			if data, err := m.MarshalBinary(); v.Canonical && (err != nil || !bytes.Equal(data, payload)) {
+				t.Errorf("%s %s: encoded %x %v", name, v.Name, data, err)
+			}
+		}
+	}
+}
+
+func TestUnixTimeJSON(t *testing.T) {
+	ts := xlpp.UnixTime(time.Unix(1700000000, 0))
+	data, err := xlpp.MessageJSON(xlpp.Message{{Channel: 1, Value: &ts}})
+	if err != nil || string(data) != `[{"channel":1,"type":"unixtime","value":1700000000}]` {
+		t.Fatalf("%s %v", data, err)
+	}
+	m, err := xlpp.ParseMessageJSON(data)
+	if err != nil || !time.Time(*m[0].Value.(*xlpp.UnixTime)).Equal(time.Time(ts)) {
+		t.Fatal(m, err)
+	}
+	if _, err := xlpp.UnmarshalJSON([]byte(`{"unixtime1":-1}`)); err == nil {
+		t.Fatal("expected error for negative time")
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	// a Binary with a length of 1 TB in 8 bytes
+	data := []byte{0, byte(xlpp.TypeBinary), 0x80, 0x80, 0x80, 0x80, 0x80, 0x20, 1, 2}
+	if _, err := xlpp.NewBytesReader(data).ReadMessage(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := xlpp.NewReader(bytes.NewReader(data)).ReadMessage(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := xlpp.NewBytesReader([]byte{xlpp.ChanActuators, 200, 1}).ReadMessage(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	w.Add(1, &bin)
+	if _, err := xlpp.NewReader(&buf, xlpp.WithMaxSize(len(bin)-1)).ReadMessage(); !errors.Is(err, xlpp.ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+	w.Add(1, &bin)
+	if _, err := xlpp.NewReader(&buf, xlpp.WithMaxSize(len(bin))).ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// panicValue is a Value that panics when read.
+type panicValue struct{ xlpp.Null }
+
+func (panicValue) ReadFrom(r io.Reader) (int64, error) {
+	panic("can not read")
+}
+
+func TestBadRegistry(t *testing.T) {
+	const typ = xlpp.Type(200)
+	defer delete(xlpp.Registry, typ)
+
+	for name, f := range map[string]func() xlpp.Value{
+		"nil":         func() xlpp.Value { return nil },
+		"nil pointer": func() xlpp.Value { return (*xlpp.Temperature)(nil) },
+		"panic":       func() xlpp.Value { return new(panicValue) },
+	} {
+		xlpp.Registry[typ] = f
+		// the bad value is nested in an array to make sure that nested values do not panic either
+		data := []byte{1, byte(xlpp.TypeArray), byte(typ), 0, 0, byte(xlpp.TypeEndOfArray)}
+		for _, opt := range []xlpp.ReaderOption{xlpp.WithPool(), xlpp.WithAllocator(new(xlpp.Arena))} {
+			if _, err := xlpp.NewBytesReader(data, opt).ReadMessage(); err == nil {
+				t.Fatalf("%s: expected error", name)
+			}
+		}
+	}
+}
+
+func TestMarkerValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"delays", []byte{0, byte(xlpp.TypeNull), xlpp.ChanDelay, 0, 1, 0, 0, byte(xlpp.TypeNull), xlpp.ChanDelay, 0, 0, 5}, true},
+		{"zero delay", []byte{xlpp.ChanDelay, 0, 0, 0}, false},
+		{"milli delays", []byte{xlpp.ChanMilliDelay, 0xe2, 0x09, 0, byte(xlpp.TypeNull), xlpp.ChanMilliDelay, 1}, true},
+		{"zero milli delay", []byte{xlpp.ChanMilliDelay, 0}, false},
+		{"actuators", []byte{xlpp.ChanActuators, 1, byte(xlpp.TypeSwitch), xlpp.ChanActuatorsWithChannel, 1, 3, byte(xlpp.TypeSwitch)}, true},
+		{"duplicate priority", []byte{xlpp.ChanPriority, 1, xlpp.ChanPriority, 2}, false},
+		{"duplicate actuators", []byte{xlpp.ChanActuators, 0, xlpp.ChanActuators, 0}, false},
+		{"duplicate actuators with channel", []byte{xlpp.ChanActuatorsWithChannel, 0, xlpp.ChanActuatorsWithChannel, 0}, false},
+		{"nested marker", []byte{0, byte(xlpp.TypeObject), 'k', 0, xlpp.ChanDelay, 0, 0, 1, 0}, false},
+	}
+	for _, test := range tests {
+		_, err := xlpp.NewBytesReader(test.data, xlpp.WithMarkerValidation()).ReadMessage()
+		var markerErr *xlpp.MarkerError
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && !errors.As(err, &markerErr) {
+			t.Errorf("%s: expected MarkerError, got %v", test.name, err)
+		}
+	}
+
+	// without validation, duplicate markers are accepted
+	if _, err := xlpp.NewBytesReader([]byte{xlpp.ChanActuators, 0, xlpp.ChanActuators, 0}).ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	var f xlpp.Formatter
+	for _, v := range []xlpp.Value{&analogInput, &luminosity, &temperature, &relativeHumidity, &accelerometer, &barometricPressure, &gyromter, &voltage, &current, &frequency, &concentration, &power, &distance, &energy, &str, &swithc} {
+		if s := f.Format(v); s != v.String() {
+			t.Errorf("%T: %q <> String %q", v, s, v.String())
+		}
+	}
+
+	f = xlpp.Formatter{Precision: 3, NoUnits: true, DecimalSeparator: ","}
+	if s := f.Format(&temperature); s != "31,600" {
+		t.Errorf("temperature: %q", s)
+	}
+	f = xlpp.Formatter{Precision: -1}
+	if s := f.Format(&xlpp.Array{&temperature, &voltage}); s != "[32 °C, 1 V]" {
+		t.Errorf("array: %q", s)
+	}
+
+	var buf bytes.Buffer
+	xlpp.NewWriter(&buf).Add(3, &temperature)
+	var out strings.Builder
+	if err := (xlpp.Formatter{}).Dump(&out, xlpp.NewBytesReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "3    temperature          31.60 °C\n" {
+		t.Errorf("dump: %q", out.String())
+	}
+
+	open := xlpp.String("open")
+	xlpp.NewWriter(&buf).Add(12, &open)
+	out.Reset()
+	if err := (xlpp.Formatter{}).Table(&out, xlpp.NewBytesReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	table := "CHANNEL  TYPE         VALUE   UNIT\n" +
+		"3        temperature  31.60   °C\n" +
+		"12       string       \"open\"  \n"
+	if out.String() != table {
+		t.Errorf("table:\n%s", out.String())
+	}
+}
+
+func TestJSONNaming(t *testing.T) {
+	for _, naming := range []xlpp.JSONNaming{xlpp.LegacyJSONNaming, xlpp.CanonicalJSONNaming} {
+		for _, v := range []xlpp.Value{&accelerometer, &gyromter, &gps, &actuatorsWithChannel} {
+			m := xlpp.Message{{Channel: 1, Value: v}}
+			data, err := xlpp.MarshalJSON(m, xlpp.WithJSONNaming(naming))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if naming == xlpp.CanonicalJSONNaming && bytes.ContainsAny(data, "XYZLMCT") {
+				t.Errorf("%T: not canonical: %s", v, data)
+			}
+			// unmarshaling accepts both namings
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(data, &values); err != nil {
+				t.Fatal(err)
+			}
+			u := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+			if err := json.Unmarshal(values[xlpp.NameOf(v)+"1"], u); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(u, v) {
+				t.Errorf("%T: %s: %+v <> %+v", v, data, deref(u), deref(v))
+			}
+		}
+	}
+
+	m := xlpp.Message{{Channel: 1, Value: &gps}}
+	data, _ := xlpp.MarshalJSON(m, xlpp.WithJSONNaming(xlpp.CanonicalJSONNaming))
+	if string(data) != `{"gps1":{"latitude":51.0493,"longitude":13.7381,"altitude":122}}` {
+		t.Errorf("gps: %s", data)
+	}
+	data, _ = xlpp.MarshalJSON(xlpp.Message{{Channel: 1, Value: &xlpp.Object{"gps": &gps}}}, xlpp.WithJSONNaming(xlpp.CanonicalJSONNaming))
+	if string(data) != `{"object1":{"gps":{"latitude":51.0493,"longitude":13.7381,"altitude":122}}}` {
+		t.Errorf("object: %s", data)
+	}
+	data, _ = xlpp.MarshalJSONUnits(xlpp.Message{{Channel: 1, Value: &accelerometer}}, xlpp.WithJSONNaming(xlpp.CanonicalJSONNaming))
+	if !bytes.Contains(data, []byte(`"value":{"x":`)) {
+		t.Errorf("accelerometer units: %s", data)
+	}
+	data, _ = xlpp.MarshalJSON(m)
+	if string(data) != `{"gps1":{"Latitude":51.0493,"Longitude":13.7381,"Meters":122}}` {
+		t.Errorf("gps legacy: %s", data)
+	}
+}
+
+func TestWriterNested(t *testing.T) {
+	var want bytes.Buffer
+	xlpp.NewWriter(&want).Add(1, &xlpp.Object{"obj": &object, "arr": &array})
+
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	steps := []func() (int, error){
+		func() (int, error) { return w.AddObject(1) },
+		func() (int, error) { return w.AddObjectKey("arr") },
+		func() (int, error) { return w.AddArray(0) },
+		func() (int, error) { return w.Add(0, &presence) },
+		func() (int, error) { return w.Add(0, &luminosity) },
+		func() (int, error) { return w.Add(0, &temperature) },
+		func() (int, error) { return w.EndArray() },
+		func() (int, error) { return w.AddObjectKey("obj") },
+		func() (int, error) { return w.AddObject(0) },
+		func() (int, error) { return w.AddObjectKey("count") },
+		func() (int, error) { return w.Add(0, &integer) },
+		func() (int, error) { return w.AddObjectKey("pos") },
+		func() (int, error) { return w.Add(0, &gps) },
+		func() (int, error) { return w.AddObjectKey("val") },
+		func() (int, error) { return w.Add(0, &digitalInput) },
+		func() (int, error) { return w.EndObject() },
+		func() (int, error) { return w.EndObject() },
+	}
+	n := 0
+	for i, step := range steps {
+		m, err := step()
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		n += m
+	}
+	if !bytes.Equal(buf.Bytes(), want.Bytes()) || n != buf.Len() {
+		t.Fatalf("streamed (%d bytes) %v <> %v", n, buf.Bytes(), want.Bytes())
+	}
+
+	w = xlpp.NewWriter(ioutil.Discard)
+	if _, err := w.AddObjectKey("key"); err == nil {
+		t.Error("expected error for AddObjectKey without AddObject")
+	}
+	if _, err := w.EndArray(); err == nil {
+		t.Error("expected error for EndArray without AddArray")
+	}
+	w.AddObject(1)
+	if _, err := w.Add(0, &integer); err == nil {
+		t.Error("expected error for a value without key")
+	}
+	if _, err := w.EndArray(); err == nil {
+		t.Error("expected error for EndArray in an Object")
+	}
+	w.AddObjectKey("key")
+	if _, err := w.Add(0, &delay); err == nil {
+		t.Error("expected error for a nested marker")
+	}
+	if _, err := w.EndObject(); err == nil {
+		t.Error("expected error for EndObject after a key without value")
+	}
+}
+
+func TestToken(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	w.Add(1, &temperature)
+	w.Add(2, &xlpp.Object{"arr": &array, "obj": &xlpp.Object{"t": &temperature}})
+	w.Add(xlpp.ChanDelay, &delay)
+	data := buf.Bytes()
+
+	var got []string
+	r := xlpp.NewBytesReader(data)
+	for {
+		tok, err := r.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := fmt.Sprintf("%d %v", tok.Channel, tok.Kind)
+		if tok.Kind == xlpp.TokenKey {
+			s += " " + tok.Key
+		}
+		if tok.Value != nil {
+			s += " " + tok.Value.String()
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"1 value 31.60 °C",
+		"2 begin object",
+		"2 key arr",
+		"2 begin array",
+		"2 value yes", "2 value 45 lux", "2 value 31.60 °C",
+		"2 end array",
+		"2 key obj",
+		"2 begin object",
+		"2 key t",
+		"2 value 31.60 °C",
+		"2 end object",
+		"2 end object",
+		"253 value 1h10m35s",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tokens:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if r.BytesConsumed() != int64(len(data)) {
+		t.Fatalf("consumed %d of %d bytes", r.BytesConsumed(), len(data))
+	}
+
+	// Next can be used between top-level tokens, but not inside of Objects or Arrays
+	r = xlpp.NewBytesReader(data)
+	if _, v, err := r.Next(); err != nil || v == nil {
+		t.Fatal(v, err)
+	}
+	if tok, err := r.Token(); err != nil || tok.Kind != xlpp.TokenBeginObject {
+		t.Fatal(tok, err)
+	}
+	if _, _, err := r.Next(); err == nil {
+		t.Fatal("expected error for Next inside of an Object")
+	}
+
+	// truncated payloads
+	r = xlpp.NewBytesReader(data[:len(data)-5])
+	var err error
+	for err == nil {
+		_, err = r.Token()
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	small, large := xlpp.Integer(12), xlpp.Integer(200)
+	zero, delay := xlpp.Delay(0), xlpp.Delay(time.Minute)
+	t1, t2 := xlpp.Temperature(20), xlpp.Temperature(21)
+	actuators := xlpp.Actuators{xlpp.TypeSwitch}
+	obj := xlpp.Object{"temperatures": &xlpp.Array{&t1, &t2}, "n": &large}
+	m := xlpp.Message{
+		{Channel: 1, Value: &small},
+		{Channel: 2, Value: &large},
+		{Channel: xlpp.ChanDelay, Value: &zero},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 3, Value: &obj},
+		{Channel: xlpp.ChanActuators, Value: &actuators},
+		{Channel: xlpp.ChanActuators, Value: &actuators},
+		{Channel: 4, Value: &xlpp.Array{&xlpp.Null{}, &xlpp.Null{}}},
+	}
+	var got []string
+	for _, s := range xlpp.Optimize(m) {
+		got = append(got, s.String())
+	}
+	want := []string{
+		"entry 1: use DigitalInput instead of Integer (saves 1 bytes)",
+		"entry 2: remove zero Delay (saves 4 bytes)",
+		"entry 3: merge with the previous Delay (saves 4 bytes)",
+		"entry 4 n: use DigitalInput instead of Integer (saves 1 bytes)",
+		"entry 4 temperatures: shorten key \"temperatures\" (saves 8 bytes)",
+		"entry 4 temperatures: use ArrayOf temperature (saves 1 bytes)",
+		"entry 6: remove repeated Actuators marker (saves 3 bytes)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("suggestions:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPriority(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	w.SetPriority(xlpp.PriorityAlarm)
+	w.Add(1, &temperature)
+
+	r := xlpp.NewBytesReader(buf.Bytes())
+	if p := r.Priority(); p != xlpp.PriorityRoutine {
+		t.Fatalf("priority before reading: %v", p)
+	}
+	m, err := r.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := r.Priority(); p != xlpp.PriorityAlarm {
+		t.Fatalf("reader priority: %v", p)
+	}
+	if p := m.Priority(); p != xlpp.PriorityAlarm || p.String() != "alarm" {
+		t.Fatalf("message priority: %v", p)
+	}
+	if p := m[1:].Priority(); p != xlpp.PriorityRoutine {
+		t.Fatalf("message without priority: %v", p)
+	}
+}
+
+func TestColourNames(t *testing.T) {
+	for s, want := range map[string]xlpp.Colour{
+		"#ffaa00": {R: 0xff, G: 0xaa, B: 0x00},
+		"#fa0":    {R: 0xff, G: 0xaa, B: 0x00},
+		"red":     {R: 255},
+		"Teal":    {G: 128, B: 128},
+	} {
+		var c xlpp.Colour
+		if err := json.Unmarshal([]byte(`"`+s+`"`), &c); err != nil || c != want {
+			t.Errorf("%s: got %v, %v, want %v", s, c, err, want)
+		}
+	}
+	for _, s := range []string{"#ff", "#ggg", "reddish"} {
+		if _, err := xlpp.ParseColour(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+
+	c := xlpp.Colour{R: 250, G: 5, B: 3}
+	if c.Name() != "" || c.NearestName() != "red" {
+		t.Fatalf("name %q, nearest name %q", c.Name(), c.NearestName())
+	}
+	if name := (xlpp.Colour{G: 255, B: 255}).Name(); name != "aqua" {
+		t.Fatalf("name %q", name)
+	}
+	if s := (xlpp.Formatter{ColourNames: true}).Format(&c); s != "R:250 G:5 B:3 (#fa0503, red)" {
+		t.Fatalf("format %q", s)
+	}
+	if s := c.String(); s != "R:250 G:5 B:3 (#fa0503)" {
+		t.Fatalf("string %q", s)
+	}
+}
+
+func TestPercentageRange(t *testing.T) {
+	data := []byte{1, byte(xlpp.TypePercentage), 200}
+	_, v, err := xlpp.NewBytesReader(data).Next()
+	if err != nil || *v.(*xlpp.Percentage) != 200 || v.(*xlpp.Percentage).Valid() {
+		t.Fatalf("expected lenient decoding, got %v %v", v, err)
+	}
+	if _, _, err := xlpp.NewBytesReader(data, xlpp.WithMarkerValidation()).Next(); !errors.Is(err, xlpp.ErrPercentageRange) {
+		t.Fatalf("expected ErrPercentageRange, got %v", err)
+	}
+	p := xlpp.Percentage(101)
+	if _, err := xlpp.NewWriter(ioutil.Discard).Add(1, &p); !errors.Is(err, xlpp.ErrPercentageRange) {
+		t.Fatalf("expected ErrPercentageRange, got %v", err)
+	}
+	_, v, err = xlpp.NewBytesReader([]byte{1, byte(xlpp.TypeExtendedPercentage), 200}).Next()
+	if err != nil || *v.(*xlpp.ExtendedPercentage) != 200 {
+		t.Fatal(v, err)
+	}
+}
+
+func TestAnalogUnitJSON(t *testing.T) {
+	for _, v := range []xlpp.AnalogUnit{{Unit: xlpp.UnitCelsius, Value: -3.5}, {Unit: xlpp.UnitNone, Value: 1}, {Unit: 200, Value: 2}} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got xlpp.AnalogUnit
+		if err := json.Unmarshal(data, &got); err != nil || got != v {
+			t.Errorf("%s: got %v, %v", data, got, err)
+		}
+	}
+	if data, _ := json.Marshal(xlpp.AnalogUnit{Unit: xlpp.UnitCelsius, Value: -3.5}); string(data) != `{"unit":"°C","value":-3.5}` {
+		t.Errorf("json: %s", data)
+	}
+	var v xlpp.AnalogUnit
+	if err := json.Unmarshal([]byte(`{"unit":"furlong","value":1}`), &v); err == nil {
+		t.Error("expected error for unknown unit")
+	}
+}
+
+func TestGasConcentrationJSON(t *testing.T) {
+	v := xlpp.GasConcentration{Gas: xlpp.GasNO2, PPM: 0.042}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) != `{"gas":"NO2","ppm":0.042}` {
+		t.Fatalf("json: %s, %v", data, err)
+	}
+	var got xlpp.GasConcentration
+	if err := json.Unmarshal([]byte(`{"gas":"NO2","ppm":0.042}`), &got); err != nil || got != v {
+		t.Fatal(got, err)
+	}
+	if err := json.Unmarshal([]byte(`{"gas":"XY","ppm":1}`), &got); err == nil {
+		t.Fatal("expected error for unknown gas")
+	}
+}
+
+func TestSamples(t *testing.T) {
+	v := xlpp.Samples{Type: xlpp.TypeTemperature, Interval: time.Minute}
+	for i := 0; i < 60; i++ {
+		v.Values = append(v.Values, 21.5+float64(i%7)/10)
+	}
+	var buf bytes.Buffer
+	if _, err := xlpp.NewWriter(&buf).Add(1, &v); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 70 {
+		t.Fatalf("%d bytes for 60 samples", buf.Len())
+	}
+	_, got, err := xlpp.NewBytesReader(buf.Bytes()).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, &v) {
+		t.Fatalf("got %v, want %v", got, v)
+	}
+	if temp, ok := got.(*xlpp.Samples).At(59).(*xlpp.Temperature); !ok || *temp != 21.8 {
+		t.Fatalf("At(59): %v", temp)
+	}
+
+	data, _ := json.Marshal(v)
+	var fromJSON xlpp.Samples
+	if err := json.Unmarshal(data, &fromJSON); err != nil || !reflect.DeepEqual(fromJSON, v) {
+		t.Fatalf("json: %s: %v", data, err)
+	}
+
+	bad := xlpp.Samples{Type: xlpp.TypeGPS, Values: []float64{1}}
+	if _, err := xlpp.NewWriter(&buf).Add(1, &bad); err == nil {
+		t.Fatal("expected error for unsupported type")
+	}
+	if v := bad.At(0); v != nil {
+		t.Fatalf("At of GPS samples: %v", v)
+	}
+	if v := (xlpp.Samples{Type: xlpp.TypePrivateMax, Values: []float64{1}}).At(0); v != nil {
+		t.Fatalf("At of unregistered samples: %v", v)
+	}
+	// a corrupt count must not allocate
+	if _, _, err := xlpp.NewBytesReader([]byte{1, byte(xlpp.TypeSamples), byte(xlpp.TypeTemperature), 60, 0xff, 0xff, 0xff, 0xff, 0x0f}).Next(); err == nil {
+		t.Fatal("expected error for corrupt count")
+	}
+}
+
+func TestImageAssembler(t *testing.T) {
+	image := make([]byte, 1000)
+	for i := range image {
+		image[i] = byte(i)
+	}
+	chunks, err := xlpp.ImageChunks(3, image, 300)
+	if err != nil || len(chunks) != 4 || len(chunks[3].Data) != 100 {
+		t.Fatalf("%d chunks, %v", len(chunks), err)
+	}
+	for _, size := range []int{0, -1} {
+		if _, err := xlpp.ImageChunks(3, image, size); err == nil {
+			t.Fatalf("size %d: expected error", size)
+		}
+	}
+	var a xlpp.ImageAssembler
+	for _, i := range []int{2, 0, 2, 3} {
+		if _, complete, err := a.Add(&chunks[i]); complete || err != nil {
+			t.Fatal(complete, err)
+		}
+	}
+	if pending := a.Pending(); len(pending) != 1 || pending[0] != 3 {
+		t.Fatalf("pending: %v", pending)
+	}
+	got, complete, err := a.Add(&chunks[1])
+	if !complete || err != nil || !bytes.Equal(got, image) {
+		t.Fatal(complete, err)
+	}
+	if len(a.Pending()) != 0 {
+		t.Fatal("image still pending")
+	}
+	if _, _, err := a.Add(&xlpp.ImageChunk{ImageID: 1, Index: 2, Total: 2}); err == nil {
+		t.Fatal("expected error for invalid index")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	on := xlpp.Switch(true)
+	if _, err := w.Schedule(5, 90*time.Second, &on); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xlpp.NewWriter(ioutil.Discard).Schedule(5, time.Minute, &xlpp.Array{}); err == nil {
+		t.Fatal("expected error for nested Array")
+	}
+	m, err := xlpp.NewBytesReader(buf.Bytes()).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, ok := m[0].Value.(*xlpp.ScheduledCommand)
+	if len(m) != 1 || !ok || m[0].Channel != 5 {
+		t.Fatalf("unexpected message: %v", m)
+	}
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if at := cmd.At(received); !at.Equal(received.Add(90 * time.Second)) {
+		t.Fatalf("At: %v", at)
+	}
+	if s, ok := cmd.Value.(*xlpp.Switch); !ok || !bool(*s) {
+		t.Fatalf("command: %v", cmd.Value)
+	}
+	if data, err := json.Marshal(xlpp.ScheduledCommand{}); err != nil || string(data) != `{"delay":0,"type":"","value":null}` {
+		t.Fatalf("zero value JSON: %s, %v", data, err)
+	}
+	if s := (xlpp.ScheduledCommand{}).String(); s != "<nil> in 0s" {
+		t.Fatalf("zero value String: %q", s)
+	}
+	// a truncated command: the partial value is printable
+	_, v, err := xlpp.NewBytesReader([]byte{1, byte(xlpp.TypeScheduledCommand)}).Next()
+	if err == nil {
+		t.Fatal("expected error for truncated ScheduledCommand")
+	}
+	_ = fmt.Sprint(v)
+}
+
+func TestIndex(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	temp1, temp2 := xlpp.Temperature(21.5), xlpp.Temperature(22.5)
+	delay := xlpp.Delay(time.Minute)
+	w.Add(1, &temp1)
+	w.Add(2, &gps)
+	w.Add(0, &delay)
+	w.Add(1, &temp2)
+	index, err := xlpp.BuildIndex(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 4 || len(index.Type(xlpp.TypeTemperature)) != 2 || index[1].Offset != 4 || index[1].Size != 11 {
+		t.Fatalf("index: %+v", index)
+	}
+	ir := xlpp.NewIndexedReader(bytes.NewReader(buf.Bytes()), index)
+	values, err := ir.Channel(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || *values[1].(*xlpp.Temperature) != temp2 {
+		t.Fatalf("values: %v", values)
+	}
+	if _, err := ir.ReadEntry(xlpp.IndexEntry{Channel: 1, Type: xlpp.TypeTemperature, Offset: 4, Size: 11}); err == nil {
+		t.Fatal("expected error for mismatching entry")
+	}
+
+	// io.ReaderAt implementations may return io.EOF with the last entry
+	ir = xlpp.NewIndexedReader(eofReaderAt{bytes.NewReader(buf.Bytes())}, index)
+	if v, err := ir.ReadEntry(index[3]); err != nil || *v.(*xlpp.Temperature) != temp2 {
+		t.Fatalf("last entry: %v, %v", v, err)
+	}
+}
+
+// eofReaderAt returns io.EOF for reads that end at the end of the data.
+type eofReaderAt struct {
+	*bytes.Reader
+}
+
+func (r eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(p, off)
+	if err == nil && off+int64(n) == r.Size() {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestMessageLookup(t *testing.T) {
+	temp1, temp2 := xlpp.Temperature(21.5), xlpp.Temperature(22.5)
+	delay := xlpp.Delay(time.Minute)
+	m := xlpp.Message{
+		{Channel: 3, Value: &temp1},
+		{Channel: 1, Value: &gps},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 3, Value: &temp2},
+	}
+	if v, ok := m.Get(3); !ok || v != &temp1 {
+		t.Fatalf("Get(3) = %v, %v", v, ok)
+	}
+	if _, ok := m.Get(xlpp.ChanDelay); ok {
+		t.Fatal("Get returned a marker")
+	}
+	if e := m.GetByType(xlpp.TypeTemperature); len(e) != 2 || e[1].Value != &temp2 {
+		t.Fatalf("GetByType: %v", e)
+	}
+	if c := m.Channels(); !reflect.DeepEqual(c, []int{3, 1}) {
+		t.Fatalf("Channels: %v", c)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	temp, hum := xlpp.Temperature(21.5), xlpp.RelativeHumidity(40)
+	d1, d2, zero := xlpp.Delay(time.Minute), xlpp.MilliDelay(500*time.Millisecond), xlpp.Delay(0)
+	prio := xlpp.PriorityAlarm
+	m := xlpp.Message{
+		{Channel: xlpp.ChanDelay, Value: &zero},
+		{Channel: 5, Value: &temp},
+		{Channel: 2, Value: &hum},
+		{Channel: xlpp.ChanDelay, Value: &d1},
+		{Channel: xlpp.ChanMilliDelay, Value: &d2},
+		{Channel: xlpp.ChanPriority, Value: &prio},
+		{Channel: 3, Value: &temp},
+		{Channel: 1, Value: &hum},
+		{Channel: xlpp.ChanDelay, Value: &d1},
+	}
+	c := m.Canonicalize()
+	var got []string
+	for _, e := range c {
+		got = append(got, fmt.Sprintf("%d:%v", e.Channel, e.Value))
+	}
+	want := []string{"254:alarm", "2:40.0 %", "5:21.50 °C", "250:1m0.5s", "1:40.0 %", "3:21.50 °C"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("canonical form:\n%v\nwant\n%v", got, want)
+	}
+	if m[1].Channel != 5 {
+		t.Fatal("Canonicalize modified the message")
+	}
+}
+
+func TestCanonicalWriter(t *testing.T) {
+	temp, hum := xlpp.Temperature(21.5), xlpp.RelativeHumidity(40)
+	d1, d2, d3 := xlpp.Delay(time.Minute), xlpp.Delay(time.Minute), xlpp.Delay(2*time.Minute)
+	obj := xlpp.Object{"b": &temp, "a": &hum}
+	encode := func(entries ...xlpp.Entry) []byte {
+		var buf bytes.Buffer
+		w := xlpp.NewWriter(&buf, xlpp.WithCanonicalEncoding())
+		for _, e := range entries {
+			if n, err := w.Add(e.Channel, e.Value); n != 0 || err != nil {
+				t.Fatal(n, err)
+			}
+		}
+		if _, err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	a := encode(xlpp.Entry{Channel: 2, Value: &hum}, xlpp.Entry{Channel: 1, Value: &temp}, xlpp.Entry{Channel: 0, Value: &d1}, xlpp.Entry{Channel: 0, Value: &d2}, xlpp.Entry{Channel: 3, Value: &obj})
+	b := encode(xlpp.Entry{Channel: 1, Value: &temp}, xlpp.Entry{Channel: 2, Value: &hum}, xlpp.Entry{Channel: xlpp.ChanDelay, Value: &d3}, xlpp.Entry{Channel: 3, Value: &obj})
+	if !bytes.Equal(a, b) {
+		t.Fatalf("canonical encodings differ:\n%x\n%x", a, b)
+	}
+	if _, err := xlpp.NewWriter(ioutil.Discard, xlpp.WithCanonicalEncoding()).AddObject(1); err == nil {
+		t.Fatal("expected error for streamed Object")
+	}
+}
+
+func TestDecodeToAny(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	temp := xlpp.Temperature(21.5)
+	ts := xlpp.UnixTime(time.Unix(1700000000, 0))
+	on := xlpp.Bool(true)
+	obj := xlpp.Object{"on": &on, "list": &xlpp.Array{&temp, &xlpp.Null{}}}
+	w.Add(3, &temp)
+	w.Add(1, &ts)
+	w.Add(2, &obj)
+	tree, err := xlpp.DecodeToAny(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"temperature3": 21.5,
+		"unixtime1":    time.Unix(1700000000, 0),
+		"object2":      map[string]interface{}{"on": true, "list": []interface{}{21.5, nil}},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Fatalf("DecodeToAny:\n%#v\nwant\n%#v", tree, want)
+	}
+}
+
+func TestUniqueChannels(t *testing.T) {
+	temp, hum := xlpp.Temperature(21.5), xlpp.RelativeHumidity(40)
+	yes, no := xlpp.Bool(true), xlpp.Bool(false)
+	w := xlpp.NewWriter(ioutil.Discard, xlpp.WithUniqueChannels(nil))
+	for _, e := range []xlpp.Entry{{Channel: 1, Value: &temp}, {Channel: 1, Value: &temp}, {Channel: 2, Value: &yes}, {Channel: 2, Value: &no}} {
+		if _, err := w.Add(e.Channel, e.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Add(1, &hum); !errors.Is(err, xlpp.ErrChannelReused) {
+		t.Fatalf("expected ErrChannelReused, got %v", err)
+	}
+	if _, err := w.AddArray(2); !errors.Is(err, xlpp.ErrChannelReused) {
+		t.Fatalf("expected ErrChannelReused, got %v", err)
+	}
+
+	var warnings []string
+	w = xlpp.NewWriter(ioutil.Discard, xlpp.WithUniqueChannels(func(channel int, old, new xlpp.Type) {
+		warnings = append(warnings, fmt.Sprintf("%d: %s -> %s", channel, old.Name(), new.Name()))
+	}))
+	w.Add(1, &temp)
+	if _, err := w.Add(1, &hum); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(warnings, []string{"1: temperature -> relativehumidity"}) {
+		t.Fatalf("warnings: %v", warnings)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	temp, hum := xlpp.Temperature(21.5), xlpp.RelativeHumidity(40)
+	str := xlpp.String("secret")
+	w.Add(1, &temp)
+	w.Add(2, &str)
+	w.Add(3, &hum)
+
+	for _, r := range []func(opts ...xlpp.ReaderOption) *xlpp.Reader{
+		func(opts ...xlpp.ReaderOption) *xlpp.Reader { return xlpp.NewBytesReader(buf.Bytes(), opts...) },
+		func(opts ...xlpp.ReaderOption) *xlpp.Reader {
+			return xlpp.NewReader(bytes.NewReader(buf.Bytes()), opts...)
+		},
+	} {
+		var raw []string
+		audit := func(channel int, typ xlpp.Type, v xlpp.Value, b []byte) (xlpp.Value, error) {
+			raw = append(raw, fmt.Sprintf("%x", b))
+			return v, nil
+		}
+		redact := func(channel int, typ xlpp.Type, v xlpp.Value, b []byte) (xlpp.Value, error) {
+			if typ == xlpp.TypeString {
+				return nil, nil
+			}
+			return v, nil
+		}
+		m, err := r(xlpp.WithHook(audit), xlpp.WithHook(redact)).ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m) != 2 || m[1].Channel != 3 {
+			t.Fatalf("message: %v", m)
+		}
+		if want := []string{"016700d7", "023473656372657400", "036850"}; !reflect.DeepEqual(raw, want) {
+			t.Fatalf("raw: %v", raw)
+		}
+	}
+
+	fail := errors.New("rejected")
+	_, err := xlpp.NewBytesReader(buf.Bytes(), xlpp.WithHook(func(int, xlpp.Type, xlpp.Value, []byte) (xlpp.Value, error) {
+		return nil, fail
+	})).ReadMessage()
+	if err != fail {
+		t.Fatalf("expected hook error, got %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	wm := xlpp.NewExpvarMetrics("xlpp_test_encoder")
+	rm := xlpp.NewExpvarMetrics("xlpp_test_decoder")
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf, xlpp.WithWriterMetrics(wm))
+	temp := xlpp.Temperature(21.5)
+	delay := xlpp.Delay(time.Minute)
+	w.Add(1, &temp)
+	w.Add(0, &delay)
+	w.Add(2, &temp)
+	if wm.Bytes.Value() != int64(buf.Len()) || wm.Entries.Get("temperature").String() != "2" {
+		t.Fatalf("writer metrics: %v", wm.Entries.String())
+	}
+
+	r := xlpp.NewBytesReader(buf.Bytes()[:buf.Len()-1], xlpp.WithMetrics(rm))
+	if _, err := r.ReadMessage(); err == nil {
+		t.Fatal("expected error for truncated payload")
+	}
+	if rm.Entries.Get("temperature").String() != "1" || rm.Entries.Get("marker").String() != "1" || rm.Bytes.Value() != 8 {
+		t.Fatalf("reader metrics: %v, %d bytes", rm.Entries.String(), rm.Bytes.Value())
+	}
+	if rm.Errors.Get("truncated").String() != "1" {
+		t.Fatalf("reader errors: %v", rm.Errors.String())
+	}
+}
+
+type testLogger []string
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	temp := xlpp.Temperature(21.5)
+	xlpp.NewWriter(&buf).Add(1, &temp)
+	var l testLogger
+	if err := xlpp.NewBytesReader(buf.Bytes(), xlpp.WithLogger(&l)).Print(); err != nil {
+		t.Fatal(err)
+	}
+	if want := (testLogger{"chan | value", "1     21.50 °C", "end (1 values)"}); !reflect.DeepEqual(l, want) {
+		t.Fatalf("logged %q", l)
+	}
+	s, err := xlpp.NewBytesReader(buf.Bytes(), xlpp.WithLogger(xlpp.NopLogger)).Sprint()
+	if err != nil || s != "chan | value\n1     21.50 °C\nend (1 values)\n" {
+		t.Fatalf("Sprint: %q, %v", s, err)
+	}
+	l = nil
+	if err := (xlpp.Formatter{Precision: 1, DecimalSeparator: ","}).Print(&l, xlpp.NewBytesReader(buf.Bytes())); err != nil || l[1] != "1     21,5 °C" {
+		t.Fatalf("Formatter.Print: %q, %v", l, err)
+	}
+
+	// a single value does not compress
+	l = nil
+	w := xlpp.NewWriter(&buf, xlpp.WithWriterCompression(), xlpp.WithWriterLogger(&l))
+	w.Add(1, &temp)
+	if _, err := w.Flush(); err != nil || len(l) != 1 || !strings.Contains(l[0], "uncompressed") {
+		t.Fatalf("Writer logged %q, %v", l, err)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	var f xlpp.Flags
+	f.Set(0, true)
+	f.Set(9, true)
+	f.Set(3, true)
+	f.Set(3, false)
+	if !f.Get(0) || f.Get(3) || !f.Get(9) || f.Get(64) || f.Count() != 2 {
+		t.Fatalf("flags %v", f)
+	}
+	if !reflect.DeepEqual(f.Indices(), []int{0, 9}) {
+		t.Fatalf("indices %v", f.Indices())
+	}
+	var g xlpp.Flags
+	if err := json.Unmarshal([]byte("513"), &g); err != nil || g != f {
+		t.Fatalf("unmarshal number: %v %v", g, err)
+	}
+	if err := json.Unmarshal([]byte("[64]"), &g); err == nil {
+		t.Fatal("unmarshal [64]: no error")
+	}
+}
+
+func TestCompactArrays(t *testing.T) {
+	t1, t2, t3 := xlpp.Temperature(21.5), xlpp.Temperature(21.6), xlpp.Temperature(21.7)
+	temps := xlpp.Array{&t1, &t2, &t3}
+	mixed := xlpp.Array{&t1, &integer}
+	nested := xlpp.Object{"temps": &temps, "mixed": &mixed, "nulls": &xlpp.Array{&null, &null}}
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf, xlpp.WithCompactArrays())
+	w.Add(1, &temps)
+	w.Add(2, &nested)
+	if got, want := fmt.Sprintf("%x", buf.Bytes()[:10]), "015c670300d700d800d9"; got != want {
+		t.Fatalf("compact array: %s, want %s", got, want)
+	}
+	var plain bytes.Buffer
+	xlpp.NewWriter(&plain).Add(2, &nested)
+	if plain.Len()-(buf.Len()-10) != 2 {
+		t.Fatalf("compact object: %d bytes, plain %d bytes", buf.Len()-10, plain.Len())
+	}
+	m, err := xlpp.NewReader(&buf).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := xlpp.Message{{Channel: 1, Value: &temps}, {Channel: 2, Value: &nested}}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("decoded %v, want %v", m, want)
+	}
+	if temps[0] != &t1 {
+		t.Fatal("WithCompactArrays modified the Array")
+	}
+
+	for _, data := range [][]byte{
+		{1, byte(xlpp.TypeArrayOf), byte(xlpp.TypeNull), 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{1, byte(xlpp.TypeArrayOf), byte(xlpp.TypeTemperature), 0xff, 0xff, 0xff, 0xff, 0x0f},
+		{1, byte(xlpp.TypeArrayOf), byte(xlpp.TypeTemperature), 2, 0, 0xd7},
+	} {
+		if _, err := xlpp.NewReader(bytes.NewReader(data)).ReadMessage(); err == nil {
+			t.Errorf("%x: no error", data)
+		}
+	}
+}
+
+// vendorValue is a custom type with one byte of data.
+type vendorValue uint8
+
+func (v vendorValue) XLPPType() xlpp.Type { return xlpp.TypePrivateMin + 1 }
+
+func (v vendorValue) String() string { return fmt.Sprintf("vendor %d", uint8(v)) }
+
+func (v *vendorValue) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [1]byte
+	m, err := io.ReadFull(r, b[:])
+	*v = vendorValue(b[0])
+	return int64(m), err
+}
+
+func (v vendorValue) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := w.Write([]byte{byte(v)})
+	return int64(m), err
+}
+
+// TestRegistryConcurrency registers types while decoding, run it with -race.
+func TestRegistryConcurrency(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			xlpp.RegisterType(xlpp.TypePrivateMax, "concurrent", func() xlpp.Value { return new(vendorValue) })
+			xlpp.UnregisterType(xlpp.TypePrivateMax)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		xlpp.NewBytesReader([]byte{1, byte(xlpp.TypeTemperature), 0, 1, 2, byte(xlpp.TypePrivateMax), 0}).ReadMessage()
+		xlpp.UnmarshalJSON([]byte(`{"concurrent1":1,"temperature2":1}`))
+		xlpp.TypePrivateMax.Name()
+	}
+	wg.Wait()
+}
+
+func TestRegisterType(t *testing.T) {
+	const typ = xlpp.TypePrivateMin + 1
+	factory := func() xlpp.Value { return new(vendorValue) }
+	if err := xlpp.RegisterType(typ, "vendor", factory); err != nil {
+		t.Fatal(err)
+	}
+	if err := xlpp.RegisterType(typ, "vendortwo", factory); !errors.Is(err, xlpp.ErrTypeRegistered) {
+		t.Fatalf("same type: %v", err)
+	}
+	if err := xlpp.RegisterType(typ+1, "temperature", factory); !errors.Is(err, xlpp.ErrTypeRegistered) {
+		t.Fatalf("same name: %v", err)
+	}
+	if err := xlpp.UnregisterType(xlpp.TypeTemperature); err == nil {
+		t.Fatal("unregistered a built-in type")
+	}
+	for _, typ := range []xlpp.Type{100, xlpp.TypePrivateMin - 1, xlpp.TypePrivateMax + 1} {
+		if err := xlpp.RegisterType(typ, "outofrange", factory); err == nil {
+			t.Fatalf("registered type %d outside the private range", typ)
+		}
+	}
+	for _, name := range []string{"", "vendor2", "Vendor", "vendor_x"} {
+		if err := xlpp.RegisterType(typ+1, name, factory); err == nil {
+			t.Fatalf("registered the invalid name %q", name)
+		}
+	}
+
+	v := vendorValue(42)
+	var buf bytes.Buffer
+	xlpp.NewWriter(&buf).Add(3, &v)
+	m, err := xlpp.NewReader(&buf).ReadMessage()
+	if err != nil || len(m) != 1 || *m[0].Value.(*vendorValue) != v || xlpp.NameOf(m[0].Value) != "vendor" {
+		t.Fatalf("decoded %v %v", m, err)
+	}
+
+	if err := xlpp.UnregisterType(typ); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := xlpp.RegistryByName["vendor"]; ok || typ.Name() != "" {
+		t.Fatal("type still registered")
+	}
+}
+
+// vendorValue16 is a custom type that uses the type of vendorValue for two bytes of data.
+type vendorValue16 uint16
+
+func (v vendorValue16) XLPPType() xlpp.Type { return xlpp.TypePrivateMin + 1 }
+
+func (v vendorValue16) String() string { return fmt.Sprintf("vendor16 %d", uint16(v)) }
+
+func (v *vendorValue16) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [2]byte
+	m, err := io.ReadFull(r, b[:])
+	*v = vendorValue16(b[0])<<8 | vendorValue16(b[1])
+	return int64(m), err
+}
+
+func (v vendorValue16) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := w.Write([]byte{byte(v >> 8), byte(v)})
+	return int64(m), err
+}
+
+func TestTypeRegistry(t *testing.T) {
+	const typ = xlpp.TypePrivateMin + 1
+	familyA, familyB := xlpp.NewTypeRegistry(), xlpp.NewTypeRegistry()
+	familyA.Register(typ, func() xlpp.Value { return new(vendorValue) })
+	familyB.Register(typ, func() xlpp.Value { return new(vendorValue16) })
+
+	a, b := vendorValue(1), vendorValue16(0x0203)
+	var bufA, bufB bytes.Buffer
+	if _, err := xlpp.NewWriter(&bufA, xlpp.WithWriterTypeRegistry(familyA)).Add(1, &xlpp.Array{&a, &temperature}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xlpp.NewWriter(&bufB, xlpp.WithWriterTypeRegistry(familyA)).Add(1, &b); err == nil {
+		t.Fatal("family A Writer wrote a family B value")
+	}
+	if _, err := xlpp.NewWriter(&bufB, xlpp.WithWriterTypeRegistry(familyB)).Add(1, &b); err != nil {
+		t.Fatal(err)
+	}
+
+	mA, err := xlpp.NewReader(&bufA, xlpp.WithTypeRegistry(familyA), xlpp.WithPool()).ReadMessage()
+	if err != nil || len(mA) != 1 || !reflect.DeepEqual(mA[0].Value, &xlpp.Array{&a, &temperature}) {
+		t.Fatalf("family A: %v %v", mA, err)
+	}
+	mB, err := xlpp.NewReader(&bufB, xlpp.WithTypeRegistry(familyB)).ReadMessage()
+	if err != nil || len(mB) != 1 || *mB[0].Value.(*vendorValue16) != b {
+		t.Fatalf("family B: %v %v", mB, err)
+	}
+	if _, ok := xlpp.Registry[typ]; ok {
+		t.Fatal("TypeRegistry modified the global Registry")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type station struct {
+		Temperature float64    `xlpp:"temperature,channel=1"`
+		Humidity    *float32   `xlpp:"relativehumidity,channel=2"`
+		Position    xlpp.GPS   `xlpp:",channel=3"`
+		Battery     int        `xlpp:"percentage,channel=4,omitempty"`
+		Open        bool       `xlpp:"switch,channel=5"`
+		Name        string     `xlpp:"string,channel=6"`
+		Time        time.Time  `xlpp:"unixtime,channel=7"`
+		Extra       xlpp.Value `xlpp:",channel=8"`
+		Ignored     int
+	}
+	humidity := float32(55.5)
+	in := station{
+		Temperature: 21.5,
+		Humidity:    &humidity,
+		Position:    gps,
+		Open:        true,
+		Name:        "station",
+		Time:        time.Unix(1600000000, 0),
+		Extra:       &integer,
+		Ignored:     7,
+	}
+	data, err := xlpp.Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := xlpp.NewBytesReader(data).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 7 || xlpp.NameOf(m[0].Value) != "temperature" || m[6].Channel != 8 {
+		t.Fatalf("message %v", m)
+	}
+	var out station
+	if err := xlpp.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Ignored = 0
+	if !out.Time.Equal(in.Time) {
+		t.Fatalf("time %v, want %v", out.Time, in.Time)
+	}
+	out.Time = in.Time
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("unmarshaled %+v, want %+v", out, in)
+	}
+
+	if _, err := xlpp.Marshal(struct {
+		N int `xlpp:"string,channel=1"`
+	}{}); err == nil {
+		t.Fatal("marshaled int as string")
+	}
+	var wrongType struct {
+		T float64 `xlpp:"voltage,channel=1"`
+	}
+	if err := xlpp.Unmarshal(data, &wrongType); err == nil {
+		t.Fatal("unmarshaled temperature as voltage")
+	}
+	if err := xlpp.Unmarshal(data, wrongType); err == nil {
+		t.Fatal("unmarshaled into non-pointer")
+	}
+	var unexported struct {
+		t float64 `xlpp:"temperature,channel=1"`
+	}
+	if _, err := xlpp.Marshal(unexported); err == nil {
+		t.Fatal("marshaled unexported field")
+	}
+	if err := xlpp.Unmarshal(data, &unexported); err == nil {
+		t.Fatal("unmarshaled unexported field")
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	values := map[int]xlpp.Value{3: &temperature, 1: &str, 2: &gps}
+	data, err := xlpp.Encode(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := xlpp.NewBytesReader(data).ReadMessage()
+	if err != nil || len(m) != 3 || m[0].Channel != 1 || m[2].Channel != 3 {
+		t.Fatalf("encoded %v %v", m, err)
+	}
+	decoded, err := xlpp.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Fatalf("decoded %v, want %v", decoded, values)
+	}
+	if _, err := xlpp.Encode(map[int]xlpp.Value{xlpp.ChanDelay: &delay}); err == nil {
+		t.Fatal("encoded a marker")
+	}
+	if _, err := xlpp.Encode(map[int]xlpp.Value{256: &temperature}); err == nil {
+		t.Fatal("encoded channel 256")
+	}
+}
+
+func TestMessageFrame(t *testing.T) {
+	t1, t2 := xlpp.Temperature(20), xlpp.Temperature(21)
+	m := xlpp.Message{
+		{Channel: 1, Value: &t1},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 1, Value: &t2},
+		{Channel: 2, Value: &str},
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded xlpp.Message
+	if err := decoded.UnmarshalBinary(data); err != nil || !reflect.DeepEqual(decoded, m) {
+		t.Fatalf("decoded %v %v", decoded, err)
+	}
+	if decoded.Len() != 3 {
+		t.Fatalf("Len %d", decoded.Len())
+	}
+
+	decoded.Set(2, &integer)
+	decoded.Set(3, &boolean)
+	decoded.Delete(1)
+	var channels []int
+	decoded.Range(func(channel int, v xlpp.Value) bool {
+		channels = append(channels, channel)
+		return true
+	})
+	if !reflect.DeepEqual(channels, []int{2, 3}) || len(decoded) != 3 {
+		t.Fatalf("channels %v, message %v", channels, decoded)
+	}
+	if v, _ := decoded.Get(2); v != &integer {
+		t.Fatalf("Set: %v", v)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	temp := xlpp.Temperature(23.5)
+	on := xlpp.Switch(true)
+	m := xlpp.Message{{Channel: 5, Value: &temp}, {Channel: 1, Value: &on}}
+
+	data, err := xlpp.MarshalJSON(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"switch1":true,"temperature5":23.5}` {
+		t.Fatalf("MarshalJSON: %s", data)
+	}
+	data, err = xlpp.MarshalJSONUnits(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"switch1":true,"temperature5":{"value":23.5,"unit":"°C"}}` {
+		t.Fatalf("MarshalJSONUnits: %s", data)
+	}
+
+	decoded, err := xlpp.UnmarshalJSON([]byte(`{"temperature5":23.5,"switch1":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0].Channel != 1 || decoded[1].Channel != 5 {
+		t.Fatalf("UnmarshalJSON: %v", decoded)
+	}
+	if !reflect.DeepEqual(decoded[1].Value, &temp) {
+		t.Fatalf("UnmarshalJSON: %v", decoded[1].Value)
+	}
+
+	for _, bad := range []string{`{"temperature":1}`, `{"unknown1":1}`, `{"temperature300":1}`, `{"temperature1":"x"}`, `[]`} {
+		if _, err := xlpp.UnmarshalJSON([]byte(bad)); err == nil {
+			t.Fatalf("UnmarshalJSON(%s): no error", bad)
+		}
+	}
+}
+
+// TestGoldenJSON verifies that MarshalJSON and UnmarshalJSON round trip the golden vectors,
+// except for markers and the items of Objects and Arrays.
+func TestGoldenJSON(t *testing.T) {
+	for _, v := range xlpp.GoldenVectors {
+		payload, _ := hex.DecodeString(v.Payload)
+		m, err := xlpp.NewBytesReader(payload).ReadMessage()
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		data, err := xlpp.MarshalJSON(m)
+		if err != nil {
+			t.Fatalf("%s: %v", v.Name, err)
+		}
+		decoded, err := xlpp.UnmarshalJSON(data)
+		markers, items := false, false
+		for _, e := range m {
+			switch e.Value.(type) {
+			case xlpp.Marker:
+				markers = true
+			case *xlpp.Object, *xlpp.Array:
+				items = true
+			}
+		}
+		if items {
+			continue
+		}
+		if markers {
+			if !errors.Is(err, xlpp.ErrReservedChannel) {
+				t.Errorf("%s: %s: got %v, want ErrReservedChannel", v.Name, data, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s: %v", v.Name, data, err)
+			continue
+		}
+		if again, err := xlpp.MarshalJSON(decoded); err != nil || !bytes.Equal(again, data) {
+			t.Errorf("%s: %s became %s %v", v.Name, data, again, err)
+		}
+	}
+}
+
+// TestJSONNames verifies that the JSON key of every registered type is parsed back to the same type and channel.
+func TestJSONNames(t *testing.T) {
+	// values whose zero value has no JSON representation
+	values := map[xlpp.Type]xlpp.Value{xlpp.TypeUnixTime: &unixtime, xlpp.TypeScheduledCommand: &scheduledCommand}
+	for typ := 0; typ < 256; typ++ {
+		f := xlpp.LookupType(xlpp.Type(typ))
+		if f == nil || xlpp.Type(typ) == xlpp.TypeEndOfArray {
+			continue
+		}
+		v, ok := values[xlpp.Type(typ)]
+		if !ok {
+			v = f()
+		}
+		data, err := xlpp.MarshalJSON(xlpp.Message{{Channel: 5, Value: v}})
+		if err != nil {
+			t.Errorf("%s: %v", xlpp.NameOf(v), err)
+			continue
+		}
+		m, err := xlpp.UnmarshalJSON(data)
+		if err != nil {
+			t.Errorf("%s: %v", data, err)
+			continue
+		}
+		if len(m) != 1 || m[0].Channel != 5 || reflect.TypeOf(m[0].Value) != reflect.TypeOf(v) {
+			t.Errorf("%s: decoded as %v", data, m)
+		}
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema := xlpp.JSONSchema()
+	if schema["$schema"] != xlpp.JSONSchemaDraft {
+		t.Fatalf("$schema: %v", schema["$schema"])
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatal(err)
+	}
+	defs := schema["$defs"].(map[string]interface{})
+	for name := range xlpp.RegistryByName {
+		if defs[name] == nil {
+			t.Fatalf("no schema for %q", name)
+		}
+	}
+	temperature := defs["temperature"].(map[string]interface{})
+	if temperature["type"] != "number" || temperature["description"] != "[°C]" {
+		t.Fatalf("temperature: %v", temperature)
+	}
+	if defs["digitalinput"].(map[string]interface{})["maximum"] != uint64(255) {
+		t.Fatalf("digitalinput: %v", defs["digitalinput"])
+	}
+	if defs["percentage"].(map[string]interface{})["maximum"] != 100 {
+		t.Fatalf("percentage: %v", defs["percentage"])
+	}
+	gpsCompact := defs["gpscompact"].(map[string]interface{})["properties"].(map[string]interface{})
+	if gpsCompact["longitude"].(map[string]interface{})["maximum"] != 180 {
+		t.Fatalf("gpscompact: %v", gpsCompact)
+	}
+
+	var pattern string
+	for p := range schema["patternProperties"].(map[string]interface{}) {
+		if strings.HasPrefix(p, "^temperature") {
+			pattern = p
+		}
+	}
+	re := regexp.MustCompile(pattern)
+	for key, match := range map[string]bool{"temperature0": true, "temperature249": true, "temperature05": true, "temperature250": false, "temperature255": false, "temperature256": false, "temperature": false} {
+		if re.MatchString(key) != match {
+			t.Fatalf("%s matches %s: %v", pattern, key, !match)
+		}
+	}
+
+	if err := xlpp.RegisterType(xlpp.TypePrivateMin+1, "vendorschema", func() xlpp.Value { return new(vendorValue) }); err != nil {
+		t.Fatal(err)
+	}
+	defer xlpp.UnregisterType(xlpp.TypePrivateMin + 1)
+	if s := xlpp.JSONSchema()["$defs"].(map[string]interface{})["vendorschema"]; s == nil {
+		t.Fatal("no schema for custom type")
+	}
+}
+
+func TestHexString(t *testing.T) {
+	temp := xlpp.Temperature(23.5)
+	s, err := xlpp.EncodeToHexString(xlpp.Message{{Channel: 0, Value: &temp}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "006700eb" {
+		t.Fatalf("EncodeToHexString: %s", s)
+	}
+	for _, s := range []string{"006700eb", "00 67 00 EB", "0x006700EB\n"} {
+		m, err := xlpp.DecodeHexString(s)
+		if err != nil {
+			t.Fatalf("DecodeHexString(%q): %v", s, err)
+		}
+		if v, _ := m.Get(0); !reflect.DeepEqual(v, &temp) {
+			t.Fatalf("DecodeHexString(%q): %v", s, m)
+		}
+	}
+	if _, err := xlpp.DecodeHexString("0067zz"); err == nil {
+		t.Fatal("DecodeHexString: no error")
+	}
+	if data, err := xlpp.DecodeHex(" 0X00 67\n"); err != nil || !bytes.Equal(data, []byte{0, 0x67}) {
+		t.Fatalf("DecodeHex: %x, %v", data, err)
+	}
+}
+
+func TestStrictLPP(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf, xlpp.WithWriterStrictLPP())
+	temp, volt := xlpp.Temperature(21.5), xlpp.Voltage(3.3)
+	str, delay := xlpp.String("x"), xlpp.Delay(time.Minute)
+	if _, err := w.Add(xlpp.ChanDelay, &temp); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []xlpp.Value{&volt, &str, &delay} {
+		if _, err := w.Add(1, v); !errors.Is(err, xlpp.ErrStrictLPP) {
+			t.Fatalf("Add(%T): expected ErrStrictLPP, got %v", v, err)
+		}
+	}
+	if _, err := w.AddObject(1); !errors.Is(err, xlpp.ErrStrictLPP) {
+		t.Fatalf("AddObject: expected ErrStrictLPP, got %v", err)
+	}
+
+	// channel 253 is a plain channel in Cayenne LPP, not a Delay marker
+	m, err := xlpp.NewBytesReader(buf.Bytes(), xlpp.WithStrictLPP()).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.Get(xlpp.ChanDelay); !reflect.DeepEqual(v, &temp) {
+		t.Fatalf("decoded %v", m)
+	}
+	for _, payload := range []string{"017400f0", "fd3302"} {
+		data, _ := hex.DecodeString(payload)
+		if _, err := xlpp.NewBytesReader(data, xlpp.WithStrictLPP()).ReadMessage(); !errors.Is(err, xlpp.ErrStrictLPP) {
+			t.Fatalf("%s: expected ErrStrictLPP, got %v", payload, err)
+		}
+		if _, err := xlpp.NewBytesReader(data, xlpp.WithStrictLPP()).Token(); !errors.Is(err, xlpp.ErrStrictLPP) {
+			t.Fatalf("%s: Token: expected ErrStrictLPP, got %v", payload, err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	str, bin := xlpp.String("hello"), xlpp.Binary("hello")
+	w.Add(1, &str)
+	w.Add(2, &bin)
+	w.Add(3, &xlpp.Array{&xlpp.Array{&xlpp.Array{}}, &str})
+	payload := buf.Bytes()
+
+	for _, test := range []struct {
+		opt   xlpp.ReaderOption
+		limit string
+	}{
+		{xlpp.WithMaxStringLen(4), "MaxStringLen"},
+		{xlpp.WithMaxBinaryLen(4), "MaxBinaryLen"},
+		{xlpp.WithMaxValues(5), "MaxValues"},
+		{xlpp.WithMaxNestingDepth(2), "MaxNestingDepth"},
+		{xlpp.WithMaxStringLen(5), ""},
+		{xlpp.WithMaxBinaryLen(5), ""},
+		{xlpp.WithMaxValues(6), ""},
+		{xlpp.WithMaxNestingDepth(3), ""},
+	} {
+		for _, r := range []*xlpp.Reader{
+			xlpp.NewBytesReader(payload, test.opt),
+			xlpp.NewReader(iotest.OneByteReader(bytes.NewReader(payload)), test.opt),
+		} {
+			_, err := r.ReadMessage()
+			var e *xlpp.LimitError
+			if test.limit == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if !errors.As(err, &e) || e.Limit != test.limit || !errors.Is(err, xlpp.ErrTooLarge) {
+				t.Fatalf("expected %s LimitError, got %v", test.limit, err)
+			}
+		}
+	}
+
+	r := xlpp.NewBytesReader(payload, xlpp.WithMaxNestingDepth(2))
+	var err error
+	for err == nil {
+		_, err = r.Token()
+	}
+	if !errors.Is(err, xlpp.ErrTooLarge) {
+		t.Fatalf("Token: expected LimitError, got %v", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	nested := func(depth int) []byte {
+		return append(append([]byte{1}, bytes.Repeat([]byte{byte(xlpp.TypeArray)}, depth)...), bytes.Repeat([]byte{byte(xlpp.TypeEndOfArray)}, depth)...)
+	}
+	if _, err := xlpp.NewBytesReader(nested(xlpp.DefaultMaxNestingDepth)).ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xlpp.NewBytesReader(nested(xlpp.DefaultMaxNestingDepth + 1)).ReadMessage(); !errors.Is(err, xlpp.ErrMaxDepth) {
+		t.Fatalf("expected ErrMaxDepth, got %v", err)
+	}
+	if _, err := xlpp.NewBytesReader(nested(1000), xlpp.WithMaxNestingDepth(-1)).ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xlpp.NewBytesReader(nested(3), xlpp.WithMaxNestingDepth(2)).ReadMessage(); !errors.Is(err, xlpp.ErrMaxDepth) {
+		t.Fatalf("expected ErrMaxDepth, got %v", err)
+	}
+}
+
+func TestStructuredErrors(t *testing.T) {
+	for _, test := range []struct {
+		payload string
+		unknown xlpp.Type
+		offset  int64
+	}{
+		{"006700eb01ee", 0xee, 5},
+		{"005b6700ebee", 0xee, 5},
+		{"006700eb017b6b00ee", 0xee, 8},
+		{"006700eb016700", 0, 4},
+		{"006700eb5b6700", 0, 4},
+		{"0167", 0, 0},
+		{"013461", 0, 0},
+	} {
+		data, _ := hex.DecodeString(test.payload)
+		for _, r := range []*xlpp.Reader{xlpp.NewBytesReader(data), xlpp.NewReader(bytes.NewReader(data))} {
+			_, err := r.ReadMessage()
+			var u *xlpp.UnknownTypeError
+			var tr *xlpp.TruncatedError
+			switch {
+			case test.unknown != 0:
+				if !errors.As(err, &u) || u.Type != test.unknown || u.Offset != test.offset {
+					t.Fatalf("%s: expected unknown type at %d, got %v", test.payload, test.offset, err)
+				}
+			case !errors.As(err, &tr) || tr.Offset != test.offset || !errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+				t.Fatalf("%s: expected truncated entry at %d, got %v", test.payload, test.offset, err)
+			}
+		}
+
+		r := xlpp.NewBytesReader(data)
+		var err error
+		for err == nil {
+			_, err = r.Token()
+		}
+		var u *xlpp.UnknownTypeError
+		if test.unknown != 0 && (!errors.As(err, &u) || u.Offset != test.offset) {
+			t.Fatalf("%s: Token: expected unknown type at %d, got %v", test.payload, test.offset, err)
+		}
+	}
+
+	temp := xlpp.Temperature(21.5)
+	if _, err := xlpp.NewWriter(ioutil.Discard).Add(xlpp.ChanDelay, &temp); !errors.Is(err, xlpp.ErrReservedChannel) {
+		t.Fatalf("expected ErrReservedChannel, got %v", err)
+	}
+}
+
+func TestSkipUnknownTypes(t *testing.T) {
+	// temperature, generic sensor, array of temperature and generic sensor, voltage
+	data, _ := hex.DecodeString("016700eb02640000002a035b6700eb640000002b5d04740141")
+	if _, err := xlpp.NewBytesReader(data).ReadMessage(); err == nil {
+		t.Fatal("expected UnknownTypeError")
+	}
+	m, err := xlpp.NewBytesReader(data, xlpp.WithSkipUnknownTypes()).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 4 {
+		t.Fatalf("decoded %v", m)
+	}
+	if v, _ := m.Get(2); !reflect.DeepEqual(v, &xlpp.UnknownValue{Type: xlpp.TypeGenericSensor, Data: []byte{0, 0, 0, 42}}) {
+		t.Fatalf("skipped %v", v)
+	}
+	if v, _ := m.Get(3); len(*v.(*xlpp.Array)) != 2 {
+		t.Fatalf("array %v", v)
+	}
+	// UnknownValues are written as they were read
+	if out, err := m.MarshalBinary(); err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("MarshalBinary: %x %v", out, err)
+	}
+	if data, err := xlpp.MarshalJSON(m[:2]); err != nil || string(data) != `{"temperature1":23.5,"unknown2":{"Type":100,"Data":"AAAAKg=="}}` {
+		t.Fatalf("MarshalJSON: %s %v", data, err)
+	}
+
+	// types missing in the TypeRegistry are skipped, if they have a fixed size
+	reg := xlpp.NewTypeRegistry()
+	reg.Unregister(xlpp.TypeTemperature)
+	reg.Unregister(xlpp.TypeString)
+	data, _ = hex.DecodeString("016700eb02340000")
+	r := xlpp.NewBytesReader(data, xlpp.WithTypeRegistry(reg), xlpp.WithSkipUnknownTypes())
+	if _, v, err := r.Next(); err != nil || !reflect.DeepEqual(v, &xlpp.UnknownValue{Type: xlpp.TypeTemperature, Data: []byte{0, 0xeb}}) {
+		t.Fatalf("Next: %v %v", v, err)
+	}
+	var u *xlpp.UnknownTypeError
+	if _, _, err := r.Next(); !errors.As(err, &u) || u.Type != xlpp.TypeString {
+		t.Fatalf("expected UnknownTypeError for strings, got %v", err)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		typ := xlpp.Type(i)
+		if xlpp.Registry[typ] == nil {
+			continue
+		}
+		size, ok := xlpp.Sizes[typ]
+		if !ok {
+			t.Fatalf("no size for type %d (%s)", typ, typ.Name())
+		}
+		if size == xlpp.VariableSize {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := xlpp.Registry[typ]().WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != size {
+			t.Fatalf("type %d (%s): size %d, but zero value has %d bytes", typ, typ.Name(), size, buf.Len())
+		}
+	}
+}
+
+func TestTypeInfo(t *testing.T) {
+	want := xlpp.TypeMetadata{Type: xlpp.TypeTemperature, Name: "temperature", Unit: "°C", Resolution: 0.1, Signed: true, WireSize: 2}
+	if info := xlpp.TypeInfo(xlpp.TypeTemperature); info != want {
+		t.Fatalf("TypeInfo: %+v", info)
+	}
+	if info := xlpp.TypeInfo(xlpp.TypeSwitch); !info.IsActuator || info.WireSize != 1 {
+		t.Fatalf("TypeInfo: %+v", info)
+	}
+	if info := xlpp.TypeInfo(xlpp.TypeString); info.WireSize != xlpp.VariableSize {
+		t.Fatalf("TypeInfo: %+v", info)
+	}
+	list := xlpp.ListTypes()
+	if len(list) != len(xlpp.Registry)-1 {
+		t.Fatalf("ListTypes: %d types, Registry: %d", len(list), len(xlpp.Registry))
+	}
+	for i, info := range list {
+		if info.Name == "" || (i > 0 && list[i-1].Type >= info.Type) {
+			t.Fatalf("ListTypes: %+v", info)
+		}
+	}
+}
+
+func TestActuation(t *testing.T) {
+	declared := []xlpp.Value{
+		&xlpp.Actuators{xlpp.TypeColour},
+		&xlpp.ActuatorsWithChannel{{Channel: 3, Type: xlpp.TypeSwitch}, {Channel: 4, Type: xlpp.TypeBoolTrue}},
+	}
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf, xlpp.WithActuators(declared...))
+	on, off, colour := xlpp.Switch(true), xlpp.Bool(false), xlpp.Colour{R: 255}
+	ack := xlpp.ActuatorAck(7)
+	w.Add(xlpp.ChanActuatorAck, &ack)
+	for _, e := range []xlpp.Entry{{Channel: 3, Value: &on}, {Channel: 4, Value: &off}, {Channel: 9, Value: &colour}} {
+		if _, err := w.AddActuation(e.Channel, e.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Schedule(3, 20*time.Minute, &on); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.AddActuation(5, &on); !errors.Is(err, xlpp.ErrUndeclaredActuator) {
+		t.Fatalf("expected ErrUndeclaredActuator, got %v", err)
+	}
+	if _, err := w.AddActuation(5, &xlpp.Array{}); err == nil {
+		t.Fatal("expected error for Array command")
+	}
+
+	commands, err := xlpp.ParseDownlink(buf.Bytes(), declared...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []xlpp.Actuation{{Channel: 3, Value: &on}, {Channel: 4, Value: &off}, {Channel: 9, Value: &colour}, {Channel: 3, Value: &on, Delay: 20 * time.Minute}}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("ParseDownlink: %v", commands)
+	}
+	if _, err := xlpp.ParseDownlink(buf.Bytes(), &xlpp.Actuators{xlpp.TypeSwitch}); !errors.Is(err, xlpp.ErrUndeclaredActuator) {
+		t.Fatalf("expected ErrUndeclaredActuator, got %v", err)
+	}
+}
+
+func TestAddAt(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	t1, t2, t3 := xlpp.Temperature(21), xlpp.Temperature(20), xlpp.Temperature(19)
+	for _, e := range []struct {
+		t time.Time
+		v xlpp.Value
+	}{{now, &t1}, {now.Add(-90 * time.Minute), &t2}, {now.Add(-300*time.Hour - 1500*time.Millisecond), &t3}} {
+		if _, err := w.AddAt(e.t, 1, e.v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.AddAt(now, 1, &t1); err != xlpp.ErrTimestampOrder {
+		t.Fatalf("expected ErrTimestampOrder, got %v", err)
+	}
+	m, err := xlpp.NewReader(bytes.NewReader(buf.Bytes())).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delay time.Duration
+	var delays []time.Duration
+	for _, e := range m {
+		switch v := e.Value.(type) {
+		case *xlpp.Delay:
+			if time.Duration(*v) > xlpp.MaxDelay {
+				t.Fatalf("Delay %v exceeds MaxDelay", *v)
+			}
+			delay += time.Duration(*v)
+		case *xlpp.MilliDelay:
+			delay += time.Duration(*v)
+		default:
+			delays = append(delays, delay)
+		}
+	}
+	want := []time.Duration{0, 90 * time.Minute, 300*time.Hour + 1500*time.Millisecond}
+	if !reflect.DeepEqual(delays, want) {
+		t.Fatalf("delays: %v, message: %v", delays, m)
+	}
+}
+
+func TestTimedReader(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf)
+	t1, t2 := xlpp.Temperature(21), xlpp.Temperature(20)
+	w.SetPriority(xlpp.PriorityAlarm)
+	w.Add(1, &t1)
+	w.AddAt(received, 2, &t1)
+	w.AddAt(received.Add(-90*time.Minute-250*time.Millisecond), 1, &t2)
+	tr := xlpp.NewTimedReader(xlpp.NewReader(&buf), received)
+	timed, err := tr.ReadSamples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := received.Add(-90*time.Minute - 250*time.Millisecond)
+	want := []xlpp.Sample{{received, 1, &t1}, {received, 2, &t1}, {at, 1, &t2}}
+	if !reflect.DeepEqual(timed, want) {
+		t.Fatalf("ReadSamples: %v", timed)
+	}
+	if d := tr.Delay(); d != 90*time.Minute+250*time.Millisecond {
+		t.Fatalf("Delay: %v", d)
+	}
+
+	// Samples are expanded to one Sample per reading, by the TimedReader and by Message.Timed
+	buf.Reset()
+	w = xlpp.NewWriter(&buf)
+	w.Add(3, &samples)
+	m, err := xlpp.NewBytesReader(buf.Bytes()).ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	readings, err := xlpp.NewTimedReader(xlpp.NewBytesReader(buf.Bytes()), received).ReadSamples()
+	if err != nil || len(readings) != 3 || !readings[0].Time.Equal(received.Add(-2*time.Minute)) || *readings[2].Value.(*xlpp.Temperature) != 21.6 {
+		t.Fatalf("Samples: %v, %v", readings, err)
+	}
+	if timed := m.Timed(received); !reflect.DeepEqual(timed, readings) {
+		t.Fatalf("Timed: %v, TimedReader: %v", timed, readings)
+	}
+}
+
+func TestCRC(t *testing.T) {
+	if crc := xlpp.CRC16([]byte("123456789")); crc != 0x29b1 {
+		t.Fatalf("CRC16: %04x", crc)
+	}
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf, xlpp.WithWriterCRC())
+	temp := xlpp.Temperature(23.5)
+	w.Add(1, &temp)
+	if n, err := w.Flush(); n != xlpp.CRCSize || err != nil {
+		t.Fatalf("Flush: %d, %v", n, err)
+	}
+	data := buf.Bytes()
+	if len(data) != 4+xlpp.CRCSize {
+		t.Fatalf("payload %x", data)
+	}
+	want := xlpp.Message{{Channel: 1, Value: &temp}}
+	for _, r := range []*xlpp.Reader{
+		xlpp.NewReader(bytes.NewReader(data), xlpp.WithCRC()),
+		xlpp.NewBytesReader(data, xlpp.WithCRC()),
+	} {
+		if m, err := r.ReadMessage(); err != nil || !reflect.DeepEqual(m, want) {
+			t.Fatalf("ReadMessage: %v, %v", m, err)
+		}
+	}
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[2] ^= 0x10
+	r := xlpp.NewBytesReader(corrupted, xlpp.WithCRC())
+	if _, err := r.ReadMessage(); err != xlpp.ErrCRC {
+		t.Fatalf("expected ErrCRC, got %v", err)
+	}
+	r.ResetBytes(data)
+	if m, err := r.ReadMessage(); err != nil || !reflect.DeepEqual(m, want) {
+		t.Fatalf("ReadMessage: %v, %v", m, err)
+	}
+	r.ResetBytes(data[:1])
+	if _, err := r.ReadMessage(); err != xlpp.ErrCRC {
+		t.Fatalf("expected ErrCRC, got %v", err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	var buf bytes.Buffer
+	w := xlpp.NewWriter(&buf, xlpp.WithWriterCompression(), xlpp.WithWriterCRC())
+	var m xlpp.Message
+	for i := 0; i < 40; i++ {
+		temp, delay := xlpp.Temperature(20+float64(i%3)/10), xlpp.Delay(10*time.Minute)
+		m = append(m, xlpp.Entry{Channel: 1, Value: &temp}, xlpp.Entry{Channel: xlpp.ChanDelay, Value: &delay})
+	}
+	plain, _ := m.MarshalBinary()
+	for _, e := range m {
+		if _, err := w.Add(e.Channel, e.Value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("%d bytes written before Flush", buf.Len())
+	}
+	if _, err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if data[0] != xlpp.ChanCompressed || len(data) >= len(plain) {
+		t.Fatalf("compressed %d bytes to %x", len(plain), data)
+	}
+	decoded, err := xlpp.NewBytesReader(data, xlpp.WithCRC(), xlpp.WithDecompression()).ReadMessage()
+	if err != nil || !reflect.DeepEqual(decoded, m) {
+		t.Fatalf("ReadMessage: %v, %v", decoded, err)
+	}
+	r := xlpp.NewReader(bytes.NewReader(data[:len(data)-xlpp.CRCSize]), xlpp.WithDecompression())
+	var tokens int
+	for {
+		tok, err := r.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || tok.Channel == xlpp.ChanCompressed {
+			t.Fatalf("Token: %v, %v", tok, err)
+		}
+		tokens++
+	}
+	if tokens != len(m) {
+		t.Fatalf("%d tokens", tokens)
+	}
+
+	// small payloads are not compressed
+	buf.Reset()
+	w = xlpp.NewWriter(&buf, xlpp.WithWriterCompression())
+	temp := xlpp.Temperature(23.5)
+	w.Add(1, &temp)
+	w.Flush()
+	if !bytes.Equal(buf.Bytes(), []byte{1, 103, 0, 235}) {
+		t.Fatalf("payload %x", buf.Bytes())
+	}
+	if _, err := w.Add(xlpp.ChanCompressed, &temp); !errors.Is(err, xlpp.ErrReservedChannel) {
+		t.Fatalf("expected ErrReservedChannel, got %v", err)
+	}
+
+	// channel 248 is only a compressed frame at the start of the payload
+	if m, err := xlpp.NewBytesReader([]byte{1, 103, 0, 235, xlpp.ChanCompressed, 103, 0, 235}, xlpp.WithDecompression()).ReadMessage(); err != nil || len(m) != 2 {
+		t.Fatalf("ReadMessage: %v, %v", m, err)
+	}
+	if _, err := xlpp.NewBytesReader(data[:len(data)/2], xlpp.WithDecompression()).ReadMessage(); err == nil {
+		t.Fatal("expected error for truncated compressed frame")
+	}
+	var bomb bytes.Buffer
+	fw := xlpp.NewWriter(&bomb, xlpp.WithWriterCompression())
+	big := xlpp.Binary(make([]byte, xlpp.MaxDecompressedSize))
+	fw.Add(1, &big)
+	fw.Flush()
+	if _, err := xlpp.NewBytesReader(bomb.Bytes(), xlpp.WithDecompression()).ReadMessage(); !errors.Is(err, xlpp.ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestFreeChannels(t *testing.T) {
+	// channels 0 to 249 are free channels, markers use the reserved channels 250 to 255
+	temp := xlpp.Temperature(23.5)
+	for _, channel := range []int{248, 249} {
+		var buf bytes.Buffer
+		if _, err := xlpp.NewWriter(&buf).Add(channel, &temp); err != nil {
+			t.Fatalf("channel %d: %v", channel, err)
+		}
+		m, err := xlpp.NewBytesReader(buf.Bytes()).ReadMessage()
+		if err != nil || !reflect.DeepEqual(m, xlpp.Message{{Channel: channel, Value: &temp}}) {
+			t.Fatalf("channel %d: %v, %v", channel, m, err)
+		}
+	}
+}
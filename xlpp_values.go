@@ -1,9 +1,15 @@
 package xlpp
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
 	"sort"
 	"strings"
 	"time"
@@ -20,11 +26,11 @@ const (
 	TypeObject      Type = 123 // '{'
 	TypeEndOfObject Type = 0   // '}'
 	TypeArray       Type = 91  // '['
-	// TypeArrayOf     Type = 92  // '['
-	TypeEndOfArray Type = 93 // '['
-	TypeFlags      Type = 56
-	TypeBinary     Type = 57
-	TypeNull       Type = 58
+	TypeArrayOf     Type = 92  // '\\'
+	TypeEndOfArray  Type = 93  // ']'
+	TypeFlags       Type = 56
+	TypeBinary      Type = 57
+	TypeNull        Type = 58
 )
 
 // Special (reserved) channels for "Marker" types:
@@ -33,6 +39,9 @@ const (
 	ChanDelay                = 253
 	ChanActuators            = 252
 	ChanActuatorsWithChannel = 251
+	ChanMilliDelay           = 250
+	ChanPriority             = 254
+	ChanActuatorAck          = 255
 )
 
 // Null is a empty type. It holds no data.
@@ -76,22 +85,47 @@ func (v *Binary) ReadFrom(r io.Reader) (n int64, err error) {
 	var brc byteReaderCounter
 	brc.ByteReader = newByteReader(r)
 	l, err := binary.ReadUvarint(&brc)
+	if err == nil {
+		err = checkBinaryLen(r, l)
+	}
+	if err == nil {
+		err = checkLength(r, l)
+	}
 	if err != nil {
 		return int64(brc.Count), err
 	}
-	*v = make(Binary, l)
+	if l > binaryChunkSize {
+		// the length comes from the wire: do not allocate more than we actually read
+		var buf bytes.Buffer
+		var m int64
+		m, err = io.CopyN(&buf, r, int64(l))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		*v = buf.Bytes()
+		return int64(brc.Count) + m, err
+	}
+	if alloc := allocator(r); alloc != nil {
+		*v = alloc.Bytes(int(l))
+	} else if pooled(r) && uint64(cap(*v)) >= l {
+		*v = (*v)[:l]
+	} else {
+		*v = make(Binary, l)
+	}
 	var m int
 	m, err = io.ReadFull(r, *v)
 	return int64(brc.Count + m), err
 }
 
+// binaryChunkSize is the max. Binary length that is allocated before reading the data.
+const binaryChunkSize = 4096
+
 // WriteTo writes the Binary to the writer.
 func (v Binary) WriteTo(w io.Writer) (n int64, err error) {
 	var buf [9]byte
 	var m int
 	m = binary.PutUvarint(buf[:], uint64(len(v)))
-	n += int64(m)
-	m, err = w.Write(buf[:m])
+	m, err = writeTo(w, buf[:m])
 	n += int64(m)
 	if err == nil {
 		m, err = w.Write(v)
@@ -102,6 +136,96 @@ func (v Binary) WriteTo(w io.Writer) (n int64, err error) {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// Flags is a bitfield of up to 64 flags, e.g. the status bits of a device.
+// Flag i is bit i: it is encoded as uvarint, so that 7 flags take 1 byte and 14 flags 2 bytes.
+type Flags uint64
+
+// XLPPType for Flags returns TypeFlags.
+func (v Flags) XLPPType() Type {
+	return TypeFlags
+}
+
+func (v Flags) String() string {
+	return fmt.Sprintf("%b", uint64(v))
+}
+
+// Get returns flag i.
+func (v Flags) Get(i int) bool {
+	return i >= 0 && i < 64 && v&(1<<uint(i)) != 0
+}
+
+// Set sets flag i to b. It panics if i is not in [0, 63].
+func (v *Flags) Set(i int, b bool) {
+	if i < 0 || i >= 64 {
+		panic("xlpp: flag index out of range")
+	}
+	if b {
+		*v |= 1 << uint(i)
+	} else {
+		*v &^= 1 << uint(i)
+	}
+}
+
+// Count returns the number of flags that are set.
+func (v Flags) Count() int {
+	return bits.OnesCount64(uint64(v))
+}
+
+// Indices returns the indices of the flags that are set, in ascending order.
+func (v Flags) Indices() []int {
+	indices := make([]int, 0, v.Count())
+	for i := 0; i < 64; i++ {
+		if v.Get(i) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// MarshalJSON encodes the Flags as the list of indices of the flags that are set, e.g. [0,3].
+func (v Flags) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Indices())
+}
+
+// UnmarshalJSON decodes the Flags from a list of indices, or from the number of the bitfield, e.g. 9 for [0,3].
+func (v *Flags) UnmarshalJSON(data []byte) error {
+	var n uint64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*v = Flags(n)
+		return nil
+	}
+	var indices []int
+	if err := json.Unmarshal(data, &indices); err != nil {
+		return err
+	}
+	*v = 0
+	for _, i := range indices {
+		if i < 0 || i >= 64 {
+			return fmt.Errorf("xlpp: flag index %d out of range", i)
+		}
+		v.Set(i, true)
+	}
+	return nil
+}
+
+// ReadFrom reads the Flags from the reader.
+func (v *Flags) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	f, err := binary.ReadUvarint(&brc)
+	*v = Flags(f)
+	return int64(brc.Count), err
+}
+
+// WriteTo writes the Flags to the writer.
+func (v Flags) WriteTo(w io.Writer) (n int64, err error) {
+	var buf [binary.MaxVarintLen64]byte
+	m, err := writeTo(w, buf[:binary.PutUvarint(buf[:], uint64(v))])
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
 // Bool is a boolean true/false.
 type Bool bool
 
@@ -155,9 +279,9 @@ func (v *Integer) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Integer to the writer.
 func (v Integer) WriteTo(w io.Writer) (n int64, err error) {
-	var buf [9]byte
+	var buf [binary.MaxVarintLen64]byte
 	m := binary.PutVarint(buf[:], int64(v))
-	m, err = w.Write(buf[:m])
+	m, err = writeTo(w, buf[:m])
 	n = int64(m)
 	return
 }
@@ -178,29 +302,24 @@ func (v String) String() string {
 
 // ReadFrom reads the String from the reader.
 func (v *String) ReadFrom(r io.Reader) (n int64, err error) {
-	buf := make([]byte, 0, 32)
-	var brc byteReaderCounter
-	brc.ByteReader = newByteReader(r)
-	for {
-		b, err := brc.ReadByte()
-		if err != nil {
-			return int64(brc.Count), err
-		}
-		if b == 0 {
-			*v = String(buf)
-			return int64(brc.Count), nil
-		}
-		buf = append(buf, b)
+	var buf []byte
+	if pooled(r) {
+		b := bufPool.Get().(*[]byte)
+		defer bufPool.Put(b)
+		buf = (*b)[:0]
 	}
+	str, m, err := readCString(r, buf)
+	*v = String(str)
+	return int64(m), err
 }
 
 // WriteTo writes the String to the writer.
 func (v String) WriteTo(w io.Writer) (n int64, err error) {
 	var m int
-	m, err = w.Write([]byte(v))
+	m, err = io.WriteString(w, string(v))
 	n += int64(m)
 	if err == nil {
-		m, err = w.Write([]byte{0})
+		m, err = writeTo(w, []byte{0})
 		n += int64(m)
 	}
 	return
@@ -289,32 +408,33 @@ func (v Object) keys() []string {
 
 // ReadFrom reads the Object from the reader.
 func (v *Object) ReadFrom(r io.Reader) (n int64, err error) {
-	*v = make(Object)
-
-	buf := make([]byte, 32)
-	var brc byteReaderCounter
-	brc.ByteReader = newByteReader(r)
+	var buf []byte
+	hint, _ := sizeHints(r)
+	if alloc := allocator(r); alloc != nil {
+		*v = alloc.Object()
+		buf = alloc.Bytes(32)[:0]
+	} else if pooled(r) {
+		if *v == nil {
+			*v = make(Object, hint)
+		}
+		b := bufPool.Get().(*[]byte)
+		defer bufPool.Put(b)
+		buf = (*b)[:0]
+	} else {
+		*v = make(Object, hint)
+		buf = make([]byte, 0, 32)
+	}
 
 	for {
 		var key string
 		{
-			var b byte
-			b, err = brc.ReadByte()
-			if b == byte(TypeEndOfObject) {
+			var m int
+			key, m, err = readCString(r, buf[:0])
+			n += int64(m)
+			if err != nil || key == "" {
+				// an empty key is the TypeEndOfObject byte
 				return
 			}
-			buf = buf[:0]
-			for {
-				if err != nil {
-					return int64(brc.Count), err
-				}
-				if b == 0 {
-					key = string(buf)
-					break
-				}
-				buf = append(buf, b)
-				b, err = brc.ReadByte()
-			}
 		}
 		{
 			var m int64
@@ -323,6 +443,10 @@ func (v *Object) ReadFrom(r io.Reader) (n int64, err error) {
 			if err != nil {
 				return
 			}
+			if _, ok := (*v)[key].(endOfArray); ok {
+				delete(*v, key)
+				return n, errUnexpectedEndOfArray
+			}
 		}
 	}
 }
@@ -351,7 +475,7 @@ func (v Object) WriteTo(w io.Writer) (n int64, err error) {
 	}
 	{
 		var m int
-		m, err = w.Write([]byte{byte(TypeEndOfObject)})
+		m, err = writeTo(w, []byte{byte(TypeEndOfObject)})
 		n += int64(m)
 		if err != nil {
 			return
@@ -363,13 +487,14 @@ func (v Object) WriteTo(w io.Writer) (n int64, err error) {
 ////////////////////////////////////////////////////////////////////////////////
 
 // Array is a simple list of values.
+//
+// Arrays of values of the same type can be encoded in the compact TypeArrayOf form (see WithCompactArrays):
+// the item type, the uvarint number of items and the items without type bytes.
+// Both forms are decoded into Arrays.
 type Array []Value
 
 // XLPPType for Array returns TypeArray.
 func (v Array) XLPPType() Type {
-	// if t := v.getItemType(); t != 0 {
-	// 	return TypeArrayOf
-	// }
 	return TypeArray
 }
 
@@ -389,25 +514,81 @@ func (v Array) String() string {
 	return b.String()
 }
 
-// func (v Array) getItemType() (t Type) {
-// 	if len(v) == 0 {
-// 		return 0
-// 	}
-// 	for i, value := range v {
-// 		if i == 0 {
-// 			t = value.XLPPType()
-// 		} else {
-// 			if t != value.XLPPType() {
-// 				return 0
-// 			}
-// 		}
-// 	}
-// 	return
-// }
+// itemType returns the type of all items, or 0 if the items have different types or the Array is empty.
+func (v Array) itemType() (t Type) {
+	for i, value := range v {
+		if i == 0 {
+			t = value.XLPPType()
+		} else if t != value.XLPPType() {
+			return 0
+		}
+	}
+	return
+}
+
+var errArrayOfItemType = errors.New("xlpp: invalid ArrayOf item type")
+
+// arrayOfItemType reports whether values of type t can be items of the compact TypeArrayOf form.
+// Values without data (Null and Bool) are excluded, so that the number of items is limited by the payload size.
+func arrayOfItemType(t Type) bool {
+	switch t {
+	case 0, TypeNull, TypeBool, TypeBoolTrue, TypeBoolFalse, TypeEndOfArray, 255:
+		return false
+	}
+	return true
+}
+
+// readArrayOf reads the Array from the reader, in the compact TypeArrayOf form.
+func (v *Array) readArrayOf(r io.Reader) (n int64, err error) {
+	var b [1]byte
+	n, err = readFrom(r, b[:])
+	if err != nil {
+		return n, toErr(err)
+	}
+	t := Type(b[0])
+	if !arrayOfItemType(t) {
+		return n, errArrayOfItemType
+	}
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	l, err := binary.ReadUvarint(&brc)
+	n += int64(brc.Count)
+	if err == nil {
+		// each item is at least one byte
+		err = checkLength(r, l)
+	}
+	if err != nil {
+		return n, toErr(err)
+	}
+	_, hint := sizeHints(r)
+	if uint64(hint) > l {
+		hint = int(l)
+	}
+	*v = make(Array, 0, hint)
+	for ; l > 0; l-- {
+		var item Value
+		var m int64
+		item, m, err = readValue(r, t)
+		n += m
+		if err != nil {
+			return
+		}
+		*v = append(*v, item)
+	}
+	return
+}
 
 // ReadFrom reads the Array from the reader.
 func (v *Array) ReadFrom(r io.Reader) (n int64, err error) {
-	*v = make(Array, 0, 8)
+	alloc := allocator(r)
+	_, hint := sizeHints(r)
+	if alloc != nil {
+		*v = alloc.Values(hint)
+	} else if pooled(r) && cap(*v) >= hint && cap(*v) != 0 {
+		*v = (*v)[:0]
+	} else {
+		*v = make(Array, 0, hint)
+	}
 	for {
 		var m int64
 		var i Value
@@ -419,6 +600,9 @@ func (v *Array) ReadFrom(r io.Reader) (n int64, err error) {
 		if _, ok := i.(endOfArray); ok {
 			return
 		}
+		if alloc != nil && len(*v) == cap(*v) {
+			*v = append(alloc.Values(2*cap(*v)+1), *v...)
+		}
 		*v = append(*v, i)
 	}
 }
@@ -437,7 +621,7 @@ func (v Array) WriteTo(w io.Writer) (n int64, err error) {
 	}
 	{
 		var m int
-		m, err = w.Write([]byte{byte(TypeEndOfArray)})
+		m, err = writeTo(w, []byte{byte(TypeEndOfArray)})
 		n += int64(m)
 		if err != nil {
 			return
@@ -446,8 +630,66 @@ func (v Array) WriteTo(w io.Writer) (n int64, err error) {
 	return
 }
 
+// arrayOf is an Array that is written in the compact TypeArrayOf form, see WithCompactArrays.
+type arrayOf Array
+
+func (v arrayOf) XLPPType() Type {
+	return TypeArrayOf
+}
+
+func (v arrayOf) String() string {
+	return Array(v).String()
+}
+
+func (v *arrayOf) ReadFrom(r io.Reader) (n int64, err error) {
+	return (*Array)(v).readArrayOf(r)
+}
+
+// WriteTo writes the item type, the number of items and the items without type bytes.
+func (v arrayOf) WriteTo(w io.Writer) (n int64, err error) {
+	var buf [1 + binary.MaxVarintLen64]byte
+	buf[0] = byte(Array(v).itemType())
+	m, err := writeTo(w, buf[:1+binary.PutUvarint(buf[1:], uint64(len(v)))])
+	n = int64(m)
+	for _, item := range v {
+		if err != nil {
+			return
+		}
+		var m int64
+		m, err = item.WriteTo(w)
+		n += m
+	}
+	return
+}
+
+// compactArrays returns the value with all Arrays of at least two items of the same type (nested in Objects and Arrays)
+// replaced by their compact TypeArrayOf form. The value is not modified.
+func compactArrays(v Value) Value {
+	switch v := v.(type) {
+	case *Object:
+		o := make(Object, len(*v))
+		for key, item := range *v {
+			o[key] = compactArrays(item)
+		}
+		return &o
+	case *Array:
+		a := make(Array, len(*v))
+		for i, item := range *v {
+			a[i] = compactArrays(item)
+		}
+		if t := a.itemType(); len(a) >= 2 && arrayOfItemType(t) {
+			c := arrayOf(a)
+			return &c
+		}
+		return &a
+	}
+	return v
+}
+
 type endOfArray struct{}
 
+var errUnexpectedEndOfArray = errors.New("unexpected end of array")
+
 func (endOfArray) XLPPType() Type {
 	return TypeEndOfArray
 }
@@ -507,7 +749,138 @@ func (v *Delay) ReadFrom(r io.Reader) (n int64, err error) {
 
 // WriteTo writes the Delay to the writer.
 func (v Delay) WriteTo(w io.Writer) (n int64, err error) {
-	m, err := w.Write([]byte{byte(v.Hours()), byte(v.Minutes()), byte(v.Seconds())})
+	m, err := writeTo(w, []byte{byte(v.Hours()), byte(v.Minutes()), byte(v.Seconds())})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A MilliDelay is a Delay with millisecond resolution, e.g. for vibration and event loggers
+// that buffer readings at sub-second intervals.
+// It is encoded as varint of milliseconds, and increments the total Delay like a Delay.
+type MilliDelay time.Duration
+
+// XLPPType for MilliDelay returns 255.
+func (v MilliDelay) XLPPType() Type {
+	return 255
+}
+
+// XLPPChannel for MilliDelay returns the constant ChanMilliDelay 250.
+func (v MilliDelay) XLPPChannel() int {
+	return ChanMilliDelay
+}
+
+func (v MilliDelay) String() string {
+	return time.Duration(v).String()
+}
+
+// ReadFrom reads the MilliDelay from the reader.
+func (v *MilliDelay) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	ms, err := binary.ReadUvarint(&brc)
+	*v = MilliDelay(time.Duration(ms) * time.Millisecond)
+	return int64(brc.Count), err
+}
+
+// WriteTo writes the MilliDelay to the writer.
+// Negative delays are written as zero.
+func (v MilliDelay) WriteTo(w io.Writer) (n int64, err error) {
+	ms := time.Duration(v) / time.Millisecond
+	if ms < 0 {
+		ms = 0
+	}
+	var buf [binary.MaxVarintLen64]byte
+	m := binary.PutUvarint(buf[:], uint64(ms))
+	m, err = writeTo(w, buf[:m])
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A Priority is a marker with the priority or delivery class of a message, that backends can use for routing,
+// e.g. to forward alarms immediately and store routine telemetry in batches.
+// Messages without a Priority marker have the PriorityRoutine.
+type Priority uint8
+
+// The known priorities. Higher values are more urgent.
+const (
+	PriorityRoutine Priority = 0
+	PriorityHigh    Priority = 1
+	PriorityAlarm   Priority = 2
+)
+
+var priorityNames = [...]string{"routine", "high", "alarm"}
+
+// XLPPType for Priority returns 255.
+func (v Priority) XLPPType() Type {
+	return 255
+}
+
+// XLPPChannel for Priority returns the constant ChanPriority 254.
+func (v Priority) XLPPChannel() int {
+	return ChanPriority
+}
+
+func (v Priority) String() string {
+	if int(v) < len(priorityNames) {
+		return priorityNames[v]
+	}
+	return fmt.Sprintf("priority %d", uint8(v))
+}
+
+// ReadFrom reads the Priority from the reader.
+func (v *Priority) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [1]byte
+	n, err = readFrom(r, b[:])
+	*v = Priority(b[0])
+	return
+}
+
+// WriteTo writes the Priority to the writer.
+func (v Priority) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := writeTo(w, []byte{byte(v)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// An ActuatorAck is a marker with the sequence number of a downlink.
+// In downlinks, it tags the payload with its sequence number. The device echoes the marker in an uplink
+// after it has executed the commands of the downlink, so that the sender knows which downlinks have been applied.
+// An uplink may acknowledge multiple downlinks.
+type ActuatorAck uint32
+
+// XLPPType for ActuatorAck returns 255.
+func (v ActuatorAck) XLPPType() Type {
+	return 255
+}
+
+// XLPPChannel for ActuatorAck returns the constant ChanActuatorAck 255.
+func (v ActuatorAck) XLPPChannel() int {
+	return ChanActuatorAck
+}
+
+func (v ActuatorAck) String() string {
+	return fmt.Sprintf("ack %d", uint32(v))
+}
+
+// ReadFrom reads the ActuatorAck from the reader.
+func (v *ActuatorAck) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	seq, err := binary.ReadUvarint(&brc)
+	if err == nil && seq > math.MaxUint32 {
+		err = errors.New("xlpp: ActuatorAck sequence number overflows 32 bits")
+	}
+	*v = ActuatorAck(seq)
+	return int64(brc.Count), err
+}
+
+// WriteTo writes the ActuatorAck to the writer.
+func (v ActuatorAck) WriteTo(w io.Writer) (n int64, err error) {
+	var buf [binary.MaxVarintLen32]byte
+	m, err := writeTo(w, buf[:binary.PutUvarint(buf[:], uint64(v))])
 	return int64(m), err
 }
 
@@ -550,13 +923,16 @@ func (v *Actuators) ReadFrom(r io.Reader) (n int64, err error) {
 	}
 	var m int64
 	l := int(b[0])
+	if err = checkLength(r, uint64(l)); err != nil {
+		return
+	}
 	*v = make(Actuators, l)
 	for i := 0; i < l; i++ {
 		m, err = readFrom(r, b[:])
+		n += m
 		if err != nil {
 			return
 		}
-		n += m
 		(*v)[i] = Type(b[0])
 	}
 	return
@@ -617,13 +993,16 @@ func (v *ActuatorsWithChannel) ReadFrom(r io.Reader) (n int64, err error) {
 	}
 	var m int64
 	l := int(b[0])
+	if err = checkLength(r, uint64(l)*2); err != nil {
+		return
+	}
 	*v = make(ActuatorsWithChannel, l)
 	for i := 0; i < l; i++ {
 		m, err = readFrom(r, b[:])
+		n += m
 		if err != nil {
 			return
 		}
-		n += m
 		(*v)[i] = Actuator{
 			Channel: int(b[0]),
 			Type:    Type(b[1]),
@@ -646,6 +1025,90 @@ func (v ActuatorsWithChannel) WriteTo(w io.Writer) (n int64, err error) {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// sliceReader is implemented by bufio.Reader.
+type sliceReader interface {
+	ReadSlice(delim byte) (line []byte, err error)
+}
+
+// readCString reads a null terminated string from the reader.
+// The string memory is taken from the Reader's Allocator, if any.
+func readCString(r io.Reader, buf []byte) (str string, n int, err error) {
+	b, n, err := readCBytes(r, buf)
+	if err != nil {
+		return "", n, err
+	}
+	if alloc := allocator(r); alloc != nil {
+		return alloc.String(b), n, nil
+	}
+	return string(b), n, nil
+}
+
+// readCBytes reads a null terminated string from the reader.
+// Byte slice backed readers (bytes.Buffer) and buffered readers (bufio.Reader) are read in chunks.
+// Other readers are read byte by byte, using buf as scratch space.
+// The returned slice is only valid until the next read.
+func readCBytes(r io.Reader, buf []byte) (b []byte, n int, err error) {
+	max := maxStringLen(r)
+	tooLong := func(l int) error {
+		if max > 0 && l > max {
+			return &LimitError{Limit: "MaxStringLen", Max: max}
+		}
+		return nil
+	}
+	switch r := r.(type) {
+	case *bytes.Buffer:
+		data := r.Bytes()
+		i := bytes.IndexByte(data, 0)
+		if i == -1 {
+			r.Next(len(data))
+			return nil, len(data), io.EOF
+		}
+		r.Next(i + 1)
+		return data[:i], i + 1, tooLong(i)
+	case sliceReader:
+		for {
+			var line []byte
+			line, err = r.ReadSlice(0)
+			n += len(line)
+			if err == nil {
+				line = line[:len(line)-1]
+				if err = tooLong(len(buf) + len(line)); err != nil {
+					return nil, n, err
+				}
+				if len(buf) == 0 {
+					return line, n, nil
+				}
+				return append(buf, line...), n, nil
+			}
+			if err != bufio.ErrBufferFull {
+				return nil, n, err
+			}
+			if err = tooLong(len(buf) + len(line)); err != nil {
+				return nil, n, err
+			}
+			buf = append(buf, line...)
+		}
+	}
+	br := newByteReader(r)
+	for {
+		var c byte
+		c, err = br.ReadByte()
+		if err != nil {
+			return nil, n, err
+		}
+		n++
+		if c == 0 {
+			return buf, n, nil
+		}
+		if err = tooLong(len(buf) + 1); err != nil {
+			return nil, n, err
+		}
+		buf = append(buf, c)
+	}
+}
+
+////////////////////
+
 type byteReader struct {
 	io.Reader
 }
@@ -659,7 +1122,7 @@ func newByteReader(r io.Reader) io.ByteReader {
 
 func (br byteReader) ReadByte() (byte, error) {
 	var buf [1]byte
-	_, err := br.Reader.Read(buf[:])
+	_, err := io.ReadFull(br.Reader, buf[:])
 	return buf[0], err
 }
 
@@ -670,8 +1133,10 @@ type byteReaderCounter struct {
 	Count int
 }
 
-func (br byteReaderCounter) ReadByte() (byte, error) {
+func (br *byteReaderCounter) ReadByte() (byte, error) {
 	b, err := br.ByteReader.ReadByte()
-	br.Count++
+	if err == nil {
+		br.Count++
+	}
 	return b, err
 }
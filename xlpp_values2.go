@@ -0,0 +1,518 @@
+package xlpp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The following extended-range types are supported by this library:
+const (
+	TypeExtendedPercentage   Type = 60 // 1 byte 0-255% unsigned
+	TypeBarometricPressure24 Type = 61 // 3 bytes 0.01 hPa unsigned
+	TypeDistanceLong         Type = 62 // uvarint 0.001m unsigned
+	TypePowerPrecise         Type = 63 // 4 bytes 0.1W unsigned
+	TypeCurrentHiRange       Type = 64 // 4 bytes 0.01A unsigned
+	TypeVoltageSigned        Type = 65 // 4 bytes 0.01V signed
+	TypeAnalogUnit           Type = 66 // 1 byte unit + 4 bytes 0.001 signed
+	TypeGasConcentration     Type = 67 // 1 byte gas + 4 bytes 1ppb unsigned
+	TypeGPS2D                Type = 68 // 3 bytes lat + 3 bytes lon, 0.0001° signed
+	TypeAccelerometerHiG     Type = 69 // 2 bytes per axis, 0.01G signed
+	TypeGyrometerHiRate      Type = 70 // 2 bytes per axis, 1°/s signed
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExtendedPercentage is a one byte integer value 0-255% (unsigned),
+// for values that may exceed 100%, e.g. battery charge or progress with overshoot.
+type ExtendedPercentage uint8
+
+// XLPPType for ExtendedPercentage returns TypeExtendedPercentage.
+func (v ExtendedPercentage) XLPPType() Type {
+	return TypeExtendedPercentage
+}
+
+func (v ExtendedPercentage) String() string {
+	return fmt.Sprintf("%d", v)
+}
+
+// ReadFrom reads the ExtendedPercentage from the reader.
+func (v *ExtendedPercentage) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [1]byte
+	n, err = readFrom(r, b[:])
+	*v = ExtendedPercentage(b[0])
+	return
+}
+
+// WriteTo writes the ExtendedPercentage to the writer.
+func (v ExtendedPercentage) WriteTo(w io.Writer) (n int64, err error) {
+	m, err := writeTo(w, []byte{byte(v)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// BarometricPressure24 is a floating point number barometric pressure value [hPa] with 0.01 data resolution (unsigned, 24 bits),
+// covering 0 - 167772.15 hPa. Unlike BarometricPressure, it represents all typical pressures with a higher resolution.
+// Negative values are written as zero.
+type BarometricPressure24 float64
+
+func (v BarometricPressure24) String() string {
+	return fmt.Sprintf("%.2f hPa", v)
+}
+
+// XLPPType for BarometricPressure24 returns TypeBarometricPressure24.
+func (v BarometricPressure24) XLPPType() Type {
+	return TypeBarometricPressure24
+}
+
+// ReadFrom reads the BarometricPressure24 from the reader.
+func (v *BarometricPressure24) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [3]byte
+	n, err = readFrom(r, b[:])
+	d := uint32(b[0])<<16 + uint32(b[1])<<8 + uint32(b[2])
+	*v = BarometricPressure24(d) / 100
+	return
+}
+
+// WriteTo writes the BarometricPressure24 to the writer.
+func (v BarometricPressure24) WriteTo(w io.Writer) (n int64, err error) {
+	i := uint32(math.Max(0, math.Min(trunc(float64(v)*100), 0xffffff)))
+	m, err := writeTo(w, []byte{byte(i >> 16), byte(i >> 8), byte(i)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// DistanceLong is a floating point number distance [m] with 0.001m data resolution (unsigned),
+// encoded as uvarint of millimeters. Unlike Distance, it does not overflow at 4294967.295m,
+// e.g. for odometer-style accumulating distances.
+// Negative values are written as zero, and values beyond the uint64 range as its maximum.
+type DistanceLong float64
+
+// maxUvarintFloat is the largest float64 that converts to an uint64.
+const maxUvarintFloat = 1<<64 - 1<<11
+
+// XLPPType for DistanceLong returns TypeDistanceLong.
+func (v DistanceLong) XLPPType() Type {
+	return TypeDistanceLong
+}
+
+func (v DistanceLong) String() string {
+	return fmt.Sprintf("%.3f m", v)
+}
+
+// ReadFrom reads the DistanceLong from the reader.
+func (v *DistanceLong) ReadFrom(r io.Reader) (n int64, err error) {
+	var brc byteReaderCounter
+	brc.ByteReader = newByteReader(r)
+	mm, err := binary.ReadUvarint(&brc)
+	*v = DistanceLong(mm) / 1000
+	return int64(brc.Count), err
+}
+
+// WriteTo writes the DistanceLong to the writer.
+func (v DistanceLong) WriteTo(w io.Writer) (n int64, err error) {
+	var buf [binary.MaxVarintLen64]byte
+	m := binary.PutUvarint(buf[:], uint64(math.Max(0, math.Min(trunc(float64(v)*1000), maxUvarintFloat))))
+	m, err = writeTo(w, buf[:m])
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// PowerPrecise is a floating point number power [W] with 0.1W data resolution (unsigned, 4 bytes),
+// for low power use cases like energy harvesting and PV monitoring, where the 1W resolution of Power is too coarse.
+// E.g. a value of 2.345W is written as 2.3. Negative values are written as zero.
+type PowerPrecise float64
+
+// XLPPType for PowerPrecise returns TypePowerPrecise.
+func (v PowerPrecise) XLPPType() Type {
+	return TypePowerPrecise
+}
+
+func (v PowerPrecise) String() string {
+	return fmt.Sprintf("%.1f W", v)
+}
+
+// ReadFrom reads the PowerPrecise from the reader.
+func (v *PowerPrecise) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [4]byte
+	n, err = readFrom(r, b[:])
+	d := uint32(b[0])<<24 + uint32(b[1])<<16 + uint32(b[2])<<8 + uint32(b[3])
+	*v = PowerPrecise(d) / 10
+	return
+}
+
+// WriteTo writes the PowerPrecise to the writer.
+func (v PowerPrecise) WriteTo(w io.Writer) (n int64, err error) {
+	i := uint32(math.Max(0, math.Min(trunc(float64(v)*10), math.MaxUint32)))
+	m, err := writeTo(w, []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// CurrentHiRange is a floating point number electrical current [A] with 0.01A data resolution (unsigned, 4 bytes),
+// for industrial mains monitoring above the 32.767A range of Current.
+// E.g. a value of 123.456A is written as 123.45. Negative values are written as zero.
+type CurrentHiRange float64
+
+// XLPPType for CurrentHiRange returns TypeCurrentHiRange.
+func (v CurrentHiRange) XLPPType() Type {
+	return TypeCurrentHiRange
+}
+
+func (v CurrentHiRange) String() string {
+	return fmt.Sprintf("%.2f A", v)
+}
+
+// ReadFrom reads the CurrentHiRange from the reader.
+func (v *CurrentHiRange) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [4]byte
+	n, err = readFrom(r, b[:])
+	d := uint32(b[0])<<24 + uint32(b[1])<<16 + uint32(b[2])<<8 + uint32(b[3])
+	*v = CurrentHiRange(d) / 100
+	return
+}
+
+// WriteTo writes the CurrentHiRange to the writer.
+func (v CurrentHiRange) WriteTo(w io.Writer) (n int64, err error) {
+	i := uint32(math.Max(0, math.Min(trunc(float64(v)*100), math.MaxUint32)))
+	m, err := writeTo(w, []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// VoltageSigned is a floating point number electrical voltage [V] with 0.01V data resolution (signed, 4 bytes),
+// for battery discharge curves and DC bus monitoring with negative or high voltages beyond the range of Voltage.
+// E.g. a value of -2.3456V is written as -2.34. Values beyond ±21474836.47V are written as the nearest value in range.
+type VoltageSigned float64
+
+// XLPPType for VoltageSigned returns TypeVoltageSigned.
+func (v VoltageSigned) XLPPType() Type {
+	return TypeVoltageSigned
+}
+
+func (v VoltageSigned) String() string {
+	return fmt.Sprintf("%.2f V", v)
+}
+
+// ReadFrom reads the VoltageSigned from the reader.
+func (v *VoltageSigned) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [4]byte
+	n, err = readFrom(r, b[:])
+	d := int32(b[0])<<24 + int32(b[1])<<16 + int32(b[2])<<8 + int32(b[3])
+	*v = VoltageSigned(d) / 100
+	return
+}
+
+// WriteTo writes the VoltageSigned to the writer.
+func (v VoltageSigned) WriteTo(w io.Writer) (n int64, err error) {
+	i := int32(math.Max(math.MinInt32, math.Min(trunc(float64(v)*100), math.MaxInt32)))
+	m, err := writeTo(w, []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A UnitCode identifies the physical unit of an AnalogUnit.
+type UnitCode uint8
+
+// The known unit codes.
+const (
+	UnitNone UnitCode = iota
+	UnitVolt
+	UnitAmpere
+	UnitCelsius
+	UnitLux
+	UnitPercent
+	UnitHectopascal
+	UnitWatt
+	UnitHertz
+	UnitMeter
+	UnitPPM
+	UnitKilogram
+	UnitOhm
+	UnitDegree
+	UnitSecond
+)
+
+var unitSymbols = [...]string{"", "V", "A", "°C", "lux", "%", "hPa", "W", "Hz", "m", "ppm", "kg", "Ω", "°", "s"}
+
+// Symbol returns the unit symbol, e.g. "V" for UnitVolt.
+// It returns an empty string for UnitNone and unknown unit codes.
+func (u UnitCode) Symbol() string {
+	if int(u) < len(unitSymbols) {
+		return unitSymbols[u]
+	}
+	return ""
+}
+
+// MarshalJSON marshals known unit codes as their symbol, and unknown unit codes as number.
+func (u UnitCode) MarshalJSON() ([]byte, error) {
+	if u != UnitNone && u.Symbol() != "" {
+		return json.Marshal(u.Symbol())
+	}
+	return json.Marshal(uint8(u))
+}
+
+// UnmarshalJSON unmarshals the unit code from a unit symbol like "V", or a number.
+func (u *UnitCode) UnmarshalJSON(data []byte) error {
+	var symbol string
+	if err := json.Unmarshal(data, &symbol); err != nil {
+		return json.Unmarshal(data, (*uint8)(u))
+	}
+	for i, s := range unitSymbols {
+		if s == symbol && i != int(UnitNone) {
+			*u = UnitCode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("xlpp: unknown unit %q", symbol)
+}
+
+// AnalogUnit is a floating point number with 0.001 data resolution (signed, 4 bytes) and its unit,
+// for generic ADC front-ends whose measured quantity is configured at runtime.
+// Values beyond ±2147483.647 are written as the nearest value in range.
+type AnalogUnit struct {
+	Unit  UnitCode `json:"unit"`
+	Value float64  `json:"value"`
+}
+
+// XLPPType for AnalogUnit returns TypeAnalogUnit.
+func (v AnalogUnit) XLPPType() Type {
+	return TypeAnalogUnit
+}
+
+func (v AnalogUnit) String() string {
+	if s := v.Unit.Symbol(); s != "" {
+		return fmt.Sprintf("%.3f %s", v.Value, s)
+	}
+	return fmt.Sprintf("%.3f", v.Value)
+}
+
+// ReadFrom reads the AnalogUnit from the reader.
+func (v *AnalogUnit) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [5]byte
+	n, err = readFrom(r, b[:])
+	v.Unit = UnitCode(b[0])
+	d := int32(b[1])<<24 + int32(b[2])<<16 + int32(b[3])<<8 + int32(b[4])
+	v.Value = float64(d) / 1000
+	return
+}
+
+// WriteTo writes the AnalogUnit to the writer.
+func (v AnalogUnit) WriteTo(w io.Writer) (n int64, err error) {
+	i := int32(math.Max(math.MinInt32, math.Min(trunc(v.Value*1000), math.MaxInt32)))
+	m, err := writeTo(w, []byte{byte(v.Unit), byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// A GasID identifies the gas of a GasConcentration.
+type GasID uint8
+
+// The known gases.
+const (
+	GasUnknown GasID = iota
+	GasCO
+	GasCO2
+	GasNO2
+	GasO3
+	GasCH4
+	GasSO2
+	GasNH3
+	GasH2S
+	GasVOC
+	GasH2
+	GasNO
+)
+
+var gasNames = [...]string{"", "CO", "CO2", "NO2", "O3", "CH4", "SO2", "NH3", "H2S", "VOC", "H2", "NO"}
+
+func (g GasID) String() string {
+	if g != GasUnknown && int(g) < len(gasNames) {
+		return gasNames[g]
+	}
+	return fmt.Sprintf("gas %d", uint8(g))
+}
+
+// MarshalJSON marshals known gases as their chemical formula, e.g. "CO2", and unknown gases as number.
+func (g GasID) MarshalJSON() ([]byte, error) {
+	if g != GasUnknown && int(g) < len(gasNames) {
+		return json.Marshal(gasNames[g])
+	}
+	return json.Marshal(uint8(g))
+}
+
+// UnmarshalJSON unmarshals the gas from its chemical formula like "CO2", or a number.
+func (g *GasID) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return json.Unmarshal(data, (*uint8)(g))
+	}
+	for i, n := range gasNames {
+		if n == name && i != int(GasUnknown) {
+			*g = GasID(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("xlpp: unknown gas %q", name)
+}
+
+// GasConcentration is the concentration [ppm] of a gas with 0.001 ppm (1 ppb) data resolution (unsigned, 4 bytes),
+// so that multi-gas sensors can report all gases with a single type. Negative concentrations are written as zero.
+type GasConcentration struct {
+	Gas GasID   `json:"gas"`
+	PPM float64 `json:"ppm"`
+}
+
+// XLPPType for GasConcentration returns TypeGasConcentration.
+func (v GasConcentration) XLPPType() Type {
+	return TypeGasConcentration
+}
+
+func (v GasConcentration) String() string {
+	return fmt.Sprintf("%s %.3f ppm", v.Gas, v.PPM)
+}
+
+// ReadFrom reads the GasConcentration from the reader.
+func (v *GasConcentration) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [5]byte
+	n, err = readFrom(r, b[:])
+	v.Gas = GasID(b[0])
+	d := uint32(b[1])<<24 + uint32(b[2])<<16 + uint32(b[3])<<8 + uint32(b[4])
+	v.PPM = float64(d) / 1000
+	return
+}
+
+// WriteTo writes the GasConcentration to the writer.
+func (v GasConcentration) WriteTo(w io.Writer) (n int64, err error) {
+	i := uint32(math.Max(0, math.Min(trunc(v.PPM*1000), math.MaxUint32)))
+	m, err := writeTo(w, []byte{byte(v.Gas), byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// GPS2D is a {latitude [°], longitude [°]} GPS location with 0.0001 data resolution (signed), without altitude.
+// It saves 3 bytes per fix compared to GPS, e.g. for trackers that send their position frequently.
+type GPS2D struct {
+	Latitude, Longitude float64
+}
+
+// XLPPType for GPS2D returns TypeGPS2D.
+func (v GPS2D) XLPPType() Type {
+	return TypeGPS2D
+}
+
+func (v GPS2D) String() string {
+	return fmt.Sprintf("%s, %s", dms(v.Latitude, "N", "S"), dms(v.Longitude, "E", "W"))
+}
+
+// ReadFrom reads the GPS2D from the reader.
+func (v *GPS2D) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [6]byte
+	n, err = readFrom(r, b[:])
+	v.Latitude = float64(int24(b[0:3])) / 10000
+	v.Longitude = float64(int24(b[3:6])) / 10000
+	return
+}
+
+// ErrGPSRange is returned for GPS2D values with a latitude outside ±90° or a longitude outside ±180°.
+var ErrGPSRange = errors.New("xlpp: GPS coordinates out of range")
+
+// WriteTo writes the GPS2D to the writer.
+// Coordinates out of range are rejected with ErrGPSRange.
+func (v GPS2D) WriteTo(w io.Writer) (n int64, err error) {
+	if !(math.Abs(v.Latitude) <= 90 && math.Abs(v.Longitude) <= 180) {
+		return 0, ErrGPSRange
+	}
+	lat := int32(trunc(v.Latitude * 10000))
+	lon := int32(trunc(v.Longitude * 10000))
+	m, err := writeTo(w, []byte{byte(lat >> 16), byte(lat >> 8), byte(lat), byte(lon >> 16), byte(lon >> 8), byte(lon)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// AccelerometerHiG is a struct of {x, y, z} floating point numbers [G] with 0.01 data resolution (signed) per axis,
+// covering ±327.67 G for impact and vibration sensors, where Accelerometer saturates at ±32.767 G.
+// Values beyond that range are written as the nearest value in range.
+type AccelerometerHiG struct {
+	X, Y, Z float64
+}
+
+func (v AccelerometerHiG) String() string {
+	return fmt.Sprintf("X: %.2f G, Y: %.2f G, Z: %.2f G", v.X, v.Y, v.Z)
+}
+
+// XLPPType for AccelerometerHiG returns TypeAccelerometerHiG.
+func (v AccelerometerHiG) XLPPType() Type {
+	return TypeAccelerometerHiG
+}
+
+// ReadFrom reads the AccelerometerHiG from the reader.
+func (v *AccelerometerHiG) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [6]byte
+	n, err = readFrom(r, b[:])
+	vx := int16(b[0])<<8 + int16(b[1])
+	vy := int16(b[2])<<8 + int16(b[3])
+	vz := int16(b[4])<<8 + int16(b[5])
+	v.X = float64(vx) / 100
+	v.Y = float64(vy) / 100
+	v.Z = float64(vz) / 100
+	return
+}
+
+// WriteTo writes the AccelerometerHiG to the writer.
+func (v AccelerometerHiG) WriteTo(w io.Writer) (n int64, err error) {
+	vx := int16(math.Max(math.MinInt16, math.Min(trunc(v.X*100), math.MaxInt16)))
+	vy := int16(math.Max(math.MinInt16, math.Min(trunc(v.Y*100), math.MaxInt16)))
+	vz := int16(math.Max(math.MinInt16, math.Min(trunc(v.Z*100), math.MaxInt16)))
+	m, err := writeTo(w, []byte{byte(vx >> 8), byte(vx), byte(vy >> 8), byte(vy), byte(vz >> 8), byte(vz)})
+	return int64(m), err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// GyrometerHiRate is a struct of {x, y, z} floating point numbers [°/s] with 1 data resolution (signed) per axis,
+// for drones and machinery with rates up to ±2000 °/s and more, where Gyrometer overflows at ±327.67 °/s.
+// Values beyond ±32767 °/s are written as the nearest value in range.
+type GyrometerHiRate struct {
+	X, Y, Z float32
+}
+
+func (v GyrometerHiRate) String() string {
+	return fmt.Sprintf("X: %.0f °/s, Y: %.0f °/s, Z: %.0f °/s", v.X, v.Y, v.Z)
+}
+
+// XLPPType for GyrometerHiRate returns TypeGyrometerHiRate.
+func (v GyrometerHiRate) XLPPType() Type {
+	return TypeGyrometerHiRate
+}
+
+// ReadFrom reads the GyrometerHiRate from the reader.
+func (v *GyrometerHiRate) ReadFrom(r io.Reader) (n int64, err error) {
+	var b [6]byte
+	n, err = readFrom(r, b[:])
+	v.X = float32(int16(b[0])<<8 + int16(b[1]))
+	v.Y = float32(int16(b[2])<<8 + int16(b[3]))
+	v.Z = float32(int16(b[4])<<8 + int16(b[5]))
+	return
+}
+
+// WriteTo writes the GyrometerHiRate to the writer.
+func (v GyrometerHiRate) WriteTo(w io.Writer) (n int64, err error) {
+	vx := int16(math.Max(math.MinInt16, math.Min(trunc32(float64(v.X)), math.MaxInt16)))
+	vy := int16(math.Max(math.MinInt16, math.Min(trunc32(float64(v.Y)), math.MaxInt16)))
+	vz := int16(math.Max(math.MinInt16, math.Min(trunc32(float64(v.Z)), math.MaxInt16)))
+	m, err := writeTo(w, []byte{byte(vx >> 8), byte(vx), byte(vy >> 8), byte(vy), byte(vz >> 8), byte(vz)})
+	return int64(m), err
+}
@@ -0,0 +1,100 @@
+// Package xlppcsv writes decoded XLPP messages as CSV, with one row of timestamp, channel, type, value and unit
+// per value, e.g. to import the payloads of field trials into spreadsheets.
+package xlppcsv
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/recorder"
+)
+
+// Header is the header row of the CSV.
+var Header = []string{"timestamp", "channel", "type", "value", "unit"}
+
+// A Writer writes messages as CSV rows. The header row is written before the first message.
+type Writer struct {
+	// Comma is the field delimiter, e.g. ';' for spreadsheets with a decimal comma. It defaults to ','.
+	Comma rune
+
+	w      *csv.Writer
+	header bool
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: csv.NewWriter(w)}
+}
+
+// Write writes the values of the message received at the given time, one row per value.
+// The timestamps (RFC 3339, UTC) are resolved from the Delay markers, and Samples are written as one row per reading
+// (see recorder.Resolve). Markers are left out.
+//
+// Numbers and bools are written as in JSON, strings (e.g. String and Colour) unquoted, UnixTimes in RFC 3339,
+// AnalogUnits as number with their own unit, and all other values in their JSON representation, e.g. {"Latitude":52.5,"Longitude":13.4,"Meters":34}.
+func (w *Writer) Write(received time.Time, m xlpp.Message) error {
+	if w.Comma != 0 {
+		w.w.Comma = w.Comma
+	}
+	if !w.header {
+		if err := w.w.Write(Header); err != nil {
+			return err
+		}
+		w.header = true
+	}
+	for _, e := range recorder.Resolve(received, m) {
+		value, err := format(e.Value)
+		if err != nil {
+			return err
+		}
+		record := []string{
+			e.Time.UTC().Format(time.RFC3339Nano),
+			strconv.Itoa(e.Channel),
+			xlpp.NameOf(e.Value),
+			value,
+			unit(e.Value),
+		}
+		if err := w.w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer, and returns the first error that occurred.
+func (w *Writer) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// format formats the value of a row.
+func format(v xlpp.Value) (string, error) {
+	switch v := v.(type) {
+	case *xlpp.UnixTime:
+		return time.Time(*v).UTC().Format(time.RFC3339), nil
+	case *xlpp.AnalogUnit:
+		return strconv.FormatFloat(v.Value, 'f', -1, 64), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return s, nil
+	}
+	return string(data), nil
+}
+
+// unit returns the unit of the value.
+func unit(v xlpp.Value) string {
+	switch v := v.(type) {
+	case *xlpp.AnalogUnit:
+		return v.Unit.Symbol()
+	}
+	return v.XLPPType().Unit()
+}
@@ -0,0 +1,53 @@
+package xlppcsv_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/xlppcsv"
+)
+
+func TestWriter(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	temp := xlpp.Temperature(21.5)
+	delay := xlpp.Delay(time.Minute)
+	str := xlpp.String("door, open")
+	gps := xlpp.GPS{Latitude: 52.5, Longitude: 13.4, Meters: 34}
+	var b strings.Builder
+	w := xlppcsv.NewWriter(&b)
+	err := w.Write(received, xlpp.Message{
+		{Channel: 1, Value: &temp},
+		{Channel: xlpp.ChanDelay, Value: &delay},
+		{Channel: 2, Value: &str},
+		{Channel: 3, Value: &gps},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(received, xlpp.Message{{Channel: 1, Value: &temp}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `timestamp,channel,type,value,unit
+2024-05-01T12:00:00Z,1,temperature,21.5,°C
+2024-05-01T11:59:00Z,2,string,"door, open",
+2024-05-01T11:59:00Z,3,gps,"{""Latitude"":52.5,""Longitude"":13.4,""Meters"":34}",
+2024-05-01T12:00:00Z,1,temperature,21.5,°C
+`
+	if b.String() != want {
+		t.Fatalf("csv:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	w = xlppcsv.NewWriter(&b)
+	w.Comma = ';'
+	w.Write(received, xlpp.Message{{Channel: 1, Value: &temp}})
+	w.Flush()
+	if !strings.HasSuffix(b.String(), "Z;1;temperature;21.5;°C\n") {
+		t.Fatalf("csv: %s", b.String())
+	}
+}
@@ -0,0 +1,153 @@
+package xlpphttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/waziup/xlpp"
+)
+
+// NewServeMux returns a REST service with the endpoints:
+//   - POST /decode decodes a binary or base64 payload, and responds with JSON (or the type of the Accept header),
+//   - POST /encode encodes JSON (or a payload of any supported type), and responds with a binary payload
+//     (or the type of the Accept header),
+//   - GET /openapi.json serves the OpenAPI specification of the service.
+func NewServeMux() *http.ServeMux {
+	identity := Handler(func(r *http.Request, m xlpp.Message) (xlpp.Message, error) {
+		if m == nil {
+			m = xlpp.Message{}
+		}
+		return m, nil
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/decode", post(func(w http.ResponseWriter, r *http.Request) {
+		if Negotiate(r) == ContentType && !acceptsBinary(r) {
+			r.Header.Set("Accept", ContentTypeJSON)
+		}
+		identity.ServeHTTP(w, r)
+	}))
+	mux.Handle("/encode", post(identity.ServeHTTP))
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(OpenAPI())
+	})
+	return mux
+}
+
+// acceptsBinary reports whether the client asked for a binary response explicitly.
+func acceptsBinary(r *http.Request) bool {
+	switch r.Header.Get("Accept") {
+	case "", "*/*":
+		return false
+	}
+	return true
+}
+
+func post(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// OpenAPI returns the OpenAPI 3 specification of the service of NewServeMux, in JSON.
+// The schemas of the values are the schemas of xlpp.JSONSchema, plus the schemas of the markers of decoded messages.
+func OpenAPI() []byte {
+	message := rebase(xlpp.JSONSchema()).(map[string]interface{})
+	schemas := message["$defs"].(map[string]interface{})
+	props := message["patternProperties"].(map[string]interface{})
+	for _, m := range markerSchemas {
+		schemas[m.name] = m.schema
+		props["^"+m.name+strconv.Itoa(m.channel)+"$"] = map[string]interface{}{"$ref": "#/components/schemas/" + m.name}
+	}
+	delete(message, "$schema")
+	delete(message, "$defs")
+	message["description"] = "XLPP values with the type name and channel as key, e.g. {\"temperature5\":23.5}, see xlpp.MarshalJSON."
+	schemas["Message"] = message
+
+	payload := map[string]interface{}{
+		ContentType:       map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+		ContentTypeBase64: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "byte"}},
+		ContentTypeJSON:   map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Message"}},
+	}
+	operation := func(id, summary string) map[string]interface{} {
+		return map[string]interface{}{
+			"operationId": id,
+			"summary":     summary,
+			"requestBody": map[string]interface{}{"required": true, "content": payload},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{"description": "The message.", "content": payload},
+				"400": map[string]interface{}{"description": "Invalid payload."},
+				"413": map[string]interface{}{"description": "Payload too large."},
+				"415": map[string]interface{}{"description": "Unsupported content type."},
+			},
+		}
+	}
+	spec := map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   "XLPP codec",
+			"version": "1.0.0",
+		},
+		"paths": map[string]interface{}{
+			"/decode": map[string]interface{}{"post": operation("decode", "Decode a XLPP payload, to JSON by default.")},
+			"/encode": map[string]interface{}{"post": operation("encode", "Encode a message, to a binary XLPP payload by default.")},
+		},
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+	data, _ := json.MarshalIndent(spec, "", "  ")
+	return data
+}
+
+// markerSchemas are the schemas of the markers, that xlpp.JSONSchema does not describe as they can not be encoded from JSON.
+var markerSchemas = []struct {
+	name    string
+	channel int
+	schema  map[string]interface{}
+}{
+	{"delay", xlpp.ChanDelay, map[string]interface{}{"type": "integer", "minimum": 0, "description": "[ns]"}},
+	{"millidelay", xlpp.ChanMilliDelay, map[string]interface{}{"type": "integer", "minimum": 0, "description": "[ns]"}},
+	{"priority", xlpp.ChanPriority, map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 255}},
+	{"actuators", xlpp.ChanActuators, map[string]interface{}{"type": "string", "contentEncoding": "base64", "description": "the XLPP types of the actuators"}},
+	{"actuatorswithchannel", xlpp.ChanActuatorsWithChannel, map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"Channel": map[string]interface{}{"type": "integer"},
+				"Type":    map[string]interface{}{"type": "integer"},
+				"channel": map[string]interface{}{"type": "integer"},
+				"type":    map[string]interface{}{"type": "integer"},
+			},
+		},
+	}},
+	{"actuatorack", xlpp.ChanActuatorAck, map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 4294967295}},
+}
+
+// rebase returns the JSON Schema v with its "#/$defs/" references moved to "#/components/schemas/".
+func rebase(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if ref, ok := item.(string); ok && key == "$ref" {
+				item = strings.Replace(ref, "#/$defs/", "#/components/schemas/", 1)
+			}
+			m[key] = rebase(item)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, item := range v {
+			a[i] = rebase(item)
+		}
+		return a
+	}
+	return v
+}
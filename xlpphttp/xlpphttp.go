@@ -0,0 +1,177 @@
+// Package xlpphttp decodes XLPP request bodies and encodes XLPP responses for net/http services.
+//
+// Three content types are supported: binary payloads (application/xlpp), base64 payloads (application/xlpp+base64)
+// and the JSON format of messages (application/json), e.g. {"temperature5":23.5}, see xlpp.MarshalJSON.
+package xlpphttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/waziup/xlpp"
+)
+
+// The supported content types.
+const (
+	ContentType       = "application/xlpp"
+	ContentTypeBase64 = "application/xlpp+base64"
+	ContentTypeJSON   = "application/json"
+)
+
+// MaxBodySize is the max. size of request bodies read by DecodeRequest.
+var MaxBodySize int64 = 1 << 20
+
+// An Error is an error of DecodeRequest, with the HTTP status code that should be returned to the client.
+type Error struct {
+	Status int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// DecodeRequest decodes the body of the request, according to its Content-Type.
+// Requests without Content-Type are decoded as binary payloads.
+// Errors are of type *Error, with status 415 for unsupported content types, 413 for too large bodies and 400 for invalid payloads.
+func DecodeRequest(r *http.Request) (xlpp.Message, error) {
+	ct := ContentType
+	if h := r.Header.Get("Content-Type"); h != "" {
+		var err error
+		if ct, _, err = mime.ParseMediaType(h); err != nil {
+			return nil, &Error{http.StatusUnsupportedMediaType, err}
+		}
+	}
+	switch ct {
+	case ContentType, ContentTypeBase64, ContentTypeJSON, "application/octet-stream", "text/plain":
+	default:
+		return nil, &Error{http.StatusUnsupportedMediaType, fmt.Errorf("xlpphttp: unsupported content type %q", ct)}
+	}
+	// read one byte more than allowed, to tell bodies of exactly MaxBodySize bytes from larger ones
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	if err != nil {
+		return nil, &Error{http.StatusBadRequest, err}
+	}
+	if int64(len(body)) > MaxBodySize {
+		return nil, &Error{http.StatusRequestEntityTooLarge, fmt.Errorf("xlpphttp: request body larger than %d bytes", MaxBodySize)}
+	}
+	var m xlpp.Message
+	switch ct {
+	case ContentTypeJSON:
+		m, err = xlpp.UnmarshalJSON(body)
+	case ContentTypeBase64, "text/plain":
+		body, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(body)))
+		if err == nil {
+			m, err = xlpp.NewBytesReader(body).ReadMessage()
+		}
+	default:
+		m, err = xlpp.NewBytesReader(body).ReadMessage()
+	}
+	if err != nil {
+		return nil, &Error{http.StatusBadRequest, err}
+	}
+	return m, nil
+}
+
+// Negotiate returns the content type of the response to the request, from its Accept header.
+// It returns ContentType if the client accepts any type.
+func Negotiate(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		t, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch t {
+		case ContentType, ContentTypeBase64, ContentTypeJSON:
+			return t
+		case "application/octet-stream":
+			return ContentType
+		case "text/plain":
+			return ContentTypeBase64
+		}
+	}
+	return ContentType
+}
+
+// EncodeResponse writes the message to the response, in the content type set in the response header (see Negotiate),
+// or as binary payload if no content type has been set.
+func EncodeResponse(w http.ResponseWriter, m xlpp.Message) error {
+	ct := w.Header().Get("Content-Type")
+	if ct == "" {
+		ct = ContentType
+		w.Header().Set("Content-Type", ct)
+	}
+	data, err := encode(ct, m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// encode returns the message in the content type ct.
+func encode(ct string, m xlpp.Message) (data []byte, err error) {
+	switch ct, _, _ = mime.ParseMediaType(ct); ct {
+	case ContentTypeJSON:
+		data, err = xlpp.MarshalJSON(m)
+	case ContentType, ContentTypeBase64, "application/octet-stream", "text/plain":
+		data, err = xlpp.ReferenceCodec.Encode(m)
+		if err == nil && ct != ContentType && ct != "application/octet-stream" {
+			data = []byte(base64.StdEncoding.EncodeToString(data))
+		}
+	default:
+		err = fmt.Errorf("xlpphttp: unsupported content type %q", ct)
+	}
+	return data, err
+}
+
+// WriteError writes the error as plain text, with the status of an *Error or 500 Internal Server Error.
+func WriteError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var e *Error
+	if errors.As(err, &e) {
+		status = e.Status
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// Handler returns a http.Handler that decodes the request body, calls fn with the message,
+// and encodes the returned message in the content type negotiated with the client.
+// If fn returns a nil message, the response has status 204 No Content.
+// Messages that can not be encoded are answered with WriteError.
+func Handler(fn func(r *http.Request, m xlpp.Message) (xlpp.Message, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m, err := DecodeRequest(r)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		if m, err = fn(r, m); err != nil {
+			WriteError(w, err)
+			return
+		}
+		if m == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		ct := Negotiate(r)
+		data, err := encode(ct, m)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", ct)
+		w.Write(data)
+	})
+}
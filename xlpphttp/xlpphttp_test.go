@@ -0,0 +1,122 @@
+package xlpphttp_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/xlpphttp"
+)
+
+func TestHandler(t *testing.T) {
+	h := xlpphttp.Handler(func(r *http.Request, m xlpp.Message) (xlpp.Message, error) {
+		return m, nil
+	})
+	payload := []byte{3, 103, 0, 235} // temperature 23.5 on channel 3
+
+	tests := []struct {
+		contentType, accept, body string
+		status                    int
+		respType, resp            string
+	}{
+		{xlpphttp.ContentType, "", string(payload), 200, xlpphttp.ContentType, string(payload)},
+		{xlpphttp.ContentTypeBase64, "application/json", base64.StdEncoding.EncodeToString(payload), 200, xlpphttp.ContentTypeJSON, `{"temperature3":23.5}`},
+		{"application/json; charset=utf-8", "text/plain", `{"temperature3":23.5}`, 200, xlpphttp.ContentTypeBase64, "A2cA6w=="},
+		{"application/xml", "", "<x/>", 415, "", ""},
+		{xlpphttp.ContentType, "", string(payload[:3]), 400, "", ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		r.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Fatalf("%s: status %d: %s", test.contentType, w.Code, w.Body)
+		}
+		if test.status != 200 {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != test.respType || w.Body.String() != test.resp {
+			t.Fatalf("%s: response %s %q", test.contentType, ct, w.Body)
+		}
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h := xlpphttp.Handler(func(r *http.Request, m xlpp.Message) (xlpp.Message, error) {
+		return xlpp.Message{{Channel: 1, Value: &xlpp.GPS2D{Latitude: 91}}}, nil
+	})
+	r := httptest.NewRequest("POST", "/", strings.NewReader("A2cA6w=="))
+	r.Header.Set("Content-Type", xlpphttp.ContentTypeBase64)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "out of range") {
+		t.Fatalf("encode error: %d %s", w.Code, w.Body)
+	}
+
+	defer func(size int64) { xlpphttp.MaxBodySize = size }(xlpphttp.MaxBodySize)
+	xlpphttp.MaxBodySize = 8
+	for _, test := range []struct {
+		body   string
+		status int
+	}{
+		{"A2cA6w==", 200},
+		{"A2cA6w==\n", 413},
+	} {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", xlpphttp.ContentTypeBase64)
+		if _, err := xlpphttp.DecodeRequest(r); (err == nil) != (test.status == 200) {
+			t.Fatalf("%q: %v", test.body, err)
+		} else if e, ok := err.(*xlpphttp.Error); ok && e.Status != test.status {
+			t.Fatalf("%q: status %d", test.body, e.Status)
+		}
+	}
+}
+
+func TestServeMux(t *testing.T) {
+	mux := xlpphttp.NewServeMux()
+	r := httptest.NewRequest("POST", "/decode", strings.NewReader("A2cA6w=="))
+	r.Header.Set("Content-Type", xlpphttp.ContentTypeBase64)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != 200 || w.Body.String() != `{"temperature3":23.5}` {
+		t.Fatalf("decode: %d %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	var spec struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI != "3.1.0" || spec.Components.Schemas["temperature"]["type"] != "number" || spec.Components.Schemas["gps"]["type"] != "object" {
+		t.Fatalf("spec: %+v", spec)
+	}
+	gps := spec.Components.Schemas["gps"]["properties"].(map[string]interface{})
+	if gps["latitude"] == nil || gps["Latitude"] == nil {
+		t.Fatalf("gps: %v", gps)
+	}
+	props := spec.Components.Schemas["Message"]["patternProperties"].(map[string]interface{})
+	if props["^delay253$"] == nil || spec.Components.Schemas["delay"] == nil || spec.Components.Schemas["item"] == nil {
+		t.Fatalf("markers: %v", props)
+	}
+	if strings.Contains(w.Body.String(), "$defs") {
+		t.Fatal("spec refers to $defs")
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/encode", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /encode: %d", w.Code)
+	}
+}
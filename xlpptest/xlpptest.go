@@ -0,0 +1,63 @@
+// Package xlpptest verifies XLPP implementations against the golden vectors of package xlpp.
+//
+// It is used in tests only, so that importing package xlpp does not link package testing into programs.
+package xlpptest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/waziup/xlpp"
+)
+
+// Conformance verifies the codec against the xlpp.GoldenVectors.
+// For every vector, the decoded payload must match the vector JSON, and encoding the decoded message must reproduce the payload byte-for-byte.
+func Conformance(t *testing.T, codec xlpp.Codec) {
+	t.Helper()
+	for _, v := range xlpp.GoldenVectors {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			payload, err := hex.DecodeString(v.Payload)
+			if err != nil {
+				t.Fatalf("bad golden payload: %v", err)
+			}
+			m, err := codec.Decode(payload)
+			if err != nil {
+				t.Fatalf("can not decode %s: %v", v.Payload, err)
+			}
+			data, err := xlpp.MessageJSON(m)
+			if err != nil {
+				t.Fatalf("can not marshal decoded message: %v", err)
+			}
+			if !jsonEqual(data, []byte(v.JSON)) {
+				t.Errorf("decode %s:\n got: %s\nwant: %s", v.Payload, data, v.JSON)
+			}
+			if v.Canonical {
+				// encode the reference message, so that encoder errors are not hidden by decoder errors
+				ref, err := xlpp.ReferenceCodec.Decode(payload)
+				if err != nil {
+					t.Fatalf("reference codec can not decode %s: %v", v.Payload, err)
+				}
+				data, err := codec.Encode(ref)
+				if err != nil {
+					t.Fatalf("can not encode: %v", err)
+				}
+				if !bytes.Equal(data, payload) {
+					t.Errorf("encode:\n got: %x\nwant: %s", data, v.Payload)
+				}
+			}
+		})
+	}
+}
+
+// jsonEqual reports whether a and b hold the same JSON data.
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
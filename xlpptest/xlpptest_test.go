@@ -0,0 +1,12 @@
+package xlpptest_test
+
+import (
+	"testing"
+
+	"github.com/waziup/xlpp"
+	"github.com/waziup/xlpp/xlpptest"
+)
+
+func TestConformance(t *testing.T) {
+	xlpptest.Conformance(t, xlpp.ReferenceCodec)
+}